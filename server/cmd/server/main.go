@@ -2,21 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"maps"
 	"os"
-	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dreadster3/yapper/server/internal/admin"
 	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/events"
 	"github.com/dreadster3/yapper/server/internal/messages"
-	"github.com/dreadster3/yapper/server/internal/platform/database"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/devauth"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/oidc"
 	"github.com/dreadster3/yapper/server/internal/platform/providers"
 	"github.com/dreadster3/yapper/server/internal/platform/router"
 	"github.com/dreadster3/yapper/server/internal/platform/router/middleware"
 	"github.com/dreadster3/yapper/server/internal/profiles"
-	"github.com/dreadster3/yapper/server/internal/steps"
+	"github.com/dreadster3/yapper/server/internal/purges"
+	"github.com/dreadster3/yapper/server/internal/secrets"
+	"github.com/dreadster3/yapper/server/internal/shares"
+	"github.com/dreadster3/yapper/server/internal/storage"
+	"github.com/dreadster3/yapper/server/internal/tokens"
+	"github.com/dreadster3/yapper/server/internal/workspaces"
 	"github.com/gin-gonic/gin/binding"
 	en_locale "github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
@@ -29,10 +40,51 @@ import (
 var (
 	port   int
 	jwkUrl string
+
+	jwtIssuer     string
+	jwtAudience   string
+	jwtLeeway     time.Duration
+	jwtAlgorithms string
+	oidcIssuer    string
+	oidcUserInfo  bool
+	jwksRefresh   time.Duration
+
 	dbHost string
 	dbPort int
 	dbUser string
 	dbPass string
+
+	eventBus string
+
+	openAIKey     string
+	anthropicKey  string
+	masterKey     string
+	masterKeyFile string
+
+	rolesClaim  string
+	adminGroups string
+
+	allowedOrigins string
+
+	workspaceProviderHosts string
+
+	devAuth     bool
+	devAuthAddr string
+
+	provisionProfiles bool
+
+	migrateOnStart bool
+
+	providerChains   string
+	providerPolicies string
+	ollamaUrls       string
+	ollamaBalancing  string
+
+	checkpointTokens   int
+	checkpointInterval time.Duration
+
+	storageBackend string
+	databaseUrl    string
 )
 
 func GetEnvDefault(key string, defaultValue string) string {
@@ -44,7 +96,7 @@ func GetEnvDefault(key string, defaultValue string) string {
 
 func GetEnvIntDefault(key string, defaultValue int) int {
 	if val, ok := os.LookupEnv(key); ok {
-		if intVal, err := strconv.Atoi(val); err != nil {
+		if intVal, err := strconv.Atoi(val); err == nil {
 			return intVal
 		}
 	}
@@ -52,13 +104,73 @@ func GetEnvIntDefault(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func GetEnvDurationDefault(key string, defaultValue time.Duration) time.Duration {
+	if val, ok := os.LookupEnv(key); ok {
+		if duration, err := time.ParseDuration(val); err == nil {
+			return duration
+		}
+	}
+
+	return defaultValue
+}
+
+func GetEnvBoolDefault(key string, defaultValue bool) bool {
+	if val, ok := os.LookupEnv(key); ok {
+		if boolVal, err := strconv.ParseBool(val); err == nil {
+			return boolVal
+		}
+	}
+
+	return defaultValue
+}
+
+// splitList splits a comma separated flag, ignoring empty items
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
 func initFlags() {
 	flag.IntVar(&port, "port", GetEnvIntDefault("PORT", 8000), "Port to listen on")
 	flag.StringVar(&jwkUrl, "jwk-url", os.Getenv("JWK_URL"), "The URL to the JWKS endpoint")
-	flag.StringVar(&dbHost, "db-host", GetEnvDefault("DB_HOST", "mongo"), "The hostname of the database")
-	flag.IntVar(&dbPort, "db-port", GetEnvIntDefault("DB_PORT", 27017), "The port of the database")
-	flag.StringVar(&dbUser, "db-user", os.Getenv("DB_USER"), "The username of the database")
-	flag.StringVar(&dbPass, "db-pass", os.Getenv("DB_PASS"), "The password of the database")
+	flag.StringVar(&oidcIssuer, "oidc-issuer", os.Getenv("OIDC_ISSUER"), "The OIDC issuer URL, the JWKS URL and issuer are discovered from it")
+	flag.BoolVar(&oidcUserInfo, "oidc-userinfo", GetEnvBoolDefault("OIDC_USERINFO", false), "Name the profiles created without a name after the user, looked up on the userinfo endpoint of the OIDC issuer")
+	flag.DurationVar(&jwksRefresh, "jwks-refresh-interval", GetEnvDurationDefault("JWKS_REFRESH_INTERVAL", time.Hour), "How often the JWKS is refreshed to pick up rotated keys")
+	flag.StringVar(&jwtIssuer, "jwt-issuer", os.Getenv("JWT_ISSUER"), "The expected issuer (iss) of the tokens, not checked when empty")
+	flag.StringVar(&jwtAudience, "jwt-audience", os.Getenv("JWT_AUDIENCE"), "The audience (aud) the tokens must contain, not checked when empty")
+	flag.DurationVar(&jwtLeeway, "jwt-leeway", GetEnvDurationDefault("JWT_LEEWAY", 30*time.Second), "The clock skew tolerated when validating the token times")
+	flag.StringVar(&jwtAlgorithms, "jwt-algorithms", GetEnvDefault("JWT_ALGORITHMS", strings.Join(middleware.DefaultAlgorithms, ",")), "Comma separated signing algorithms accepted")
+	flag.StringVar(&storageBackend, "storage", GetEnvDefault("STORAGE", storage.BackendMongo), "The storage backend (mongo, postgres or sqlite)")
+	flag.StringVar(&databaseUrl, "database-url", os.Getenv("DATABASE_URL"), "The connection URL of the SQL storage backends, the database file for sqlite")
+	flag.StringVar(&dbHost, "db-host", GetEnvDefault("DB_HOST", "mongo"), "The hostname of the Mongo database")
+	flag.IntVar(&dbPort, "db-port", GetEnvIntDefault("DB_PORT", 27017), "The port of the Mongo database")
+	flag.StringVar(&dbUser, "db-user", os.Getenv("DB_USER"), "The username of the Mongo database")
+	flag.StringVar(&dbPass, "db-pass", os.Getenv("DB_PASS"), "The password of the Mongo database")
+	flag.StringVar(&eventBus, "event-bus", GetEnvDefault("EVENT_BUS", events.BackendMemory), "The event bus backend (memory or mongo). Mongo requires a replica set")
+	flag.StringVar(&openAIKey, "openai-api-key", os.Getenv("OPENAI_API_KEY"), "The server default OpenAI API key")
+	flag.StringVar(&anthropicKey, "anthropic-api-key", os.Getenv("ANTHROPIC_API_KEY"), "The server default Anthropic API key")
+	flag.StringVar(&ollamaUrls, "ollama-urls", GetEnvDefault("OLLAMA_URLS", "http://localhost:11434"), "Comma separated URLs of the ollama instances, the requests are load balanced when there are several")
+	flag.StringVar(&ollamaBalancing, "ollama-balancing", GetEnvDefault("OLLAMA_BALANCING", providers.BalancingRoundRobin), "How requests are spread over the ollama instances (round-robin, least-in-flight or model-aware)")
+	flag.StringVar(&providerPolicies, "provider-policies", os.Getenv("PROVIDER_POLICIES"), "Retry, timeout and circuit breaker options per provider, e.g. ollama=retries:2,backoff:500ms,timeout:10m,idle-timeout:2m,breaker-failures:5,breaker-cooldown:30s;openai=...")
+	flag.StringVar(&providerChains, "provider-chains", os.Getenv("PROVIDER_CHAINS"), "Logical providers failing over a list of providers, e.g. local=ollama:llama3.1:8b,openai:gpt-4o-mini;other=...")
+	flag.StringVar(&masterKey, "master-key", os.Getenv("MASTER_KEY"), "The base64 encoded 32 bytes key encrypting profile secrets")
+	flag.StringVar(&masterKeyFile, "master-key-file", os.Getenv("MASTER_KEY_FILE"), "The file containing the master key, used when --master-key is not set")
+	flag.StringVar(&rolesClaim, "roles-claim", GetEnvDefault("ROLES_CLAIM", auth.DefaultRolesClaim), "The token claim holding the groups of the user, nested claims are separated by dots")
+	flag.StringVar(&adminGroups, "admin-groups", os.Getenv("ADMIN_GROUPS"), "Comma separated groups granting the admin role")
+	flag.StringVar(&allowedOrigins, "allowed-origins", os.Getenv("ALLOWED_ORIGINS"), "Comma separated origins allowed to open WebSockets besides the server's own, e.g. https://chat.example.com")
+	flag.StringVar(&workspaceProviderHosts, "workspace-provider-hosts", os.Getenv("WORKSPACE_PROVIDER_HOSTS"), "Comma separated hosts the workspace providers can reach, e.g. api.openai.com,*.example.com. Any public host when empty, private addresses are always refused")
+	flag.BoolVar(&devAuth, "dev-auth", GetEnvBoolDefault("DEV_AUTH", false), "Issue tokens locally instead of using the identity provider. Development only, refused in release mode or with an identity provider configured")
+	flag.StringVar(&devAuthAddr, "dev-auth-addr", GetEnvDefault("DEV_AUTH_ADDR", "127.0.0.1:8001"), "The address serving the development JWKS and token endpoints")
+	flag.BoolVar(&provisionProfiles, "auto-provision-profiles", GetEnvBoolDefault("AUTO_PROVISION_PROFILES", false), "Create a profile from the token claims on the first request of a user")
+	flag.BoolVar(&migrateOnStart, "migrate-on-start", GetEnvBoolDefault("MIGRATE_ON_START", true), "Apply the pending database migrations on startup")
+	flag.IntVar(&checkpointTokens, "checkpoint-tokens", GetEnvIntDefault("CHECKPOINT_TOKENS", messages.DefaultCheckpointPolicy.Tokens), "How many streamed tokens are received before the partial response is stored, 0 disables it")
+	flag.DurationVar(&checkpointInterval, "checkpoint-interval", GetEnvDurationDefault("CHECKPOINT_INTERVAL", messages.DefaultCheckpointPolicy.Interval), "How often the partial response is stored while streaming, 0 disables it")
 	flag.Parse()
 }
 
@@ -77,29 +189,224 @@ func _main() error {
 
 	ctx := context.Background()
 
-	db, closeDatabase, err := database.ConnectDatabase(ctx, dbHost, dbPort, dbUser, dbPass)
+	var cipher encryption.Cipher
+	key, err := encryption.LoadMasterKey(masterKey, masterKeyFile)
+	switch {
+	case errors.Is(err, encryption.ErrNoMasterKey):
+		logger.Warn("No master key configured, API keys cannot be stored")
+		cipher = encryption.NewDisabledCipher()
+	case err != nil:
+		return err
+	default:
+		if cipher, err = encryption.NewEnvelopeCipher(key); err != nil {
+			return err
+		}
+	}
+
+	store, err := storage.Open(ctx, storage.Config{
+		Backend:  storageBackend,
+		Host:     dbHost,
+		Port:     dbPort,
+		User:     dbUser,
+		Password: dbPass,
+		URL:      databaseUrl,
+		Cipher:   cipher,
+	}, logger)
 	if err != nil {
 		return err
 	}
-	defer closeDatabase(ctx)
+	defer store.Close(ctx)
 
-	profileRepository := profiles.NewProfileRepository(db, logger.With(zap.String("repository", "profile")))
-	profileHandler := profiles.NewProfileHandler(profileRepository)
+	if flag.NArg() > 0 {
+		return runCommand(ctx, store.Migrations, flag.Args())
+	}
 
-	registeredProviders, err := providers.SetupProviders("http://localhost:11434", logger)
+	if migrateOnStart {
+		if _, err := store.Migrations.Up(ctx); err != nil {
+			return err
+		}
+	}
+
+	if devAuth {
+		if os.Getenv("GIN_MODE") == "release" {
+			return fmt.Errorf("development authentication cannot be enabled in release mode")
+		}
+		// The tokens of the identity provider would be rejected, fail instead of
+		// silently replacing it
+		if oidcIssuer != "" || jwkUrl != "" || jwtIssuer != "" {
+			return fmt.Errorf("development authentication cannot be enabled with an identity provider configured (oidc-issuer, jwk-url or jwt-issuer)")
+		}
+
+		issuer, err := devauth.NewIssuer(devAuthAddr, logger.With(zap.String("component", "devauth")))
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := issuer.Run(ctx); err != nil {
+				logger.Error("Development issuer stopped", zap.Error(err))
+			}
+		}()
+
+		jwkUrl = issuer.JWKSUrl()
+		jwtIssuer = issuer.Issuer()
+		if jwtAudience == "" {
+			jwtAudience = devauth.Audience
+		}
+	}
+
+	var nameResolver profiles.DisplayNameResolver
+	if oidcIssuer != "" {
+		oidcConfig, err := oidc.Discover(ctx, oidcIssuer)
+		if err != nil {
+			return err
+		}
+
+		if jwkUrl == "" {
+			jwkUrl = oidcConfig.JWKSURI
+		}
+		if jwtIssuer == "" {
+			jwtIssuer = oidcConfig.Issuer
+		}
+
+		if oidcUserInfo {
+			userInfoClient, err := oidc.NewUserInfoClient(oidcConfig)
+			if err != nil {
+				return err
+			}
+			nameResolver = userInfoClient
+		}
+	} else if oidcUserInfo {
+		return fmt.Errorf("the userinfo lookup requires an OIDC issuer (oidc-issuer)")
+	}
+
+	profileRepository := store.Profiles
+
+	ollamaInstances := splitList(ollamaUrls)
+	if len(ollamaInstances) == 0 {
+		return errors.New("at least one ollama URL is required")
+	}
+
+	providerConfigs := map[string]providers.Config{
+		providers.TypeOllama:    {Type: providers.TypeOllama, URL: ollamaInstances[0]},
+		providers.TypeOpenAI:    {Type: providers.TypeOpenAI, URL: providers.DefaultOpenAIURL, APIKey: openAIKey},
+		providers.TypeAnthropic: {Type: providers.TypeAnthropic, URL: providers.DefaultAnthropicURL, APIKey: anthropicKey},
+	}
+	policies, err := providers.ParsePolicies(providerPolicies)
+	if err != nil {
+		return err
+	}
+	registeredProviders, err := providers.SetupProviders(providerConfigs, policies, logger)
 	if err != nil {
 		return err
 	}
 
-	chatRepository := chats.NewChatRepository(db, logger.With(zap.String("repository", "chat")))
-	chatHandler := chats.NewChatHandler(registeredProviders, chatRepository)
+	if len(ollamaInstances) > 1 {
+		pool, err := providers.NewOllamaPool(ollamaInstances, "", ollamaBalancing, logger.With(zap.String("provider", providers.TypeOllama)))
+		if err != nil {
+			return err
+		}
+
+		registeredProviders.RegisterPool(ctx, providers.TypeOllama, providerConfigs[providers.TypeOllama], pool)
+	}
 
-	stepsRepository := steps.NewStepRepository(db, logger.With(zap.String("repository", "step")))
-	messageRepository := messages.NewMessageRepository(db, logger.With(zap.String("repository", "message")))
-	messageHandler := messages.NewMessageHandler(messageRepository, chatRepository, stepsRepository, registeredProviders)
+	providerConfigRepository := store.ProviderConfigs
+	if err := admin.LoadProviders(ctx, providerConfigRepository, cipher, registeredProviders, logger); err != nil {
+		return err
+	}
+
+	chains, err := providers.ParseChains(providerChains)
+	if err != nil {
+		return err
+	}
+	for name, targets := range chains {
+		if err := registeredProviders.RegisterChain(name, targets); err != nil {
+			return err
+		}
+	}
+
+	var bus events.Bus
+	switch eventBus {
+	case events.BackendMemory:
+		bus = events.NewMemoryBus(logger.With(zap.String("bus", events.BackendMemory)))
+	case events.BackendMongo:
+		if store.Mongo == nil {
+			return fmt.Errorf("the %s event bus requires the %s storage backend", events.BackendMongo, storage.BackendMongo)
+		}
+
+		bus, err = events.NewMongoBus(ctx, store.Mongo, logger.With(zap.String("bus", events.BackendMongo)))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %s", events.ErrUnknownBackend, eventBus)
+	}
+	eventHandler := events.NewEventHandler(bus)
+
+	chatRepository := store.Chats
+	stepsRepository := store.Steps
+	messageRepository := store.Messages
+	memberRepository := store.Members
+	workspaceRepository := store.Workspaces
+	workspaceAccess := workspaces.NewChatAccess(workspaceRepository)
+	chatAuthorizer := chats.NewAuthorizer(chatRepository, memberRepository, workspaceAccess)
+
+	shareRepository := store.Shares
+	shareHandler := shares.NewShareHandler(shareRepository, chatRepository, chatAuthorizer, messageRepository, stepsRepository)
+
+	chatContentDeleter := chats.NewContentDeleters(
+		shareRepository,
+		memberRepository,
+		messages.NewChatContentDeleter(messageRepository, stepsRepository),
+	)
+
+	secretRepository := store.Secrets
+	secretHandler := secrets.NewSecretHandler(secretRepository, cipher)
+
+	tokenRepository := store.Tokens
+	tokenHandler := tokens.NewTokenHandler(tokenRepository)
+	tokenAuthenticator := tokens.NewAuthenticator(tokenRepository, logger.With(zap.String("authenticator", "token")))
+
+	purgeJobRepository := store.PurgeJobs
+	purger := purges.NewPurger(purgeJobRepository, profileRepository, chatRepository, memberRepository, chatContentDeleter, workspaceRepository, shareRepository, secretRepository, tokenRepository, logger.With(zap.String("worker", "purger")))
+	go purger.Run(ctx)
+
+	reconciler := messages.NewReconciler(messageRepository, stepsRepository, store.UnitOfWork, logger.With(zap.String("worker", "reconciler")))
+	go reconciler.Run(ctx)
+
+	profileHandler := profiles.NewProfileHandler(profileRepository, purger, nameResolver)
+	chatHandler := chats.NewChatHandler(registeredProviders, chatRepository, chatAuthorizer, workspaceAccess, chatContentDeleter, bus)
+	memberHandler := chats.NewMemberHandler(memberRepository, profileRepository, chatAuthorizer)
+	egress := providers.Egress{AllowedHosts: splitList(workspaceProviderHosts)}
+	var providerResolver providers.Resolver
+	providerResolver = providers.NewRegistryResolver(registeredProviders)
+	providerResolver = secrets.NewProviderResolver(secretRepository, cipher, registeredProviders, providerResolver, logger)
+	providerResolver = workspaces.NewProviderResolver(workspaceRepository, cipher, egress, providerResolver, logger)
+	providerResolver = providers.NewChainResolver(registeredProviders, providerResolver, logger)
+	messageHandler := messages.NewMessageHandler(messageRepository, chatAuthorizer, stepsRepository, store.UnitOfWork, providerResolver, bus, messages.CheckpointPolicy{
+		Tokens:   checkpointTokens,
+		Interval: checkpointInterval,
+	}, splitList(allowedOrigins))
+	workspaceHandler := workspaces.NewWorkspaceHandler(workspaceRepository, profileRepository, chatRepository, chatContentDeleter, egress, cipher)
+
+	userRepository := store.Users
+	adminHandler := admin.NewAdminHandler(profileRepository, messageRepository, userRepository, providerConfigRepository, registeredProviders, cipher)
+
+	roleMapper := auth.RoleMapper{
+		Claim: rolesClaim,
+		Roles: map[string][]auth.Role{},
+	}
+	for _, group := range splitList(adminGroups) {
+		roleMapper.Roles[group] = append(roleMapper.Roles[group], auth.RoleAdmin)
+	}
 
 	jwtConfig := &middleware.JWTConfig{
-		JWKSUrl: jwkUrl,
+		JWKSUrl:    jwkUrl,
+		Issuer:     jwtIssuer,
+		Audience:   jwtAudience,
+		Leeway:     jwtLeeway,
+		Algorithms: splitList(jwtAlgorithms),
+
+		RefreshInterval: jwksRefresh,
 	}
 	locale := en_locale.New()
 	translator := ut.New(locale, locale)
@@ -108,7 +415,7 @@ func _main() error {
 		return fmt.Errorf("translator for 'en' not found")
 	}
 
-	engine, err := router.SetupRouter(en_translator, jwtConfig, profileRepository, chatHandler, profileHandler, messageHandler)
+	engine, err := router.SetupRouter(en_translator, logger, jwtConfig, tokenAuthenticator, roleMapper, userRepository, profileRepository, provisionProfiles, chatHandler, profileHandler, memberHandler, messageHandler, eventHandler, shareHandler, workspaceHandler, secretHandler, adminHandler, tokenHandler)
 	if err != nil {
 		return err
 	}
@@ -120,11 +427,11 @@ func _main() error {
 			return err
 		}
 
-		validProviders := slices.Collect(maps.Keys(registeredProviders))
+		// Providers can change at runtime, the valid options are listed on every error
 		v.RegisterTranslation("registered_provider", en_translator, func(ut ut.Translator) error {
-			return ut.Add("registered_provider", fmt.Sprintf("{0} is not a registered provider. Valid options are: %s", validProviders), true)
+			return ut.Add("registered_provider", "{0} is not a registered provider. Valid options are: {1}", true)
 		}, func(ut ut.Translator, fe validator.FieldError) string {
-			t, _ := ut.T("registered_provider", fe.Field())
+			t, _ := ut.T("registered_provider", fe.Field(), fmt.Sprint(registeredProviders.Names()))
 			return t
 		})
 	}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/migrations"
+)
+
+// runCommand runs the subcommand given after the flags, e.g. `server migrate up`
+func runCommand(ctx context.Context, runner *migrations.Runner, args []string) error {
+	if args[0] != "migrate" {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: migrate up|status")
+	}
+
+	switch args[1] {
+	case "up":
+		applied, err := runner.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("Applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return err
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up or status", args[1])
+	}
+}
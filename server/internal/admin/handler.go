@@ -0,0 +1,282 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/messages"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/users"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultUsagePeriod = 30 * 24 * time.Hour
+
+type AdminHandler interface {
+	ListProfiles(c *gin.Context)
+	Usage(c *gin.Context)
+	ListUsers(c *gin.Context)
+	DisableUser(c *gin.Context)
+	EnableUser(c *gin.Context)
+	ListProviders(c *gin.Context)
+	SaveProvider(c *gin.Context)
+	RemoveProvider(c *gin.Context)
+	ListBackends(c *gin.Context)
+}
+
+var ErrProviderNotPooled = errors.New("provider is not load balanced")
+
+type adminHandler struct {
+	profileRepository        profiles.ProfileRepository
+	messageRepository        messages.MessageRepository
+	userRepository           users.UserRepository
+	providerConfigRepository ProviderConfigRepository
+	registry                 *providers.Registry
+	cipher                   encryption.Cipher
+}
+
+func NewAdminHandler(profileRepository profiles.ProfileRepository, messageRepository messages.MessageRepository, userRepository users.UserRepository, providerConfigRepository ProviderConfigRepository, registry *providers.Registry, cipher encryption.Cipher) AdminHandler {
+	return &adminHandler{
+		profileRepository:        profileRepository,
+		messageRepository:        messageRepository,
+		userRepository:           userRepository,
+		providerConfigRepository: providerConfigRepository,
+		registry:                 registry,
+		cipher:                   cipher,
+	}
+}
+
+func (h *adminHandler) ListProfiles(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	allProfiles, err := h.profileRepository.GetAll(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.Map(allProfiles, func(p *profiles.Profile) Profile {
+		return Profile{Profile: p, UserId: p.UserId}
+	}))
+}
+
+func (h *adminHandler) Usage(c *gin.Context) {
+	var query struct {
+		Since time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if query.Since.IsZero() {
+		query.Since = time.Now().Add(-defaultUsagePeriod)
+	}
+
+	ctx := c.Request.Context()
+	usage, err := h.messageRepository.Usage(ctx, query.Since)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"since": query.Since,
+		"usage": usage,
+	})
+}
+
+func (h *adminHandler) ListUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	allUsers, err := h.userRepository.GetAll(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, allUsers)
+}
+
+type userUri struct {
+	UserId auth.UserId `uri:"user_id" binding:"required"`
+}
+
+func (h *adminHandler) DisableUser(c *gin.Context) {
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.Error(err)
+		return
+	}
+
+	h.setDisabled(c, true, request.Reason)
+}
+
+func (h *adminHandler) EnableUser(c *gin.Context) {
+	h.setDisabled(c, false, "")
+}
+
+func (h *adminHandler) setDisabled(c *gin.Context, disabled bool, reason string) {
+	var uri userUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user := &users.User{
+		Id:       uri.UserId,
+		Disabled: disabled,
+		Reason:   reason,
+	}
+
+	ctx := c.Request.Context()
+	if err := h.userRepository.Save(ctx, user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *adminHandler) ListProviders(c *gin.Context) {
+	names := h.registry.Names()
+
+	configs := make([]*ProviderConfig, 0, len(names))
+	for _, name := range names {
+		if config, ok := h.registry.Config(name); ok {
+			configs = append(configs, fromConfig(name, config))
+		} else if targets, ok := h.registry.Chain(name); ok {
+			configs = append(configs, fromChain(name, targets))
+		}
+	}
+
+	c.JSON(http.StatusOK, configs)
+}
+
+type providerUri struct {
+	Name string `uri:"provider" binding:"required"`
+}
+
+func (h *adminHandler) SaveProvider(c *gin.Context) {
+	var config *ProviderConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var uri providerUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+	config.Name = uri.Name
+
+	if _, ok := h.registry.Chain(config.Name); ok {
+		c.Status(http.StatusConflict)
+		c.Error(fmt.Errorf("%w: %s is already a chain", providers.ErrNameInUse, config.Name))
+		return
+	}
+	if _, ok := h.registry.Pool(config.Name); ok {
+		c.Status(http.StatusConflict)
+		c.Error(fmt.Errorf("%w: %s", providers.ErrProviderPooled, config.Name))
+		return
+	}
+
+	// Keep the current key unless a new one is provided
+	if config.APIKey == "" {
+		if current, ok := h.registry.Config(config.Name); ok {
+			config.APIKey = current.APIKey
+		}
+	}
+
+	if config.APIKey != "" {
+		envelope, err := h.cipher.Seal([]byte(config.APIKey), associatedData(config.Name))
+		if err != nil {
+			if errors.Is(err, encryption.ErrNoMasterKey) {
+				c.Status(http.StatusServiceUnavailable)
+			}
+
+			c.Error(err)
+			return
+		}
+		config.Envelope = envelope
+	}
+
+	ctx := c.Request.Context()
+	if err := h.providerConfigRepository.Save(ctx, config); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.registry.Register(config.Name, config.Config()); err != nil {
+		if errors.Is(err, providers.ErrNameInUse) || errors.Is(err, providers.ErrProviderPooled) {
+			c.Status(http.StatusConflict)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, fromConfig(config.Name, config.Config()))
+}
+
+// RemoveProvider unregisters the provider. Providers configured on startup
+// are registered again on the next start.
+func (h *adminHandler) RemoveProvider(c *gin.Context) {
+	var uri providerUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if _, ok := h.registry.Get(uri.Name); !ok {
+		c.Status(http.StatusNotFound)
+		c.Error(ErrProviderConfigNotFound)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.providerConfigRepository.Delete(ctx, uri.Name); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.registry.Remove(uri.Name)
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListBackends returns the metrics of the instances of a load balanced
+// provider
+func (h *adminHandler) ListBackends(c *gin.Context) {
+	var uri providerUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if _, ok := h.registry.Get(uri.Name); !ok {
+		c.Status(http.StatusNotFound)
+		c.Error(ErrProviderConfigNotFound)
+		return
+	}
+
+	pool, ok := h.registry.Pool(uri.Name)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		c.Error(ErrProviderNotPooled)
+		return
+	}
+
+	c.JSON(http.StatusOK, pool.Stats())
+}
@@ -0,0 +1,116 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/admin"
+	"github.com/dreadster3/yapper/server/internal/messages"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/platform/router/middleware"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/tokens"
+	"github.com/dreadster3/yapper/server/internal/users"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const adminUserId = "admin"
+
+// adminAuthenticator stands in for the identity provider, every token it
+// accepts belongs to a member of the admins group
+type adminAuthenticator struct{}
+
+func (adminAuthenticator) Accepts(token string) bool {
+	return !strings.HasPrefix(token, tokens.Prefix)
+}
+
+func (adminAuthenticator) Authenticate(c *gin.Context, token string) error {
+	c.Set(auth.UserIdContextKey, adminUserId)
+	c.Set(auth.UserClaimsContextKey, jwt.MapClaims{"sub": adminUserId, "groups": []any{"admins"}})
+	return nil
+}
+
+func TestAdminRoutesRejectPersonalAccessTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := sqldbtest.Open(t)
+	logger := zap.NewNop()
+	tokenRepository := tokens.NewSQLTokenRepository(db, logger)
+	adminHandler := admin.NewAdminHandler(
+		profiles.NewSQLProfileRepository(db, logger),
+		messages.NewSQLMessageRepository(db, logger),
+		users.NewSQLUserRepository(db, logger),
+		admin.NewSQLProviderConfigRepository(db, logger),
+		providers.NewRegistry(logger),
+		encryption.NewDisabledCipher(),
+	)
+
+	// Tokens are minted by the admin for their own profile
+	tokenEngine := gin.New()
+	tokenEngine.Use(middleware.ErrorMiddleware(nil, logger))
+	tokenEngine.POST("/tokens", func(c *gin.Context) {
+		c.Set(profiles.ProfileContextKey, &profiles.Profile{Id: "profile", UserId: adminUserId})
+	}, tokens.NewTokenHandler(tokenRepository).Create)
+
+	mint := func(scopes string) string {
+		t.Helper()
+
+		recorder := httptest.NewRecorder()
+		tokenEngine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/tokens", strings.NewReader(`{"name":"cli","scopes":`+scopes+`}`)))
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("Create() status = %d (%s), want %d", recorder.Code, recorder.Body, http.StatusCreated)
+		}
+
+		var token tokens.Token
+		if err := json.Unmarshal(recorder.Body.Bytes(), &token); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		return token.Value
+	}
+
+	mapper := auth.RoleMapper{Claim: auth.DefaultRolesClaim, Roles: map[string][]auth.Role{"admins": {auth.RoleAdmin}}}
+	engine := gin.New()
+	engine.Use(middleware.ErrorMiddleware(nil, logger))
+	adminRoutes := engine.Group("/admin",
+		middleware.AuthenticationMiddleware(logger, tokens.NewAuthenticator(tokenRepository, logger), adminAuthenticator{}),
+		middleware.RolesMiddleware(mapper),
+		middleware.RequireRole(auth.RoleAdmin),
+	)
+	adminRoutes.GET("/users", adminHandler.ListUsers)
+	adminRoutes.POST("/users/:user_id/disable", adminHandler.DisableUser)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{name: "identity provider token", method: http.MethodGet, path: "/admin/users", token: "jwt", want: http.StatusOK},
+		{name: "read token", method: http.MethodGet, path: "/admin/users", token: mint(`["read"]`), want: http.StatusForbidden},
+		{name: "write token", method: http.MethodGet, path: "/admin/users", token: mint(`["write"]`), want: http.StatusForbidden},
+		{name: "write token disabling a user", method: http.MethodPost, path: "/admin/users/other/disable", token: mint(`["read","write"]`), want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, nil)
+			request.Header.Set("Authorization", "Bearer "+tt.token)
+
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d (%s), want %d", recorder.Code, recorder.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.uber.org/zap/zapcore"
+)
+
+// Profile exposes the owner of the profile, hidden from the regular API
+type Profile struct {
+	*profiles.Profile
+	UserId auth.UserId `json:"user_id"`
+}
+
+// ProviderConfig is a server default provider. The API key is write only and
+// stored encrypted.
+type ProviderConfig struct {
+	Name          string               `json:"name" binding:"-"`
+	Type          string               `json:"type" binding:"required,oneof=ollama openai anthropic"`
+	URL           string               `json:"url" binding:"omitempty,url"`
+	APIKey        string               `json:"api_key,omitempty" binding:"-"`
+	HasAPIKey     bool                 `json:"has_api_key" binding:"-"`
+	AllowedModels []string             `json:"allowed_models" binding:"-"`
+	Targets       []string             `json:"targets,omitempty" binding:"-"`
+	Envelope      *encryption.Envelope `json:"-" binding:"-"`
+}
+
+func (p ProviderConfig) Config() providers.Config {
+	return providers.Config{
+		Type:          p.Type,
+		URL:           p.URL,
+		APIKey:        p.APIKey,
+		AllowedModels: p.AllowedModels,
+	}
+}
+
+// associatedData binds the envelope to the name of the provider
+func associatedData(name string) []byte {
+	return encryption.AssociatedData("provider_config", name)
+}
+
+func fromConfig(name string, config providers.Config) *ProviderConfig {
+	return &ProviderConfig{
+		Name:          name,
+		Type:          config.Type,
+		URL:           config.URL,
+		HasAPIKey:     config.APIKey != "",
+		AllowedModels: config.AllowedModels,
+	}
+}
+
+// fromChain lists a chain with the providers, the targets are read only
+func fromChain(name string, targets []providers.Target) *ProviderConfig {
+	return &ProviderConfig{
+		Name: name,
+		Type: providers.TypeChain,
+		Targets: utils.Map(targets, func(target providers.Target) string {
+			return target.String()
+		}),
+	}
+}
+
+func (p ProviderConfig) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("name", p.Name)
+	encoder.AddString("type", p.Type)
+	encoder.AddString("url", p.URL)
+	encoder.AddBool("has_api_key", p.Envelope != nil)
+	return nil
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"go.uber.org/zap"
+)
+
+// LoadProviders registers the provider configurations saved through the admin
+// API, replacing the startup ones of the same name. The load balanced
+// providers are kept, their instances are configured on startup.
+func LoadProviders(ctx context.Context, repository ProviderConfigRepository, cipher encryption.Cipher, registry *providers.Registry, logger *zap.Logger) error {
+	configs, err := repository.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("admin.LoadProviders: %w", err)
+	}
+
+	for _, config := range configs {
+		if _, ok := registry.Pool(config.Name); ok {
+			logger.Warn("Ignoring the saved configuration of a load balanced provider", zap.String("provider", config.Name))
+			continue
+		}
+
+		if config.Envelope != nil {
+			apiKey, err := cipher.Open(config.Envelope, associatedData(config.Name))
+			if err != nil {
+				return fmt.Errorf("admin.LoadProviders: %s: %w", config.Name, err)
+			}
+			config.APIKey = string(apiKey)
+		}
+
+		if err := registry.Register(config.Name, config.Config()); err != nil {
+			return fmt.Errorf("admin.LoadProviders: %s: %w", config.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package admin
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var ErrProviderConfigNotFound = errors.New("provider configuration not found")
+
+// ProviderConfigRepository stores the provider configurations made through
+// the admin API, they take precedence over the ones given on startup
+type ProviderConfigRepository interface {
+	GetAll(ctx context.Context) ([]*ProviderConfig, error)
+	Save(ctx context.Context, config *ProviderConfig) error
+	Delete(ctx context.Context, name string) error
+}
+
+const collectionName = "provider_configs"
+
+type providerConfig struct {
+	Name          string   `bson:"_id"`
+	Type          string   `bson:"type"`
+	URL           string   `bson:"url"`
+	AllowedModels []string `bson:"allowed_models"`
+	KeyId         string   `bson:"key_id,omitempty"`
+	WrappedKey    []byte   `bson:"wrapped_key,omitempty"`
+	Ciphertext    []byte   `bson:"ciphertext,omitempty"`
+}
+
+func (p providerConfig) ToModel() *ProviderConfig {
+	var envelope *encryption.Envelope
+	if p.Ciphertext != nil {
+		envelope = &encryption.Envelope{
+			KeyId:      p.KeyId,
+			WrappedKey: p.WrappedKey,
+			Ciphertext: p.Ciphertext,
+		}
+	}
+
+	return &ProviderConfig{
+		Name:          p.Name,
+		Type:          p.Type,
+		URL:           p.URL,
+		HasAPIKey:     envelope != nil,
+		AllowedModels: p.AllowedModels,
+		Envelope:      envelope,
+	}
+}
+
+func fromModel(p *ProviderConfig) *providerConfig {
+	entity := &providerConfig{
+		Name:          p.Name,
+		Type:          p.Type,
+		URL:           p.URL,
+		AllowedModels: p.AllowedModels,
+	}
+
+	if p.Envelope != nil {
+		entity.KeyId = p.Envelope.KeyId
+		entity.WrappedKey = p.Envelope.WrappedKey
+		entity.Ciphertext = p.Envelope.Ciphertext
+	}
+
+	return entity
+}
+
+type providerConfigRepository struct {
+	db *mongo.Database
+}
+
+func NewProviderConfigRepository(db *mongo.Database, logger *zap.Logger) ProviderConfigRepository {
+	var repo ProviderConfigRepository
+	repo = &providerConfigRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *providerConfigRepository) Collection() *mongo.Collection {
+	return r.db.Collection(collectionName)
+}
+
+func (r *providerConfigRepository) GetAll(ctx context.Context) ([]*ProviderConfig, error) {
+	cursor, err := r.Collection().Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []providerConfig
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(p providerConfig) *ProviderConfig {
+		return p.ToModel()
+	}), nil
+}
+
+func (r *providerConfigRepository) Save(ctx context.Context, config *ProviderConfig) error {
+	entity := fromModel(config)
+	if _, err := r.Collection().ReplaceOne(ctx, bson.M{"_id": entity.Name}, entity, options.Replace().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *providerConfigRepository) Delete(ctx context.Context, name string) error {
+	if _, err := r.Collection().DeleteOne(ctx, bson.M{"_id": name}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type repositoryMiddleware func(ProviderConfigRepository) ProviderConfigRepository
+
+type loggingMiddleware struct {
+	logger *zap.Logger
+	next   ProviderConfigRepository
+}
+
+func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
+	return func(next ProviderConfigRepository) ProviderConfigRepository {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *loggingMiddleware) GetAll(ctx context.Context) (configs []*ProviderConfig, err error) {
+	defer func() {
+		m.logger.Debug("GetAll", zap.Objects("configs", configs), zap.Error(err))
+	}()
+
+	return m.next.GetAll(ctx)
+}
+
+func (m *loggingMiddleware) Save(ctx context.Context, config *ProviderConfig) (err error) {
+	defer func() {
+		m.logger.Debug("Save", utils.Object("config", config), zap.Error(err))
+	}()
+
+	return m.next.Save(ctx, config)
+}
+
+func (m *loggingMiddleware) Delete(ctx context.Context, name string) (err error) {
+	defer func() {
+		m.logger.Debug("Delete", zap.String("name", name), zap.Error(err))
+	}()
+
+	return m.next.Delete(ctx, name)
+}
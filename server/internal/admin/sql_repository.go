@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlProviderConfigRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLProviderConfigRepository(db *sqldb.DB, logger *zap.Logger) ProviderConfigRepository {
+	var repo ProviderConfigRepository
+	repo = &sqlProviderConfigRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *sqlProviderConfigRepository) GetAll(ctx context.Context) ([]*ProviderConfig, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT name, type, url, allowed_models, key_id, wrapped_key, ciphertext FROM provider_configs ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*ProviderConfig, error) {
+		var config ProviderConfig
+		var allowedModels string
+		var keyId sql.NullString
+		var wrappedKey, ciphertext []byte
+		if err := rows.Scan(&config.Name, &config.Type, &config.URL, &allowedModels, &keyId, &wrappedKey, &ciphertext); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(allowedModels), &config.AllowedModels); err != nil {
+			return nil, err
+		}
+
+		if ciphertext != nil {
+			config.Envelope = &encryption.Envelope{
+				KeyId:      keyId.String,
+				WrappedKey: wrappedKey,
+				Ciphertext: ciphertext,
+			}
+		}
+		config.HasAPIKey = config.Envelope != nil
+
+		return &config, nil
+	})
+}
+
+func (r *sqlProviderConfigRepository) Save(ctx context.Context, config *ProviderConfig) error {
+	allowedModels, err := json.Marshal(config.AllowedModels)
+	if err != nil {
+		return err
+	}
+
+	var keyId sql.NullString
+	var wrappedKey, ciphertext []byte
+	if config.Envelope != nil {
+		keyId = sql.NullString{String: config.Envelope.KeyId, Valid: true}
+		wrappedKey = config.Envelope.WrappedKey
+		ciphertext = config.Envelope.Ciphertext
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO provider_configs (name, type, url, allowed_models, key_id, wrapped_key, ciphertext) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			type = excluded.type,
+			url = excluded.url,
+			allowed_models = excluded.allowed_models,
+			key_id = excluded.key_id,
+			wrapped_key = excluded.wrapped_key,
+			ciphertext = excluded.ciphertext`,
+		config.Name, config.Type, config.URL, string(allowedModels), keyId, wrappedKey, ciphertext)
+	return err
+}
+
+func (r *sqlProviderConfigRepository) Delete(ctx context.Context, name string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM provider_configs WHERE name = ?", name)
+	return err
+}
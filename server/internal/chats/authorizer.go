@@ -0,0 +1,204 @@
+package chats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/utils"
+)
+
+type Permission int
+
+const (
+	// PermissionRead allows reading the chat and its messages
+	PermissionRead Permission = iota
+	// PermissionWrite allows sending messages
+	PermissionWrite
+	// PermissionManage allows renaming, deleting, sharing and managing members
+	PermissionManage
+)
+
+func (r Role) Allows(permission Permission) bool {
+	switch r {
+	case RoleOwner:
+		return true
+	case RoleEditor:
+		return permission <= PermissionWrite
+	case RoleViewer:
+		return permission <= PermissionRead
+	default:
+		return false
+	}
+}
+
+// WorkspaceAccess resolves the access workspaces grant on the chats they own.
+type WorkspaceAccess interface {
+	// ChatRole returns the role the workspace grants the profile on its chats,
+	// or auth.ErrForbidden if the profile is not a member of the workspace
+	ChatRole(ctx context.Context, workspaceId domain.WorkspaceId, profileId domain.ProfileId) (Role, error)
+	WorkspaceIds(ctx context.Context, profileId domain.ProfileId) ([]domain.WorkspaceId, error)
+	Members(ctx context.Context, workspaceId domain.WorkspaceId) ([]domain.ProfileId, error)
+}
+
+// Authorizer decides what a profile can do on a chat. Every handler touching a
+// chat goes through it instead of comparing profile ids.
+type Authorizer interface {
+	// Authorize returns the chat if the profile has the permission on it,
+	// ErrChatNotFound if it does not exist and auth.ErrForbidden otherwise.
+	Authorize(ctx context.Context, chatId ChatId, profileId domain.ProfileId, permission Permission) (*Chat, error)
+	RoleOf(ctx context.Context, chat *Chat, profileId domain.ProfileId) (Role, error)
+	// Audience returns every profile that can read the chat
+	Audience(ctx context.Context, chat *Chat) ([]domain.ProfileId, error)
+	// Accessible returns every chat the profile can read: its personal chats,
+	// the chats of its workspaces and the chats it is a member of
+	Accessible(ctx context.Context, profileId domain.ProfileId) ([]*Chat, error)
+}
+
+type authorizer struct {
+	chatRepository   ChatRepository
+	memberRepository MemberRepository
+	workspaceAccess  WorkspaceAccess
+}
+
+func NewAuthorizer(chatRepository ChatRepository, memberRepository MemberRepository, workspaceAccess WorkspaceAccess) Authorizer {
+	return &authorizer{
+		chatRepository:   chatRepository,
+		memberRepository: memberRepository,
+		workspaceAccess:  workspaceAccess,
+	}
+}
+
+func (a *authorizer) Authorize(ctx context.Context, chatId ChatId, profileId domain.ProfileId, permission Permission) (*Chat, error) {
+	chat, err := a.chatRepository.FindById(ctx, chatId)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := a.RoleOf(ctx, chat, profileId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !role.Allows(permission) {
+		return nil, fmt.Errorf("chats.Authorize: %w", auth.ErrForbidden)
+	}
+
+	return chat, nil
+}
+
+// RoleOf returns the role of the profile on the chat. The creator of a
+// workspace chat only owns it while it is a member of the workspace.
+func (a *authorizer) RoleOf(ctx context.Context, chat *Chat, profileId domain.ProfileId) (Role, error) {
+	if chat.WorkspaceId == "" && chat.ProfileId == profileId {
+		return RoleOwner, nil
+	}
+
+	if chat.WorkspaceId != "" {
+		role, err := a.workspaceAccess.ChatRole(ctx, chat.WorkspaceId, profileId)
+		if err == nil {
+			if chat.ProfileId == profileId {
+				return RoleOwner, nil
+			}
+
+			return role, nil
+		}
+
+		if !errors.Is(err, auth.ErrForbidden) {
+			return "", err
+		}
+	}
+
+	member, err := a.memberRepository.Find(ctx, chat.Id, profileId)
+	if err != nil {
+		if errors.Is(err, ErrMemberNotFound) {
+			return "", fmt.Errorf("chats.RoleOf: %w", auth.ErrForbidden)
+		}
+
+		return "", err
+	}
+
+	if member.Status != MemberStatusAccepted {
+		return "", fmt.Errorf("chats.RoleOf: %w", auth.ErrForbidden)
+	}
+
+	return member.Role, nil
+}
+
+func (a *authorizer) Audience(ctx context.Context, chat *Chat) ([]domain.ProfileId, error) {
+	members, err := a.memberRepository.GetByChatId(ctx, chat.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	audience := make([]domain.ProfileId, 0, len(members)+1)
+	if chat.WorkspaceId == "" {
+		audience = append(audience, chat.ProfileId)
+	}
+	for _, member := range members {
+		if member.Status == MemberStatusAccepted {
+			audience = append(audience, member.ProfileId)
+		}
+	}
+
+	if chat.WorkspaceId != "" {
+		workspaceMembers, err := a.workspaceAccess.Members(ctx, chat.WorkspaceId)
+		if err != nil {
+			return nil, err
+		}
+		audience = append(audience, workspaceMembers...)
+	}
+
+	slices.Sort(audience)
+	return slices.Compact(audience), nil
+}
+
+func (a *authorizer) Accessible(ctx context.Context, profileId domain.ProfileId) ([]*Chat, error) {
+	owned, err := a.chatRepository.GetByProfileId(ctx, profileId)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceIds, err := a.workspaceAccess.WorkspaceIds(ctx, profileId)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceChats, err := a.chatRepository.GetByWorkspaceIds(ctx, workspaceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := a.memberRepository.GetByProfileId(ctx, profileId, MemberStatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedChats, err := a.chatRepository.GetByIds(ctx, utils.Map(memberships, func(m *Member) ChatId {
+		return m.ChatId
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	// The workspace chats created by the profile are only accessible while it
+	// is a member of the workspace
+	owned = slices.DeleteFunc(owned, func(chat *Chat) bool {
+		return chat.WorkspaceId != "" && !slices.Contains(workspaceIds, chat.WorkspaceId)
+	})
+
+	seen := make(map[ChatId]struct{})
+	result := make([]*Chat, 0)
+	for _, chat := range slices.Concat(owned, workspaceChats, sharedChats) {
+		if _, ok := seen[chat.Id]; ok {
+			continue
+		}
+		seen[chat.Id] = struct{}{}
+		result = append(result, chat)
+	}
+
+	return result, nil
+}
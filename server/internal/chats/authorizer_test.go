@@ -0,0 +1,79 @@
+package chats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"go.uber.org/zap"
+)
+
+// staticWorkspaceAccess grants the roles of the workspace members
+type staticWorkspaceAccess map[domain.WorkspaceId]map[domain.ProfileId]Role
+
+func (a staticWorkspaceAccess) ChatRole(ctx context.Context, workspaceId domain.WorkspaceId, profileId domain.ProfileId) (Role, error) {
+	role, ok := a[workspaceId][profileId]
+	if !ok {
+		return "", fmt.Errorf("ChatRole: %w", auth.ErrForbidden)
+	}
+
+	return role, nil
+}
+
+func (a staticWorkspaceAccess) WorkspaceIds(ctx context.Context, profileId domain.ProfileId) ([]domain.WorkspaceId, error) {
+	var ids []domain.WorkspaceId
+	for id, members := range a {
+		if _, ok := members[profileId]; ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (a staticWorkspaceAccess) Members(ctx context.Context, workspaceId domain.WorkspaceId) ([]domain.ProfileId, error) {
+	var ids []domain.ProfileId
+	for id := range a[workspaceId] {
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func TestAuthorizerWorkspaceCreator(t *testing.T) {
+	ctx := context.Background()
+	db := sqldbtest.Open(t)
+	chatRepository := NewSQLChatRepository(db, zap.NewNop())
+	workspaces := staticWorkspaceAccess{"workspace": {"creator": RoleEditor, "admin": RoleOwner}}
+	authorizer := NewAuthorizer(chatRepository, NewSQLMemberRepository(db, zap.NewNop()), workspaces)
+
+	chat := &Chat{Name: "Plans", ProfileId: "creator", WorkspaceId: "workspace"}
+	if err := chatRepository.Create(ctx, chat); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if role, err := authorizer.RoleOf(ctx, chat, "creator"); err != nil || role != RoleOwner {
+		t.Errorf("RoleOf() the creator = %q, %v, want %q", role, err, RoleOwner)
+	}
+
+	// The creator is removed from the workspace
+	delete(workspaces["workspace"], "creator")
+
+	if _, err := authorizer.RoleOf(ctx, chat, "creator"); !errors.Is(err, auth.ErrForbidden) {
+		t.Errorf("RoleOf() the removed creator error = %v, want %v", err, auth.ErrForbidden)
+	}
+	if chats, err := authorizer.Accessible(ctx, "creator"); err != nil || len(chats) != 0 {
+		t.Errorf("Accessible() the removed creator = %v, %v, want no chat", chats, err)
+	}
+	if audience, err := authorizer.Audience(ctx, chat); err != nil || slices.Contains(audience, "creator") {
+		t.Errorf("Audience() = %v, %v, want the removed creator left out", audience, err)
+	}
+	if role, err := authorizer.RoleOf(ctx, chat, "admin"); err != nil || role != RoleOwner {
+		t.Errorf("RoleOf() the workspace admin = %q, %v, want %q", role, err, RoleOwner)
+	}
+}
@@ -1,24 +1,45 @@
 package chats
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/events"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
 	"github.com/dreadster3/yapper/server/internal/platform/providers"
 	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
 type ChatHandler interface {
 	Create(c *gin.Context)
+	List(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
 }
 
 type chatHandler struct {
-	providers  map[string]providers.Provider
-	repository ChatRepository
+	providers       *providers.Registry
+	repository      ChatRepository
+	authorizer      Authorizer
+	workspaceAccess WorkspaceAccess
+	contentDeleter  ContentDeleter
+	bus             events.Bus
 }
 
-func NewChatHandler(providers map[string]providers.Provider, chatRepository ChatRepository) ChatHandler {
-	return &chatHandler{providers: providers, repository: chatRepository}
+func NewChatHandler(providers *providers.Registry, chatRepository ChatRepository, authorizer Authorizer, workspaceAccess WorkspaceAccess, contentDeleter ContentDeleter, bus events.Bus) ChatHandler {
+	return &chatHandler{
+		providers:       providers,
+		repository:      chatRepository,
+		authorizer:      authorizer,
+		workspaceAccess: workspaceAccess,
+		contentDeleter:  contentDeleter,
+		bus:             bus,
+	}
 }
 
 func (ch *chatHandler) Create(c *gin.Context) {
@@ -32,10 +53,143 @@ func (ch *chatHandler) Create(c *gin.Context) {
 	chat.ProfileId = profile.Id
 
 	ctx := c.Request.Context()
+	if chat.WorkspaceId != "" {
+		role, err := ch.workspaceAccess.ChatRole(ctx, chat.WorkspaceId, profile.Id)
+		if err == nil && !role.Allows(PermissionWrite) {
+			err = fmt.Errorf("chatHandler.Create: %w", auth.ErrForbidden)
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
 	if err := ch.repository.Create(ctx, chat); err != nil {
 		c.Error(err)
 		return
 	}
 
+	PublishChatEvent(ctx, ch.bus, ch.authorizer, events.EventChatCreated, chat, chat)
+
 	c.JSON(http.StatusCreated, chat)
 }
+
+func (ch *chatHandler) List(c *gin.Context) {
+	var query struct {
+		WorkspaceId domain.WorkspaceId `form:"workspace_id"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		return
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	chats, err := ch.authorizer.Accessible(ctx, profile.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if query.WorkspaceId != "" {
+		chats = utils.Filter(chats, func(chat *Chat) bool {
+			return chat.WorkspaceId == query.WorkspaceId
+		})
+	}
+
+	c.JSON(http.StatusOK, chats)
+}
+
+func (ch *chatHandler) Update(c *gin.Context) {
+	var request struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	chat, ok := AuthorizeChat(c, ch.authorizer, PermissionManage)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	chat.Name = request.Name
+	if err := ch.repository.Update(ctx, chat); err != nil {
+		c.Error(err)
+		return
+	}
+
+	PublishChatEvent(ctx, ch.bus, ch.authorizer, events.EventChatRenamed, chat, chat)
+
+	c.JSON(http.StatusOK, chat)
+}
+
+func (ch *chatHandler) Delete(c *gin.Context) {
+	chat, ok := AuthorizeChat(c, ch.authorizer, PermissionManage)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Resolved before the memberships are removed with the chat content
+	audience := audienceOf(ctx, ch.authorizer, chat)
+
+	if err := ch.contentDeleter.DeleteByChatId(ctx, chat.Id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := ch.repository.Delete(ctx, chat.Id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	events.PublishAll(ctx, ch.bus, events.EventChatDeleted, audience, gin.H{"id": chat.Id})
+
+	c.Status(http.StatusNoContent)
+}
+
+// PublishChatEvent publishes the event to every profile that can read the chat.
+func PublishChatEvent(ctx context.Context, bus events.Bus, authorizer Authorizer, eventType events.EventType, chat *Chat, data any) error {
+	return events.PublishAll(ctx, bus, eventType, audienceOf(ctx, authorizer, chat), data)
+}
+
+func audienceOf(ctx context.Context, authorizer Authorizer, chat *Chat) []domain.ProfileId {
+	audience, err := authorizer.Audience(ctx, chat)
+	if err != nil {
+		return []domain.ProfileId{chat.ProfileId}
+	}
+
+	return audience
+}
+
+// AuthorizeChat loads the chat from the chat_id route segment and checks that
+// the current profile has the permission on it, aborting the request with the
+// proper error otherwise.
+func AuthorizeChat(c *gin.Context, authorizer Authorizer, permission Permission) (*Chat, bool) {
+	var uri struct {
+		ChatId ChatId `uri:"chat_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return nil, false
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	chat, err := authorizer.Authorize(c.Request.Context(), uri.ChatId, profile.Id, permission)
+	if err != nil {
+		if errors.Is(err, ErrChatNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return nil, false
+	}
+
+	return chat, true
+}
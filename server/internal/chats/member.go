@@ -0,0 +1,44 @@
+package chats
+
+import (
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap/zapcore"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+type MemberStatus string
+
+const (
+	MemberStatusInvited  MemberStatus = "invited"
+	MemberStatusAccepted MemberStatus = "accepted"
+)
+
+// Member grants a profile access to a chat it does not own. The profile that
+// created the chat is always its owner and has no member record, members can
+// only be editors or viewers.
+type Member struct {
+	ChatId    ChatId           `json:"chat_id" binding:"-"`
+	ProfileId domain.ProfileId `json:"profile_id" binding:"required"`
+	Role      Role             `json:"role" binding:"required,oneof=editor viewer"`
+	Status    MemberStatus     `json:"status" binding:"-"`
+	InvitedBy domain.ProfileId `json:"invited_by" binding:"-"`
+	CreatedAt time.Time        `json:"created_at" binding:"-"`
+}
+
+func (m Member) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("chat_id", string(m.ChatId))
+	encoder.AddString("profile_id", string(m.ProfileId))
+	encoder.AddString("role", string(m.Role))
+	encoder.AddString("status", string(m.Status))
+	encoder.AddString("invited_by", string(m.InvitedBy))
+	return nil
+}
@@ -0,0 +1,253 @@
+package chats
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrCannotInviteOwner = errors.New("the chat owner cannot be invited")
+
+type MemberHandler interface {
+	Invite(c *gin.Context)
+	List(c *gin.Context)
+	Update(c *gin.Context)
+	Remove(c *gin.Context)
+	ListInvitations(c *gin.Context)
+	AcceptInvitation(c *gin.Context)
+	DeclineInvitation(c *gin.Context)
+}
+
+type memberHandler struct {
+	repository        MemberRepository
+	profileRepository profiles.ProfileRepository
+	authorizer        Authorizer
+}
+
+func NewMemberHandler(repository MemberRepository, profileRepository profiles.ProfileRepository, authorizer Authorizer) MemberHandler {
+	return &memberHandler{
+		repository:        repository,
+		profileRepository: profileRepository,
+		authorizer:        authorizer,
+	}
+}
+
+type memberUri struct {
+	ChatId    ChatId           `uri:"chat_id" binding:"required"`
+	ProfileId domain.ProfileId `uri:"member_id" binding:"required"`
+}
+
+func (h *memberHandler) Invite(c *gin.Context) {
+	var member *Member
+	if err := c.ShouldBindJSON(&member); err != nil {
+		c.Error(err)
+		return
+	}
+
+	chat, ok := AuthorizeChat(c, h.authorizer, PermissionManage)
+	if !ok {
+		return
+	}
+
+	if member.ProfileId == chat.ProfileId {
+		c.Status(http.StatusBadRequest)
+		c.Error(ErrCannotInviteOwner)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.profileRepository.FindById(ctx, member.ProfileId); err != nil {
+		if errors.Is(err, profiles.ErrProfileNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	member.ChatId = chat.Id
+	member.Status = MemberStatusInvited
+	member.InvitedBy = profiles.GetProfileFromContext(c).Id
+
+	// Re-inviting an existing member only changes their role
+	if existing, err := h.repository.Find(ctx, chat.Id, member.ProfileId); err == nil {
+		member.Status = existing.Status
+		member.CreatedAt = existing.CreatedAt
+	}
+
+	if err := h.repository.Save(ctx, member); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+func (h *memberHandler) List(c *gin.Context) {
+	chat, ok := AuthorizeChat(c, h.authorizer, PermissionRead)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	members, err := h.repository.GetByChatId(ctx, chat.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func (h *memberHandler) Update(c *gin.Context) {
+	var request struct {
+		Role Role `json:"role" binding:"required,oneof=editor viewer"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var uri memberUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	chat, ok := AuthorizeChat(c, h.authorizer, PermissionManage)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	member, ok := h.findMember(c, chat.Id, uri.ProfileId)
+	if !ok {
+		return
+	}
+
+	member.Role = request.Role
+	if err := h.repository.Save(ctx, member); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// Remove revokes a membership. Members can always remove themselves.
+func (h *memberHandler) Remove(c *gin.Context) {
+	var uri memberUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	permission := PermissionManage
+	if uri.ProfileId == profiles.GetProfileFromContext(c).Id {
+		permission = PermissionRead
+	}
+
+	chat, ok := AuthorizeChat(c, h.authorizer, permission)
+	if !ok {
+		return
+	}
+
+	if _, ok := h.findMember(c, chat.Id, uri.ProfileId); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.repository.Delete(ctx, chat.Id, uri.ProfileId); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *memberHandler) ListInvitations(c *gin.Context) {
+	profile := profiles.GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	invitations, err := h.repository.GetByProfileId(ctx, profile.Id, MemberStatusInvited)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func (h *memberHandler) AcceptInvitation(c *gin.Context) {
+	invitation, ok := h.findInvitation(c)
+	if !ok {
+		return
+	}
+
+	invitation.Status = MemberStatusAccepted
+
+	ctx := c.Request.Context()
+	if err := h.repository.Save(ctx, invitation); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitation)
+}
+
+func (h *memberHandler) DeclineInvitation(c *gin.Context) {
+	invitation, ok := h.findInvitation(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.repository.Delete(ctx, invitation.ChatId, invitation.ProfileId); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *memberHandler) findInvitation(c *gin.Context) (*Member, bool) {
+	var uri struct {
+		ChatId ChatId `uri:"chat_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return nil, false
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	invitation, ok := h.findMember(c, uri.ChatId, profile.Id)
+	if !ok {
+		return nil, false
+	}
+
+	if invitation.Status != MemberStatusInvited {
+		c.Status(http.StatusNotFound)
+		c.Error(fmt.Errorf("memberHandler.findInvitation: %w", ErrMemberNotFound))
+		return nil, false
+	}
+
+	return invitation, true
+}
+
+func (h *memberHandler) findMember(c *gin.Context, chatId ChatId, profileId domain.ProfileId) (*Member, bool) {
+	member, err := h.repository.Find(c.Request.Context(), chatId, profileId)
+	if err != nil {
+		if errors.Is(err, ErrMemberNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return nil, false
+	}
+
+	return member, true
+}
@@ -0,0 +1,284 @@
+package chats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var ErrMemberNotFound = errors.New("member not found")
+
+type MemberRepository interface {
+	Find(ctx context.Context, chatId ChatId, profileId domain.ProfileId) (*Member, error)
+	GetByChatId(ctx context.Context, chatId ChatId) ([]*Member, error)
+	GetByProfileId(ctx context.Context, profileId domain.ProfileId, status MemberStatus) ([]*Member, error)
+	// Save creates the membership or replaces the existing one
+	Save(ctx context.Context, member *Member) error
+	Delete(ctx context.Context, chatId ChatId, profileId domain.ProfileId) error
+	DeleteByChatId(ctx context.Context, chatId ChatId) error
+	DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error
+}
+
+const membersCollectionName = "chat_members"
+
+type member struct {
+	ChatId    primitive.ObjectID `bson:"chat_id"`
+	ProfileId primitive.ObjectID `bson:"profile_id"`
+	Role      string             `bson:"role"`
+	Status    string             `bson:"status"`
+	InvitedBy primitive.ObjectID `bson:"invited_by"`
+	CreatedAt primitive.DateTime `bson:"created_at"`
+}
+
+func (m member) ToModel() *Member {
+	return &Member{
+		ChatId:    ChatId(m.ChatId.Hex()),
+		ProfileId: domain.ProfileId(m.ProfileId.Hex()),
+		Role:      Role(m.Role),
+		Status:    MemberStatus(m.Status),
+		InvitedBy: domain.ProfileId(m.InvitedBy.Hex()),
+		CreatedAt: m.CreatedAt.Time(),
+	}
+}
+
+func memberFromModel(m *Member) (*member, error) {
+	chatId, err := primitive.ObjectIDFromHex(string(m.ChatId))
+	if err != nil {
+		return nil, err
+	}
+
+	profileId, err := primitive.ObjectIDFromHex(string(m.ProfileId))
+	if err != nil {
+		return nil, err
+	}
+
+	invitedBy, err := primitive.ObjectIDFromHex(string(m.InvitedBy))
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := m.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return &member{
+		ChatId:    chatId,
+		ProfileId: profileId,
+		Role:      string(m.Role),
+		Status:    string(m.Status),
+		InvitedBy: invitedBy,
+		CreatedAt: primitive.NewDateTimeFromTime(createdAt),
+	}, nil
+}
+
+type memberRepository struct {
+	db *mongo.Database
+}
+
+func NewMemberRepository(db *mongo.Database, logger *zap.Logger) MemberRepository {
+	var repo MemberRepository
+	repo = &memberRepository{db: db}
+	repo = NewMemberLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *memberRepository) Collection() *mongo.Collection {
+	return r.db.Collection(membersCollectionName)
+}
+
+func (r *memberRepository) find(ctx context.Context, filter bson.M) ([]*Member, error) {
+	cursor, err := r.Collection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []member
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(m member) *Member {
+		return m.ToModel()
+	}), nil
+}
+
+func (r *memberRepository) Find(ctx context.Context, chatId ChatId, profileId domain.ProfileId) (*Member, error) {
+	chatObjId, err := primitive.ObjectIDFromHex(string(chatId))
+	if err != nil {
+		return nil, ErrMemberNotFound
+	}
+
+	profileObjId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return nil, ErrMemberNotFound
+	}
+
+	var entity member
+	if err := r.Collection().FindOne(ctx, bson.M{"chat_id": chatObjId, "profile_id": profileObjId}).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMemberNotFound
+		}
+
+		return nil, err
+	}
+
+	return entity.ToModel(), nil
+}
+
+func (r *memberRepository) GetByChatId(ctx context.Context, chatId ChatId) ([]*Member, error) {
+	objId, err := primitive.ObjectIDFromHex(string(chatId))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.find(ctx, bson.M{"chat_id": objId})
+}
+
+func (r *memberRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId, status MemberStatus) ([]*Member, error) {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.find(ctx, bson.M{"profile_id": objId, "status": status})
+}
+
+func (r *memberRepository) Save(ctx context.Context, member *Member) error {
+	entity, err := memberFromModel(member)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"chat_id": entity.ChatId, "profile_id": entity.ProfileId}
+	if _, err := r.Collection().ReplaceOne(ctx, filter, entity, options.Replace().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	member.CreatedAt = entity.CreatedAt.Time()
+	return nil
+}
+
+func (r *memberRepository) Delete(ctx context.Context, chatId ChatId, profileId domain.ProfileId) error {
+	chatObjId, err := primitive.ObjectIDFromHex(string(chatId))
+	if err != nil {
+		return err
+	}
+
+	profileObjId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteOne(ctx, bson.M{"chat_id": chatObjId, "profile_id": profileObjId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *memberRepository) DeleteByChatId(ctx context.Context, chatId ChatId) error {
+	objId, err := primitive.ObjectIDFromHex(string(chatId))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteMany(ctx, bson.M{"chat_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *memberRepository) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteMany(ctx, bson.M{"profile_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type memberRepositoryMiddleware func(MemberRepository) MemberRepository
+
+type memberLoggingMiddleware struct {
+	logger *zap.Logger
+	next   MemberRepository
+}
+
+func NewMemberLoggingMiddleware(logger *zap.Logger) memberRepositoryMiddleware {
+	return func(next MemberRepository) MemberRepository {
+		return &memberLoggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *memberLoggingMiddleware) Find(ctx context.Context, chatId ChatId, profileId domain.ProfileId) (member *Member, err error) {
+	defer func() {
+		m.logger.Debug("Find", zap.String("chat_id", string(chatId)), zap.String("profile_id", string(profileId)), utils.Object("member", member), zap.Error(err))
+	}()
+
+	return m.next.Find(ctx, chatId, profileId)
+}
+
+func (m *memberLoggingMiddleware) GetByChatId(ctx context.Context, chatId ChatId) (members []*Member, err error) {
+	defer func() {
+		m.logger.Debug("GetByChatId", zap.String("chat_id", string(chatId)), zap.Objects("members", members), zap.Error(err))
+	}()
+
+	return m.next.GetByChatId(ctx, chatId)
+}
+
+func (m *memberLoggingMiddleware) GetByProfileId(ctx context.Context, profileId domain.ProfileId, status MemberStatus) (members []*Member, err error) {
+	defer func() {
+		m.logger.Debug("GetByProfileId", zap.String("profile_id", string(profileId)), zap.String("status", string(status)), zap.Objects("members", members), zap.Error(err))
+	}()
+
+	return m.next.GetByProfileId(ctx, profileId, status)
+}
+
+func (m *memberLoggingMiddleware) Save(ctx context.Context, member *Member) (err error) {
+	defer func() {
+		m.logger.Debug("Save", utils.Object("member", member), zap.Error(err))
+	}()
+
+	return m.next.Save(ctx, member)
+}
+
+func (m *memberLoggingMiddleware) Delete(ctx context.Context, chatId ChatId, profileId domain.ProfileId) (err error) {
+	defer func() {
+		m.logger.Debug("Delete", zap.String("chat_id", string(chatId)), zap.String("profile_id", string(profileId)), zap.Error(err))
+	}()
+
+	return m.next.Delete(ctx, chatId, profileId)
+}
+
+func (m *memberLoggingMiddleware) DeleteByChatId(ctx context.Context, chatId ChatId) (err error) {
+	defer func() {
+		m.logger.Debug("DeleteByChatId", zap.String("chat_id", string(chatId)), zap.Error(err))
+	}()
+
+	return m.next.DeleteByChatId(ctx, chatId)
+}
+
+func (m *memberLoggingMiddleware) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) (err error) {
+	defer func() {
+		m.logger.Debug("DeleteByProfileId", zap.String("profile_id", string(profileId)), zap.Error(err))
+	}()
+
+	return m.next.DeleteByProfileId(ctx, profileId)
+}
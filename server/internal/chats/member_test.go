@@ -0,0 +1,27 @@
+package chats
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestMemberRoleValidation(t *testing.T) {
+	tests := []struct {
+		role    Role
+		wantErr bool
+	}{
+		{RoleEditor, false},
+		{RoleViewer, false},
+		// Owners cannot be invited, they could remove the creator of the chat
+		{RoleOwner, true},
+		{"admin", true},
+	}
+
+	for _, tt := range tests {
+		member := Member{ProfileId: "profile", Role: tt.role}
+		if err := binding.Validator.ValidateStruct(member); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateStruct() with role %q error = %v, want error %v", tt.role, err, tt.wantErr)
+		}
+	}
+}
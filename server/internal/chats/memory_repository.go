@@ -0,0 +1,101 @@
+package chats
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap"
+)
+
+type memoryChatRepository struct {
+	mutex sync.RWMutex
+	chats map[ChatId]Chat
+}
+
+// NewMemoryChatRepository returns a repository keeping the chats in this
+// process, they are lost on restart.
+func NewMemoryChatRepository(logger *zap.Logger) ChatRepository {
+	var repo ChatRepository
+	repo = &memoryChatRepository{chats: make(map[ChatId]Chat)}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *memoryChatRepository) Create(ctx context.Context, chat *Chat) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if chat.Id == "" {
+		chat.Id = ChatId(domain.NewId())
+	}
+
+	r.chats[chat.Id] = *chat
+	return nil
+}
+
+func (r *memoryChatRepository) FindById(ctx context.Context, id ChatId) (*Chat, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	chat, ok := r.chats[id]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+
+	return &chat, nil
+}
+
+func (r *memoryChatRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Chat, error) {
+	return r.find(func(chat Chat) bool {
+		return chat.ProfileId == profileId
+	}), nil
+}
+
+func (r *memoryChatRepository) GetByWorkspaceIds(ctx context.Context, workspaceIds []domain.WorkspaceId) ([]*Chat, error) {
+	return r.find(func(chat Chat) bool {
+		return chat.WorkspaceId != "" && slices.Contains(workspaceIds, chat.WorkspaceId)
+	}), nil
+}
+
+func (r *memoryChatRepository) GetByIds(ctx context.Context, ids []ChatId) ([]*Chat, error) {
+	return r.find(func(chat Chat) bool {
+		return slices.Contains(ids, chat.Id)
+	}), nil
+}
+
+func (r *memoryChatRepository) find(match func(Chat) bool) []*Chat {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := []*Chat{}
+	for _, id := range slices.Sorted(maps.Keys(r.chats)) {
+		if chat := r.chats[id]; match(chat) {
+			result = append(result, &chat)
+		}
+	}
+
+	return result
+}
+
+func (r *memoryChatRepository) Update(ctx context.Context, chat *Chat) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.chats[chat.Id]; !ok {
+		return ErrChatNotFound
+	}
+
+	r.chats[chat.Id] = *chat
+	return nil
+}
+
+func (r *memoryChatRepository) Delete(ctx context.Context, id ChatId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.chats, id)
+	return nil
+}
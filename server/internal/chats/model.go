@@ -20,14 +20,16 @@ type ChatMessage struct {
 type ChatId string
 
 type Chat struct {
-	Id        ChatId           `json:"id" binding:"-"`
-	ProfileId domain.ProfileId `json:"-" binding:"-"`
-	Name      string           `json:"name" binding:"required"`
+	Id          ChatId             `json:"id" binding:"-"`
+	ProfileId   domain.ProfileId   `json:"-" binding:"-"`
+	WorkspaceId domain.WorkspaceId `json:"workspace_id,omitempty" binding:"-"`
+	Name        string             `json:"name" binding:"required"`
 }
 
 func (c Chat) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	encoder.AddString("id", string(c.Id))
 	encoder.AddString("profile_id", string(c.ProfileId))
+	encoder.AddString("workspace_id", string(c.WorkspaceId))
 	encoder.AddString("name", c.Name)
 	return nil
 }
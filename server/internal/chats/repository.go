@@ -5,15 +5,47 @@ import (
 	"errors"
 
 	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/database"
+	"github.com/dreadster3/yapper/server/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
+var ErrChatNotFound = errors.New("chat not found")
+
 type ChatRepository interface {
 	Create(ctx context.Context, chat *Chat) error
 	FindById(ctx context.Context, id ChatId) (*Chat, error)
+	// GetByProfileId returns the chats created by the profile, in any workspace
+	GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Chat, error)
+	GetByWorkspaceIds(ctx context.Context, workspaceIds []domain.WorkspaceId) ([]*Chat, error)
+	GetByIds(ctx context.Context, ids []ChatId) ([]*Chat, error)
+	Update(ctx context.Context, chat *Chat) error
+	Delete(ctx context.Context, id ChatId) error
+}
+
+// ContentDeleter removes everything stored under a chat (messages, steps...)
+type ContentDeleter interface {
+	DeleteByChatId(ctx context.Context, chatId ChatId) error
+}
+
+type contentDeleters []ContentDeleter
+
+// NewContentDeleters combines deleters, running them in order.
+func NewContentDeleters(deleters ...ContentDeleter) ContentDeleter {
+	return contentDeleters(deleters)
+}
+
+func (d contentDeleters) DeleteByChatId(ctx context.Context, chatId ChatId) error {
+	for _, deleter := range d {
+		if err := deleter.DeleteByChatId(ctx, chatId); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 const (
@@ -21,25 +53,30 @@ const (
 )
 
 type chat struct {
-	Id        primitive.ObjectID `bson:"_id"`
-	Name      string             `bson:"name"`
-	ProfileId primitive.ObjectID `bson:"profile_id"`
+	Id          primitive.ObjectID  `bson:"_id"`
+	Name        string              `bson:"name"`
+	ProfileId   primitive.ObjectID  `bson:"profile_id"`
+	WorkspaceId *primitive.ObjectID `bson:"workspace_id,omitempty"`
 }
 
 func (c chat) ToModel() *Chat {
+	var workspaceId domain.WorkspaceId
+	if c.WorkspaceId != nil {
+		workspaceId = domain.WorkspaceId(c.WorkspaceId.Hex())
+	}
+
 	return &Chat{
-		Id:        ChatId(c.Id.Hex()),
-		Name:      c.Name,
-		ProfileId: domain.ProfileId(c.ProfileId.Hex()),
+		Id:          ChatId(c.Id.Hex()),
+		Name:        c.Name,
+		ProfileId:   domain.ProfileId(c.ProfileId.Hex()),
+		WorkspaceId: workspaceId,
 	}
 }
 
 func fromModel(c *Chat) (*chat, error) {
-	id, err := primitive.ObjectIDFromHex(string(c.Id))
+	id, err := database.ObjectId(c.Id)
 	if err != nil {
-		if errors.Is(err, primitive.ErrInvalidHex) {
-			id = primitive.NewObjectID()
-		}
+		return nil, err
 	}
 
 	profileId, err := primitive.ObjectIDFromHex(string(c.ProfileId))
@@ -47,10 +84,20 @@ func fromModel(c *Chat) (*chat, error) {
 		return nil, err
 	}
 
+	var workspaceId *primitive.ObjectID
+	if c.WorkspaceId != "" {
+		objId, err := primitive.ObjectIDFromHex(string(c.WorkspaceId))
+		if err != nil {
+			return nil, err
+		}
+		workspaceId = &objId
+	}
+
 	return &chat{
-		Id:        id,
-		Name:      c.Name,
-		ProfileId: profileId,
+		Id:          id,
+		Name:        c.Name,
+		ProfileId:   profileId,
+		WorkspaceId: workspaceId,
 	}, nil
 }
 
@@ -87,17 +134,95 @@ func (r *chatRepository) Create(ctx context.Context, chat *Chat) error {
 func (r *chatRepository) FindById(ctx context.Context, id ChatId) (*Chat, error) {
 	objId, err := primitive.ObjectIDFromHex(string(id))
 	if err != nil {
-		return nil, err
+		return nil, ErrChatNotFound
 	}
 
 	var chat *chat
 	if err := r.Collection().FindOne(ctx, bson.M{"_id": objId}).Decode(&chat); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrChatNotFound
+		}
+
 		return nil, err
 	}
 
 	return chat.ToModel(), nil
 }
 
+func (r *chatRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Chat, error) {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.find(ctx, bson.M{"profile_id": objId})
+}
+
+func (r *chatRepository) GetByWorkspaceIds(ctx context.Context, workspaceIds []domain.WorkspaceId) ([]*Chat, error) {
+	objIds, err := database.ObjectIds(workspaceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.find(ctx, bson.M{"workspace_id": bson.M{"$in": objIds}})
+}
+
+func (r *chatRepository) GetByIds(ctx context.Context, ids []ChatId) ([]*Chat, error) {
+	objIds, err := database.ObjectIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.find(ctx, bson.M{"_id": bson.M{"$in": objIds}})
+}
+
+func (r *chatRepository) find(ctx context.Context, filter bson.M) ([]*Chat, error) {
+	cursor, err := r.Collection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []chat
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(c chat) *Chat {
+		return c.ToModel()
+	}), nil
+}
+
+func (r *chatRepository) Update(ctx context.Context, chat *Chat) error {
+	entity, err := fromModel(chat)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.Collection().UpdateByID(ctx, entity.Id, bson.M{"$set": entity})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrChatNotFound
+	}
+
+	return nil
+}
+
+func (r *chatRepository) Delete(ctx context.Context, id ChatId) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteOne(ctx, bson.M{"_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 type repositoryMiddleware func(ChatRepository) ChatRepository
 
 type loggingMiddleware struct {
@@ -116,7 +241,7 @@ func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
 
 func (m *loggingMiddleware) Create(ctx context.Context, chat *Chat) (err error) {
 	defer func() {
-		m.logger.Debug("Create", zap.Object("chat", chat), zap.Error(err))
+		m.logger.Debug("Create", utils.Object("chat", chat), zap.Error(err))
 	}()
 
 	return m.next.Create(ctx, chat)
@@ -124,8 +249,48 @@ func (m *loggingMiddleware) Create(ctx context.Context, chat *Chat) (err error)
 
 func (m *loggingMiddleware) FindById(ctx context.Context, id ChatId) (chat *Chat, err error) {
 	defer func() {
-		m.logger.Debug("FindById", zap.String("id", string(id)), zap.Object("chat", chat), zap.Error(err))
+		m.logger.Debug("FindById", zap.String("id", string(id)), utils.Object("chat", chat), zap.Error(err))
 	}()
 
 	return m.next.FindById(ctx, id)
 }
+
+func (m *loggingMiddleware) GetByProfileId(ctx context.Context, profileId domain.ProfileId) (chats []*Chat, err error) {
+	defer func() {
+		m.logger.Debug("GetByProfileId", zap.String("profile_id", string(profileId)), zap.Objects("chats", chats), zap.Error(err))
+	}()
+
+	return m.next.GetByProfileId(ctx, profileId)
+}
+
+func (m *loggingMiddleware) GetByWorkspaceIds(ctx context.Context, workspaceIds []domain.WorkspaceId) (chats []*Chat, err error) {
+	defer func() {
+		m.logger.Debug("GetByWorkspaceIds", zap.Any("workspace_ids", workspaceIds), zap.Objects("chats", chats), zap.Error(err))
+	}()
+
+	return m.next.GetByWorkspaceIds(ctx, workspaceIds)
+}
+
+func (m *loggingMiddleware) GetByIds(ctx context.Context, ids []ChatId) (chats []*Chat, err error) {
+	defer func() {
+		m.logger.Debug("GetByIds", zap.Any("ids", ids), zap.Objects("chats", chats), zap.Error(err))
+	}()
+
+	return m.next.GetByIds(ctx, ids)
+}
+
+func (m *loggingMiddleware) Update(ctx context.Context, chat *Chat) (err error) {
+	defer func() {
+		m.logger.Debug("Update", utils.Object("chat", chat), zap.Error(err))
+	}()
+
+	return m.next.Update(ctx, chat)
+}
+
+func (m *loggingMiddleware) Delete(ctx context.Context, id ChatId) (err error) {
+	defer func() {
+		m.logger.Debug("Delete", zap.String("id", string(id)), zap.Error(err))
+	}()
+
+	return m.next.Delete(ctx, id)
+}
@@ -0,0 +1,37 @@
+package chats_test
+
+import (
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/platform/database/mongotest"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/dreadster3/yapper/server/internal/storage/storagetest"
+	"go.uber.org/zap"
+)
+
+func TestChatRepository(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		storagetest.TestChatRepository(t, func(t *testing.T) chats.ChatRepository {
+			return chats.NewMemoryChatRepository(zap.NewNop())
+		})
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		storagetest.TestChatRepository(t, func(t *testing.T) chats.ChatRepository {
+			return chats.NewSQLChatRepository(sqldbtest.Open(t), zap.NewNop())
+		})
+	})
+
+	t.Run("Postgres", func(t *testing.T) {
+		storagetest.TestChatRepository(t, func(t *testing.T) chats.ChatRepository {
+			return chats.NewSQLChatRepository(sqldbtest.OpenPostgres(t), zap.NewNop())
+		})
+	})
+
+	t.Run("Mongo", func(t *testing.T) {
+		storagetest.TestChatRepository(t, func(t *testing.T) chats.ChatRepository {
+			return chats.NewChatRepository(mongotest.Open(t), zap.NewNop())
+		})
+	})
+}
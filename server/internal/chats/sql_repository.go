@@ -0,0 +1,192 @@
+package chats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlChatRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLChatRepository(db *sqldb.DB, logger *zap.Logger) ChatRepository {
+	var repo ChatRepository
+	repo = &sqlChatRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+const chatColumns = "id, name, profile_id, workspace_id"
+
+func scanChat(row interface{ Scan(...any) error }) (*Chat, error) {
+	var chat Chat
+	var workspaceId sql.NullString
+	if err := row.Scan(&chat.Id, &chat.Name, &chat.ProfileId, &workspaceId); err != nil {
+		return nil, err
+	}
+	chat.WorkspaceId = domain.WorkspaceId(workspaceId.String)
+
+	return &chat, nil
+}
+
+func nullWorkspaceId(workspaceId domain.WorkspaceId) sql.NullString {
+	return sql.NullString{String: string(workspaceId), Valid: workspaceId != ""}
+}
+
+func (r *sqlChatRepository) Create(ctx context.Context, chat *Chat) error {
+	if chat.Id == "" {
+		chat.Id = ChatId(domain.NewId())
+	}
+
+	_, err := r.db.ExecContext(ctx, "INSERT INTO chats ("+chatColumns+") VALUES (?, ?, ?, ?)",
+		chat.Id, chat.Name, chat.ProfileId, nullWorkspaceId(chat.WorkspaceId))
+	return err
+}
+
+func (r *sqlChatRepository) FindById(ctx context.Context, id ChatId) (*Chat, error) {
+	chat, err := scanChat(r.db.QueryRowContext(ctx, "SELECT "+chatColumns+" FROM chats WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChatNotFound
+	}
+
+	return chat, err
+}
+
+func (r *sqlChatRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Chat, error) {
+	return r.find(ctx, "profile_id = ?", profileId)
+}
+
+func (r *sqlChatRepository) GetByWorkspaceIds(ctx context.Context, workspaceIds []domain.WorkspaceId) ([]*Chat, error) {
+	if len(workspaceIds) == 0 {
+		return []*Chat{}, nil
+	}
+
+	placeholders, args := sqldb.In(workspaceIds)
+	return r.find(ctx, "workspace_id IN ("+placeholders+")", args...)
+}
+
+func (r *sqlChatRepository) GetByIds(ctx context.Context, ids []ChatId) ([]*Chat, error) {
+	if len(ids) == 0 {
+		return []*Chat{}, nil
+	}
+
+	placeholders, args := sqldb.In(ids)
+	return r.find(ctx, "id IN ("+placeholders+")", args...)
+}
+
+func (r *sqlChatRepository) find(ctx context.Context, where string, args ...any) ([]*Chat, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+chatColumns+" FROM chats WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Chat, error) {
+		return scanChat(rows)
+	})
+}
+
+func (r *sqlChatRepository) Update(ctx context.Context, chat *Chat) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE chats SET name = ?, profile_id = ?, workspace_id = ? WHERE id = ?",
+		chat.Name, chat.ProfileId, nullWorkspaceId(chat.WorkspaceId), chat.Id)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrChatNotFound
+	}
+
+	return nil
+}
+
+func (r *sqlChatRepository) Delete(ctx context.Context, id ChatId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM chats WHERE id = ?", id)
+	return err
+}
+
+type sqlMemberRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLMemberRepository(db *sqldb.DB, logger *zap.Logger) MemberRepository {
+	var repo MemberRepository
+	repo = &sqlMemberRepository{db: db}
+	repo = NewMemberLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+const memberColumns = "chat_id, profile_id, role, status, invited_by, created_at"
+
+func scanMember(row interface{ Scan(...any) error }) (*Member, error) {
+	var member Member
+	if err := row.Scan(&member.ChatId, &member.ProfileId, &member.Role, &member.Status, &member.InvitedBy, &member.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (r *sqlMemberRepository) find(ctx context.Context, where string, args ...any) ([]*Member, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+memberColumns+" FROM chat_members WHERE "+where+" ORDER BY created_at", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Member, error) {
+		return scanMember(rows)
+	})
+}
+
+func (r *sqlMemberRepository) Find(ctx context.Context, chatId ChatId, profileId domain.ProfileId) (*Member, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+memberColumns+" FROM chat_members WHERE chat_id = ? AND profile_id = ?", chatId, profileId)
+	member, err := scanMember(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMemberNotFound
+	}
+
+	return member, err
+}
+
+func (r *sqlMemberRepository) GetByChatId(ctx context.Context, chatId ChatId) ([]*Member, error) {
+	return r.find(ctx, "chat_id = ?", chatId)
+}
+
+func (r *sqlMemberRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId, status MemberStatus) ([]*Member, error) {
+	return r.find(ctx, "profile_id = ? AND status = ?", profileId, status)
+}
+
+func (r *sqlMemberRepository) Save(ctx context.Context, member *Member) error {
+	if member.CreatedAt.IsZero() {
+		member.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO chat_members (`+memberColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_id, profile_id) DO UPDATE SET
+			role = excluded.role,
+			status = excluded.status,
+			invited_by = excluded.invited_by,
+			created_at = excluded.created_at`,
+		member.ChatId, member.ProfileId, member.Role, member.Status, member.InvitedBy, member.CreatedAt.UTC())
+	return err
+}
+
+func (r *sqlMemberRepository) Delete(ctx context.Context, chatId ChatId, profileId domain.ProfileId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM chat_members WHERE chat_id = ? AND profile_id = ?", chatId, profileId)
+	return err
+}
+
+func (r *sqlMemberRepository) DeleteByChatId(ctx context.Context, chatId ChatId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM chat_members WHERE chat_id = ?", chatId)
+	return err
+}
+
+func (r *sqlMemberRepository) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM chat_members WHERE profile_id = ?", profileId)
+	return err
+}
@@ -0,0 +1,48 @@
+package chats
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"go.uber.org/zap"
+)
+
+func TestSQLMemberRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLMemberRepository(sqldbtest.Open(t), zap.NewNop())
+
+	member := &Member{ChatId: "chat", ProfileId: "guest", Role: RoleViewer, Status: MemberStatusInvited, InvitedBy: "owner"}
+	if err := repo.Save(ctx, member); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if member.CreatedAt.IsZero() {
+		t.Errorf("Save() did not set the creation time")
+	}
+
+	member.Role = RoleEditor
+	member.Status = MemberStatusAccepted
+	if err := repo.Save(ctx, member); err != nil {
+		t.Fatalf("Save() of an existing member error = %v", err)
+	}
+
+	found, err := repo.Find(ctx, "chat", "guest")
+	if err != nil || found.Role != RoleEditor || found.Status != MemberStatusAccepted {
+		t.Errorf("Find() = %v, %v, want the replaced membership", found, err)
+	}
+
+	if members, err := repo.GetByProfileId(ctx, "guest", MemberStatusInvited); err != nil || len(members) != 0 {
+		t.Errorf("GetByProfileId(invited) = %v, %v, want no members", members, err)
+	}
+	if members, err := repo.GetByChatId(ctx, "chat"); err != nil || len(members) != 1 {
+		t.Errorf("GetByChatId() = %v, %v, want 1 member", members, err)
+	}
+
+	if err := repo.DeleteByProfileId(ctx, "guest"); err != nil {
+		t.Fatalf("DeleteByProfileId() error = %v", err)
+	}
+	if _, err := repo.Find(ctx, "chat", "guest"); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("Find() after delete error = %v, want %v", err, ErrMemberNotFound)
+	}
+}
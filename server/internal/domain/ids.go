@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	processUnique = newProcessUnique()
+	idCounter     atomic.Uint32
+)
+
+func newProcessUnique() [5]byte {
+	var unique [5]byte
+	if _, err := rand.Read(unique[:]); err != nil {
+		panic(err)
+	}
+
+	return unique
+}
+
+func init() {
+	var seed [4]byte
+	rand.Read(seed[:])
+	idCounter.Store(binary.BigEndian.Uint32(seed[:]))
+}
+
+// NewId returns a new unique id made of 24 hex characters. Ids sort by
+// creation time and share the layout of Mongo ObjectIDs, so they are valid
+// in every storage backend.
+func NewId() string {
+	var id [12]byte
+	binary.BigEndian.PutUint32(id[0:4], uint32(time.Now().Unix()))
+	copy(id[4:9], processUnique[:])
+
+	counter := idCounter.Add(1)
+	id[9] = byte(counter >> 16)
+	id[10] = byte(counter >> 8)
+	id[11] = byte(counter)
+
+	return hex.EncodeToString(id[:])
+}
@@ -5,4 +5,6 @@ type (
 	ChatId    string
 	MessageId string
 	StepId    string
+
+	WorkspaceId string
 )
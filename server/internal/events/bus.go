@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrEventDropped   = errors.New("event dropped, subscriber is not keeping up")
+	ErrUnknownBackend = errors.New("unknown event bus backend")
+)
+
+const (
+	BackendMemory = "memory"
+	BackendMongo  = "mongo"
+)
+
+// Bus delivers events to every subscriber of the profile the event belongs to.
+type Bus interface {
+	Publish(ctx context.Context, event Event) error
+	// Subscribe returns a channel of events for the profile which is closed
+	// once ctx is done.
+	Subscribe(ctx context.Context, profileId domain.ProfileId) (<-chan Event, error)
+}
+
+// Publish builds the event and publishes it on the bus.
+func Publish(ctx context.Context, bus Bus, eventType EventType, profileId domain.ProfileId, data any) error {
+	event, err := NewEvent(eventType, profileId, data)
+	if err != nil {
+		return fmt.Errorf("events.Publish: %w", err)
+	}
+
+	return bus.Publish(ctx, event)
+}
+
+// PublishAll publishes the event to every profile.
+func PublishAll(ctx context.Context, bus Bus, eventType EventType, profileIds []domain.ProfileId, data any) error {
+	var errs []error
+	for _, profileId := range profileIds {
+		errs = append(errs, Publish(ctx, bus, eventType, profileId, data))
+	}
+
+	return errors.Join(errs...)
+}
+
+type busMiddleware func(Bus) Bus
+
+type loggingMiddleware struct {
+	logger *zap.Logger
+	next   Bus
+}
+
+func NewLoggingMiddleware(logger *zap.Logger) busMiddleware {
+	return func(next Bus) Bus {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *loggingMiddleware) Publish(ctx context.Context, event Event) (err error) {
+	defer func() {
+		if err != nil {
+			m.logger.Warn("Publish", zap.Object("event", event), zap.Error(err))
+			return
+		}
+
+		m.logger.Debug("Publish", zap.Object("event", event))
+	}()
+
+	return m.next.Publish(ctx, event)
+}
+
+func (m *loggingMiddleware) Subscribe(ctx context.Context, profileId domain.ProfileId) (events <-chan Event, err error) {
+	defer func() {
+		m.logger.Debug("Subscribe", zap.String("profile_id", string(profileId)), zap.Error(err))
+	}()
+
+	return m.next.Subscribe(ctx, profileId)
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"io"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/gin-gonic/gin"
+)
+
+const keepAliveInterval = 30 * time.Second
+
+type EventHandler interface {
+	Stream(c *gin.Context)
+}
+
+type eventHandler struct {
+	bus Bus
+}
+
+func NewEventHandler(bus Bus) EventHandler {
+	return &eventHandler{bus: bus}
+}
+
+func (h *eventHandler) Stream(c *gin.Context) {
+	profile := profiles.GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	events, err := h.bus.Subscribe(ctx, profile.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+
+			c.SSEvent(string(event.Type), event)
+		case <-keepAlive.C:
+			c.SSEvent("ping", "")
+		case <-ctx.Done():
+			return false
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap"
+)
+
+const subscriberBufferSize = 64
+
+type memoryBus struct {
+	mutex       sync.RWMutex
+	subscribers map[domain.ProfileId]map[chan Event]struct{}
+}
+
+// NewMemoryBus returns a bus that only delivers events within this process.
+func NewMemoryBus(logger *zap.Logger) Bus {
+	var bus Bus
+	bus = &memoryBus{subscribers: make(map[domain.ProfileId]map[chan Event]struct{})}
+	bus = NewLoggingMiddleware(logger)(bus)
+	return bus
+}
+
+func (b *memoryBus) Publish(ctx context.Context, event Event) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	var err error
+	for subscriber := range b.subscribers[event.ProfileId] {
+		select {
+		case subscriber <- event:
+		default:
+			err = ErrEventDropped
+		}
+	}
+
+	return err
+}
+
+func (b *memoryBus) Subscribe(ctx context.Context, profileId domain.ProfileId) (<-chan Event, error) {
+	subscriber := make(chan Event, subscriberBufferSize)
+
+	b.mutex.Lock()
+	if _, ok := b.subscribers[profileId]; !ok {
+		b.subscribers[profileId] = make(map[chan Event]struct{})
+	}
+	b.subscribers[profileId][subscriber] = struct{}{}
+	b.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		delete(b.subscribers[profileId], subscriber)
+		if len(b.subscribers[profileId]) == 0 {
+			delete(b.subscribers, profileId)
+		}
+		close(subscriber)
+	}()
+
+	return subscriber, nil
+}
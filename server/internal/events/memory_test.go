@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap"
+)
+
+func receive(t *testing.T, events <-chan Event) (Event, bool) {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatalf("no event received")
+		return Event{}, false
+	}
+}
+
+func TestMemoryBusFanOut(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemoryBus(zap.NewNop())
+
+	first, _ := bus.Subscribe(ctx, "profile")
+	second, _ := bus.Subscribe(ctx, "profile")
+	other, _ := bus.Subscribe(ctx, "other")
+
+	if err := Publish(ctx, bus, EventChatCreated, "profile", map[string]string{"id": "chat"}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	for _, events := range []<-chan Event{first, second} {
+		if event, ok := receive(t, events); !ok || event.Type != EventChatCreated || string(event.Data) != `{"id":"chat"}` {
+			t.Errorf("received %+v, %v, want the published event", event, ok)
+		}
+	}
+	select {
+	case event := <-other:
+		t.Errorf("another profile received %+v", event)
+	default:
+	}
+}
+
+func TestMemoryBusUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+	bus := &memoryBus{subscribers: make(map[domain.ProfileId]map[chan Event]struct{})}
+
+	subscribeCtx, cancel := context.WithCancel(ctx)
+	events, _ := bus.Subscribe(subscribeCtx, "profile")
+	cancel()
+
+	if _, ok := receive(t, events); ok {
+		t.Fatalf("the channel is still open once the context is done")
+	}
+
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+	if len(bus.subscribers) != 0 {
+		t.Errorf("subscribers = %v, want the subscriber removed", bus.subscribers)
+	}
+}
+
+func TestMemoryBusSlowSubscriber(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemoryBus(zap.NewNop())
+
+	slow, _ := bus.Subscribe(ctx, "profile")
+	fast, _ := bus.Subscribe(ctx, "profile")
+
+	// The slow subscriber never reads, the publisher is not blocked by it
+	var dropped int
+	for range subscriberBufferSize + 1 {
+		if err := Publish(ctx, bus, EventMessageUpdated, "profile", nil); errors.Is(err, ErrEventDropped) {
+			dropped++
+		}
+		if _, ok := receive(t, fast); !ok {
+			t.Fatalf("the fast subscriber missed an event")
+		}
+	}
+
+	if dropped != 1 || len(slow) != subscriberBufferSize {
+		t.Errorf("dropped = %d, buffered = %d, want the events past the buffer dropped", dropped, len(slow))
+	}
+}
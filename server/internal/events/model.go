@@ -0,0 +1,49 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap/zapcore"
+)
+
+type EventType string
+
+const (
+	EventChatCreated    EventType = "chat.created"
+	EventChatRenamed    EventType = "chat.renamed"
+	EventChatDeleted    EventType = "chat.deleted"
+	EventMessageCreated EventType = "message.created"
+	EventMessageUpdated EventType = "message.updated"
+	// EventError is the last event of a subscription the bus had to end
+	EventError EventType = "error"
+)
+
+type Event struct {
+	Type      EventType        `json:"type"`
+	ProfileId domain.ProfileId `json:"-"`
+	Data      json.RawMessage  `json:"data"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+func NewEvent(eventType EventType, profileId domain.ProfileId, data any) (Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		Type:      eventType,
+		ProfileId: profileId,
+		Data:      encoded,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (e Event) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("type", string(e.Type))
+	encoder.AddString("profile_id", string(e.ProfileId))
+	encoder.AddTime("created_at", e.CreatedAt)
+	return nil
+}
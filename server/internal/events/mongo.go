@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	collectionName = "events"
+	eventRetention = time.Hour
+)
+
+type eventEntity struct {
+	Id        primitive.ObjectID `bson:"_id"`
+	Type      string             `bson:"type"`
+	ProfileId string             `bson:"profile_id"`
+	Data      string             `bson:"data"`
+	CreatedAt primitive.DateTime `bson:"created_at"`
+}
+
+func (e eventEntity) ToModel() Event {
+	return Event{
+		Type:      EventType(e.Type),
+		ProfileId: domain.ProfileId(e.ProfileId),
+		Data:      []byte(e.Data),
+		CreatedAt: e.CreatedAt.Time(),
+	}
+}
+
+func fromModel(e Event) *eventEntity {
+	return &eventEntity{
+		Id:        primitive.NewObjectID(),
+		Type:      string(e.Type),
+		ProfileId: string(e.ProfileId),
+		Data:      string(e.Data),
+		CreatedAt: primitive.NewDateTimeFromTime(e.CreatedAt),
+	}
+}
+
+type changeEvent struct {
+	FullDocument eventEntity `bson:"fullDocument"`
+}
+
+// mongoBus stores events in a collection and delivers them through change
+// streams, so every server instance connected to the same replica set sees
+// them. Stored events expire after eventRetention.
+type mongoBus struct {
+	db     *mongo.Database
+	logger *zap.Logger
+}
+
+func NewMongoBus(ctx context.Context, db *mongo.Database, logger *zap.Logger) (Bus, error) {
+	mongoBus := &mongoBus{db: db, logger: logger}
+
+	_, err := mongoBus.Collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(eventRetention.Seconds())),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("events.NewMongoBus: %w", err)
+	}
+
+	var bus Bus
+	bus = mongoBus
+	bus = NewLoggingMiddleware(logger)(bus)
+	return bus, nil
+}
+
+func (b *mongoBus) Collection() *mongo.Collection {
+	return b.db.Collection(collectionName)
+}
+
+func (b *mongoBus) Publish(ctx context.Context, event Event) error {
+	if _, err := b.Collection().InsertOne(ctx, fromModel(event)); err != nil {
+		return fmt.Errorf("mongoBus.Publish: %w", err)
+	}
+
+	return nil
+}
+
+func (b *mongoBus) Subscribe(ctx context.Context, profileId domain.ProfileId) (<-chan Event, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"operationType":           "insert",
+			"fullDocument.profile_id": string(profileId),
+		}}},
+	}
+
+	stream, err := b.Collection().Watch(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("mongoBus.Subscribe: %w", err)
+	}
+
+	subscriber := make(chan Event, subscriberBufferSize)
+	go func() {
+		defer close(subscriber)
+		defer stream.Close(context.Background())
+
+		for stream.Next(ctx) {
+			var change changeEvent
+			if err := stream.Decode(&change); err != nil {
+				continue
+			}
+
+			select {
+			case subscriber <- change.FullDocument.ToModel():
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// The subscriber is told the stream ended, it would otherwise wait
+		// for events that never come
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			b.logger.Error("Event change stream failed", zap.String("profile_id", string(profileId)), zap.Error(err))
+			if event, err := NewEvent(EventError, profileId, map[string]string{"error": "event stream interrupted"}); err == nil {
+				select {
+				case subscriber <- event:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}()
+
+	return subscriber, nil
+}
@@ -0,0 +1,121 @@
+package messages
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/steps"
+)
+
+// CheckpointPolicy decides how often the streamed content is stored while
+// generating, a checkpoint is made as soon as either limit is reached. The
+// content received since the last checkpoint is lost on a crash.
+type CheckpointPolicy struct {
+	// Tokens is the number of chunks received from the provider, they usually
+	// hold a single token
+	Tokens int
+	// Interval is the time elapsed since the last checkpoint
+	Interval time.Duration
+}
+
+var DefaultCheckpointPolicy = CheckpointPolicy{Tokens: 32, Interval: 500 * time.Millisecond}
+
+// heartbeatInterval is how often a running generation refreshes its response
+// while the provider sends nothing, e.g. while it loads the model
+const heartbeatInterval = time.Minute
+
+// heartbeat refreshes the response until stop is called, so the reconciler
+// tells a silent generation from one that is no longer running
+func heartbeat(ctx context.Context, messageRepository MessageRepository, id domain.MessageId) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A missed heartbeat is made up by the next one
+				messageRepository.Touch(ctx, id)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// checkpointer appends the content streamed into a response and its step to
+// the stored ones. The final state is still written by runGeneration, the
+// checkpoints only make the partial response durable and visible.
+type checkpointer struct {
+	policy            CheckpointPolicy
+	messageRepository MessageRepository
+	stepRepository    steps.StepRepository
+	message           *Message
+	step              *steps.Step
+
+	messageDelta strings.Builder
+	stepDelta    strings.Builder
+	tokens       int
+	last         time.Time
+}
+
+func newCheckpointer(policy CheckpointPolicy, messageRepository MessageRepository, stepRepository steps.StepRepository, message *Message, step *steps.Step) *checkpointer {
+	return &checkpointer{
+		policy:            policy,
+		messageRepository: messageRepository,
+		stepRepository:    stepRepository,
+		message:           message,
+		step:              step,
+		last:              time.Now(),
+	}
+}
+
+func (c *checkpointer) add(delta string, thinking bool) {
+	if thinking {
+		c.stepDelta.WriteString(delta)
+	} else {
+		c.messageDelta.WriteString(delta)
+	}
+	c.tokens++
+}
+
+func (c *checkpointer) due() bool {
+	if c.tokens == 0 {
+		return false
+	}
+
+	return (c.policy.Tokens > 0 && c.tokens >= c.policy.Tokens) ||
+		(c.policy.Interval > 0 && time.Since(c.last) >= c.policy.Interval)
+}
+
+// flush stores the content received since the last checkpoint. On failure the
+// content is kept and sent again with the next checkpoint.
+func (c *checkpointer) flush(ctx context.Context) error {
+	if c.stepDelta.Len() > 0 {
+		if err := c.stepRepository.AppendContent(ctx, c.step.Id, c.stepDelta.String()); err != nil {
+			return err
+		}
+		c.stepDelta.Reset()
+	}
+
+	if c.messageDelta.Len() > 0 {
+		if err := c.messageRepository.AppendContent(ctx, c.message.Id, c.messageDelta.String()); err != nil {
+			return err
+		}
+		c.messageDelta.Reset()
+	}
+
+	c.tokens = 0
+	c.last = time.Now()
+	return nil
+}
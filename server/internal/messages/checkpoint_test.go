@@ -0,0 +1,85 @@
+package messages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"go.uber.org/zap"
+)
+
+func TestCheckpointer(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	messageRepository := NewMemoryMessageRepository(logger)
+	stepRepository := steps.NewMemoryStepRepository(logger)
+
+	message := &Message{ChatId: "chat", Role: MessageRoleAssistant}
+	if err := messageRepository.Create(ctx, message); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	step := &steps.Step{MessageId: message.Id, Type: "thinking"}
+	if err := stepRepository.Create(ctx, step); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	checkpoint := newCheckpointer(CheckpointPolicy{Tokens: 3, Interval: time.Hour}, messageRepository, stepRepository, message, step)
+	if checkpoint.due() {
+		t.Errorf("due() = true without content")
+	}
+
+	checkpoint.add("Hmm", true)
+	checkpoint.add("Hel", false)
+	if checkpoint.due() {
+		t.Errorf("due() = true before the token limit")
+	}
+
+	checkpoint.add("lo", false)
+	if !checkpoint.due() {
+		t.Fatalf("due() = false at the token limit")
+	}
+	if err := checkpoint.flush(ctx); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if checkpoint.due() {
+		t.Errorf("due() = true right after a checkpoint")
+	}
+
+	checkpoint.add("!", false)
+	if err := checkpoint.flush(ctx); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	if found, _ := messageRepository.FindById(ctx, message.Id); found.Content != "Hello!" {
+		t.Errorf("stored message content = %q, want %q", found.Content, "Hello!")
+	}
+	if found, _ := stepRepository.GetByMessageId(ctx, message.Id); found[0].Content != "Hmm" {
+		t.Errorf("stored step content = %q, want %q", found[0].Content, "Hmm")
+	}
+
+	// The content of a failed checkpoint is sent again with the next one
+	checkpoint.message = &Message{Id: "missing"}
+	checkpoint.add(" Bye", false)
+	if err := checkpoint.flush(ctx); err == nil {
+		t.Fatalf("flush() of a missing message error = nil")
+	}
+	checkpoint.message = message
+	if err := checkpoint.flush(ctx); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if found, _ := messageRepository.FindById(ctx, message.Id); found.Content != "Hello! Bye" {
+		t.Errorf("stored message content = %q, want %q", found.Content, "Hello! Bye")
+	}
+}
+
+func TestCheckpointerInterval(t *testing.T) {
+	checkpoint := newCheckpointer(CheckpointPolicy{Interval: time.Millisecond}, nil, nil, &Message{}, &steps.Step{})
+	checkpoint.add("Hello", false)
+	time.Sleep(2 * time.Millisecond)
+
+	if !checkpoint.due() {
+		t.Errorf("due() = false once the interval elapsed")
+	}
+}
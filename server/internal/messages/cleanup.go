@@ -0,0 +1,38 @@
+package messages
+
+import (
+	"context"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/steps"
+)
+
+type chatContentDeleter struct {
+	messageRepository MessageRepository
+	stepRepository    steps.StepRepository
+}
+
+// NewChatContentDeleter returns a deleter for the messages of a chat and their
+// steps. Steps are removed first so a failure never leaves orphaned steps
+// behind and the deletion can simply be retried.
+func NewChatContentDeleter(messageRepository MessageRepository, stepRepository steps.StepRepository) chats.ContentDeleter {
+	return &chatContentDeleter{
+		messageRepository: messageRepository,
+		stepRepository:    stepRepository,
+	}
+}
+
+func (d *chatContentDeleter) DeleteByChatId(ctx context.Context, chatId chats.ChatId) error {
+	messages, err := d.messageRepository.GetByChatId(ctx, chatId)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if err := d.stepRepository.DeleteByMessageId(ctx, message.Id); err != nil {
+			return err
+		}
+	}
+
+	return d.messageRepository.DeleteByChatId(ctx, chatId)
+}
@@ -0,0 +1,316 @@
+package messages
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/events"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"github.com/dreadster3/yapper/server/internal/utils"
+)
+
+type GenerationEventType string
+
+const (
+	GenerationEventThinking GenerationEventType = "thinking"
+	GenerationEventMessage  GenerationEventType = "message"
+)
+
+type GenerationEvent struct {
+	Type GenerationEventType
+	// Delta is the chunk received from the provider
+	Delta string
+	// Content is the accumulated content for the event type
+	Content string
+}
+
+type GenerationCallback func(GenerationEvent)
+
+// generation holds everything required to produce an assistant reply for a
+// user message. It is shared by every transport (HTTP, SSE and WebSocket).
+type generation struct {
+	chat     *chats.Chat
+	provider providers.Provider
+	history  []providers.Message
+	// request is nil when retrying a failed response
+	request  *Message
+	response *Message
+	step     *steps.Step
+}
+
+// failed reports whether the provider could not complete the response
+func (g *generation) failed() bool {
+	return g.response.Status == MessageStatusFailed
+}
+
+func (h *messageHandler) prepareGeneration(ctx context.Context, profile *profiles.Profile, message *Message) (*generation, error) {
+	message.Role = MessageRoleUser
+	message.Status = MessageStatusDone
+	message.Error = nil
+	message.BackendProvider = ""
+	message.BackendModel = ""
+
+	chat, err := h.authorizer.Authorize(ctx, message.ChatId, profile.Id, chats.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := h.providers.Resolve(ctx, providers.Scope{ProfileId: profile.Id, WorkspaceId: chat.WorkspaceId}, message.Provider, message.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []providers.Message
+	agentResponse := &Message{
+		ChatId:   chat.Id,
+		Provider: message.Provider,
+		Model:    message.Model,
+		Role:     MessageRoleAssistant,
+		Status:   MessageStatusPending,
+		Content:  "",
+	}
+	step := &steps.Step{
+		Type:    "thinking",
+		Content: "",
+		Status:  steps.StepStatusPending,
+	}
+
+	// Either the whole exchange is stored or nothing is, a failure never leaves
+	// a pending response behind
+	err = h.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := h.messageRepository.Create(ctx, message); err != nil {
+			return err
+		}
+
+		messages, err := h.messageRepository.GetByChatId(ctx, message.ChatId)
+		if err != nil {
+			return err
+		}
+
+		history = h.history(ctx, messages)
+
+		if err := h.messageRepository.Create(ctx, agentResponse); err != nil {
+			return err
+		}
+
+		step.MessageId = agentResponse.Id
+		return h.stepsRepository.Create(ctx, step)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chats.PublishChatEvent(ctx, h.bus, h.authorizer, events.EventMessageCreated, chat, message)
+	chats.PublishChatEvent(ctx, h.bus, h.authorizer, events.EventMessageCreated, chat, agentResponse)
+
+	return &generation{
+		chat:     chat,
+		provider: provider,
+		history:  history,
+		request:  message,
+		response: agentResponse,
+		step:     step,
+	}, nil
+}
+
+// prepareRetry resets a failed response, and its steps, so it is generated
+// again from the messages that precede it
+func (h *messageHandler) prepareRetry(ctx context.Context, profile *profiles.Profile, chatId chats.ChatId, messageId domain.MessageId) (*generation, error) {
+	chat, err := h.authorizer.Authorize(ctx, chatId, profile.Id, chats.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := h.messageRepository.FindById(ctx, messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.ChatId != chat.Id {
+		return nil, ErrMessageNotFound
+	}
+
+	if response.Role != MessageRoleAssistant || response.Status != MessageStatusFailed {
+		return nil, ErrMessageNotRetryable
+	}
+
+	provider, err := h.providers.Resolve(ctx, providers.Scope{ProfileId: profile.Id, WorkspaceId: chat.WorkspaceId}, response.Provider, response.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []providers.Message
+	step := &steps.Step{
+		MessageId: response.Id,
+		Type:      "thinking",
+		Content:   "",
+		Status:    steps.StepStatusPending,
+	}
+
+	err = h.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		messages, err := h.messageRepository.GetByChatId(ctx, chat.Id)
+		if err != nil {
+			return err
+		}
+
+		idx := slices.IndexFunc(messages, func(message *Message) bool {
+			return message.Id == response.Id
+		})
+		if idx < 0 {
+			return ErrMessageNotFound
+		}
+
+		history = h.history(ctx, messages[:idx])
+
+		// Only one of the concurrent retries resets the response
+		if err := h.messageRepository.ResetFailed(ctx, response.Id); err != nil {
+			return err
+		}
+		response.Content = ""
+		response.Status = MessageStatusPending
+		response.Error = nil
+		response.BackendProvider = ""
+		response.BackendModel = ""
+
+		if err := h.stepsRepository.DeleteByMessageId(ctx, response.Id); err != nil {
+			return err
+		}
+
+		return h.stepsRepository.Create(ctx, step)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chats.PublishChatEvent(ctx, h.bus, h.authorizer, events.EventMessageUpdated, chat, response)
+
+	return &generation{
+		chat:     chat,
+		provider: provider,
+		history:  history,
+		response: response,
+		step:     step,
+	}, nil
+}
+
+// history converts the chat messages for the provider, failed responses are
+// left out as the model never produced them
+func (h *messageHandler) history(ctx context.Context, messages []*Message) []providers.Message {
+	providerMessages := make([]providers.Message, 0, len(messages))
+	for _, message := range messages {
+		if message.Status == MessageStatusFailed {
+			continue
+		}
+
+		providerMessage := providers.Message{
+			Role:     providers.ParseRole(string(message.Role)),
+			Content:  message.Content,
+			Metadata: map[string]any{},
+		}
+
+		messageSteps, _ := h.stepsRepository.GetByMessageId(ctx, message.Id)
+		if len(messageSteps) > 0 {
+			thinkingSteps := utils.Filter(messageSteps, func(step *steps.Step) bool {
+				return step.Type == "thinking"
+			})
+			thiking := strings.Join(utils.Map(thinkingSteps, func(s *steps.Step) string { return s.Content }), "")
+			providerMessage.Metadata[providers.ThinkMetadataKey] = thiking
+		}
+
+		providerMessages = append(providerMessages, providerMessage)
+	}
+
+	return providerMessages
+}
+
+// runGeneration streams the provider response into the generation's response
+// and step, calling onEvent for every chunk, and persists the final state once
+// the provider is done. A provider error fails the response, the returned
+// error is only about storing it.
+func (h *messageHandler) runGeneration(ctx context.Context, g *generation, onEvent GenerationCallback) error {
+	if onEvent == nil {
+		onEvent = func(GenerationEvent) {}
+	}
+
+	chatCtx, route := providers.WithRoute(ctx)
+	checkpoint := newCheckpointer(h.checkpointPolicy, h.messageRepository, h.stepsRepository, g.response, g.step)
+	stopHeartbeat := heartbeat(ctx, h.messageRepository, g.response.Id)
+	chatErr := g.provider.Chat(chatCtx, g.response.Model, g.history, func(m providers.Message) error {
+		isThinking := false
+		if thinking, ok := m.Metadata[providers.ThinkMetadataKey]; ok {
+			if converted, ok := thinking.(bool); ok {
+				isThinking = converted
+			}
+		}
+
+		if isThinking {
+			g.step.Content += m.Content
+			g.step.Status = steps.StepStatusPending
+			onEvent(GenerationEvent{Type: GenerationEventThinking, Delta: m.Content, Content: g.step.Content})
+		} else {
+			g.response.Content += m.Content
+			g.response.Status = MessageStatusPending
+			onEvent(GenerationEvent{Type: GenerationEventMessage, Delta: m.Content, Content: g.response.Content})
+		}
+
+		// A failed checkpoint does not stop the generation, the content is
+		// stored with the next one or with the final update
+		checkpoint.add(m.Content, isThinking)
+		if checkpoint.due() && checkpoint.flush(ctx) == nil {
+			chats.PublishChatEvent(ctx, h.bus, h.authorizer, events.EventMessageUpdated, g.chat, g.response)
+		}
+
+		return nil
+	})
+	stopHeartbeat()
+
+	g.response.BackendProvider = route.Provider
+	g.response.BackendModel = route.Model
+
+	// A cancelled request (e.g. client hung up) is not a failure, the partial
+	// response is kept as done
+	stepStatus, responseStatus := steps.StepStatusDone, MessageStatusDone
+	if chatErr != nil && ctx.Err() == nil {
+		provider := g.response.Provider
+		if route.Provider != "" {
+			provider = route.Provider
+		}
+
+		code, retryable := providers.Classify(chatErr)
+		g.response.Error = &MessageError{
+			Provider:  provider,
+			Code:      code,
+			Message:   providers.ErrorMessage(chatErr),
+			Retryable: retryable,
+		}
+		stepStatus, responseStatus = steps.StepStatusFailed, MessageStatusFailed
+	}
+
+	// The request context may already be cancelled, the final state is still
+	// persisted
+	ctx = context.WithoutCancel(ctx)
+
+	// The response may have been failed by the reconciler, and retried since,
+	// the final state is only stored while it is still pending
+	g.step.Status = stepStatus
+	g.response.Status = responseStatus
+	err := h.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := h.messageRepository.Complete(ctx, g.response); err != nil {
+			return err
+		}
+
+		return h.stepsRepository.Update(ctx, g.step)
+	})
+	if err != nil {
+		return fmt.Errorf("messageHandler.runGeneration: %w", err)
+	}
+	chats.PublishChatEvent(ctx, h.bus, h.authorizer, events.EventMessageUpdated, g.chat, g.response)
+
+	return nil
+}
@@ -0,0 +1,338 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/events"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/platform/providers/providerstest"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/dreadster3/yapper/server/internal/platform/transaction"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"go.uber.org/zap"
+)
+
+// ownerAuthorizer only lets the chat owners in
+type ownerAuthorizer struct {
+	chats chats.ChatRepository
+}
+
+func (a ownerAuthorizer) Authorize(ctx context.Context, chatId chats.ChatId, profileId domain.ProfileId, permission chats.Permission) (*chats.Chat, error) {
+	chat, err := a.chats.FindById(ctx, chatId)
+	if err != nil {
+		return nil, err
+	}
+
+	if chat.ProfileId != profileId {
+		return nil, auth.ErrForbidden
+	}
+
+	return chat, nil
+}
+
+func (a ownerAuthorizer) RoleOf(ctx context.Context, chat *chats.Chat, profileId domain.ProfileId) (chats.Role, error) {
+	return chats.RoleOwner, nil
+}
+
+func (a ownerAuthorizer) Audience(ctx context.Context, chat *chats.Chat) ([]domain.ProfileId, error) {
+	return []domain.ProfileId{chat.ProfileId}, nil
+}
+
+func (a ownerAuthorizer) Accessible(ctx context.Context, profileId domain.ProfileId) ([]*chats.Chat, error) {
+	return a.chats.GetByProfileId(ctx, profileId)
+}
+
+func TestGeneration(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	chatRepository := chats.NewMemoryChatRepository(logger)
+	messageRepository := NewMemoryMessageRepository(logger)
+	stepRepository := steps.NewMemoryStepRepository(logger)
+	provider := providerstest.New(
+		providerstest.Thinking("Greeting, "),
+		providerstest.Thinking("be polite"),
+		providerstest.Text("Hello"),
+		providerstest.Text("!"),
+	)
+	handler := NewMessageHandler(messageRepository, ownerAuthorizer{chatRepository}, stepRepository, transaction.None(), provider, events.NewMemoryBus(logger), DefaultCheckpointPolicy, nil).(*messageHandler)
+
+	profile := &profiles.Profile{Id: "profile"}
+	chat := &chats.Chat{Name: "Chat", ProfileId: profile.Id}
+	if err := chatRepository.Create(ctx, chat); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	request := &Message{ChatId: chat.Id, Provider: "fake", Model: "model", Content: "Hi"}
+	generation, err := handler.prepareGeneration(ctx, profile, request)
+	if err != nil {
+		t.Fatalf("prepareGeneration() error = %v", err)
+	}
+
+	var received []GenerationEvent
+	if err := handler.runGeneration(ctx, generation, func(event GenerationEvent) {
+		received = append(received, event)
+	}); err != nil {
+		t.Fatalf("runGeneration() error = %v", err)
+	}
+
+	calls := provider.Calls()
+	if len(calls) != 1 || calls[0].Model != "model" || len(calls[0].Messages) != 1 || calls[0].Messages[0].Role != providers.RoleUser {
+		t.Errorf("provider calls = %+v, want the user message sent to the model", calls)
+	}
+
+	if len(received) != 4 {
+		t.Fatalf("runGeneration() sent %d events, want 4", len(received))
+	}
+	if last := received[3]; last.Type != GenerationEventMessage || last.Delta != "!" || last.Content != "Hello!" {
+		t.Errorf("last event = %+v, want the accumulated message", last)
+	}
+
+	history, err := messageRepository.GetByChatId(ctx, chat.Id)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("GetByChatId() = %v, %v, want the request and the response", history, err)
+	}
+	if response := history[1]; response.Content != "Hello!" || response.Status != MessageStatusDone || response.Role != MessageRoleAssistant {
+		t.Errorf("response = %+v, want the done assistant reply", response)
+	}
+
+	messageSteps, err := stepRepository.GetByMessageId(ctx, history[1].Id)
+	if err != nil || len(messageSteps) != 1 {
+		t.Fatalf("GetByMessageId() = %v, %v, want the thinking step", messageSteps, err)
+	}
+	if step := messageSteps[0]; step.Content != "Greeting, be polite" || step.Status != steps.StepStatusDone {
+		t.Errorf("step = %+v, want the done thinking step", step)
+	}
+}
+
+// failingStepRepository fails every step creation
+type failingStepRepository struct {
+	steps.StepRepository
+}
+
+var errCreateStep = errors.New("create step")
+
+func (r failingStepRepository) Create(ctx context.Context, step *steps.Step) error {
+	return errCreateStep
+}
+
+func TestPrepareGenerationIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	db := sqldbtest.Open(t)
+
+	chatRepository := chats.NewSQLChatRepository(db, logger)
+	messageRepository := NewSQLMessageRepository(db, logger)
+	stepRepository := failingStepRepository{steps.NewSQLStepRepository(db, logger)}
+	handler := NewMessageHandler(messageRepository, ownerAuthorizer{chatRepository}, stepRepository, db, providerstest.New(), events.NewMemoryBus(logger), DefaultCheckpointPolicy, nil).(*messageHandler)
+
+	profile := &profiles.Profile{Id: "profile"}
+	chat := &chats.Chat{Name: "Chat", ProfileId: profile.Id}
+	if err := chatRepository.Create(ctx, chat); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	request := &Message{ChatId: chat.Id, Provider: "fake", Model: "model", Content: "Hi"}
+	if _, err := handler.prepareGeneration(ctx, profile, request); !errors.Is(err, errCreateStep) {
+		t.Fatalf("prepareGeneration() error = %v, want %v", err, errCreateStep)
+	}
+
+	if history, err := messageRepository.GetByChatId(ctx, chat.Id); err != nil || len(history) != 0 {
+		t.Errorf("GetByChatId() = %v, %v, want the messages to be rolled back", history, err)
+	}
+}
+
+func TestGenerationFailureAndRetry(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	chatRepository := chats.NewMemoryChatRepository(logger)
+	messageRepository := NewMemoryMessageRepository(logger)
+	stepRepository := steps.NewMemoryStepRepository(logger)
+	provider := providerstest.New(providerstest.Thinking("Hmm"), providerstest.Text("Hel"))
+	provider.Err = &providers.Error{StatusCode: 503, Code: providers.ErrorCodeUnavailable, Message: "overloaded"}
+	handler := NewMessageHandler(messageRepository, ownerAuthorizer{chatRepository}, stepRepository, transaction.None(), provider, events.NewMemoryBus(logger), DefaultCheckpointPolicy, nil).(*messageHandler)
+
+	profile := &profiles.Profile{Id: "profile"}
+	chat := &chats.Chat{Name: "Chat", ProfileId: profile.Id}
+	if err := chatRepository.Create(ctx, chat); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	generation, err := handler.prepareGeneration(ctx, profile, &Message{ChatId: chat.Id, Provider: "fake", Model: "model", Content: "Hi"})
+	if err != nil {
+		t.Fatalf("prepareGeneration() error = %v", err)
+	}
+	if err := handler.runGeneration(ctx, generation, nil); err != nil {
+		t.Fatalf("runGeneration() error = %v", err)
+	}
+
+	failed, err := messageRepository.FindById(ctx, generation.response.Id)
+	if err != nil {
+		t.Fatalf("FindById() error = %v", err)
+	}
+	want := MessageError{Provider: "fake", Code: providers.ErrorCodeUnavailable, Message: "overloaded", Retryable: true}
+	if failed.Status != MessageStatusFailed || failed.Error == nil || *failed.Error != want {
+		t.Fatalf("response = %+v, want the failed reply with %+v", failed, want)
+	}
+	if messageSteps, err := stepRepository.GetByMessageId(ctx, failed.Id); err != nil || len(messageSteps) != 1 || messageSteps[0].Status != steps.StepStatusFailed {
+		t.Errorf("GetByMessageId() = %v, %v, want the failed step", messageSteps, err)
+	}
+
+	if _, err := handler.prepareRetry(ctx, profile, chat.Id, generation.request.Id); !errors.Is(err, ErrMessageNotRetryable) {
+		t.Errorf("prepareRetry() of the user message error = %v, want %v", err, ErrMessageNotRetryable)
+	}
+	if _, err := handler.prepareRetry(ctx, &profiles.Profile{Id: "other"}, chat.Id, failed.Id); !errors.Is(err, auth.ErrForbidden) {
+		t.Errorf("prepareRetry() by another profile error = %v, want %v", err, auth.ErrForbidden)
+	}
+
+	provider.Err = nil
+	retry, err := handler.prepareRetry(ctx, profile, chat.Id, failed.Id)
+	if err != nil {
+		t.Fatalf("prepareRetry() error = %v", err)
+	}
+	if err := handler.runGeneration(ctx, retry, nil); err != nil {
+		t.Fatalf("runGeneration() error = %v", err)
+	}
+
+	calls := provider.Calls()
+	if len(calls) != 2 || len(calls[1].Messages) != 1 || calls[1].Messages[0].Content != "Hi" {
+		t.Errorf("provider calls = %+v, want the retry to send the messages before the response", calls)
+	}
+
+	history, err := messageRepository.GetByChatId(ctx, chat.Id)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("GetByChatId() = %v, %v, want the request and the response", history, err)
+	}
+	if response := history[1]; response.Id != failed.Id || response.Content != "Hel" || response.Status != MessageStatusDone || response.Error != nil {
+		t.Errorf("response = %+v, want the same reply regenerated", response)
+	}
+	if messageSteps, err := stepRepository.GetByMessageId(ctx, failed.Id); err != nil || len(messageSteps) != 1 || messageSteps[0].Content != "Hmm" || messageSteps[0].Status != steps.StepStatusDone {
+		t.Errorf("GetByMessageId() = %v, %v, want the failed step to be replaced", messageSteps, err)
+	}
+
+	if _, err := handler.prepareRetry(ctx, profile, chat.Id, failed.Id); !errors.Is(err, ErrMessageNotRetryable) {
+		t.Errorf("prepareRetry() of a done response error = %v, want %v", err, ErrMessageNotRetryable)
+	}
+}
+
+func TestGenerationCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := zap.NewNop()
+
+	chatRepository := chats.NewMemoryChatRepository(logger)
+	messageRepository := NewMemoryMessageRepository(logger)
+	provider := providerstest.New(providerstest.Text("Hel"), providerstest.Text("lo"))
+	handler := NewMessageHandler(messageRepository, ownerAuthorizer{chatRepository}, steps.NewMemoryStepRepository(logger), transaction.None(), provider, events.NewMemoryBus(logger), DefaultCheckpointPolicy, nil).(*messageHandler)
+
+	profile := &profiles.Profile{Id: "profile"}
+	chat := &chats.Chat{Name: "Chat", ProfileId: profile.Id}
+	if err := chatRepository.Create(ctx, chat); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	generation, err := handler.prepareGeneration(ctx, profile, &Message{ChatId: chat.Id, Provider: "fake", Model: "model", Content: "Hi"})
+	if err != nil {
+		t.Fatalf("prepareGeneration() error = %v", err)
+	}
+
+	// The client hangs up after the first chunk
+	if err := handler.runGeneration(ctx, generation, func(GenerationEvent) { cancel() }); err != nil {
+		t.Fatalf("runGeneration() error = %v", err)
+	}
+
+	if response, err := messageRepository.FindById(context.Background(), generation.response.Id); err != nil || response.Status != MessageStatusDone || response.Error != nil {
+		t.Errorf("FindById() = %+v, %v, want the partial reply kept as done", response, err)
+	}
+}
+
+// staticResolver resolves every name to the same provider
+type staticResolver struct {
+	provider providers.Provider
+}
+
+func (r staticResolver) Resolve(ctx context.Context, scope providers.Scope, name string, model string) (providers.Provider, error) {
+	return r.provider, nil
+}
+
+func TestGenerationRecordsBackend(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	chatRepository := chats.NewMemoryChatRepository(logger)
+	messageRepository := NewMemoryMessageRepository(logger)
+	provider := providers.NewFailoverProvider([]providers.Backend{
+		{Name: "ollama", Model: "llama", Provider: &providerstest.Provider{Err: &providers.Error{StatusCode: 503, Code: providers.ErrorCodeUnavailable}}},
+		{Name: "openai", Model: "gpt", Provider: providerstest.New(providerstest.Text("Hello"))},
+	}, logger)
+	handler := NewMessageHandler(messageRepository, ownerAuthorizer{chatRepository}, steps.NewMemoryStepRepository(logger), transaction.None(), staticResolver{provider}, events.NewMemoryBus(logger), DefaultCheckpointPolicy, nil).(*messageHandler)
+
+	profile := &profiles.Profile{Id: "profile"}
+	chat := &chats.Chat{Name: "Chat", ProfileId: profile.Id}
+	if err := chatRepository.Create(ctx, chat); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	generation, err := handler.prepareGeneration(ctx, profile, &Message{ChatId: chat.Id, Provider: "local", Model: "any", Content: "Hi"})
+	if err != nil {
+		t.Fatalf("prepareGeneration() error = %v", err)
+	}
+	if err := handler.runGeneration(ctx, generation, nil); err != nil {
+		t.Fatalf("runGeneration() error = %v", err)
+	}
+
+	response, err := messageRepository.FindById(ctx, generation.response.Id)
+	if err != nil || response.Status != MessageStatusDone || response.Provider != "local" || response.BackendProvider != "openai" || response.BackendModel != "gpt" {
+		t.Errorf("FindById() = %+v, %v, want the response served by the fallback", response, err)
+	}
+}
+
+func TestGenerationKeepsReconciledResponse(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	chatRepository := chats.NewMemoryChatRepository(logger)
+	messageRepository := NewMemoryMessageRepository(logger)
+	stepRepository := steps.NewMemoryStepRepository(logger)
+	provider := providerstest.New(providerstest.Text("Hello"), providerstest.Text("!"))
+	handler := NewMessageHandler(messageRepository, ownerAuthorizer{chatRepository}, stepRepository, transaction.None(), provider, events.NewMemoryBus(logger), DefaultCheckpointPolicy, nil).(*messageHandler)
+
+	profile := &profiles.Profile{Id: "profile"}
+	chat := &chats.Chat{Name: "Chat", ProfileId: profile.Id}
+	if err := chatRepository.Create(ctx, chat); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	generation, err := handler.prepareGeneration(ctx, profile, &Message{ChatId: chat.Id, Provider: "fake", Model: "model", Content: "Hi"})
+	if err != nil {
+		t.Fatalf("prepareGeneration() error = %v", err)
+	}
+
+	// The reconciler gives up on the response while it is still streaming
+	reconciled := false
+	err = handler.runGeneration(ctx, generation, func(GenerationEvent) {
+		if !reconciled {
+			reconciled = true
+			if err := messageRepository.FailPending(ctx, []domain.MessageId{generation.response.Id}); err != nil {
+				t.Fatalf("FailPending() error = %v", err)
+			}
+		}
+	})
+	if !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("runGeneration() error = %v, want %v", err, ErrMessageNotFound)
+	}
+
+	response, err := messageRepository.FindById(ctx, generation.response.Id)
+	if err != nil || response.Status != MessageStatusFailed || response.Error == nil || response.Error.Code != ErrorCodeInterrupted {
+		t.Errorf("FindById() = %+v, %v, want the response failed by the reconciler", response, err)
+	}
+	if found, err := stepRepository.GetByMessageId(ctx, generation.response.Id); err != nil || len(found) != 1 || found[0].Status == steps.StepStatusDone {
+		t.Errorf("GetByMessageId() = %v, %v, want the step left to the reconciler", found, err)
+	}
+}
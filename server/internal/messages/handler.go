@@ -2,37 +2,52 @@ package messages
 
 import (
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/dreadster3/yapper/server/internal/chats"
-	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/events"
 	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/platform/transaction"
 	"github.com/dreadster3/yapper/server/internal/profiles"
 	"github.com/dreadster3/yapper/server/internal/steps"
-	"github.com/dreadster3/yapper/server/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	MIMEEventStream = "text/event-stream"
 )
 
 type MessageHandler interface {
 	SendMessage(c *gin.Context)
+	Retry(c *gin.Context)
+	Connect(c *gin.Context)
 }
 
 type messageHandler struct {
-	providers         map[string]providers.Provider
+	providers         providers.Resolver
 	messageRepository MessageRepository
-	chatRepository    chats.ChatRepository
+	authorizer        chats.Authorizer
 	stepsRepository   steps.StepRepository
+	unitOfWork        transaction.UnitOfWork
+	bus               events.Bus
+	checkpointPolicy  CheckpointPolicy
+	upgrader          *websocket.Upgrader
 }
 
-func NewMessageHandler(messageRepository MessageRepository, chatRepository chats.ChatRepository, stepsRepository steps.StepRepository, providers map[string]providers.Provider) MessageHandler {
+func NewMessageHandler(messageRepository MessageRepository, authorizer chats.Authorizer, stepsRepository steps.StepRepository, unitOfWork transaction.UnitOfWork, providers providers.Resolver, bus events.Bus, checkpointPolicy CheckpointPolicy, allowedOrigins []string) MessageHandler {
 	return &messageHandler{
 		messageRepository: messageRepository,
-		chatRepository:    chatRepository,
+		authorizer:        authorizer,
 		stepsRepository:   stepsRepository,
+		unitOfWork:        unitOfWork,
 		providers:         providers,
+		bus:               bus,
+		checkpointPolicy:  checkpointPolicy,
+		upgrader:          newUpgrader(allowedOrigins),
 	}
 }
 
@@ -47,124 +62,97 @@ func (h *messageHandler) SendMessage(c *gin.Context) {
 		c.Error(err)
 		return
 	}
-	message.Role = MessageRoleUser
-	message.Status = MessageStatusDone
-
-	ctx := c.Request.Context()
-
-	provider, ok := h.providers[message.Provider]
-	if !ok {
-		c.Error(errors.New("provider not found"))
-		return
-	}
 
 	profile := profiles.GetProfileFromContext(c)
-	chat, err := h.chatRepository.FindById(ctx, message.ChatId)
+	generation, err := h.prepareGeneration(c.Request.Context(), profile, message)
 	if err != nil {
-		c.Error(err)
-		return
-	}
+		if errors.Is(err, chats.ErrChatNotFound) {
+			c.Status(http.StatusNotFound)
+		}
 
-	if chat.ProfileId != profile.Id {
-		c.Error(fmt.Errorf("messageHandler.SendMessage: %w", auth.ErrForbidden))
-		return
-	}
+		if errors.Is(err, providers.ErrModelNotAllowed) || errors.Is(err, providers.ErrAddressNotAllowed) {
+			c.Status(http.StatusBadRequest)
+		}
 
-	if err := h.messageRepository.Create(ctx, message); err != nil {
 		c.Error(err)
 		return
 	}
 
-	messages, err := h.messageRepository.GetByChatId(ctx, chat.Id)
-	if err != nil {
+	h.respond(c, generation, http.StatusCreated)
+}
+
+type retryUri struct {
+	ChatId    chats.ChatId     `uri:"chat_id" binding:"required"`
+	MessageId domain.MessageId `uri:"message_id" binding:"required"`
+}
+
+// Retry generates a failed response again, it is answered like SendMessage
+func (h *messageHandler) Retry(c *gin.Context) {
+	var uri retryUri
+	if err := c.ShouldBindUri(&uri); err != nil {
 		c.Error(err)
 		return
 	}
 
-	providerMessages := make([]providers.Message, len(messages))
-	for idx, message := range messages {
-		providerMessage := providers.Message{
-			Role:     providers.ParseRole(string(message.Role)),
-			Content:  message.Content,
-			Metadata: map[string]any{},
+	profile := profiles.GetProfileFromContext(c)
+	generation, err := h.prepareRetry(c.Request.Context(), profile, uri.ChatId, uri.MessageId)
+	if err != nil {
+		if errors.Is(err, chats.ErrChatNotFound) || errors.Is(err, ErrMessageNotFound) {
+			c.Status(http.StatusNotFound)
 		}
 
-		messageSteps, _ := h.stepsRepository.GetByMessageId(ctx, message.Id)
-		if len(messageSteps) > 0 {
-			thinkingSteps := utils.Filter(messageSteps, func(step *steps.Step) bool {
-				return step.Type == "thinking"
-			})
-			thiking := strings.Join(utils.Map(thinkingSteps, func(s *steps.Step) string { return s.Content }), "")
-			providerMessage.Metadata[providers.ThinkMetadataKey] = thiking
+		if errors.Is(err, ErrMessageNotRetryable) {
+			c.Status(http.StatusConflict)
 		}
 
-		providerMessages[idx] = providerMessage
-	}
+		if errors.Is(err, providers.ErrModelNotAllowed) || errors.Is(err, providers.ErrAddressNotAllowed) {
+			c.Status(http.StatusBadRequest)
+		}
 
-	agentResponse := &Message{
-		ChatId:   chat.Id,
-		Provider: message.Provider,
-		Model:    message.Model,
-		Role:     MessageRoleAssistant,
-		Status:   MessageStatusPending,
-		Content:  "",
-	}
-	if err := h.messageRepository.Create(ctx, agentResponse); err != nil {
 		c.Error(err)
 		return
 	}
 
-	step := &steps.Step{
-		MessageId: agentResponse.Id,
-		Type:      "thinking",
-		Content:   "",
-		Status:    steps.StepStatusPending,
-	}
-	if err := h.stepsRepository.Create(ctx, step); err != nil {
-		c.Error(err)
-		return
-	}
+	h.respond(c, generation, http.StatusOK)
+}
 
-	c.Stream(func(w io.Writer) bool {
-		provider.Chat(ctx, message.Model, providerMessages, func(m providers.Message) error {
-			isThinking := false
-			if thinking, ok := m.Metadata[providers.ThinkMetadataKey]; ok {
-				if converted, ok := thinking.(bool); ok {
-					isThinking = converted
-				}
-			}
+// respond runs the generation, streaming it when the client accepts server
+// sent events. A failed response is sent with a bad gateway status, or as
+// the error event.
+func (h *messageHandler) respond(c *gin.Context, generation *generation, status int) {
+	ctx := c.Request.Context()
 
-			if isThinking {
-				step.Content += m.Content
-				step.Status = steps.StepStatusPending
-				c.SSEvent("thinking", step.Content)
-				return nil
+	switch c.NegotiateFormat(MIMEEventStream, binding.MIMEJSON) {
+	case binding.MIMEJSON:
+		if err := h.runGeneration(ctx, generation, nil); err != nil {
+			c.Error(err)
+			return
+		}
+
+		if generation.failed() {
+			status = http.StatusBadGateway
+		}
+
+		c.JSON(status, generation.response)
+	default:
+		c.Stream(func(w io.Writer) bool {
+			err := h.runGeneration(ctx, generation, func(event GenerationEvent) {
+				c.SSEvent(string(event.Type), event.Content)
+				c.Writer.Flush()
+			})
+			if err != nil {
+				c.Error(err)
+				c.SSEvent("error", err.Error())
+				return false
 			}
 
-			if !isThinking {
-				agentResponse.Content += m.Content
-				agentResponse.Status = MessageStatusPending
-				c.SSEvent("message", agentResponse.Content)
+			if generation.failed() {
+				c.SSEvent("error", generation.response)
+				return false
 			}
 
-			return nil
+			c.SSEvent("done", generation.response)
+			return false
 		})
-
-		c.SSEvent("done", "")
-		return false
-	})
-
-	step.Status = steps.StepStatusDone
-	if err := h.stepsRepository.Update(ctx, step); err != nil {
-		c.Error(err)
-		return
 	}
-
-	agentResponse.Status = MessageStatusDone
-	if err := h.messageRepository.Update(ctx, agentResponse); err != nil {
-		c.Error(err)
-		return
-	}
-
-	c.JSON(http.StatusCreated, agentResponse)
 }
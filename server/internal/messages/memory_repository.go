@@ -0,0 +1,244 @@
+package messages
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap"
+)
+
+type memoryMessageRepository struct {
+	mutex    sync.RWMutex
+	messages map[domain.MessageId]Message
+}
+
+// NewMemoryMessageRepository returns a repository keeping the messages in
+// this process, they are lost on restart.
+func NewMemoryMessageRepository(logger *zap.Logger) MessageRepository {
+	var repo MessageRepository
+	repo = &memoryMessageRepository{messages: make(map[domain.MessageId]Message)}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *memoryMessageRepository) FindById(ctx context.Context, id domain.MessageId) (*Message, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	message, ok := r.messages[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+
+	return copyMessage(message), nil
+}
+
+func (r *memoryMessageRepository) GetByChatId(ctx context.Context, chatId chats.ChatId) ([]*Message, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := []*Message{}
+	for _, message := range r.messages {
+		if message.ChatId == chatId {
+			result = append(result, copyMessage(message))
+		}
+	}
+
+	slices.SortFunc(result, func(a, b *Message) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.Id, b.Id))
+	})
+
+	return result, nil
+}
+
+func (r *memoryMessageRepository) Create(ctx context.Context, message *Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if message.Id == "" {
+		message.Id = domain.MessageId(domain.NewId())
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	if message.UpdatedAt.IsZero() {
+		message.UpdatedAt = message.CreatedAt
+	}
+	if message.Status == "" {
+		message.Status = MessageStatusPending
+	}
+
+	r.messages[message.Id] = *copyMessage(*message)
+	return nil
+}
+
+func (r *memoryMessageRepository) Update(ctx context.Context, message *Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.messages[message.Id]; ok {
+		r.update(existing, message)
+	}
+
+	return nil
+}
+
+func (r *memoryMessageRepository) Complete(ctx context.Context, message *Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.messages[message.Id]
+	if !ok || existing.Status != MessageStatusPending {
+		return ErrMessageNotFound
+	}
+
+	r.update(existing, message)
+	return nil
+}
+
+// update replaces the existing message, the caller holds the lock
+func (r *memoryMessageRepository) update(existing Message, message *Message) {
+	// The chat and creation time are fixed once created
+	message.UpdatedAt = time.Now()
+	updated := *copyMessage(*message)
+	updated.ChatId = existing.ChatId
+	updated.CreatedAt = existing.CreatedAt
+	r.messages[message.Id] = updated
+}
+
+func (r *memoryMessageRepository) AppendContent(ctx context.Context, id domain.MessageId, delta string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	message, ok := r.messages[id]
+	if !ok {
+		return ErrMessageNotFound
+	}
+
+	message.Content += delta
+	message.UpdatedAt = time.Now()
+	r.messages[id] = message
+	return nil
+}
+
+func (r *memoryMessageRepository) GetPendingBefore(ctx context.Context, before time.Time) ([]*Message, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := []*Message{}
+	for _, message := range r.messages {
+		if message.Status == MessageStatusPending && message.UpdatedAt.Before(before) {
+			result = append(result, copyMessage(message))
+		}
+	}
+
+	return result, nil
+}
+
+func (r *memoryMessageRepository) FailPending(ctx context.Context, ids []domain.MessageId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, id := range ids {
+		if message, ok := r.messages[id]; ok && message.Status == MessageStatusPending {
+			message.Status = MessageStatusFailed
+			interrupted := interruptedError
+			message.Error = &interrupted
+			message.UpdatedAt = time.Now()
+			r.messages[id] = message
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryMessageRepository) ResetFailed(ctx context.Context, id domain.MessageId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	message, ok := r.messages[id]
+	if !ok || message.Status != MessageStatusFailed {
+		return ErrMessageNotRetryable
+	}
+
+	message.Status = MessageStatusPending
+	message.Content = ""
+	message.Error = nil
+	message.BackendProvider = ""
+	message.BackendModel = ""
+	message.UpdatedAt = time.Now()
+	r.messages[id] = message
+	return nil
+}
+
+func (r *memoryMessageRepository) Touch(ctx context.Context, id domain.MessageId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	message, ok := r.messages[id]
+	if !ok {
+		return ErrMessageNotFound
+	}
+
+	message.UpdatedAt = time.Now()
+	r.messages[id] = message
+	return nil
+}
+
+func (r *memoryMessageRepository) DeleteByChatId(ctx context.Context, chatId chats.ChatId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, message := range r.messages {
+		if message.ChatId == chatId {
+			delete(r.messages, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryMessageRepository) Usage(ctx context.Context, since time.Time) ([]*Usage, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	type key struct{ provider, model string }
+	usage := map[key]*Usage{}
+	for _, message := range r.messages {
+		if message.Role != MessageRoleAssistant || message.CreatedAt.Before(since) {
+			continue
+		}
+
+		k := key{message.Provider, message.Model}
+		if _, ok := usage[k]; !ok {
+			usage[k] = &Usage{Provider: message.Provider, Model: message.Model}
+		}
+		usage[k].Messages++
+		usage[k].Characters += int64(utf8.RuneCountInString(message.Content))
+	}
+
+	result := make([]*Usage, 0, len(usage))
+	for _, u := range usage {
+		result = append(result, u)
+	}
+	slices.SortFunc(result, func(a, b *Usage) int {
+		return cmp.Or(cmp.Compare(a.Provider, b.Provider), cmp.Compare(a.Model, b.Model))
+	})
+
+	return result, nil
+}
+
+func copyMessage(message Message) *Message {
+	if message.Error != nil {
+		messageError := *message.Error
+		message.Error = &messageError
+	}
+
+	return &message
+}
@@ -13,6 +13,9 @@ type MessageStatus string
 const (
 	MessageStatusPending MessageStatus = "pending"
 	MessageStatusDone    MessageStatus = "done"
+	// MessageStatusFailed is set on the responses whose generation stopped
+	// before completing
+	MessageStatusFailed MessageStatus = "failed"
 )
 
 type MessageRole string
@@ -24,13 +27,23 @@ const (
 
 type Message struct {
 	Id        domain.MessageId `json:"id" binding:"-"`
-	ChatId    chats.ChatId     `json:"chat_id" uri:"chat_id" binding:"omitempty,mongodb"`
+	ChatId    chats.ChatId     `json:"chat_id" uri:"chat_id" binding:"-"`
 	Provider  string           `json:"provider" binding:"required,registered_provider"`
 	Model     string           `json:"model" binding:"required"`
 	Role      MessageRole      `json:"role" binding:"-"`
 	Content   string           `json:"content" binding:"required"`
 	Status    MessageStatus    `json:"status" binding:"-"`
 	CreatedAt time.Time        `json:"created_at" binding:"-"`
+	// UpdatedAt is the time of the last write, the generation of a pending
+	// response refreshes it with every checkpoint and heartbeat
+	UpdatedAt time.Time `json:"updated_at" binding:"-"`
+
+	// Error describes why a failed response stopped
+	Error *MessageError `json:"error,omitempty" binding:"-"`
+	// BackendProvider and BackendModel are the concrete provider and model
+	// that served the response, when the provider is a chain
+	BackendProvider string `json:"backend_provider,omitempty" binding:"-"`
+	BackendModel    string `json:"backend_model,omitempty" binding:"-"`
 }
 
 func (m Message) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
@@ -38,6 +51,51 @@ func (m Message) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	encoder.AddString("provider", m.Provider)
 	encoder.AddString("model", m.Model)
 	encoder.AddString("content", m.Content)
+	encoder.AddString("status", string(m.Status))
+	if m.BackendProvider != "" {
+		encoder.AddString("backend_provider", m.BackendProvider)
+		encoder.AddString("backend_model", m.BackendModel)
+	}
+	if m.Error != nil {
+		encoder.AddObject("error", m.Error)
+	}
 	encoder.AddTime("created_at", m.CreatedAt)
+	encoder.AddTime("updated_at", m.UpdatedAt)
 	return nil
 }
+
+// MessageError is the failure of a generation, the code is one of the
+// normalized provider error codes
+type MessageError struct {
+	Provider  string `json:"provider"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+const ErrorCodeInterrupted = "interrupted"
+
+// interruptedError is set on the responses failed by the reconciler, their
+// generation stopped without reporting an error
+var interruptedError = MessageError{
+	Code:      ErrorCodeInterrupted,
+	Message:   "the generation stopped before completing",
+	Retryable: true,
+}
+
+func (e MessageError) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("provider", e.Provider)
+	encoder.AddString("code", e.Code)
+	encoder.AddString("message", e.Message)
+	encoder.AddBool("retryable", e.Retryable)
+	return nil
+}
+
+// Usage is the number of assistant messages, and their length, generated by
+// a provider model
+type Usage struct {
+	Provider   string `json:"provider"`
+	Model      string `json:"model"`
+	Messages   int64  `json:"messages"`
+	Characters int64  `json:"characters"`
+}
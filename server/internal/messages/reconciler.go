@@ -0,0 +1,93 @@
+package messages
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/transaction"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	reconcileInterval = time.Minute
+	// orphanedAfter is how long a running generation can go without
+	// refreshing its response, the generations of other instances included
+	orphanedAfter = 2 * heartbeatInterval
+)
+
+// Reconciler fails the responses, and their steps, left pending by a
+// generation that is no longer running, e.g. the server restarted while
+// streaming. Running generations refresh their response with every checkpoint
+// and heartbeat, a response is orphaned once it has not been for
+// orphanedAfter.
+type Reconciler struct {
+	messageRepository MessageRepository
+	stepRepository    steps.StepRepository
+	unitOfWork        transaction.UnitOfWork
+	logger            *zap.Logger
+}
+
+func NewReconciler(messageRepository MessageRepository, stepRepository steps.StepRepository, unitOfWork transaction.UnitOfWork, logger *zap.Logger) *Reconciler {
+	return &Reconciler{
+		messageRepository: messageRepository,
+		stepRepository:    stepRepository,
+		unitOfWork:        unitOfWork,
+		logger:            logger,
+	}
+}
+
+// Run reconciles the orphaned responses on start, the ones left over by a
+// previous run, then periodically until ctx is done
+func (r *Reconciler) Run(ctx context.Context) {
+	if err := r.Reconcile(ctx); err != nil {
+		r.logger.Error("Failed to reconcile pending messages", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.Reconcile(ctx); err != nil {
+			r.logger.Error("Failed to reconcile pending messages", zap.Error(err))
+		}
+	}
+}
+
+// Reconcile fails the responses no generation refreshed lately
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	stale, err := r.messageRepository.GetPendingBefore(ctx, time.Now().Add(-orphanedAfter))
+	if err != nil {
+		return fmt.Errorf("Reconciler.Reconcile: %w", err)
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	ids := utils.Map(stale, func(message *Message) domain.MessageId {
+		return message.Id
+	})
+	err = r.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := r.stepRepository.FailPendingByMessageIds(ctx, ids); err != nil {
+			return err
+		}
+
+		return r.messageRepository.FailPending(ctx, ids)
+	})
+	if err != nil {
+		return fmt.Errorf("Reconciler.Reconcile: %w", err)
+	}
+
+	r.logger.Info("Failed stale pending messages", zap.Int("count", len(ids)))
+	return nil
+}
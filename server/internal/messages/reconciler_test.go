@@ -0,0 +1,89 @@
+package messages
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/transaction"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"go.uber.org/zap"
+)
+
+func TestReconciler(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	messageRepository := NewMemoryMessageRepository(logger)
+	stepRepository := steps.NewMemoryStepRepository(logger)
+	reconciler := NewReconciler(messageRepository, stepRepository, transaction.None(), logger)
+
+	stale := &Message{ChatId: "chat", Role: MessageRoleAssistant, Status: MessageStatusPending, CreatedAt: time.Now().Add(-time.Hour)}
+	running := &Message{ChatId: "chat", Role: MessageRoleAssistant, Status: MessageStatusPending}
+	// A long generation is not stale while it makes progress
+	long := &Message{ChatId: "chat", Role: MessageRoleAssistant, Status: MessageStatusPending, CreatedAt: time.Now().Add(-time.Hour)}
+	for _, message := range []*Message{stale, running, long} {
+		if err := messageRepository.Create(ctx, message); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if err := messageRepository.AppendContent(ctx, long.Id, "still going"); err != nil {
+		t.Fatalf("AppendContent() error = %v", err)
+	}
+
+	staleStep := &steps.Step{MessageId: stale.Id, Type: "thinking", Status: steps.StepStatusPending}
+	runningStep := &steps.Step{MessageId: running.Id, Type: "thinking", Status: steps.StepStatusPending}
+	for _, step := range []*steps.Step{staleStep, runningStep} {
+		if err := stepRepository.Create(ctx, step); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	if err := reconciler.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	for message, want := range map[*Message]MessageStatus{stale: MessageStatusFailed, running: MessageStatusPending, long: MessageStatusPending} {
+		if found, _ := messageRepository.FindById(ctx, message.Id); found.Status != want {
+			t.Errorf("message status = %q, want %q", found.Status, want)
+		}
+	}
+
+	for step, want := range map[*steps.Step]steps.StepStatus{staleStep: steps.StepStatusFailed, runningStep: steps.StepStatusPending} {
+		found, _ := stepRepository.GetByMessageId(ctx, step.MessageId)
+		if len(found) != 1 || found[0].Status != want {
+			t.Errorf("steps of %s = %v, want status %q", step.MessageId, found, want)
+		}
+	}
+}
+
+func TestReconcilerOrphaned(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	messageRepository := NewMemoryMessageRepository(logger)
+	reconciler := NewReconciler(messageRepository, steps.NewMemoryStepRepository(logger), transaction.None(), logger)
+
+	// Its heartbeat stopped a few minutes ago, e.g. the process restarted
+	// shortly after the reconciliation on start
+	orphaned := &Message{ChatId: "chat", Role: MessageRoleAssistant, Status: MessageStatusPending, CreatedAt: time.Now().Add(-5 * time.Minute)}
+	// Still refreshed by its heartbeat
+	running := &Message{ChatId: "chat", Role: MessageRoleAssistant, Status: MessageStatusPending, CreatedAt: time.Now().Add(-5 * time.Minute)}
+	for _, message := range []*Message{orphaned, running} {
+		if err := messageRepository.Create(ctx, message); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if err := messageRepository.Touch(ctx, running.Id); err != nil {
+		t.Fatalf("Touch() error = %v", err)
+	}
+
+	if err := reconciler.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	for message, want := range map[*Message]MessageStatus{orphaned: MessageStatusFailed, running: MessageStatusPending} {
+		if found, _ := messageRepository.FindById(ctx, message.Id); found.Status != want {
+			t.Errorf("message status = %q, want %q", found.Status, want)
+		}
+	}
+}
@@ -7,17 +7,47 @@ import (
 
 	"github.com/dreadster3/yapper/server/internal/chats"
 	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/database"
+	"github.com/dreadster3/yapper/server/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
+var (
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrMessageNotRetryable is returned when retrying a message that is not
+	// a failed response
+	ErrMessageNotRetryable = errors.New("only failed responses can be retried")
+)
+
 type MessageRepository interface {
 	FindById(ctx context.Context, id domain.MessageId) (*Message, error)
 	GetByChatId(ctx context.Context, chatId chats.ChatId) ([]*Message, error)
 	Create(ctx context.Context, message *Message) error
 	Update(ctx context.Context, message *Message) error
+	// Complete stores the final state of a response that is still pending, it
+	// returns ErrMessageNotFound when the response was failed or retried
+	// meanwhile
+	Complete(ctx context.Context, message *Message) error
+	// AppendContent adds the delta at the end of the stored content
+	AppendContent(ctx context.Context, id domain.MessageId, delta string) error
+	// GetPendingBefore returns the pending messages last updated before the
+	// given time
+	GetPendingBefore(ctx context.Context, before time.Time) ([]*Message, error)
+	// Touch refreshes the update time of a message whose generation is still
+	// running
+	Touch(ctx context.Context, id domain.MessageId) error
+	// FailPending marks the messages that are still pending as failed
+	FailPending(ctx context.Context, ids []domain.MessageId) error
+	// ResetFailed clears a failed message and marks it pending again, it
+	// returns ErrMessageNotRetryable when the message is not failed
+	ResetFailed(ctx context.Context, id domain.MessageId) error
+	DeleteByChatId(ctx context.Context, chatId chats.ChatId) error
+	// Usage aggregates the assistant messages created since the given time
+	Usage(ctx context.Context, since time.Time) ([]*Usage, error)
 }
 
 const (
@@ -32,7 +62,19 @@ type message struct {
 	Role      string             `bson:"role"`
 	Content   string             `bson:"content"`
 	Status    string             `bson:"status"`
+	Error     *messageError      `bson:"error,omitempty"`
 	CreatedAt primitive.DateTime `bson:"created_at"`
+	UpdatedAt primitive.DateTime `bson:"updated_at"`
+
+	BackendProvider string `bson:"backend_provider,omitempty"`
+	BackendModel    string `bson:"backend_model,omitempty"`
+}
+
+type messageError struct {
+	Provider  string `bson:"provider"`
+	Code      string `bson:"code"`
+	Message   string `bson:"message"`
+	Retryable bool   `bson:"retryable"`
 }
 
 func (m message) ToModel() *Message {
@@ -44,16 +86,19 @@ func (m message) ToModel() *Message {
 		Role:      MessageRole(m.Role),
 		Content:   m.Content,
 		Status:    MessageStatus(m.Status),
+		Error:     (*MessageError)(m.Error),
 		CreatedAt: m.CreatedAt.Time(),
+		UpdatedAt: m.UpdatedAt.Time(),
+
+		BackendProvider: m.BackendProvider,
+		BackendModel:    m.BackendModel,
 	}
 }
 
 func fromModel(m *Message) (*message, error) {
-	id, err := primitive.ObjectIDFromHex(string(m.Id))
+	id, err := database.ObjectId(m.Id)
 	if err != nil {
-		if errors.Is(err, primitive.ErrInvalidHex) {
-			id = primitive.NewObjectID()
-		}
+		return nil, err
 	}
 
 	chatId, err := primitive.ObjectIDFromHex(string(m.ChatId))
@@ -65,6 +110,10 @@ func fromModel(m *Message) (*message, error) {
 	if createdAt.IsZero() {
 		createdAt = time.Now()
 	}
+	updatedAt := m.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
 
 	status := string(m.Status)
 	if m.Status == "" {
@@ -79,7 +128,12 @@ func fromModel(m *Message) (*message, error) {
 		Role:      string(m.Role),
 		Content:   m.Content,
 		Status:    status,
+		Error:     (*messageError)(m.Error),
 		CreatedAt: primitive.NewDateTimeFromTime(createdAt),
+		UpdatedAt: primitive.NewDateTimeFromTime(updatedAt),
+
+		BackendProvider: m.BackendProvider,
+		BackendModel:    m.BackendModel,
 	}, nil
 }
 
@@ -99,7 +153,21 @@ func (r *messageRepository) Collection() *mongo.Collection {
 }
 
 func (r *messageRepository) FindById(ctx context.Context, id domain.MessageId) (*Message, error) {
-	return nil, errors.New("not implemented") // TODO: Implement
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+
+	var entity message
+	if err := r.Collection().FindOne(ctx, bson.M{"_id": objId}).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMessageNotFound
+		}
+
+		return nil, err
+	}
+
+	return entity.ToModel(), nil
 }
 
 func (r *messageRepository) GetByChatId(ctx context.Context, chatId chats.ChatId) ([]*Message, error) {
@@ -108,7 +176,8 @@ func (r *messageRepository) GetByChatId(ctx context.Context, chatId chats.ChatId
 		return nil, err
 	}
 
-	cursor, err := r.Collection().Find(ctx, bson.M{"chat_id": objId})
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.Collection().Find(ctx, bson.M{"chat_id": objId}, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -127,6 +196,16 @@ func (r *messageRepository) GetByChatId(ctx context.Context, chatId chats.ChatId
 }
 
 func (r *messageRepository) Create(ctx context.Context, message *Message) error {
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	if message.UpdatedAt.IsZero() {
+		message.UpdatedAt = message.CreatedAt
+	}
+	if message.Status == "" {
+		message.Status = MessageStatusPending
+	}
+
 	entity, err := fromModel(message)
 	if err != nil {
 		return err
@@ -142,12 +221,209 @@ func (r *messageRepository) Create(ctx context.Context, message *Message) error
 }
 
 func (r *messageRepository) Update(ctx context.Context, message *Message) error {
+	id, update, err := updateDocument(message)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().UpdateByID(ctx, id, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *messageRepository) Complete(ctx context.Context, message *Message) error {
+	id, update, err := updateDocument(message)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.Collection().UpdateOne(ctx, bson.M{"_id": id, "status": string(MessageStatusPending)}, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+// updateDocument is the update replacing the stored message
+func updateDocument(message *Message) (primitive.ObjectID, bson.M, error) {
+	message.UpdatedAt = time.Now()
 	entity, err := fromModel(message)
+	if err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+
+	// The optional fields are omitted when empty, they have to be removed
+	// explicitly
+	update := bson.M{"$set": entity}
+	unset := bson.M{}
+	if entity.Error == nil {
+		unset["error"] = ""
+	}
+	if entity.BackendProvider == "" {
+		unset["backend_provider"] = ""
+		unset["backend_model"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	return entity.Id, update, nil
+}
+
+func (r *messageRepository) AppendContent(ctx context.Context, id domain.MessageId, delta string) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return ErrMessageNotFound
+	}
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"content":    bson.M{"$concat": bson.A{"$content", delta}},
+		"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+	}}}}
+	result, err := r.Collection().UpdateByID(ctx, objId, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+func (r *messageRepository) GetPendingBefore(ctx context.Context, before time.Time) ([]*Message, error) {
+	cursor, err := r.Collection().Find(ctx, bson.M{
+		"status":     string(MessageStatusPending),
+		"updated_at": bson.M{"$lt": primitive.NewDateTimeFromTime(before)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []message
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(m message) *Message {
+		return m.ToModel()
+	}), nil
+}
+
+func (r *messageRepository) FailPending(ctx context.Context, ids []domain.MessageId) error {
+	objIds, err := database.ObjectIds(ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Collection().UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": objIds}, "status": string(MessageStatusPending)},
+		bson.M{"$set": bson.M{"status": string(MessageStatusFailed), "error": messageError(interruptedError), "updated_at": primitive.NewDateTimeFromTime(time.Now())}},
+	)
+	return err
+}
+
+func (r *messageRepository) Touch(ctx context.Context, id domain.MessageId) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return ErrMessageNotFound
+	}
+
+	result, err := r.Collection().UpdateByID(ctx, objId, bson.M{"$set": bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+func (r *messageRepository) ResetFailed(ctx context.Context, id domain.MessageId) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return ErrMessageNotFound
+	}
+
+	result, err := r.Collection().UpdateOne(ctx,
+		bson.M{"_id": objId, "status": string(MessageStatusFailed)},
+		bson.M{
+			"$set":   bson.M{"status": string(MessageStatusPending), "content": "", "updated_at": primitive.NewDateTimeFromTime(time.Now())},
+			"$unset": bson.M{"error": "", "backend_provider": "", "backend_model": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrMessageNotRetryable
+	}
+
+	return nil
+}
+
+func (r *messageRepository) Usage(ctx context.Context, since time.Time) ([]*Usage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"role":       string(MessageRoleAssistant),
+			"created_at": bson.M{"$gte": primitive.NewDateTimeFromTime(since)},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        bson.M{"provider": "$provider", "model": "$model"},
+			"messages":   bson.M{"$sum": 1},
+			"characters": bson.M{"$sum": bson.M{"$strLenCP": "$content"}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.provider", Value: 1}, {Key: "_id.model", Value: 1}}}},
+	}
+
+	cursor, err := r.Collection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []struct {
+		Id struct {
+			Provider string `bson:"provider"`
+			Model    string `bson:"model"`
+		} `bson:"_id"`
+		Messages   int64 `bson:"messages"`
+		Characters int64 `bson:"characters"`
+	}
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	usage := make([]*Usage, len(entities))
+	for idx, entity := range entities {
+		usage[idx] = &Usage{
+			Provider:   entity.Id.Provider,
+			Model:      entity.Id.Model,
+			Messages:   entity.Messages,
+			Characters: entity.Characters,
+		}
+	}
+
+	return usage, nil
+}
+
+func (r *messageRepository) DeleteByChatId(ctx context.Context, chatId chats.ChatId) error {
+	objId, err := primitive.ObjectIDFromHex(string(chatId))
 	if err != nil {
 		return err
 	}
 
-	if _, err := r.Collection().UpdateByID(ctx, entity.Id, bson.M{"$set": entity}); err != nil {
+	if _, err := r.Collection().DeleteMany(ctx, bson.M{"chat_id": objId}); err != nil {
 		return err
 	}
 
@@ -172,7 +448,7 @@ func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
 
 func (m *loggerMiddleware) FindById(ctx context.Context, id domain.MessageId) (message *Message, err error) {
 	defer func() {
-		m.logger.Debug("FindById", zap.String("id", string(id)), zap.Object("message", message), zap.Error(err))
+		m.logger.Debug("FindById", zap.String("id", string(id)), utils.Object("message", message), zap.Error(err))
 	}()
 
 	return m.next.FindById(ctx, id)
@@ -188,7 +464,7 @@ func (m *loggerMiddleware) GetByChatId(ctx context.Context, chatId chats.ChatId)
 
 func (m *loggerMiddleware) Create(ctx context.Context, message *Message) (err error) {
 	defer func() {
-		m.logger.Debug("Create", zap.Object("message", message), zap.Error(err))
+		m.logger.Debug("Create", utils.Object("message", message), zap.Error(err))
 	}()
 
 	return m.next.Create(ctx, message)
@@ -196,8 +472,72 @@ func (m *loggerMiddleware) Create(ctx context.Context, message *Message) (err er
 
 func (m *loggerMiddleware) Update(ctx context.Context, message *Message) (err error) {
 	defer func() {
-		m.logger.Debug("Update", zap.Object("message", message), zap.Error(err))
+		m.logger.Debug("Update", utils.Object("message", message), zap.Error(err))
 	}()
 
 	return m.next.Update(ctx, message)
 }
+
+func (m *loggerMiddleware) Complete(ctx context.Context, message *Message) (err error) {
+	defer func() {
+		m.logger.Debug("Complete", utils.Object("message", message), zap.Error(err))
+	}()
+
+	return m.next.Complete(ctx, message)
+}
+
+func (m *loggerMiddleware) AppendContent(ctx context.Context, id domain.MessageId, delta string) (err error) {
+	defer func() {
+		m.logger.Debug("AppendContent", zap.String("id", string(id)), zap.Int("length", len(delta)), zap.Error(err))
+	}()
+
+	return m.next.AppendContent(ctx, id, delta)
+}
+
+func (m *loggerMiddleware) GetPendingBefore(ctx context.Context, before time.Time) (messages []*Message, err error) {
+	defer func() {
+		m.logger.Debug("GetPendingBefore", zap.Time("before", before), zap.Objects("messages", messages), zap.Error(err))
+	}()
+
+	return m.next.GetPendingBefore(ctx, before)
+}
+
+func (m *loggerMiddleware) FailPending(ctx context.Context, ids []domain.MessageId) (err error) {
+	defer func() {
+		m.logger.Debug("FailPending", zap.Strings("ids", utils.Map(ids, func(id domain.MessageId) string { return string(id) })), zap.Error(err))
+	}()
+
+	return m.next.FailPending(ctx, ids)
+}
+
+func (m *loggerMiddleware) Touch(ctx context.Context, id domain.MessageId) (err error) {
+	defer func() {
+		m.logger.Debug("Touch", zap.String("id", string(id)), zap.Error(err))
+	}()
+
+	return m.next.Touch(ctx, id)
+}
+
+func (m *loggerMiddleware) ResetFailed(ctx context.Context, id domain.MessageId) (err error) {
+	defer func() {
+		m.logger.Debug("ResetFailed", zap.String("id", string(id)), zap.Error(err))
+	}()
+
+	return m.next.ResetFailed(ctx, id)
+}
+
+func (m *loggerMiddleware) Usage(ctx context.Context, since time.Time) (usage []*Usage, err error) {
+	defer func() {
+		m.logger.Debug("Usage", zap.Time("since", since), zap.Int("count", len(usage)), zap.Error(err))
+	}()
+
+	return m.next.Usage(ctx, since)
+}
+
+func (m *loggerMiddleware) DeleteByChatId(ctx context.Context, chatId chats.ChatId) (err error) {
+	defer func() {
+		m.logger.Debug("DeleteByChatId", zap.String("chat_id", string(chatId)), zap.Error(err))
+	}()
+
+	return m.next.DeleteByChatId(ctx, chatId)
+}
@@ -0,0 +1,37 @@
+package messages_test
+
+import (
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/messages"
+	"github.com/dreadster3/yapper/server/internal/platform/database/mongotest"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/dreadster3/yapper/server/internal/storage/storagetest"
+	"go.uber.org/zap"
+)
+
+func TestMessageRepository(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		storagetest.TestMessageRepository(t, func(t *testing.T) messages.MessageRepository {
+			return messages.NewMemoryMessageRepository(zap.NewNop())
+		})
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		storagetest.TestMessageRepository(t, func(t *testing.T) messages.MessageRepository {
+			return messages.NewSQLMessageRepository(sqldbtest.Open(t), zap.NewNop())
+		})
+	})
+
+	t.Run("Postgres", func(t *testing.T) {
+		storagetest.TestMessageRepository(t, func(t *testing.T) messages.MessageRepository {
+			return messages.NewSQLMessageRepository(sqldbtest.OpenPostgres(t), zap.NewNop())
+		})
+	})
+
+	t.Run("Mongo", func(t *testing.T) {
+		storagetest.TestMessageRepository(t, func(t *testing.T) messages.MessageRepository {
+			return messages.NewMessageRepository(mongotest.Open(t), zap.NewNop())
+		})
+	})
+}
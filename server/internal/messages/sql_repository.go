@@ -0,0 +1,230 @@
+package messages
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlMessageRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLMessageRepository(db *sqldb.DB, logger *zap.Logger) MessageRepository {
+	var repo MessageRepository
+	repo = &sqlMessageRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+const messageColumns = "id, chat_id, provider, model, role, content, status, error_details, backend_provider, backend_model, created_at, updated_at"
+
+func scanMessage(row interface{ Scan(...any) error }) (*Message, error) {
+	var message Message
+	var errorDetails sql.NullString
+	if err := row.Scan(&message.Id, &message.ChatId, &message.Provider, &message.Model, &message.Role, &message.Content, &message.Status, &errorDetails, &message.BackendProvider, &message.BackendModel, &message.CreatedAt, &message.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if errorDetails.Valid {
+		if err := json.Unmarshal([]byte(errorDetails.String), &message.Error); err != nil {
+			return nil, err
+		}
+	}
+
+	return &message, nil
+}
+
+func encodeErrorDetails(messageError *MessageError) (sql.NullString, error) {
+	if messageError == nil {
+		return sql.NullString{}, nil
+	}
+
+	encoded, err := json.Marshal(messageError)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+func (r *sqlMessageRepository) FindById(ctx context.Context, id domain.MessageId) (*Message, error) {
+	message, err := scanMessage(r.db.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+
+	return message, err
+}
+
+func (r *sqlMessageRepository) GetByChatId(ctx context.Context, chatId chats.ChatId) ([]*Message, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE chat_id = ? ORDER BY created_at, id", chatId)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Message, error) {
+		return scanMessage(rows)
+	})
+}
+
+func (r *sqlMessageRepository) Create(ctx context.Context, message *Message) error {
+	if message.Id == "" {
+		message.Id = domain.MessageId(domain.NewId())
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	if message.UpdatedAt.IsZero() {
+		message.UpdatedAt = message.CreatedAt
+	}
+	if message.Status == "" {
+		message.Status = MessageStatusPending
+	}
+
+	errorDetails, err := encodeErrorDetails(message.Error)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, "INSERT INTO messages ("+messageColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		message.Id, message.ChatId, message.Provider, message.Model, message.Role, message.Content, message.Status, errorDetails, message.BackendProvider, message.BackendModel, message.CreatedAt.UTC(), message.UpdatedAt.UTC())
+	return err
+}
+
+func (r *sqlMessageRepository) Update(ctx context.Context, message *Message) error {
+	_, err := r.update(ctx, message, "id = ?", message.Id)
+	return err
+}
+
+func (r *sqlMessageRepository) Complete(ctx context.Context, message *Message) error {
+	affected, err := r.update(ctx, message, "id = ? AND status = ?", message.Id, MessageStatusPending)
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+// update replaces the stored message matching the condition, it returns the
+// number of messages updated
+func (r *sqlMessageRepository) update(ctx context.Context, message *Message, where string, args ...any) (int64, error) {
+	errorDetails, err := encodeErrorDetails(message.Error)
+	if err != nil {
+		return 0, err
+	}
+
+	message.UpdatedAt = time.Now()
+	result, err := r.db.ExecContext(ctx, "UPDATE messages SET provider = ?, model = ?, role = ?, content = ?, status = ?, error_details = ?, backend_provider = ?, backend_model = ?, updated_at = ? WHERE "+where,
+		append([]any{message.Provider, message.Model, message.Role, message.Content, message.Status, errorDetails, message.BackendProvider, message.BackendModel, message.UpdatedAt.UTC()}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *sqlMessageRepository) AppendContent(ctx context.Context, id domain.MessageId, delta string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE messages SET content = content || ?, updated_at = ? WHERE id = ?", delta, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+func (r *sqlMessageRepository) GetPendingBefore(ctx context.Context, before time.Time) ([]*Message, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE status = ? AND updated_at < ? ORDER BY updated_at, id",
+		MessageStatusPending, before.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Message, error) {
+		return scanMessage(rows)
+	})
+}
+
+func (r *sqlMessageRepository) FailPending(ctx context.Context, ids []domain.MessageId) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	errorDetails, err := encodeErrorDetails(&interruptedError)
+	if err != nil {
+		return err
+	}
+
+	placeholders, args := sqldb.In(ids)
+	_, err = r.db.ExecContext(ctx, "UPDATE messages SET status = ?, error_details = ?, updated_at = ? WHERE status = ? AND id IN ("+placeholders+")",
+		append([]any{MessageStatusFailed, errorDetails, time.Now().UTC(), MessageStatusPending}, args...)...)
+	return err
+}
+
+func (r *sqlMessageRepository) Touch(ctx context.Context, id domain.MessageId) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE messages SET updated_at = ? WHERE id = ?", time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+func (r *sqlMessageRepository) ResetFailed(ctx context.Context, id domain.MessageId) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE messages SET status = ?, content = '', error_details = NULL, backend_provider = '', backend_model = '', updated_at = ? WHERE id = ? AND status = ?",
+		MessageStatusPending, time.Now().UTC(), id, MessageStatusFailed)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrMessageNotRetryable
+	}
+
+	return nil
+}
+
+func (r *sqlMessageRepository) DeleteByChatId(ctx context.Context, chatId chats.ChatId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM messages WHERE chat_id = ?", chatId)
+	return err
+}
+
+func (r *sqlMessageRepository) Usage(ctx context.Context, since time.Time) ([]*Usage, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT provider, model, COUNT(*), COALESCE(SUM(LENGTH(content)), 0)
+		FROM messages
+		WHERE role = ? AND created_at >= ?
+		GROUP BY provider, model
+		ORDER BY provider, model`,
+		MessageRoleAssistant, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Usage, error) {
+		var usage Usage
+		if err := rows.Scan(&usage.Provider, &usage.Model, &usage.Messages, &usage.Characters); err != nil {
+			return nil, err
+		}
+
+		return &usage, nil
+	})
+}
@@ -0,0 +1,246 @@
+package messages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+)
+
+type SocketMessageType string
+
+const (
+	// Client to server
+	SocketMessageSend   SocketMessageType = "send"
+	SocketMessageCancel SocketMessageType = "cancel"
+
+	// Server to client
+	SocketMessageCreated SocketMessageType = "created"
+	SocketMessageDelta   SocketMessageType = "delta"
+	SocketMessageStep    SocketMessageType = "step"
+	SocketMessageDone    SocketMessageType = "done"
+	SocketMessageError   SocketMessageType = "error"
+)
+
+var (
+	ErrGenerationInProgress = errors.New("a message is already being generated")
+	ErrUnknownSocketMessage = errors.New("unknown message type")
+)
+
+type SocketMessage struct {
+	Type    SocketMessageType `json:"type"`
+	Payload json.RawMessage   `json:"payload,omitempty"`
+}
+
+type DeltaPayload struct {
+	MessageId domain.MessageId    `json:"message_id"`
+	Event     GenerationEventType `json:"event"`
+	Delta     string              `json:"delta"`
+}
+
+type ErrorPayload struct {
+	Error string `json:"error"`
+	// Message is the failed response, when the provider failed
+	Message *Message `json:"message,omitempty"`
+}
+
+// newUpgrader accepts the handshakes from the same origin, the allowed
+// origins and the clients that do not send an Origin (not browsers)
+func newUpgrader(allowedOrigins []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" || slices.Contains(allowedOrigins, origin) {
+				return true
+			}
+
+			parsed, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(parsed.Host, r.Host)
+		},
+	}
+}
+
+type chatSocket struct {
+	handler *messageHandler
+	conn    *websocket.Conn
+	profile *profiles.Profile
+	chatId  chats.ChatId
+
+	writeMutex sync.Mutex
+
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+	// generation identifies the running generation, so a generation only
+	// clears its own cancel function
+	generation uint64
+}
+
+func (h *messageHandler) Connect(c *gin.Context) {
+	chat, ok := chats.AuthorizeChat(c, h.authorizer, chats.PermissionRead)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	profile := profiles.GetProfileFromContext(c)
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	socket := &chatSocket{
+		handler: h,
+		conn:    conn,
+		profile: profile,
+		chatId:  chat.Id,
+	}
+	socket.serve(ctx)
+}
+
+func (s *chatSocket) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		var message SocketMessage
+		if err := s.conn.ReadJSON(&message); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.writeError(err)
+			}
+			s.cancelGeneration()
+			return
+		}
+
+		switch message.Type {
+		case SocketMessageSend:
+			var request *Message
+			if err := json.Unmarshal(message.Payload, &request); err != nil {
+				s.writeError(err)
+				continue
+			}
+			request.ChatId = s.chatId
+
+			if err := binding.Validator.ValidateStruct(request); err != nil {
+				s.writeError(err)
+				continue
+			}
+
+			generationCtx, generation, ok := s.startGeneration(ctx)
+			if !ok {
+				s.writeError(ErrGenerationInProgress)
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer s.finishGeneration(generation)
+				s.generate(generationCtx, request)
+			}()
+		case SocketMessageCancel:
+			s.cancelGeneration()
+		default:
+			s.writeError(ErrUnknownSocketMessage)
+		}
+	}
+}
+
+func (s *chatSocket) startGeneration(ctx context.Context) (context.Context, uint64, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cancel != nil {
+		return nil, 0, false
+	}
+
+	s.generation++
+	ctx, s.cancel = context.WithCancel(ctx)
+	return ctx, s.generation, true
+}
+
+// finishGeneration releases the generation, unless it was cancelled and a new
+// one started since
+func (s *chatSocket) finishGeneration(generation uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.generation == generation && s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+func (s *chatSocket) cancelGeneration() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+func (s *chatSocket) generate(ctx context.Context, request *Message) {
+	generation, err := s.handler.prepareGeneration(ctx, s.profile, request)
+	if err != nil {
+		s.writeError(err)
+		return
+	}
+
+	s.write(SocketMessageCreated, generation.request)
+	s.write(SocketMessageCreated, generation.response)
+	s.write(SocketMessageStep, generation.step)
+
+	err = s.handler.runGeneration(ctx, generation, func(event GenerationEvent) {
+		s.write(SocketMessageDelta, DeltaPayload{
+			MessageId: generation.response.Id,
+			Event:     event.Type,
+			Delta:     event.Delta,
+		})
+	})
+	if err != nil {
+		s.writeError(err)
+		return
+	}
+
+	s.write(SocketMessageStep, generation.step)
+	if generation.failed() {
+		s.write(SocketMessageError, ErrorPayload{Error: generation.response.Error.Message, Message: generation.response})
+		return
+	}
+
+	s.write(SocketMessageDone, generation.response)
+}
+
+func (s *chatSocket) write(messageType SocketMessageType, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	return s.conn.WriteJSON(SocketMessage{Type: messageType, Payload: data})
+}
+
+func (s *chatSocket) writeError(err error) error {
+	return s.write(SocketMessageError, ErrorPayload{Error: err.Error()})
+}
@@ -0,0 +1,72 @@
+package messages
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSocketGenerations(t *testing.T) {
+	socket := &chatSocket{}
+
+	first, firstGeneration, ok := socket.startGeneration(context.Background())
+	if !ok {
+		t.Fatalf("startGeneration() refused the first generation")
+	}
+	if _, _, ok := socket.startGeneration(context.Background()); ok {
+		t.Errorf("startGeneration() accepted a second generation while one is running")
+	}
+
+	// The client cancels then sends again before the first generation returns
+	socket.cancelGeneration()
+	second, secondGeneration, ok := socket.startGeneration(context.Background())
+	if !ok {
+		t.Fatalf("startGeneration() refused a generation after the cancel")
+	}
+	if first.Err() == nil {
+		t.Errorf("first generation was not cancelled")
+	}
+
+	socket.finishGeneration(firstGeneration)
+	if second.Err() != nil {
+		t.Errorf("finishing the first generation cancelled the second")
+	}
+	if _, _, ok := socket.startGeneration(context.Background()); ok {
+		t.Errorf("startGeneration() accepted a generation while the second is running")
+	}
+
+	socket.finishGeneration(secondGeneration)
+	if second.Err() == nil {
+		t.Errorf("finishing the second generation did not release it")
+	}
+	if _, _, ok := socket.startGeneration(context.Background()); !ok {
+		t.Errorf("startGeneration() refused a generation once the others finished")
+	}
+}
+
+func TestUpgraderCheckOrigin(t *testing.T) {
+	upgrader := newUpgrader([]string{"https://app.example.com"})
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"https://app.example.com", true},
+		{"https://yapper.example.com", true},
+		{"https://evil.example.com", false},
+		{"null", false},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "https://yapper.example.com/api/v1/chats/1/ws", nil)
+		if tt.origin != "" {
+			request.Header.Set("Origin", tt.origin)
+		}
+
+		if got := upgrader.CheckOrigin(request); got != tt.want {
+			t.Errorf("CheckOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
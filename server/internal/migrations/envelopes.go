@@ -0,0 +1,177 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The associated data of the envelopes, copied so the migration keeps
+// sealing the same way if the secrets or admin packages change
+func secretAssociatedData(profileId string, provider string) []byte {
+	return encryption.AssociatedData("profile_secret", profileId, provider)
+}
+
+func providerConfigAssociatedData(name string) []byte {
+	return encryption.AssociatedData("provider_config", name)
+}
+
+// reseal seals again an envelope sealed without associated data. It returns
+// nil when the envelope is already bound to the associated data, so it can
+// be run again.
+func reseal(cipher encryption.Cipher, envelope *encryption.Envelope, associatedData []byte) (*encryption.Envelope, error) {
+	if _, err := cipher.Open(envelope, associatedData); err == nil {
+		return nil, nil
+	}
+
+	plaintext, err := cipher.Open(envelope, nil)
+	if err != nil {
+		return nil, fmt.Errorf("re-sealing the stored keys with the master key: %w", err)
+	}
+
+	return cipher.Seal(plaintext, associatedData)
+}
+
+type sealedRow struct {
+	key      []any
+	aad      []byte
+	envelope encryption.Envelope
+}
+
+// resealSQL seals again the envelopes of a table, the query selects the key
+// id, wrapped key and ciphertext followed by the given number of key columns,
+// in the order of the update parameters
+func resealSQL(db *sqldb.DB, cipher encryption.Cipher, keys int, query string, update string, associatedData func(key []string) []byte) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		sealed, err := sqldb.Scan(rows, func(rows *sql.Rows) (sealedRow, error) {
+			var row sealedRow
+			key := make([]string, keys)
+			dest := []any{&row.envelope.KeyId, &row.envelope.WrappedKey, &row.envelope.Ciphertext}
+			for i := range key {
+				dest = append(dest, &key[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return row, err
+			}
+
+			row.aad = associatedData(key)
+			for _, value := range key {
+				row.key = append(row.key, value)
+			}
+			return row, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, row := range sealed {
+			envelope, err := reseal(cipher, &row.envelope, row.aad)
+			if err != nil {
+				return err
+			}
+			if envelope == nil {
+				continue
+			}
+
+			args := append([]any{envelope.KeyId, envelope.WrappedKey, envelope.Ciphertext}, row.key...)
+			if _, err := db.ExecContext(ctx, update, args...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// resealSQLSecrets binds the profile secrets to their profile and provider
+func resealSQLSecrets(db *sqldb.DB, cipher encryption.Cipher) func(ctx context.Context) error {
+	return resealSQL(db, cipher, 2,
+		"SELECT key_id, wrapped_key, ciphertext, profile_id, provider FROM profile_secrets",
+		"UPDATE profile_secrets SET key_id = ?, wrapped_key = ?, ciphertext = ? WHERE profile_id = ? AND provider = ?",
+		func(key []string) []byte { return secretAssociatedData(key[0], key[1]) },
+	)
+}
+
+// resealSQLProviderConfigs binds the provider API keys to their provider
+func resealSQLProviderConfigs(db *sqldb.DB, cipher encryption.Cipher) func(ctx context.Context) error {
+	return resealSQL(db, cipher, 1,
+		"SELECT key_id, wrapped_key, ciphertext, name FROM provider_configs WHERE key_id IS NOT NULL",
+		"UPDATE provider_configs SET key_id = ?, wrapped_key = ?, ciphertext = ? WHERE name = ?",
+		func(key []string) []byte { return providerConfigAssociatedData(key[0]) },
+	)
+}
+
+type mongoEnvelope struct {
+	Id         any                `bson:"_id"`
+	ProfileId  primitive.ObjectID `bson:"profile_id"`
+	Provider   string             `bson:"provider"`
+	KeyId      string             `bson:"key_id"`
+	WrappedKey []byte             `bson:"wrapped_key"`
+	Ciphertext []byte             `bson:"ciphertext"`
+}
+
+// resealMongo seals again the envelopes of a collection, the envelopes
+// already bound to their document are skipped so it can be run again
+func resealMongo(db *mongo.Database, cipher encryption.Cipher, collectionName string, associatedData func(document mongoEnvelope) []byte) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		collection := db.Collection(collectionName)
+		cursor, err := collection.Find(ctx, bson.M{"key_id": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+
+		var documents []mongoEnvelope
+		if err := cursor.All(ctx, &documents); err != nil {
+			return err
+		}
+
+		for _, document := range documents {
+			envelope, err := reseal(cipher, &encryption.Envelope{
+				KeyId:      document.KeyId,
+				WrappedKey: document.WrappedKey,
+				Ciphertext: document.Ciphertext,
+			}, associatedData(document))
+			if err != nil {
+				return err
+			}
+			if envelope == nil {
+				continue
+			}
+
+			update := bson.M{"$set": bson.M{
+				"key_id":      envelope.KeyId,
+				"wrapped_key": envelope.WrappedKey,
+				"ciphertext":  envelope.Ciphertext,
+			}}
+			if _, err := collection.UpdateByID(ctx, document.Id, update); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func resealMongoSecrets(db *mongo.Database, cipher encryption.Cipher) func(ctx context.Context) error {
+	return resealMongo(db, cipher, "profile_secrets", func(document mongoEnvelope) []byte {
+		return secretAssociatedData(document.ProfileId.Hex(), document.Provider)
+	})
+}
+
+func resealMongoProviderConfigs(db *mongo.Database, cipher encryption.Cipher) func(ctx context.Context) error {
+	return resealMongo(db, cipher, "provider_configs", func(document mongoEnvelope) []byte {
+		name, _ := document.Id.(string)
+		return providerConfigAssociatedData(name)
+	})
+}
@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+)
+
+func TestSQLEnvelopesAssociatedData(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqldb.Open(sqldb.SQLite{}, ":memory:")
+	if err != nil {
+		t.Fatalf("sqldb.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cipher, err := encryption.NewEnvelopeCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("NewEnvelopeCipher() error = %v", err)
+	}
+
+	migrateSQL(t, db, cipher, 14)
+	envelope, err := cipher.Seal([]byte("sk-secret"), nil)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO profile_secrets (profile_id, provider, hint, key_id, wrapped_key, ciphertext, updated_at)
+		VALUES ('profile', 'openai', '****', ?, ?, ?, CURRENT_TIMESTAMP)`, envelope.KeyId, envelope.WrappedKey, envelope.Ciphertext); err != nil {
+		t.Fatalf("inserting a secret: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO provider_configs (name, type, url, allowed_models, key_id, wrapped_key, ciphertext)
+		VALUES ('openai', 'openai', 'https://api.openai.com', '[]', ?, ?, ?), ('local', 'ollama', 'http://ollama:11434', '[]', NULL, NULL, NULL)`,
+		envelope.KeyId, envelope.WrappedKey, envelope.Ciphertext); err != nil {
+		t.Fatalf("inserting the provider configs: %v", err)
+	}
+
+	migrateSQL(t, db, cipher, 15)
+
+	var secret encryption.Envelope
+	if err := db.QueryRowContext(ctx, "SELECT key_id, wrapped_key, ciphertext FROM profile_secrets").Scan(&secret.KeyId, &secret.WrappedKey, &secret.Ciphertext); err != nil {
+		t.Fatalf("reading the secret: %v", err)
+	}
+	if plaintext, err := cipher.Open(&secret, secretAssociatedData("profile", "openai")); err != nil || string(plaintext) != "sk-secret" {
+		t.Errorf("Open() secret = %q, %v, want it bound to the profile and provider", plaintext, err)
+	}
+
+	var config encryption.Envelope
+	if err := db.QueryRowContext(ctx, "SELECT key_id, wrapped_key, ciphertext FROM provider_configs WHERE name = 'openai'").Scan(&config.KeyId, &config.WrappedKey, &config.Ciphertext); err != nil {
+		t.Fatalf("reading the provider config: %v", err)
+	}
+	if plaintext, err := cipher.Open(&config, providerConfigAssociatedData("openai")); err != nil || string(plaintext) != "sk-secret" {
+		t.Errorf("Open() provider config = %q, %v, want it bound to the provider", plaintext, err)
+	}
+}
+
+func TestSQLEnvelopesAssociatedDataWithoutMasterKey(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqldb.Open(sqldb.SQLite{}, ":memory:")
+	if err != nil {
+		t.Fatalf("sqldb.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrateSQL(t, db, encryption.NewDisabledCipher(), 14)
+	if _, err := db.ExecContext(ctx, `INSERT INTO profile_secrets (profile_id, provider, hint, key_id, wrapped_key, ciphertext, updated_at)
+		VALUES ('profile', 'openai', '****', 'key', x'00', x'00', CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatalf("inserting a secret: %v", err)
+	}
+
+	reseal := resealSQLSecrets(db, encryption.NewDisabledCipher())
+	if err := reseal(ctx); !errors.Is(err, encryption.ErrNoMasterKey) {
+		t.Errorf("resealSQLSecrets() error = %v, want %v", err, encryption.ErrNoMasterKey)
+	}
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// setMongoMessagesUpdatedAt starts the update time of the existing messages at
+// their creation time
+func setMongoMessagesUpdatedAt(db *mongo.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		collection := db.Collection("messages")
+		update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"updated_at": "$created_at"}}}}
+		if _, err := collection.UpdateMany(ctx, bson.M{"updated_at": bson.M{"$exists": false}}, update); err != nil {
+			return err
+		}
+
+		// Stale responses are found by their update time now
+		if _, err := collection.Indexes().DropOne(ctx, "status_created_at"); err != nil && !isIndexNotFound(err) {
+			return err
+		}
+
+		return nil
+	}
+}
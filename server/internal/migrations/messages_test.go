@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+)
+
+func TestSQLMessagesUpdatedAt(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqldb.Open(sqldb.SQLite{}, ":memory:")
+	if err != nil {
+		t.Fatalf("sqldb.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrateSQL(t, db, encryption.NewDisabledCipher(), 16)
+	createdAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	if _, err := db.ExecContext(ctx, "INSERT INTO messages (id, chat_id, provider, model, role, content, status, created_at) VALUES ('message', 'chat', 'ollama', 'llama', 'assistant', '', 'pending', ?)", createdAt); err != nil {
+		t.Fatalf("inserting a message: %v", err)
+	}
+
+	migrateSQL(t, db, encryption.NewDisabledCipher(), 17)
+	var updatedAt time.Time
+	if err := db.QueryRowContext(ctx, "SELECT updated_at FROM messages WHERE id = 'message'").Scan(&updatedAt); err != nil {
+		t.Fatalf("reading the message: %v", err)
+	}
+	if !updatedAt.Equal(createdAt) {
+		t.Errorf("updated_at = %v, want the creation time %v", updatedAt, createdAt)
+	}
+}
@@ -0,0 +1,19 @@
+// Package migrations lists the versioned database migrations of every storage
+// backend. Migrations are append only, a released version must never be
+// changed.
+package migrations
+
+import "context"
+
+// sequence runs the steps of a migration in order
+func sequence(steps ...func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for _, step := range steps {
+			if err := step(ctx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
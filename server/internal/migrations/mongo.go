@@ -0,0 +1,150 @@
+package migrations
+
+import (
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/migrations"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func index(name string, keys ...string) mongo.IndexModel {
+	document := make(bson.D, len(keys))
+	for idx, key := range keys {
+		document[idx] = bson.E{Key: key, Value: 1}
+	}
+
+	return mongo.IndexModel{Keys: document, Options: options.Index().SetName(name)}
+}
+
+func uniqueIndex(name string, keys ...string) mongo.IndexModel {
+	model := index(name, keys...)
+	model.Options.SetUnique(true)
+	return model
+}
+
+// Mongo returns the migrations of the Mongo backend
+func Mongo(db *mongo.Database, cipher encryption.Cipher) []migrations.Migration {
+	return []migrations.Migration{
+		{
+			// Added after the release of version 1, it runs first on the
+			// databases where duplicated default profiles failed it
+			Version: 0,
+			Name:    "profiles_default_dedupe",
+			Up:      keepMongoDefaultProfile(db),
+		},
+		{
+			Version: 1,
+			Name:    "profiles_default_unique",
+			// Guarantees a single default profile per user
+			Up: migrations.CreateIndexes(db, "profiles", mongo.IndexModel{
+				Keys: bson.D{{Key: "user_id", Value: 1}},
+				Options: options.Index().
+					SetName("user_id_default_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"default": true}),
+			}),
+		},
+		{
+			Version: 2,
+			Name:    "profiles_user_id",
+			Up:      migrations.CreateIndexes(db, "profiles", index("user_id", "user_id")),
+		},
+		{
+			Version: 3,
+			Name:    "messages_chat_id",
+			Up:      migrations.CreateIndexes(db, "messages", index("chat_id_created_at", "chat_id", "created_at")),
+		},
+		{
+			Version: 4,
+			Name:    "steps_message_id",
+			Up:      migrations.CreateIndexes(db, "steps", index("message_id", "message_id")),
+		},
+		{
+			Version: 5,
+			Name:    "chats_owner",
+			Up: migrations.CreateIndexes(db, "chats",
+				index("profile_id", "profile_id"),
+				index("workspace_id", "workspace_id"),
+			),
+		},
+		{
+			Version: 6,
+			Name:    "chat_members",
+			Up: migrations.CreateIndexes(db, "chat_members",
+				uniqueIndex("chat_id_profile_id_unique", "chat_id", "profile_id"),
+				index("profile_id", "profile_id"),
+			),
+		},
+		{
+			Version: 7,
+			Name:    "shares",
+			Up: migrations.CreateIndexes(db, "shares",
+				uniqueIndex("token_unique", "token"),
+				index("chat_id", "chat_id"),
+			),
+		},
+		{
+			Version: 8,
+			Name:    "purge_jobs_next_attempt_at",
+			Up:      migrations.CreateIndexes(db, "purge_jobs", index("next_attempt_at", "next_attempt_at")),
+		},
+		{
+			Version: 9,
+			Name:    "workspaces_members",
+			Up:      migrations.CreateIndexes(db, "workspaces", index("members_profile_id", "members.profile_id")),
+		},
+		{
+			Version: 10,
+			Name:    "profile_secrets",
+			Up:      migrations.CreateIndexes(db, "profile_secrets", uniqueIndex("profile_id_provider_unique", "profile_id", "provider")),
+		},
+		{
+			Version: 11,
+			Name:    "personal_access_tokens",
+			Up: migrations.CreateIndexes(db, "personal_access_tokens",
+				uniqueIndex("hash_unique", "hash"),
+				index("profile_id", "profile_id"),
+			),
+		},
+		{
+			Version: 12,
+			Name:    "messages_status_created_at",
+			Up:      migrations.CreateIndexes(db, "messages", index("status_created_at", "status", "created_at")),
+		},
+		{
+			Version: 13,
+			Name:    "shares_token_hash",
+			Up: sequence(
+				hashMongoShareTokens(db),
+				migrations.CreateIndexes(db, "shares", uniqueIndex("token_hash_unique", "token_hash")),
+			),
+		},
+		{
+			Version: 14,
+			Name:    "envelopes_associated_data",
+			Up: sequence(
+				resealMongoSecrets(db, cipher),
+				resealMongoProviderConfigs(db, cipher),
+			),
+		},
+		{
+			Version: 15,
+			Name:    "workspaces_provider_keys",
+			Up:      sealMongoWorkspaceKeys(db, cipher),
+		},
+		{
+			Version: 16,
+			Name:    "messages_updated_at",
+			Up: sequence(
+				setMongoMessagesUpdatedAt(db),
+				migrations.CreateIndexes(db, "messages", index("status_updated_at", "status", "updated_at")),
+			),
+		},
+		{
+			Version: 17,
+			Name:    "workspaces_version",
+			Up:      setMongoWorkspacesVersion(db),
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// keepMongoDefaultProfile keeps the oldest default profile of every user, the
+// profiles created before the unique index could have several
+func keepMongoDefaultProfile(db *mongo.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		collection := db.Collection("profiles")
+		cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"default": true}}},
+			{{Key: "$sort", Value: bson.M{"_id": 1}}},
+			{{Key: "$group", Value: bson.M{"_id": "$user_id", "ids": bson.M{"$push": "$_id"}}}},
+			{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+		})
+		if err != nil {
+			return err
+		}
+
+		var users []struct {
+			Ids []any `bson:"ids"`
+		}
+		if err := cursor.All(ctx, &users); err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			filter := bson.M{"_id": bson.M{"$in": user.Ids[1:]}}
+			if _, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"default": false}}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package migrations_test
+
+import (
+	"context"
+	"testing"
+
+	schema "github.com/dreadster3/yapper/server/internal/migrations"
+	"github.com/dreadster3/yapper/server/internal/platform/database/mongotest"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/migrations"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestMongoDefaultProfiles(t *testing.T) {
+	ctx := context.Background()
+	db := mongotest.Open(t)
+
+	// A database created before the unique index, with several default
+	// profiles for a user
+	profiles := db.Collection("profiles")
+	if _, err := profiles.Indexes().DropOne(ctx, "user_id_default_unique"); err != nil {
+		t.Fatalf("dropping the index: %v", err)
+	}
+	if _, err := db.Collection("schema_migrations").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": []int{0, 1}}}); err != nil {
+		t.Fatalf("forgetting the migrations: %v", err)
+	}
+
+	oldest, newest, other := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	documents := []any{
+		bson.M{"_id": newest, "user_id": "user", "name": "newest", "default": true},
+		bson.M{"_id": oldest, "user_id": "user", "name": "oldest", "default": true},
+		bson.M{"_id": other, "user_id": "other", "name": "other", "default": true},
+	}
+	if _, err := profiles.InsertMany(ctx, documents); err != nil {
+		t.Fatalf("inserting the profiles: %v", err)
+	}
+
+	runner, err := migrations.NewRunner(migrations.NewMongoStore(db), schema.Mongo(db, encryption.NewDisabledCipher()), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRunner() error = %v", err)
+	}
+	if _, err := runner.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	for id, want := range map[primitive.ObjectID]bool{oldest: true, newest: false, other: true} {
+		var profile struct {
+			Default bool `bson:"default"`
+		}
+		if err := profiles.FindOne(ctx, bson.M{"_id": id}).Decode(&profile); err != nil {
+			t.Fatalf("reading the profile: %v", err)
+		}
+		if profile.Default != want {
+			t.Errorf("profile %s default = %v, want %v", id.Hex(), profile.Default, want)
+		}
+	}
+}
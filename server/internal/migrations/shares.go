@@ -0,0 +1,99 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// hashShareToken is the hash the share repositories store. It is copied so
+// the migration keeps hashing the same way if the shares package changes.
+func hashShareToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+type shareToken struct {
+	id    string
+	token string
+}
+
+// hashSQLShareTokens replaces the plaintext share tokens with their hash, it
+// runs in the transaction renaming the column so every row is hashed once
+func hashSQLShareTokens(db *sqldb.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, "SELECT id, token_hash FROM shares")
+		if err != nil {
+			return err
+		}
+
+		shares, err := sqldb.Scan(rows, func(rows *sql.Rows) (shareToken, error) {
+			var share shareToken
+			err := rows.Scan(&share.id, &share.token)
+			return share, err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, share := range shares {
+			if _, err := db.ExecContext(ctx, "UPDATE shares SET token_hash = ? WHERE id = ?", hashShareToken(share.token), share.id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// hashMongoShareTokens replaces the plaintext share tokens with their hash.
+// The documents still holding a token are the ones left to hash, so it can
+// be run again.
+func hashMongoShareTokens(db *mongo.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		collection := db.Collection("shares")
+
+		// The unique index would reject the documents without a token
+		if _, err := collection.Indexes().DropOne(ctx, "token_unique"); err != nil && !isIndexNotFound(err) {
+			return err
+		}
+
+		cursor, err := collection.Find(ctx, bson.M{"token": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+
+		var shares []struct {
+			Id    any    `bson:"_id"`
+			Token string `bson:"token"`
+		}
+		if err := cursor.All(ctx, &shares); err != nil {
+			return err
+		}
+
+		for _, share := range shares {
+			update := bson.M{
+				"$set":   bson.M{"token_hash": hashShareToken(share.Token)},
+				"$unset": bson.M{"token": ""},
+			}
+			if _, err := collection.UpdateByID(ctx, share.Id, update); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// isIndexNotFound reports whether the dropped index or its collection does
+// not exist
+func isIndexNotFound(err error) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && (commandErr.Code == 27 || commandErr.Code == 26)
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/migrations"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+// migrateSQL applies the SQL migrations up to the version
+func migrateSQL(t *testing.T, db *sqldb.DB, cipher encryption.Cipher, version int) {
+	t.Helper()
+
+	var pending []migrations.Migration
+	for _, migration := range SQL(db, cipher) {
+		if migration.Version <= version {
+			pending = append(pending, migration)
+		}
+	}
+
+	runner, err := migrations.NewRunner(migrations.NewSQLStore(db), pending, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRunner() error = %v", err)
+	}
+	if _, err := runner.Up(context.Background()); err != nil {
+		t.Fatalf("Up() to version %d error = %v", version, err)
+	}
+}
+
+func TestSQLShareTokenHash(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqldb.Open(sqldb.SQLite{}, ":memory:")
+	if err != nil {
+		t.Fatalf("sqldb.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrateSQL(t, db, encryption.NewDisabledCipher(), 13)
+	if _, err := db.ExecContext(ctx, "INSERT INTO shares (id, token, chat_id, profile_id, created_at) VALUES ('share', 'secret', 'chat', 'profile', CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("inserting a share: %v", err)
+	}
+
+	migrateSQL(t, db, encryption.NewDisabledCipher(), 14)
+	var hash string
+	if err := db.QueryRowContext(ctx, "SELECT token_hash FROM shares WHERE id = 'share'").Scan(&hash); err != nil {
+		t.Fatalf("reading the share: %v", err)
+	}
+	if hash != hashShareToken("secret") {
+		t.Errorf("token_hash = %q, want the hash of the token", hash)
+	}
+}
@@ -0,0 +1,257 @@
+package migrations
+
+import (
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/migrations"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+)
+
+// SQL returns the migrations of the SQL backends. Ids are stored as text and
+// times in UTC, relations are enforced by the application like in Mongo. The
+// cipher re-seals the stored keys.
+func SQL(db *sqldb.DB, cipher encryption.Cipher) []migrations.Migration {
+	return []migrations.Migration{
+		{
+			Version: 1,
+			Name:    "create_profiles",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS profiles (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL,
+					preferences TEXT NOT NULL,
+					user_id TEXT NOT NULL,
+					is_default BOOLEAN NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS profiles_user_id ON profiles (user_id)`,
+				// Guarantees a single default profile per user
+				`CREATE UNIQUE INDEX IF NOT EXISTS profiles_user_id_default_unique ON profiles (user_id) WHERE is_default`,
+			),
+		},
+		{
+			Version: 2,
+			Name:    "create_chats",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS chats (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL,
+					profile_id TEXT NOT NULL,
+					workspace_id TEXT
+				)`,
+				`CREATE INDEX IF NOT EXISTS chats_profile_id ON chats (profile_id)`,
+				`CREATE INDEX IF NOT EXISTS chats_workspace_id ON chats (workspace_id)`,
+				`CREATE TABLE IF NOT EXISTS chat_members (
+					chat_id TEXT NOT NULL,
+					profile_id TEXT NOT NULL,
+					role TEXT NOT NULL,
+					status TEXT NOT NULL,
+					invited_by TEXT NOT NULL,
+					created_at {{timestamp}} NOT NULL,
+					PRIMARY KEY (chat_id, profile_id)
+				)`,
+				`CREATE INDEX IF NOT EXISTS chat_members_profile_id ON chat_members (profile_id)`,
+			),
+		},
+		{
+			Version: 3,
+			Name:    "create_messages",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS messages (
+					id TEXT PRIMARY KEY,
+					chat_id TEXT NOT NULL,
+					provider TEXT NOT NULL,
+					model TEXT NOT NULL,
+					role TEXT NOT NULL,
+					content TEXT NOT NULL,
+					status TEXT NOT NULL,
+					created_at {{timestamp}} NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS messages_chat_id_created_at ON messages (chat_id, created_at)`,
+				`CREATE TABLE IF NOT EXISTS steps (
+					id TEXT PRIMARY KEY,
+					message_id TEXT NOT NULL,
+					type TEXT NOT NULL,
+					content TEXT NOT NULL,
+					status TEXT NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS steps_message_id ON steps (message_id)`,
+			),
+		},
+		{
+			Version: 4,
+			Name:    "create_shares",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS shares (
+					id TEXT PRIMARY KEY,
+					token TEXT NOT NULL UNIQUE,
+					chat_id TEXT NOT NULL,
+					profile_id TEXT NOT NULL,
+					expires_at {{timestamp}},
+					revoked_at {{timestamp}},
+					created_at {{timestamp}} NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS shares_chat_id ON shares (chat_id)`,
+			),
+		},
+		{
+			Version: 5,
+			Name:    "create_purge_jobs",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS purge_jobs (
+					id TEXT PRIMARY KEY,
+					profile_id TEXT NOT NULL,
+					attempts INTEGER NOT NULL,
+					last_error TEXT NOT NULL,
+					next_attempt_at {{timestamp}} NOT NULL,
+					created_at {{timestamp}} NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS purge_jobs_next_attempt_at ON purge_jobs (next_attempt_at)`,
+			),
+		},
+		{
+			Version: 6,
+			Name:    "create_workspaces",
+			Up: migrations.Exec(db,
+				// The providers are stored as JSON, they are always read and
+				// written with the workspace
+				`CREATE TABLE IF NOT EXISTS workspaces (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL,
+					providers TEXT NOT NULL,
+					created_at {{timestamp}} NOT NULL
+				)`,
+				`CREATE TABLE IF NOT EXISTS workspace_members (
+					workspace_id TEXT NOT NULL,
+					profile_id TEXT NOT NULL,
+					role TEXT NOT NULL,
+					position INTEGER NOT NULL,
+					PRIMARY KEY (workspace_id, profile_id)
+				)`,
+				`CREATE INDEX IF NOT EXISTS workspace_members_profile_id ON workspace_members (profile_id)`,
+			),
+		},
+		{
+			Version: 7,
+			Name:    "create_profile_secrets",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS profile_secrets (
+					profile_id TEXT NOT NULL,
+					provider TEXT NOT NULL,
+					hint TEXT NOT NULL,
+					key_id TEXT NOT NULL,
+					wrapped_key {{blob}} NOT NULL,
+					ciphertext {{blob}} NOT NULL,
+					updated_at {{timestamp}} NOT NULL,
+					PRIMARY KEY (profile_id, provider)
+				)`,
+			),
+		},
+		{
+			Version: 8,
+			Name:    "create_personal_access_tokens",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS personal_access_tokens (
+					id TEXT PRIMARY KEY,
+					profile_id TEXT NOT NULL,
+					user_id TEXT NOT NULL,
+					name TEXT NOT NULL,
+					scopes TEXT NOT NULL,
+					hash TEXT NOT NULL UNIQUE,
+					expires_at {{timestamp}},
+					last_used_at {{timestamp}},
+					created_at {{timestamp}} NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS personal_access_tokens_profile_id ON personal_access_tokens (profile_id)`,
+			),
+		},
+		{
+			Version: 9,
+			Name:    "create_users",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS users (
+					id TEXT PRIMARY KEY,
+					disabled BOOLEAN NOT NULL,
+					reason TEXT NOT NULL,
+					updated_at {{timestamp}} NOT NULL
+				)`,
+			),
+		},
+		{
+			Version: 10,
+			Name:    "create_provider_configs",
+			Up: migrations.Exec(db,
+				`CREATE TABLE IF NOT EXISTS provider_configs (
+					name TEXT PRIMARY KEY,
+					type TEXT NOT NULL,
+					url TEXT NOT NULL,
+					allowed_models TEXT NOT NULL,
+					key_id TEXT,
+					wrapped_key {{blob}},
+					ciphertext {{blob}}
+				)`,
+			),
+		},
+		{
+			Version: 11,
+			Name:    "messages_status_created_at",
+			Up: migrations.Exec(db,
+				`CREATE INDEX IF NOT EXISTS messages_status_created_at ON messages (status, created_at)`,
+			),
+		},
+		{
+			Version: 12,
+			Name:    "messages_error_details",
+			// The error of the failed messages, stored as JSON
+			Up: migrations.Exec(db,
+				`ALTER TABLE messages ADD COLUMN error_details TEXT`,
+			),
+		},
+		{
+			Version: 13,
+			Name:    "messages_backend",
+			Up: migrations.Exec(db,
+				`ALTER TABLE messages ADD COLUMN backend_provider TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE messages ADD COLUMN backend_model TEXT NOT NULL DEFAULT ''`,
+			),
+		},
+		{
+			Version: 14,
+			Name:    "shares_token_hash",
+			Up: sequence(
+				migrations.Exec(db, `ALTER TABLE shares RENAME COLUMN token TO token_hash`),
+				hashSQLShareTokens(db),
+			),
+		},
+		{
+			Version: 15,
+			Name:    "envelopes_associated_data",
+			Up: sequence(
+				resealSQLSecrets(db, cipher),
+				resealSQLProviderConfigs(db, cipher),
+			),
+		},
+		{
+			Version: 16,
+			Name:    "workspaces_provider_keys",
+			Up:      sealSQLWorkspaceKeys(db, cipher),
+		},
+		{
+			Version: 17,
+			Name:    "messages_updated_at",
+			// Stale responses are found by the time of their last checkpoint
+			Up: migrations.Exec(db,
+				`ALTER TABLE messages ADD COLUMN updated_at {{timestamp}}`,
+				`UPDATE messages SET updated_at = created_at`,
+				`DROP INDEX IF EXISTS messages_status_created_at`,
+				`CREATE INDEX IF NOT EXISTS messages_status_updated_at ON messages (status, updated_at)`,
+			),
+		},
+		{
+			Version: 18,
+			Name:    "workspaces_version",
+			// Concurrent workspace updates are detected by the version
+			Up: migrations.Exec(db,
+				`ALTER TABLE workspaces ADD COLUMN version INTEGER NOT NULL DEFAULT 0`,
+			),
+		},
+	}
+}
@@ -0,0 +1,140 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// workspaceProviderAssociatedData is the associated data the workspaces
+// package seals the provider keys with, copied like the other envelopes
+func workspaceProviderAssociatedData(workspaceId string, name string) []byte {
+	return encryption.AssociatedData("workspace_provider", workspaceId, name)
+}
+
+// sealProviderKey replaces the plaintext API key of a workspace provider with
+// its envelope, it reports whether the provider changed
+func sealProviderKey(cipher encryption.Cipher, workspaceId string, provider map[string]any) (bool, error) {
+	apiKey, ok := provider["api_key"]
+	if !ok {
+		return false, nil
+	}
+	delete(provider, "api_key")
+
+	if key, _ := apiKey.(string); key != "" {
+		name, _ := provider["name"].(string)
+		envelope, err := cipher.Seal([]byte(key), workspaceProviderAssociatedData(workspaceId, name))
+		if err != nil {
+			return false, err
+		}
+
+		provider["key_id"] = envelope.KeyId
+		provider["wrapped_key"] = envelope.WrappedKey
+		provider["ciphertext"] = envelope.Ciphertext
+	}
+
+	return true, nil
+}
+
+type workspaceProviders struct {
+	id        string
+	providers string
+}
+
+// sealSQLWorkspaceKeys encrypts the API keys stored in the providers column
+func sealSQLWorkspaceKeys(db *sqldb.DB, cipher encryption.Cipher) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, "SELECT id, providers FROM workspaces")
+		if err != nil {
+			return err
+		}
+
+		workspaces, err := sqldb.Scan(rows, func(rows *sql.Rows) (workspaceProviders, error) {
+			var workspace workspaceProviders
+			err := rows.Scan(&workspace.id, &workspace.providers)
+			return workspace, err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, workspace := range workspaces {
+			var providers []map[string]any
+			if err := json.Unmarshal([]byte(workspace.providers), &providers); err != nil {
+				return err
+			}
+
+			changed := false
+			for _, provider := range providers {
+				sealed, err := sealProviderKey(cipher, workspace.id, provider)
+				if err != nil {
+					return err
+				}
+				changed = changed || sealed
+			}
+			if !changed {
+				continue
+			}
+
+			encoded, err := json.Marshal(providers)
+			if err != nil {
+				return err
+			}
+			if _, err := db.ExecContext(ctx, "UPDATE workspaces SET providers = ? WHERE id = ?", string(encoded), workspace.id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// sealMongoWorkspaceKeys encrypts the API keys of the workspace providers. The
+// workspaces still holding a plaintext key are the ones left, so it can be run
+// again.
+func sealMongoWorkspaceKeys(db *mongo.Database, cipher encryption.Cipher) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		collection := db.Collection("workspaces")
+		cursor, err := collection.Find(ctx, bson.M{"providers.api_key": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+
+		var workspaces []struct {
+			Id        primitive.ObjectID `bson:"_id"`
+			Providers []bson.M           `bson:"providers"`
+		}
+		if err := cursor.All(ctx, &workspaces); err != nil {
+			return err
+		}
+
+		for _, workspace := range workspaces {
+			for _, provider := range workspace.Providers {
+				if _, err := sealProviderKey(cipher, workspace.Id.Hex(), provider); err != nil {
+					return err
+				}
+			}
+
+			if _, err := collection.UpdateByID(ctx, workspace.Id, bson.M{"$set": bson.M{"providers": workspace.Providers}}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// setMongoWorkspacesVersion starts the version of the existing workspaces, the
+// updates only match the version they loaded
+func setMongoWorkspacesVersion(db *mongo.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := db.Collection("workspaces").UpdateMany(ctx, bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": 0}})
+		return err
+	}
+}
@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+)
+
+func TestSQLWorkspaceProviderKeys(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqldb.Open(sqldb.SQLite{}, ":memory:")
+	if err != nil {
+		t.Fatalf("sqldb.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cipher, err := encryption.NewEnvelopeCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("NewEnvelopeCipher() error = %v", err)
+	}
+
+	migrateSQL(t, db, cipher, 15)
+	providers := `[{"name":"openai","type":"openai","url":"https://api.openai.com/v1","api_key":"sk-secret","allowed_models":null},
+		{"name":"ollama","type":"ollama","url":"http://gpu:11434","api_key":"","allowed_models":["llama"]}]`
+	if _, err := db.ExecContext(ctx, "INSERT INTO workspaces (id, name, providers, created_at) VALUES ('workspace', 'Team', ?, CURRENT_TIMESTAMP)", providers); err != nil {
+		t.Fatalf("inserting a workspace: %v", err)
+	}
+
+	migrateSQL(t, db, cipher, 16)
+
+	var stored string
+	if err := db.QueryRowContext(ctx, "SELECT providers FROM workspaces WHERE id = 'workspace'").Scan(&stored); err != nil {
+		t.Fatalf("reading the workspace: %v", err)
+	}
+
+	var configs []struct {
+		Name          string   `json:"name"`
+		APIKey        *string  `json:"api_key"`
+		AllowedModels []string `json:"allowed_models"`
+		KeyId         string   `json:"key_id"`
+		WrappedKey    []byte   `json:"wrapped_key"`
+		Ciphertext    []byte   `json:"ciphertext"`
+	}
+	if err := json.Unmarshal([]byte(stored), &configs); err != nil {
+		t.Fatalf("decoding the providers: %v", err)
+	}
+	if len(configs) != 2 || configs[0].APIKey != nil || configs[1].APIKey != nil {
+		t.Fatalf("providers = %s, want the plaintext keys removed", stored)
+	}
+
+	envelope := &encryption.Envelope{KeyId: configs[0].KeyId, WrappedKey: configs[0].WrappedKey, Ciphertext: configs[0].Ciphertext}
+	if plaintext, err := cipher.Open(envelope, workspaceProviderAssociatedData("workspace", "openai")); err != nil || string(plaintext) != "sk-secret" {
+		t.Errorf("Open() = %q, %v, want the key bound to the workspace provider", plaintext, err)
+	}
+	if configs[1].KeyId != "" || len(configs[1].AllowedModels) != 1 {
+		t.Errorf("provider without a key = %+v, want it unchanged", configs[1])
+	}
+}
@@ -2,11 +2,20 @@ package auth
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 const (
 	UserIdContextKey     = "user_id"
 	UserClaimsContextKey = "user_claims"
+	// AccessTokenContextKey is the raw JWT, it can be used against the identity
+	// provider (e.g. userinfo)
+	AccessTokenContextKey = "access_token"
+	// TokenProfileIdContextKey is the profile a personal access token is bound to
+	TokenProfileIdContextKey = "token_profile_id"
+	// ScopesContextKey holds the scopes of personal access tokens, requests
+	// authenticated with a JWT are not restricted by scopes
+	ScopesContextKey = "token_scopes"
 )
 
 type UserId string
@@ -14,3 +23,57 @@ type UserId string
 func GetUserIdFromContext(c *gin.Context) UserId {
 	return UserId(c.GetString(UserIdContextKey))
 }
+
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+)
+
+// Allows reports whether the granted scopes cover the required one, write
+// implies read
+func Allows(granted []Scope, required Scope) bool {
+	for _, scope := range granted {
+		if scope == required || scope == ScopeWrite {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetScopesFromContext returns the token scopes and whether the request is
+// restricted by them
+func GetScopesFromContext(c *gin.Context) ([]Scope, bool) {
+	scopes, ok := c.Get(ScopesContextKey)
+	if !ok {
+		return nil, false
+	}
+
+	converted, _ := scopes.([]Scope)
+	return converted, true
+}
+
+// GetClaimsFromContext returns the JWT claims, empty for other credentials
+func GetClaimsFromContext(c *gin.Context) map[string]any {
+	claims, _ := c.Get(UserClaimsContextKey)
+	switch converted := claims.(type) {
+	case jwt.MapClaims:
+		return converted
+	case map[string]any:
+		return converted
+	default:
+		return map[string]any{}
+	}
+}
+
+func GetAccessTokenFromContext(c *gin.Context) (string, bool) {
+	token := c.GetString(AccessTokenContextKey)
+	return token, token != ""
+}
+
+func GetTokenProfileIdFromContext(c *gin.Context) (string, bool) {
+	profileId := c.GetString(TokenProfileIdContextKey)
+	return profileId, profileId != ""
+}
@@ -2,4 +2,7 @@ package auth
 
 import "errors"
 
-var ErrForbidden = errors.New("forbidden to access this resource")
+var (
+	ErrForbidden    = errors.New("forbidden to access this resource")
+	ErrUnauthorized = errors.New("invalid credentials")
+)
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RolesContextKey = "user_roles"
+
+	DefaultRolesClaim = "groups"
+)
+
+type Role string
+
+const (
+	RoleAdmin Role = "admin"
+)
+
+// RoleMapper maps the values of a token claim (e.g. the groups of the
+// identity provider) to roles. Nested claims are addressed with dots, e.g.
+// realm_access.roles.
+type RoleMapper struct {
+	Claim string
+	Roles map[string][]Role
+}
+
+func (m RoleMapper) Map(claims map[string]any) []Role {
+	roles := make([]Role, 0)
+	for _, value := range claimValues(claims, m.Claim) {
+		roles = append(roles, m.Roles[value]...)
+	}
+
+	slices.Sort(roles)
+	return slices.Compact(roles)
+}
+
+func claimValues(claims map[string]any, path string) []string {
+	var current any = claims
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[key]
+	}
+
+	switch value := current.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func GetRolesFromContext(c *gin.Context) []Role {
+	roles, _ := c.Get(RolesContextKey)
+	converted, _ := roles.([]Role)
+	return converted
+}
+
+func HasRole(c *gin.Context, role Role) bool {
+	return slices.Contains(GetRolesFromContext(c), role)
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRoleMapper(t *testing.T) {
+	mapper := RoleMapper{
+		Claim: DefaultRolesClaim,
+		Roles: map[string][]Role{
+			"admins":    {RoleAdmin},
+			"operators": {RoleAdmin},
+		},
+	}
+	nested := RoleMapper{
+		Claim: "realm_access.roles",
+		Roles: map[string][]Role{"yapper-admin": {RoleAdmin}},
+	}
+
+	tests := []struct {
+		name   string
+		mapper RoleMapper
+		claims map[string]any
+		want   []Role
+	}{
+		{name: "mapped group", mapper: mapper, claims: map[string]any{"groups": []any{"users", "admins"}}, want: []Role{RoleAdmin}},
+		{name: "single group claim", mapper: mapper, claims: map[string]any{"groups": "admins"}, want: []Role{RoleAdmin}},
+		{name: "duplicate roles", mapper: mapper, claims: map[string]any{"groups": []any{"admins", "operators"}}, want: []Role{RoleAdmin}},
+		{name: "unmapped groups", mapper: mapper, claims: map[string]any{"groups": []any{"users"}}, want: []Role{}},
+		{name: "non string groups", mapper: mapper, claims: map[string]any{"groups": []any{1, true}}, want: []Role{}},
+		{name: "missing claim", mapper: mapper, claims: map[string]any{"sub": "user"}, want: []Role{}},
+		{name: "no claims", mapper: mapper, claims: nil, want: []Role{}},
+		{name: "nested claim", mapper: nested, claims: map[string]any{"realm_access": map[string]any{"roles": []any{"yapper-admin"}}}, want: []Role{RoleAdmin}},
+		{name: "nested claim not an object", mapper: nested, claims: map[string]any{"realm_access": "yapper-admin"}, want: []Role{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mapper.Map(tt.claims); !slices.Equal(got, tt.want) {
+				t.Errorf("Map() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
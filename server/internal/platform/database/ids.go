@@ -0,0 +1,39 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidId = errors.New("invalid id")
+
+// ObjectId converts the id of a model being stored, models that were never
+// stored get a new id
+func ObjectId[T ~string](id T) (primitive.ObjectID, error) {
+	if id == "" {
+		id = T(domain.NewId())
+	}
+
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidId
+	}
+
+	return objId, nil
+}
+
+// ObjectIds converts the ids of stored models, e.g. for an $in filter
+func ObjectIds[T ~string](ids []T) ([]primitive.ObjectID, error) {
+	objIds := make([]primitive.ObjectID, len(ids))
+	for idx, id := range ids {
+		objId, err := primitive.ObjectIDFromHex(string(id))
+		if err != nil {
+			return nil, ErrInvalidId
+		}
+		objIds[idx] = objId
+	}
+
+	return objIds, nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+
+	"github.com/dreadster3/yapper/server/internal/platform/transaction"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+type unitOfWork struct {
+	client *mongo.Client
+}
+
+// NewUnitOfWork runs the units of work in Mongo transactions. Transactions
+// require a replica set, on a standalone server the writes are not atomic.
+func NewUnitOfWork(ctx context.Context, db *mongo.Database, logger *zap.Logger) (transaction.UnitOfWork, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return nil, err
+	}
+
+	// mongos answers isdbgrid, sharded clusters support transactions as well
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		logger.Warn("MongoDB is not a replica set, writes are not transactional")
+		return transaction.None(), nil
+	}
+
+	return &unitOfWork{client: db.Client()}, nil
+}
+
+// Do runs fn in a transaction, the repositories join it through the session
+// carried by the context
+func (u *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := u.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (any, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
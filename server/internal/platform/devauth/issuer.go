@@ -0,0 +1,183 @@
+// Package devauth issues tokens for local development, standing in for the
+// identity provider. It must never be enabled in production.
+package devauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	Algorithm = "ES256"
+	Audience  = "yapper"
+
+	keyId             = "devauth"
+	defaultExpiration = time.Hour
+)
+
+type Issuer struct {
+	key      *ecdsa.PrivateKey
+	issuer   string
+	listener net.Listener
+	server   *http.Server
+	logger   *zap.Logger
+}
+
+// NewIssuer generates a signing key and starts listening on addr, so the
+// JWKS can be fetched as soon as it returns
+func NewIssuer(addr string, logger *zap.Logger) (*Issuer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("devauth.NewIssuer: %w", err)
+	}
+
+	issuer := &Issuer{
+		key:      key,
+		issuer:   "http://" + listener.Addr().String(),
+		listener: listener,
+		logger:   logger,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jwks", issuer.serveJWKS)
+	mux.HandleFunc("POST /token", issuer.serveToken)
+	issuer.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	return issuer, nil
+}
+
+func (i *Issuer) Issuer() string {
+	return i.issuer
+}
+
+func (i *Issuer) JWKSUrl() string {
+	return i.issuer + "/jwks"
+}
+
+// Run serves the JWKS and token endpoints until ctx is done
+func (i *Issuer) Run(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		i.server.Shutdown(context.Background())
+	}()
+
+	i.logger.Warn("Development authentication enabled, anyone can mint tokens", zap.String("issuer", i.issuer))
+	if err := i.server.Serve(i.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Mint signs a token for the subject, extra claims are added as is (e.g.
+// groups)
+func (i *Issuer) Mint(subject string, expiresIn time.Duration, extra map[string]any) (string, error) {
+	if subject == "" {
+		return "", errors.New("devauth.Mint: subject required")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for key, value := range extra {
+		claims[key] = value
+	}
+	claims["iss"] = i.issuer
+	claims["aud"] = []string{Audience}
+	claims["sub"] = subject
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(expiresIn).Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = keyId
+	return token.SignedString(i.key)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+func (i *Issuer) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	size := (i.key.Curve.Params().BitSize + 7) / 8
+	key := jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(i.key.X.FillBytes(make([]byte, size))),
+		Y:   base64.RawURLEncoding.EncodeToString(i.key.Y.FillBytes(make([]byte, size))),
+		Kid: keyId,
+		Alg: Algorithm,
+		Use: "sig",
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []jwk{key}})
+}
+
+type tokenRequest struct {
+	Subject string   `json:"sub"`
+	Groups  []string `json:"groups"`
+	// ExpiresIn is in seconds, one hour by default
+	ExpiresIn int `json:"expires_in"`
+}
+
+func (i *Issuer) serveToken(w http.ResponseWriter, r *http.Request) {
+	var request tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	if request.Subject == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "sub is required"})
+		return
+	}
+
+	expiresIn := defaultExpiration
+	if request.ExpiresIn > 0 {
+		expiresIn = time.Duration(request.ExpiresIn) * time.Second
+	}
+
+	extra := map[string]any{}
+	if len(request.Groups) > 0 {
+		extra["groups"] = request.Groups
+	}
+
+	token, err := i.Mint(request.Subject, expiresIn, extra)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(expiresIn.Seconds()),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,82 @@
+package devauth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/devauth"
+	"github.com/dreadster3/yapper/server/internal/platform/router/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestIssuer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	issuer, err := devauth.NewIssuer("127.0.0.1:0", zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+	go issuer.Run(ctx)
+
+	// The server validates the tokens the way it is configured in dev auth
+	jwtMiddleware, err := middleware.NewJWTMiddleware(middleware.JWTConfig{
+		JWKSUrl:  issuer.JWKSUrl(),
+		Issuer:   issuer.Issuer(),
+		Audience: devauth.Audience,
+	})
+	if err != nil {
+		t.Fatalf("NewJWTMiddleware() error = %v", err)
+	}
+	engine := gin.New()
+	engine.GET("/", jwtMiddleware.Middleware(zap.NewNop()), func(c *gin.Context) {
+		c.String(http.StatusOK, string(auth.GetUserIdFromContext(c)))
+	})
+
+	authenticate := func(token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	token, err := issuer.Mint("alice", time.Minute, nil)
+	if err != nil {
+		t.Fatalf("Mint() error = %v", err)
+	}
+	if recorder := authenticate(token); recorder.Code != http.StatusOK || recorder.Body.String() != "alice" {
+		t.Errorf("response = %d %s, want the minted token accepted", recorder.Code, recorder.Body)
+	}
+
+	response, err := http.Post(issuer.Issuer()+"/token", "application/json", bytes.NewBufferString(`{"sub":"bob"}`))
+	if err != nil {
+		t.Fatalf("POST /token error = %v", err)
+	}
+	defer response.Body.Close()
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding the token: %v", err)
+	}
+	if recorder := authenticate(body.AccessToken); recorder.Code != http.StatusOK || recorder.Body.String() != "bob" {
+		t.Errorf("response = %d %s, want the token of the endpoint accepted", recorder.Code, recorder.Body)
+	}
+
+	expired, err := issuer.Mint("alice", -time.Minute, nil)
+	if err != nil {
+		t.Fatalf("Mint() error = %v", err)
+	}
+	if recorder := authenticate(expired); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("response = %d, want the expired token rejected", recorder.Code)
+	}
+}
@@ -0,0 +1,186 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const keySize = 32
+
+var (
+	ErrNoMasterKey      = errors.New("no master key configured")
+	ErrInvalidMasterKey = errors.New("master key must be 32 bytes, base64 encoded")
+	ErrUnknownKey       = errors.New("envelope was sealed with another master key")
+	ErrMalformed        = errors.New("malformed envelope")
+)
+
+// Envelope is a value encrypted with its own data key, the data key being
+// encrypted (wrapped) with the master key. Only the envelope is stored.
+type Envelope struct {
+	// KeyId identifies the master key that wrapped the data key
+	KeyId      string
+	WrappedKey []byte
+	Ciphertext []byte
+}
+
+// Cipher seals values in envelopes. The associated data binds an envelope to
+// the record holding it, an envelope copied to another record does not open.
+type Cipher interface {
+	Seal(plaintext []byte, associatedData []byte) (*Envelope, error)
+	Open(envelope *Envelope, associatedData []byte) ([]byte, error)
+}
+
+// AssociatedData identifies a record, e.g. the profile and provider of a
+// secret. The parts are separated by a byte they cannot contain.
+func AssociatedData(parts ...string) []byte {
+	return []byte(strings.Join(parts, "\x00"))
+}
+
+type envelopeCipher struct {
+	keyId     string
+	masterKey cipher.AEAD
+}
+
+func NewEnvelopeCipher(masterKey []byte) (Cipher, error) {
+	if len(masterKey) != keySize {
+		return nil, ErrInvalidMasterKey
+	}
+
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(masterKey)
+	return &envelopeCipher{
+		keyId:     hex.EncodeToString(digest[:8]),
+		masterKey: aead,
+	}, nil
+}
+
+func (c *envelopeCipher) Seal(plaintext []byte, associatedData []byte) (*Envelope, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(dataAEAD, plaintext, associatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := seal(c.masterKey, dataKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		KeyId:      c.keyId,
+		WrappedKey: wrappedKey,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+func (c *envelopeCipher) Open(envelope *Envelope, associatedData []byte) ([]byte, error) {
+	if envelope.KeyId != c.keyId {
+		return nil, ErrUnknownKey
+	}
+
+	dataKey, err := open(c.masterKey, envelope.WrappedKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(dataAEAD, envelope.Ciphertext, associatedData)
+}
+
+type disabledCipher struct{}
+
+// NewDisabledCipher returns a cipher that refuses every operation, used when
+// no master key is configured
+func NewDisabledCipher() Cipher {
+	return disabledCipher{}
+}
+
+func (disabledCipher) Seal([]byte, []byte) (*Envelope, error) {
+	return nil, ErrNoMasterKey
+}
+
+func (disabledCipher) Open(*Envelope, []byte) ([]byte, error) {
+	return nil, ErrNoMasterKey
+}
+
+// LoadMasterKey decodes the base64 master key, read from the file when value
+// is empty. It returns ErrNoMasterKey when neither is set.
+func LoadMasterKey(value string, file string) ([]byte, error) {
+	if value == "" && file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("LoadMasterKey: %w", err)
+		}
+		value = string(content)
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, ErrNoMasterKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidMasterKey
+	}
+
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal prefixes the ciphertext with its random nonce
+func seal(aead cipher.AEAD, plaintext []byte, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, associatedData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,37 @@
+package encryption
+
+import "testing"
+
+func TestEnvelopeAssociatedData(t *testing.T) {
+	cipher, err := NewEnvelopeCipher(make([]byte, keySize))
+	if err != nil {
+		t.Fatalf("NewEnvelopeCipher() error = %v", err)
+	}
+
+	envelope, err := cipher.Seal([]byte("sk-secret"), AssociatedData("profile_secret", "profile", "openai"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	plaintext, err := cipher.Open(envelope, AssociatedData("profile_secret", "profile", "openai"))
+	if err != nil || string(plaintext) != "sk-secret" {
+		t.Fatalf("Open() = %q, %v, want %q", plaintext, err, "sk-secret")
+	}
+
+	tests := []struct {
+		name           string
+		associatedData []byte
+	}{
+		{"other profile", AssociatedData("profile_secret", "other", "openai")},
+		{"other provider", AssociatedData("profile_secret", "profile", "anthropic")},
+		{"none", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cipher.Open(envelope, tt.associatedData); err == nil {
+				t.Errorf("Open() opened an envelope sealed for another record")
+			}
+		})
+	}
+}
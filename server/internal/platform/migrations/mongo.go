@@ -0,0 +1,101 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	collectionName = "schema_migrations"
+	lockId         = "lock"
+)
+
+type appliedMigration struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+type lock struct {
+	Id       string    `bson:"_id"`
+	LockedAt time.Time `bson:"locked_at"`
+}
+
+// mongoStore records the migrations in a collection, the lock being a
+// document of the same collection
+type mongoStore struct {
+	db *mongo.Database
+}
+
+func NewMongoStore(db *mongo.Database) Store {
+	return &mongoStore{db: db}
+}
+
+func (s *mongoStore) Collection() *mongo.Collection {
+	return s.db.Collection(collectionName)
+}
+
+func (s *mongoStore) TryLock(ctx context.Context, staleBefore time.Time) (bool, error) {
+	stale := bson.M{"_id": lockId, "locked_at": bson.M{"$lt": staleBefore}}
+	if _, err := s.Collection().DeleteOne(ctx, stale); err != nil {
+		return false, err
+	}
+
+	_, err := s.Collection().InsertOne(ctx, lock{Id: lockId, LockedAt: time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (s *mongoStore) Unlock(ctx context.Context) error {
+	_, err := s.Collection().DeleteOne(ctx, bson.M{"_id": lockId})
+	return err
+}
+
+func (s *mongoStore) Applied(ctx context.Context) (map[int]time.Time, error) {
+	cursor, err := s.Collection().Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time, len(records))
+	for _, record := range records {
+		applied[record.Version] = record.AppliedAt
+	}
+
+	return applied, nil
+}
+
+func (s *mongoStore) Record(ctx context.Context, migration Migration, appliedAt time.Time) error {
+	_, err := s.Collection().InsertOne(ctx, appliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: appliedAt})
+	return err
+}
+
+// Atomically runs fn as is, index creation cannot be part of a transaction so
+// the Mongo migrations must be idempotent
+func (s *mongoStore) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// CreateIndexes returns a migration creating the indexes on the collection,
+// creating an index that already exists with the same options is a no-op
+func CreateIndexes(db *mongo.Database, collection string, indexes ...mongo.IndexModel) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes); err != nil {
+			return fmt.Errorf("creating indexes on %s: %w", collection, err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+// Package migrations applies versioned changes (indexes, tables, data fixes)
+// to the database, recording the applied versions in the database itself.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	lockRetryInterval = time.Second
+	// lockTimeout releases the lock of a runner that died while migrating
+	lockTimeout = 10 * time.Minute
+)
+
+var ErrDuplicateVersion = errors.New("duplicate migration version")
+
+// Migration must be idempotent unless the store applies it atomically, a
+// runner stopped before recording it applies it again
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context) error
+}
+
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Store keeps track of the applied migrations in the migrated database
+type Store interface {
+	// TryLock takes the migration lock, a lock taken before staleBefore is
+	// released first
+	TryLock(ctx context.Context, staleBefore time.Time) (bool, error)
+	Unlock(ctx context.Context) error
+	Applied(ctx context.Context) (map[int]time.Time, error)
+	Record(ctx context.Context, migration Migration, appliedAt time.Time) error
+	// Atomically runs fn, the migration and its record, in a transaction when
+	// the database supports it
+	Atomically(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type Runner struct {
+	store      Store
+	migrations []Migration
+	logger     *zap.Logger
+}
+
+func NewRunner(store Store, migrations []Migration, logger *zap.Logger) (*Runner, error) {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int { return a.Version - b.Version })
+
+	for idx := 1; idx < len(sorted); idx++ {
+		if sorted[idx].Version == sorted[idx-1].Version {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, sorted[idx].Version)
+		}
+	}
+
+	return &Runner{
+		store:      store,
+		migrations: sorted,
+		logger:     logger,
+	}, nil
+}
+
+// Up applies the pending migrations in order, holding a lock so concurrent
+// instances do not run them twice
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	if err := r.lock(ctx); err != nil {
+		return nil, fmt.Errorf("Runner.Up: %w", err)
+	}
+	defer r.unlock(context.WithoutCancel(ctx))
+
+	applied, err := r.store.Applied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Runner.Up: %w", err)
+	}
+
+	result := make([]Migration, 0)
+	for _, migration := range r.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		r.logger.Info("Applying migration", zap.Int("version", migration.Version), zap.String("name", migration.Name))
+		err := r.store.Atomically(ctx, func(ctx context.Context) error {
+			if err := migration.Up(ctx); err != nil {
+				return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+			}
+
+			if err := r.store.Record(ctx, migration, time.Now().UTC()); err != nil {
+				return fmt.Errorf("recording migration %d: %w", migration.Version, err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("Runner.Up: %w", err)
+		}
+
+		result = append(result, migration)
+	}
+
+	return result, nil
+}
+
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.store.Applied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Runner.Status: %w", err)
+	}
+
+	statuses := make([]Status, len(r.migrations))
+	for idx, migration := range r.migrations {
+		statuses[idx] = Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[idx].AppliedAt = &appliedAt
+		}
+	}
+
+	return statuses, nil
+}
+
+func (r *Runner) lock(ctx context.Context) error {
+	for {
+		locked, err := r.store.TryLock(ctx, time.Now().Add(-lockTimeout))
+		if err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+
+		r.logger.Info("Waiting for the migration lock")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func (r *Runner) unlock(ctx context.Context) {
+	if err := r.store.Unlock(ctx); err != nil {
+		r.logger.Error("Failed to release the migration lock", zap.Error(err))
+	}
+}
@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+func openSQLite(t *testing.T) *sqldb.DB {
+	t.Helper()
+
+	db, err := sqldb.Open(sqldb.SQLite{}, ":memory:")
+	if err != nil {
+		t.Fatalf("sqldb.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestNewRunnerDuplicateVersion(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	migrations := []Migration{{Version: 1, Name: "first", Up: up}, {Version: 2, Name: "second", Up: up}, {Version: 1, Name: "again", Up: up}}
+
+	if _, err := NewRunner(NewSQLStore(openSQLite(t)), migrations, zap.NewNop()); !errors.Is(err, ErrDuplicateVersion) {
+		t.Errorf("NewRunner() error = %v, want %v", err, ErrDuplicateVersion)
+	}
+}
+
+func TestRunnerUp(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	var order []int
+	migration := func(version int) Migration {
+		return Migration{Version: version, Name: "migration", Up: func(ctx context.Context) error {
+			order = append(order, version)
+			return nil
+		}}
+	}
+
+	runner, err := NewRunner(NewSQLStore(db), []Migration{migration(3), migration(1), migration(2)}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRunner() error = %v", err)
+	}
+	applied, err := runner.Up(ctx)
+	if err != nil || len(applied) != 3 {
+		t.Fatalf("Up() = %v, %v, want every migration applied", applied, err)
+	}
+	if want := []int{1, 2, 3}; !slices.Equal(order, want) {
+		t.Errorf("Up() applied %v, want %v", order, want)
+	}
+
+	// The applied migrations are skipped
+	runner, _ = NewRunner(NewSQLStore(db), []Migration{migration(1), migration(2), migration(3), migration(4)}, zap.NewNop())
+	if applied, err := runner.Up(ctx); err != nil || len(applied) != 1 || applied[0].Version != 4 {
+		t.Errorf("Up() again = %v, %v, want the new migration applied", applied, err)
+	}
+
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Status() = %+v, want the migration applied", status)
+		}
+	}
+}
+
+func TestRunnerLock(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	store := NewSQLStore(db)
+
+	var applied bool
+	migrations := []Migration{{Version: 1, Name: "migration", Up: func(ctx context.Context) error {
+		applied = true
+		return nil
+	}}}
+	runner, err := NewRunner(store, migrations, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRunner() error = %v", err)
+	}
+
+	// Another instance is migrating
+	if locked, err := store.TryLock(ctx, time.Now().Add(-lockTimeout)); err != nil || !locked {
+		t.Fatalf("TryLock() = %v, %v, want the lock", locked, err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := runner.Up(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Up() while locked error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if applied {
+		t.Errorf("Up() while locked applied the migration")
+	}
+
+	// The lock of a runner that died is taken over once stale
+	if _, err := db.ExecContext(ctx, "UPDATE schema_migrations_lock SET locked_at = ?", time.Now().Add(-2*lockTimeout).UTC()); err != nil {
+		t.Fatalf("aging the lock: %v", err)
+	}
+	if _, err := runner.Up(ctx); err != nil || !applied {
+		t.Fatalf("Up() over a stale lock error = %v, applied = %v", err, applied)
+	}
+
+	// The lock is released once migrated
+	if locked, err := store.TryLock(ctx, time.Now().Add(-lockTimeout)); err != nil || !locked {
+		t.Errorf("TryLock() after Up() = %v, %v, want the lock released", locked, err)
+	}
+}
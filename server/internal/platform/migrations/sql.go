@@ -0,0 +1,112 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+)
+
+// sqlStore records the migrations in a table, the lock being a single row
+// table
+type sqlStore struct {
+	db *sqldb.DB
+}
+
+func NewSQLStore(db *sqldb.DB) Store {
+	return &sqlStore{db: db}
+}
+
+func (s *sqlStore) init(ctx context.Context) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at {{timestamp}} NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id INTEGER PRIMARY KEY,
+			locked_at {{timestamp}} NOT NULL
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, s.db.Schema(statement)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlStore) TryLock(ctx context.Context, staleBefore time.Time) (bool, error) {
+	if err := s.init(ctx); err != nil {
+		return false, err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE locked_at < ?", staleBefore.UTC()); err != nil {
+		return false, err
+	}
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)", time.Now().UTC())
+	if s.db.IsUniqueViolation(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (s *sqlStore) Unlock(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM schema_migrations_lock")
+	return err
+}
+
+func (s *sqlStore) Applied(ctx context.Context) (map[int]time.Time, error) {
+	if err := s.init(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func (s *sqlStore) Record(ctx context.Context, migration Migration, appliedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", migration.Version, migration.Name, appliedAt.UTC())
+	return err
+}
+
+// Atomically runs fn in a transaction, Postgres and SQLite both roll back the
+// schema changes of a failed migration
+func (s *sqlStore) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.db.Do(ctx, fn)
+}
+
+// Exec returns a migration running the statements, the portable column types
+// are replaced with the ones of the dialect. The statements are run in the
+// transaction of the migration.
+func Exec(db *sqldb.DB, statements ...string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for _, statement := range statements {
+			if _, err := db.ExecContext(ctx, db.Schema(statement)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSQLStoreAtomically(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE items (id TEXT PRIMARY KEY)"); err != nil {
+		t.Fatalf("creating the table: %v", err)
+	}
+
+	crash := errors.New("crashed")
+	addColumn := Exec(db, `ALTER TABLE items ADD COLUMN name TEXT`)
+	migrations := []Migration{{
+		Version: 1,
+		Name:    "items_name",
+		Up: func(ctx context.Context) error {
+			if err := addColumn(ctx); err != nil {
+				return err
+			}
+
+			return crash
+		},
+	}}
+
+	runner, err := NewRunner(NewSQLStore(db), migrations, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRunner() error = %v", err)
+	}
+	if _, err := runner.Up(ctx); !errors.Is(err, crash) {
+		t.Fatalf("Up() error = %v, want %v", err, crash)
+	}
+
+	// The column added before the failure was rolled back, the migration
+	// applies again
+	migrations[0].Up = addColumn
+	runner, _ = NewRunner(NewSQLStore(db), migrations, zap.NewNop())
+	applied, err := runner.Up(ctx)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("Up() = %v, %v, want the migration applied", applied, err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO items (id, name) VALUES ('1', 'item')"); err != nil {
+		t.Errorf("the column was not added: %v", err)
+	}
+}
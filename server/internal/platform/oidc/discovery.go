@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// requestTimeout bounds the requests to the provider, startup and profile
+	// creation wait on them
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	ErrIssuerMismatch  = errors.New("discovered issuer does not match the configured one")
+	ErrNoUserInfo      = errors.New("the provider has no userinfo endpoint")
+	ErrSubjectMismatch = errors.New("userinfo subject does not match the token one")
+)
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// Configuration is the subset of the provider metadata the server uses
+type Configuration struct {
+	Issuer           string   `json:"issuer"`
+	JWKSURI          string   `json:"jwks_uri"`
+	UserInfoEndpoint string   `json:"userinfo_endpoint"`
+	Algorithms       []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// Discover fetches the provider metadata of the issuer
+func Discover(ctx context.Context, issuer string) (*Configuration, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	var config Configuration
+	if err := getJSON(ctx, issuer+discoveryPath, "", &config); err != nil {
+		return nil, fmt.Errorf("oidc.Discover: %w", err)
+	}
+
+	if strings.TrimSuffix(config.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc.Discover: %w: %s", ErrIssuerMismatch, config.Issuer)
+	}
+
+	if config.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc.Discover: jwks_uri missing from the provider metadata")
+	}
+
+	return &config, nil
+}
+
+func getJSON(ctx context.Context, url string, accessToken string, target any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("GET %s: %s: %s", url, response.Status, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
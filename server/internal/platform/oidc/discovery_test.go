@@ -0,0 +1,76 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newProvider serves the metadata returned by configure, called with the URL
+// of the provider
+func newProvider(t *testing.T, configure func(url string) map[string]any) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != discoveryPath {
+			http.NotFound(w, r)
+			return
+		}
+
+		json.NewEncoder(w).Encode(configure(server.URL))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDiscover(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("valid", func(t *testing.T) {
+		server := newProvider(t, func(url string) map[string]any {
+			return map[string]any{"issuer": url + "/", "jwks_uri": url + "/jwks", "userinfo_endpoint": url + "/userinfo"}
+		})
+
+		config, err := Discover(ctx, server.URL+"/")
+		if err != nil {
+			t.Fatalf("Discover() error = %v", err)
+		}
+		if config.JWKSURI != server.URL+"/jwks" || config.UserInfoEndpoint != server.URL+"/userinfo" {
+			t.Errorf("Discover() = %+v, want the provider metadata", config)
+		}
+	})
+
+	t.Run("issuer mismatch", func(t *testing.T) {
+		server := newProvider(t, func(url string) map[string]any {
+			return map[string]any{"issuer": "https://attacker.example.com", "jwks_uri": url + "/jwks"}
+		})
+
+		if _, err := Discover(ctx, server.URL); !errors.Is(err, ErrIssuerMismatch) {
+			t.Errorf("Discover() error = %v, want %v", err, ErrIssuerMismatch)
+		}
+	})
+
+	t.Run("no jwks", func(t *testing.T) {
+		server := newProvider(t, func(url string) map[string]any {
+			return map[string]any{"issuer": url}
+		})
+
+		if _, err := Discover(ctx, server.URL); err == nil || !strings.Contains(err.Error(), "jwks_uri") {
+			t.Errorf("Discover() error = %v, want the missing jwks_uri", err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		server := newProvider(t, nil)
+
+		if _, err := Discover(ctx, server.URL+"/realms/missing"); err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("Discover() error = %v, want the status of the response", err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+)
+
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+}
+
+// DisplayName is the first of name, preferred username and email set
+func (u UserInfo) DisplayName() string {
+	for _, name := range []string{u.Name, u.PreferredUsername, u.Email} {
+		if name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+type UserInfoClient struct {
+	endpoint string
+}
+
+func NewUserInfoClient(config *Configuration) (*UserInfoClient, error) {
+	if config.UserInfoEndpoint == "" {
+		return nil, ErrNoUserInfo
+	}
+
+	return &UserInfoClient{endpoint: config.UserInfoEndpoint}, nil
+}
+
+// Fetch returns the claims of the user of the access token, they are only
+// trusted when their subject is the one of the validated token
+func (c *UserInfoClient) Fetch(ctx context.Context, subject string, accessToken string) (*UserInfo, error) {
+	var userInfo UserInfo
+	if err := getJSON(ctx, c.endpoint, accessToken, &userInfo); err != nil {
+		return nil, fmt.Errorf("UserInfoClient.Fetch: %w", err)
+	}
+
+	if userInfo.Subject != subject {
+		return nil, fmt.Errorf("UserInfoClient.Fetch: %w: %s", ErrSubjectMismatch, userInfo.Subject)
+	}
+
+	return &userInfo, nil
+}
+
+// DisplayName fetches the name to show for the user of the access token
+func (c *UserInfoClient) DisplayName(ctx context.Context, userId auth.UserId, accessToken string) (string, error) {
+	userInfo, err := c.Fetch(ctx, string(userId), accessToken)
+	if err != nil {
+		return "", err
+	}
+
+	return userInfo.DisplayName(), nil
+}
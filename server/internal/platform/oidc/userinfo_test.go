@@ -0,0 +1,62 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserInfoClient(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer alice-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"sub": "alice", "preferred_username": "alice", "email": "alice@example.com"})
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := NewUserInfoClient(&Configuration{}); !errors.Is(err, ErrNoUserInfo) {
+		t.Errorf("NewUserInfoClient() error = %v, want %v", err, ErrNoUserInfo)
+	}
+
+	client, err := NewUserInfoClient(&Configuration{UserInfoEndpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewUserInfoClient() error = %v", err)
+	}
+
+	if name, err := client.DisplayName(ctx, "alice", "alice-token"); err != nil || name != "alice" {
+		t.Errorf("DisplayName() = %q, %v, want the preferred username", name, err)
+	}
+
+	// The access token of another user must not name the profile
+	if _, err := client.DisplayName(ctx, "bob", "alice-token"); !errors.Is(err, ErrSubjectMismatch) {
+		t.Errorf("DisplayName() of another subject error = %v, want %v", err, ErrSubjectMismatch)
+	}
+
+	if _, err := client.DisplayName(ctx, "alice", "expired"); err == nil {
+		t.Errorf("DisplayName() with a rejected token error = nil")
+	}
+}
+
+func TestUserInfoDisplayName(t *testing.T) {
+	tests := []struct {
+		userInfo UserInfo
+		want     string
+	}{
+		{userInfo: UserInfo{Name: "Alice", PreferredUsername: "alice", Email: "alice@example.com"}, want: "Alice"},
+		{userInfo: UserInfo{PreferredUsername: "alice", Email: "alice@example.com"}, want: "alice"},
+		{userInfo: UserInfo{Email: "alice@example.com"}, want: "alice@example.com"},
+		{userInfo: UserInfo{Subject: "alice"}, want: ""},
+	}
+	for _, test := range tests {
+		if name := test.userInfo.DisplayName(); name != test.want {
+			t.Errorf("%+v.DisplayName() = %q, want %q", test.userInfo, name, test.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultAnthropicURL = "https://api.anthropic.com"
+
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 8192
+)
+
+type anthropicProvider struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewAnthropicProvider(url string, apiKey string, client *http.Client, logger *zap.Logger) (Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if url == "" {
+		url = DefaultAnthropicURL
+	}
+
+	var provider Provider
+	provider = &anthropicProvider{
+		url:    strings.TrimSuffix(url, "/"),
+		apiKey: apiKey,
+		client: client,
+	}
+	provider = NewLoggingMiddleware(logger)(provider)
+	return provider, nil
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
+	if p.apiKey == "" {
+		return ErrMissingAPIKey
+	}
+
+	// System messages are not part of the conversation in the messages API
+	var system []string
+	mappedMessages := make([]anthropicMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.Role == RoleSystem {
+			system = append(system, message.Content)
+			continue
+		}
+
+		mappedMessages = append(mappedMessages, anthropicMessage{
+			Role:    message.Role.String(),
+			Content: message.Content,
+		})
+	}
+
+	request := map[string]any{
+		"model":      model,
+		"messages":   mappedMessages,
+		"max_tokens": anthropicMaxTokens,
+		"stream":     true,
+	}
+	if len(system) > 0 {
+		request["system"] = strings.Join(system, "\n\n")
+	}
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+
+	return postStream(ctx, p.client, p.url+"/v1/messages", headers, request, func(_ string, data []byte) error {
+		var event anthropicEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return err
+		}
+
+		switch event.Type {
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				return callback(assistantMessage(event.Delta.Text, false))
+			case "thinking_delta":
+				return callback(assistantMessage(event.Delta.Thinking, true))
+			}
+		case "error":
+			return &Error{Code: anthropicErrorCode(event.Error.Type), Message: event.Error.Message}
+		}
+
+		return nil
+	})
+}
+
+// anthropicErrorCode maps the type of the errors sent in the stream
+func anthropicErrorCode(errorType string) string {
+	switch errorType {
+	case "rate_limit_error":
+		return ErrorCodeRateLimited
+	case "overloaded_error":
+		return ErrorCodeUnavailable
+	case "api_error":
+		return ErrorCodeServerError
+	case "authentication_error", "permission_error":
+		return ErrorCodeUnauthorized
+	case "invalid_request_error", "not_found_error", "request_too_large":
+		return ErrorCodeInvalidRequest
+	default:
+		return ErrorCodeUnknown
+	}
+}
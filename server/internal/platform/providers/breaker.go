@@ -0,0 +1,160 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	// breakerHalfOpen lets a single trial request through
+	breakerHalfOpen
+)
+
+// CircuitBreaker stops sending requests to a backend after too many
+// consecutive failures, for the cooldown, then lets a trial request decide
+// whether it recovered. Only an unreachable or broken backend counts as a
+// failure, not the requests it refuses.
+type CircuitBreaker struct {
+	failures int
+	cooldown time.Duration
+
+	mutex       sync.Mutex
+	state       breakerState
+	consecutive int
+	openedAt    time.Time
+}
+
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		failures: failures,
+		cooldown: cooldown,
+	}
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the state with the outcome of an allowed request, a request
+// cancelled by the caller tells nothing about the backend
+func (b *CircuitBreaker) record(ctx context.Context, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// The cooldown is over, the next request is the trial
+		if b.state == breakerHalfOpen {
+			b.state = breakerOpen
+		}
+	case err != nil && breaksCircuit(err):
+		b.consecutive++
+		if b.state == breakerHalfOpen || b.consecutive >= b.failures {
+			b.state = breakerOpen
+			b.openedAt = time.Now()
+		}
+	default:
+		b.consecutive = 0
+		b.state = breakerClosed
+	}
+}
+
+func breaksCircuit(err error) bool {
+	code, _ := Classify(err)
+	return code == ErrorCodeTimeout || shouldFailover(err)
+}
+
+type circuitBreakerMiddleware struct {
+	breaker *CircuitBreaker
+	next    Provider
+}
+
+// NewCircuitBreakerMiddleware fails fast while the breaker is open, the error
+// is an unavailable provider Error matching ErrCircuitOpen. A trial request
+// closes the breaker as soon as it streams its first token.
+func NewCircuitBreakerMiddleware(breaker *CircuitBreaker) middleware {
+	return func(next Provider) Provider {
+		return &circuitBreakerMiddleware{
+			breaker: breaker,
+			next:    next,
+		}
+	}
+}
+
+func (m *circuitBreakerMiddleware) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
+	if !m.breaker.allow() {
+		return fmt.Errorf("%w: %w", ErrCircuitOpen, &Error{Code: ErrorCodeUnavailable, Message: ErrCircuitOpen.Error()})
+	}
+
+	// The first token shows the backend recovered, a half-open breaker does
+	// not hold the other requests back for the whole generation
+	streamed := false
+	err := m.next.Chat(ctx, model, messages, func(message Message) error {
+		if !streamed {
+			streamed = true
+			m.breaker.record(ctx, nil)
+		}
+
+		return callback(message)
+	})
+	m.breaker.record(ctx, err)
+	return err
+}
+
+// Breakers holds the circuit breakers shared by the providers reaching the
+// same backend, e.g. the ones built for each request with a profile's own key.
+// A breaker is keyed by the provider name and its policy, a provider whose
+// policy changed starts with a new one.
+type Breakers struct {
+	mutex sync.Mutex
+	byKey map[breakerKey]*CircuitBreaker
+}
+
+type breakerKey struct {
+	name     string
+	failures int
+	cooldown time.Duration
+}
+
+func NewBreakers() *Breakers {
+	return &Breakers{byKey: make(map[breakerKey]*CircuitBreaker)}
+}
+
+// Get returns the breaker of the provider, creating it on first use
+func (b *Breakers) Get(name string, policy Policy) *CircuitBreaker {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	key := breakerKey{name: name, failures: policy.BreakerFailures, cooldown: policy.BreakerCooldown}
+	breaker, ok := b.byKey[key]
+	if !ok {
+		breaker = NewCircuitBreaker(policy.BreakerFailures, policy.BreakerCooldown)
+		b.byKey[key] = breaker
+	}
+
+	return breaker
+}
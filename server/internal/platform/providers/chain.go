@@ -0,0 +1,112 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var ErrInvalidChain = errors.New("invalid provider chain")
+
+// Target is a provider, and the model, of a chain. An empty model uses the
+// requested one.
+type Target struct {
+	Provider string
+	Model    string
+}
+
+func (t Target) String() string {
+	if t.Model == "" {
+		return t.Provider
+	}
+
+	return t.Provider + ":" + t.Model
+}
+
+// ParseChains parses the chains in the name=provider:model,provider:model
+// format, chains are separated by semicolons. The model is split at the
+// first colon, so it can contain colons itself (e.g. ollama:llama3.1:8b).
+func ParseChains(value string) (map[string][]Target, error) {
+	chains := map[string][]Target{}
+	for _, definition := range strings.Split(value, ";") {
+		definition = strings.TrimSpace(definition)
+		if definition == "" {
+			continue
+		}
+
+		name, targets, ok := strings.Cut(definition, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidChain, definition)
+		}
+
+		if _, ok := chains[name]; ok {
+			return nil, fmt.Errorf("%w: %s is defined twice", ErrInvalidChain, name)
+		}
+
+		for _, target := range strings.Split(targets, ",") {
+			provider, model, _ := strings.Cut(strings.TrimSpace(target), ":")
+			if provider == "" {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidChain, definition)
+			}
+
+			chains[name] = append(chains[name], Target{Provider: provider, Model: model})
+		}
+	}
+
+	return chains, nil
+}
+
+type chainResolver struct {
+	registry *Registry
+	next     Resolver
+	logger   *zap.Logger
+}
+
+// NewChainResolver resolves the chains of the registry to a failover provider
+// over their targets, the targets are resolved by next like any other
+// provider
+func NewChainResolver(registry *Registry, next Resolver, logger *zap.Logger) Resolver {
+	return &chainResolver{
+		registry: registry,
+		next:     next,
+		logger:   logger,
+	}
+}
+
+func (r *chainResolver) Resolve(ctx context.Context, scope Scope, name string, model string) (Provider, error) {
+	targets, ok := r.registry.Chain(name)
+	if !ok {
+		return r.next.Resolve(ctx, scope, name, model)
+	}
+
+	// A target that cannot be used (e.g. the model is not allowed) is left
+	// out, the chain only fails when none of them can
+	var firstErr error
+	backends := make([]Backend, 0, len(targets))
+	for _, target := range targets {
+		if target.Model == "" {
+			target.Model = model
+		}
+
+		provider, err := r.next.Resolve(ctx, scope, target.Provider, target.Model)
+		if err != nil {
+			r.logger.Debug("Skipping chain target", zap.String("chain", name), zap.Stringer("target", target), zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		backends = append(backends, Backend{Name: target.Provider, Model: target.Model, Provider: provider})
+	}
+
+	if len(backends) == 0 {
+		return nil, firstErr
+	}
+
+	return NewFailoverProvider(backends, r.logger.With(zap.String("chain", name))), nil
+}
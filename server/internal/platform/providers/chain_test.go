@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestParseChains(t *testing.T) {
+	chains, err := ParseChains(" local = ollama:llama3.1:8b, openai:gpt-4o-mini ; any=ollama;")
+	if err != nil {
+		t.Fatalf("ParseChains() error = %v", err)
+	}
+
+	want := map[string][]Target{
+		"local": {{Provider: "ollama", Model: "llama3.1:8b"}, {Provider: "openai", Model: "gpt-4o-mini"}},
+		"any":   {{Provider: "ollama"}},
+	}
+	if !reflect.DeepEqual(chains, want) {
+		t.Errorf("ParseChains() = %v, want %v", chains, want)
+	}
+
+	for _, value := range []string{"ollama:llama", "=ollama", "local=ollama,,openai", "a=ollama;a=openai"} {
+		if _, err := ParseChains(value); !errors.Is(err, ErrInvalidChain) {
+			t.Errorf("ParseChains(%q) error = %v, want %v", value, err, ErrInvalidChain)
+		}
+	}
+}
+
+func TestChainResolver(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry(zap.NewNop())
+	if err := registry.Register("ollama", Config{Type: TypeOllama, URL: "http://localhost:11434", AllowedModels: []string{"llama"}}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := registry.RegisterChain("ollama", []Target{{Provider: "ollama"}}); !errors.Is(err, ErrInvalidChain) {
+		t.Errorf("RegisterChain() over a provider error = %v, want %v", err, ErrInvalidChain)
+	}
+	if err := registry.RegisterChain("local", []Target{{Provider: "missing", Model: "llama"}, {Provider: "ollama", Model: "llama"}}); err != nil {
+		t.Fatalf("RegisterChain() error = %v", err)
+	}
+	if err := registry.RegisterChain("forbidden", []Target{{Provider: "ollama", Model: "mistral"}}); err != nil {
+		t.Fatalf("RegisterChain() error = %v", err)
+	}
+
+	resolver := NewChainResolver(registry, NewRegistryResolver(registry), zap.NewNop())
+
+	provider, err := resolver.Resolve(ctx, Scope{}, "local", "")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if backends := provider.(*failoverProvider).backends; len(backends) != 1 || backends[0].Name != "ollama" || backends[0].Model != "llama" {
+		t.Errorf("Resolve() backends = %+v, want the targets that can be used", backends)
+	}
+
+	if _, err := resolver.Resolve(ctx, Scope{}, "forbidden", ""); !errors.Is(err, ErrModelNotAllowed) {
+		t.Errorf("Resolve() of a chain without usable targets error = %v, want %v", err, ErrModelNotAllowed)
+	}
+
+	if provider, err := resolver.Resolve(ctx, Scope{}, "ollama", "llama"); err != nil || provider == nil {
+		t.Errorf("Resolve() of a provider = %v, %v, want the registered provider", provider, err)
+	}
+}
@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	neturl "net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrAddressNotAllowed = errors.New("provider address is not allowed")
+
+// Egress restricts the backends reachable by the providers users configure
+// (e.g. in a workspace), so they cannot make the server send requests to its
+// own network. Private, loopback and link local addresses are always refused.
+type Egress struct {
+	// AllowedHosts are the hosts that can be reached, a leading "*." matches
+	// the subdomains. Any public host is allowed when empty.
+	AllowedHosts []string
+}
+
+// CheckURL checks the URL of a provider before it is saved or used, the
+// address it resolves to is checked again when connecting
+func (e Egress) CheckURL(url string) error {
+	parsed, err := neturl.Parse(url)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrAddressNotAllowed, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrAddressNotAllowed, parsed.Scheme)
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublic(addr) {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, host)
+	}
+	if !e.allowsHost(host) {
+		return fmt.Errorf("%w: %s is not in the allowed hosts", ErrAddressNotAllowed, host)
+	}
+
+	return nil
+}
+
+func (e Egress) allowsHost(host string) bool {
+	if len(e.AllowedHosts) == 0 {
+		return true
+	}
+
+	for _, allowed := range e.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasSuffix(host, suffix) {
+			return true
+		}
+		if host == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Client returns an HTTP client refusing to connect to non public addresses,
+// whatever the host name resolves to. Proxies are not used, they would
+// connect on its behalf.
+func (e Egress) Client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !isPublic(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrAddressNotAllowed, address)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Transport: transport}
+}
+
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
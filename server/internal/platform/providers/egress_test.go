@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEgressCheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		egress  Egress
+		url     string
+		wantErr bool
+	}{
+		{"public host", Egress{}, "https://api.openai.com/v1", false},
+		{"public address", Egress{}, "http://8.8.8.8:11434", false},
+		{"loopback", Egress{}, "http://127.0.0.1:11434", true},
+		{"localhost", Egress{}, "http://localhost:11434", true},
+		{"private", Egress{}, "http://10.0.0.5", true},
+		{"link local", Egress{}, "http://169.254.169.254/latest/meta-data", true},
+		{"mapped loopback", Egress{}, "http://[::ffff:127.0.0.1]", true},
+		{"scheme", Egress{}, "file:///etc/passwd", true},
+		{"allowed host", Egress{AllowedHosts: []string{"api.openai.com"}}, "https://api.openai.com/v1", false},
+		{"allowed subdomain", Egress{AllowedHosts: []string{"*.example.com"}}, "https://llm.example.com", false},
+		{"not allowed", Egress{AllowedHosts: []string{"*.example.com"}}, "https://example.org", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.egress.CheckURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckURL(%q) error = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrAddressNotAllowed) {
+				t.Errorf("CheckURL(%q) error = %v, want %v", tt.url, err, ErrAddressNotAllowed)
+			}
+		})
+	}
+}
+
+func TestEgressClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The host name of a public looking URL can resolve to a private address
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+	if _, err := (Egress{}).Client().Do(request); !errors.Is(err, ErrAddressNotAllowed) {
+		t.Errorf("Do() error = %v, want %v", err, ErrAddressNotAllowed)
+	}
+}
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Normalized error codes, they do not depend on the provider type
+const (
+	ErrorCodeRateLimited    = "rate_limited"
+	ErrorCodeUnauthorized   = "unauthorized"
+	ErrorCodeInvalidRequest = "invalid_request"
+	ErrorCodeServerError    = "server_error"
+	ErrorCodeUnavailable    = "unavailable"
+	ErrorCodeTimeout        = "timeout"
+	ErrorCodeCanceled       = "canceled"
+	ErrorCodeUnknown        = "unknown"
+)
+
+// Error is a request refused, or failed, by the provider. It matches
+// ErrProviderResponse.
+type Error struct {
+	// StatusCode is the HTTP status of the response, zero when the error was
+	// reported in the stream
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func newStatusError(statusCode int, message string) *Error {
+	code := ErrorCodeUnknown
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		code = ErrorCodeRateLimited
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		code = ErrorCodeUnauthorized
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		code = ErrorCodeTimeout
+	case statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable:
+		code = ErrorCodeUnavailable
+	case statusCode >= http.StatusInternalServerError:
+		code = ErrorCodeServerError
+	case statusCode >= http.StatusBadRequest:
+		code = ErrorCodeInvalidRequest
+	}
+
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+
+	return &Error{StatusCode: statusCode, Code: code, Message: truncate(message, maxErrorMessage)}
+}
+
+// maxErrorMessage bounds the provider messages reported to the users
+const maxErrorMessage = 512
+
+func truncate(message string, size int) string {
+	message = strings.TrimSpace(message)
+	if len(message) <= size {
+		return message
+	}
+
+	return strings.ToValidUTF8(message[:size], "") + "…"
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: %d %s: %s", ErrProviderResponse, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", ErrProviderResponse, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrProviderResponse
+}
+
+// Retryable reports whether the same request may succeed later
+func (e *Error) Retryable() bool {
+	switch e.Code {
+	case ErrorCodeRateLimited, ErrorCodeServerError, ErrorCodeUnavailable, ErrorCodeTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Classify returns the normalized code of an error returned by Chat, and
+// whether retrying the request may succeed
+func Classify(err error) (code string, retryable bool) {
+	var providerErr *Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &providerErr):
+		return providerErr.Code, providerErr.Retryable()
+	case errors.Is(err, ErrMissingAPIKey):
+		return ErrorCodeUnauthorized, false
+	case errors.Is(err, ErrModelNotAllowed), errors.Is(err, ErrAddressNotAllowed):
+		return ErrorCodeInvalidRequest, false
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCodeTimeout, true
+	case errors.Is(err, context.Canceled):
+		return ErrorCodeCanceled, true
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ErrorCodeTimeout, true
+		}
+		return ErrorCodeUnavailable, true
+	default:
+		return ErrorCodeUnknown, false
+	}
+}
+
+// errorMessages describe the errors that did not come from the provider, their
+// text can hold internal addresses
+var errorMessages = map[string]string{
+	ErrorCodeRateLimited:    "provider rate limit reached",
+	ErrorCodeUnauthorized:   "provider refused the credentials",
+	ErrorCodeInvalidRequest: "request not allowed",
+	ErrorCodeServerError:    "provider failed",
+	ErrorCodeUnavailable:    "provider unavailable",
+	ErrorCodeTimeout:        "provider timed out",
+	ErrorCodeCanceled:       "request canceled",
+	ErrorCodeUnknown:        "provider request failed",
+}
+
+// ErrorMessage returns the message reported by the provider, or the one of
+// the error code when the error did not come from the provider
+func ErrorMessage(err error) string {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.Message
+	}
+
+	code, _ := Classify(err)
+	return errorMessages[code]
+}
@@ -0,0 +1,100 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      string
+		retryable bool
+	}{
+		{"rate limited", newStatusError(http.StatusTooManyRequests, "slow down"), ErrorCodeRateLimited, true},
+		{"unauthorized", newStatusError(http.StatusUnauthorized, "bad key"), ErrorCodeUnauthorized, false},
+		{"invalid request", newStatusError(http.StatusNotFound, "no such model"), ErrorCodeInvalidRequest, false},
+		{"server error", newStatusError(http.StatusInternalServerError, "oops"), ErrorCodeServerError, true},
+		{"unavailable", newStatusError(http.StatusServiceUnavailable, "overloaded"), ErrorCodeUnavailable, true},
+		{"stream error", &Error{Code: anthropicErrorCode("overloaded_error"), Message: "overloaded"}, ErrorCodeUnavailable, true},
+		{"wrapped", fmt.Errorf("chat: %w", newStatusError(http.StatusBadGateway, "")), ErrorCodeUnavailable, true},
+		{"missing key", ErrMissingAPIKey, ErrorCodeUnauthorized, false},
+		{"deadline", context.DeadlineExceeded, ErrorCodeTimeout, true},
+		{"connection refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorCodeUnavailable, true},
+		{"unknown", errors.New("model not found"), ErrorCodeUnknown, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, retryable := Classify(tt.err)
+			if code != tt.code || retryable != tt.retryable {
+				t.Errorf("Classify() = %q, %v, want %q, %v", code, retryable, tt.code, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	dialErr := &url.Error{Op: "Post", URL: "http://10.0.0.12:11434/api/chat", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"provider message", newStatusError(http.StatusNotFound, "no such model"), "no such model"},
+		{"network error", fmt.Errorf("chat: %w", dialErr), "provider unavailable"},
+		{"deadline", context.DeadlineExceeded, "provider timed out"},
+		{"model not allowed", fmt.Errorf("%w: llama", ErrModelNotAllowed), "request not allowed"},
+		{"unknown", errors.New("read http://10.0.0.12:11434: broken pipe"), "provider request failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorMessage(tt.err); got != tt.want {
+				t.Errorf("ErrorMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostStreamStatusError(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantMessage string
+	}{
+		{"openai", `{"error": {"message": "slow down", "type": "rate_limit"}}`, "slow down"},
+		{"ollama", `{"error": "slow down"}`, "slow down"},
+		// The body of an unknown server is not reflected
+		{"other", "<html>internal dashboard</html>", "Too Many Requests"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, tt.body, http.StatusTooManyRequests)
+			}))
+			defer server.Close()
+
+			err := postStream(context.Background(), server.Client(), server.URL, nil, map[string]any{}, func(string, []byte) error {
+				return nil
+			})
+
+			var providerErr *Error
+			if !errors.As(err, &providerErr) || providerErr.StatusCode != http.StatusTooManyRequests || providerErr.Message != tt.wantMessage {
+				t.Fatalf("postStream() error = %v, want the status error with %q", err, tt.wantMessage)
+			}
+			if !errors.Is(err, ErrProviderResponse) {
+				t.Errorf("postStream() error = %v, want it to match %v", err, ErrProviderResponse)
+			}
+		})
+	}
+}
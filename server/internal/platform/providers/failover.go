@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Backend is a concrete provider, and the model, a failover provider can send
+// a request to
+type Backend struct {
+	Name     string
+	Model    string
+	Provider Provider
+}
+
+type failoverProvider struct {
+	backends []Backend
+	logger   *zap.Logger
+}
+
+// NewFailoverProvider sends the requests to the first backend, moving on to the
+// next one when it cannot be reached, fails or times out before streaming
+// anything. The requested model is ignored, every backend uses its own.
+func NewFailoverProvider(backends []Backend, logger *zap.Logger) Provider {
+	return &failoverProvider{
+		backends: backends,
+		logger:   logger,
+	}
+}
+
+func (p *failoverProvider) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
+	var err error
+	for idx, backend := range p.backends {
+		recordRoute(ctx, backend.Name, backend.Model)
+
+		streamed := false
+		err = backend.Provider.Chat(ctx, backend.Model, messages, func(m Message) error {
+			streamed = true
+			return callback(m)
+		})
+
+		// Content already sent to the client cannot be taken back
+		if err == nil || streamed || ctx.Err() != nil || !shouldFailover(err) {
+			return err
+		}
+
+		if idx < len(p.backends)-1 {
+			p.logger.Warn("Provider failed, trying the next one",
+				zap.String("backend", backend.Name),
+				zap.String("model", backend.Model),
+				zap.String("next", p.backends[idx+1].Name),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return err
+}
+
+// shouldFailover reports whether the backend is unreachable, broken or too
+// slow, as opposed to refusing the request
+func shouldFailover(err error) bool {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		// e.g. the idle timeout expired before the first token
+		if providerErr.Code == ErrorCodeTimeout {
+			return true
+		}
+
+		if providerErr.StatusCode != 0 {
+			return providerErr.StatusCode >= http.StatusInternalServerError
+		}
+
+		return providerErr.Code == ErrorCodeServerError || providerErr.Code == ErrorCodeUnavailable
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+type routeContextKey struct{}
+
+// Route is the concrete provider and model that served a chat request
+type Route struct {
+	Provider string
+	Model    string
+}
+
+// WithRoute returns a context recording the backend picked by the providers
+// that route requests (e.g. failover), the route stays empty otherwise
+func WithRoute(ctx context.Context) (context.Context, *Route) {
+	route := &Route{}
+	return context.WithValue(ctx, routeContextKey{}, route), route
+}
+
+func recordRoute(ctx context.Context, provider string, model string) {
+	if route, ok := ctx.Value(routeContextKey{}).(*Route); ok {
+		route.Provider = provider
+		route.Model = model
+	}
+}
@@ -0,0 +1,102 @@
+package providers_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/platform/providers/providerstest"
+	"go.uber.org/zap"
+)
+
+func TestFailoverProvider(t *testing.T) {
+	unavailable := &providers.Error{StatusCode: http.StatusServiceUnavailable, Code: providers.ErrorCodeUnavailable, Message: "overloaded"}
+	refused := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	invalid := &providers.Error{StatusCode: http.StatusBadRequest, Code: providers.ErrorCodeInvalidRequest, Message: "bad request"}
+	timeout := &providers.Error{Code: providers.ErrorCodeTimeout, Message: "no token received for 30s"}
+
+	tests := []struct {
+		name      string
+		primary   *providerstest.Provider
+		wantErr   error
+		wantRoute providers.Route
+		wantCalls int
+	}{
+		{"primary serves", providerstest.New(providerstest.Text("primary")), nil, providers.Route{Provider: "primary", Model: "small"}, 0},
+		{"server error", &providerstest.Provider{Err: unavailable}, nil, providers.Route{Provider: "fallback", Model: "large"}, 1},
+		{"connection refused", &providerstest.Provider{Err: refused}, nil, providers.Route{Provider: "fallback", Model: "large"}, 1},
+		{"timeout before streaming", &providerstest.Provider{Err: timeout}, nil, providers.Route{Provider: "fallback", Model: "large"}, 1},
+		{"timeout after streaming", &providerstest.Provider{Chunks: []providers.Message{providerstest.Text("partial")}, Err: timeout}, timeout, providers.Route{Provider: "primary", Model: "small"}, 0},
+		{"refused request", &providerstest.Provider{Err: invalid}, invalid, providers.Route{Provider: "primary", Model: "small"}, 0},
+		{"failed after streaming", &providerstest.Provider{Chunks: []providers.Message{providerstest.Text("partial")}, Err: unavailable}, unavailable, providers.Route{Provider: "primary", Model: "small"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := providerstest.New(providerstest.Text("fallback"))
+			provider := providers.NewFailoverProvider([]providers.Backend{
+				{Name: "primary", Model: "small", Provider: tt.primary},
+				{Name: "fallback", Model: "large", Provider: fallback},
+			}, zap.NewNop())
+
+			ctx, route := providers.WithRoute(context.Background())
+			err := provider.Chat(ctx, "ignored", nil, func(providers.Message) error { return nil })
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Chat() error = %v, want %v", err, tt.wantErr)
+			}
+			if *route != tt.wantRoute {
+				t.Errorf("route = %+v, want %+v", *route, tt.wantRoute)
+			}
+
+			calls := fallback.Calls()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("fallback calls = %d, want %d", len(calls), tt.wantCalls)
+			}
+			if len(calls) > 0 && calls[0].Model != "large" {
+				t.Errorf("fallback model = %q, want the backend model", calls[0].Model)
+			}
+		})
+	}
+}
+
+func TestFailoverProviderIdleTimeout(t *testing.T) {
+	stalled := &providerstest.Provider{Chunks: []providers.Message{providerstest.Text("late")}, Delay: time.Second}
+	fallback := providerstest.New(providerstest.Text("fallback"))
+	provider := providers.NewFailoverProvider([]providers.Backend{
+		{Name: "primary", Model: "small", Provider: providers.NewTimeoutMiddleware(0, 20*time.Millisecond)(stalled)},
+		{Name: "fallback", Model: "large", Provider: fallback},
+	}, zap.NewNop())
+
+	var received []string
+	ctx, route := providers.WithRoute(context.Background())
+	err := provider.Chat(ctx, "ignored", nil, func(m providers.Message) error {
+		received = append(received, m.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if route.Provider != "fallback" || len(received) != 1 || received[0] != "fallback" {
+		t.Errorf("Chat() route = %+v, received %v, want the fallback response", *route, received)
+	}
+}
+
+func TestFailoverProviderExhausted(t *testing.T) {
+	last := &providers.Error{StatusCode: http.StatusBadGateway, Code: providers.ErrorCodeUnavailable, Message: "bad gateway"}
+	provider := providers.NewFailoverProvider([]providers.Backend{
+		{Name: "primary", Model: "small", Provider: &providerstest.Provider{Err: &providers.Error{StatusCode: http.StatusInternalServerError, Code: providers.ErrorCodeServerError}}},
+		{Name: "fallback", Model: "large", Provider: &providerstest.Provider{Err: last}},
+	}, zap.NewNop())
+
+	ctx, route := providers.WithRoute(context.Background())
+	if err := provider.Chat(ctx, "ignored", nil, func(providers.Message) error { return nil }); !errors.Is(err, last) {
+		t.Errorf("Chat() error = %v, want the error of the last backend", err)
+	}
+	if route.Provider != "fallback" {
+		t.Errorf("route = %+v, want the last backend tried", *route)
+	}
+}
@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrMissingAPIKey    = errors.New("provider requires an API key")
+	ErrProviderResponse = errors.New("provider returned an error")
+)
+
+// errorMessage extracts the message of an error response, {"error": "..."},
+// {"error": {"message": "..."}} or {"message": "..."}. Other bodies are not
+// reported, the server could be anything.
+func errorMessage(body []byte) string {
+	var payload struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+
+	var message string
+	if err := json.Unmarshal(payload.Error, &message); err == nil {
+		return message
+	}
+
+	var nested struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(payload.Error, &nested); err == nil && nested.Message != "" {
+		return nested.Message
+	}
+
+	return payload.Message
+}
+
+// postStream sends the JSON body and reads the server sent events of the
+// response, calling onEvent with the event name and data of each of them
+func postStream(ctx context.Context, client *http.Client, url string, headers map[string]string, body any, onEvent func(event string, data []byte) error) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "text/event-stream")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return newStatusError(response.StatusCode, errorMessage(body))
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	event := ""
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				if err := onEvent(event, data.Bytes()); err != nil {
+					return err
+				}
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if data.Len() > 0 {
+		return onEvent(event, data.Bytes())
+	}
+
+	return nil
+}
@@ -2,6 +2,9 @@ package providers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -24,8 +27,116 @@ func NewLoggingMiddleware(logger *zap.Logger) middleware {
 
 func (m *loggingMiddleware) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) (err error) {
 	defer func() {
+		// The users only get a generic message, the cause is kept in the logs
+		if err != nil && ctx.Err() == nil {
+			m.logger.Warn("Chat failed", zap.String("model", model), zap.Error(err))
+			return
+		}
+
 		m.logger.Debug("Chat", zap.String("model", model), zap.Objects("messages", messages), zap.Error(err))
 	}()
 
 	return m.next.Chat(ctx, model, messages, callback)
 }
+
+type retryMiddleware struct {
+	retries int
+	backoff time.Duration
+	logger  *zap.Logger
+	next    Provider
+}
+
+// NewRetryMiddleware retries the requests failing with a transient error
+// before anything was streamed, waiting backoff then doubling it between
+// attempts
+func NewRetryMiddleware(retries int, backoff time.Duration, logger *zap.Logger) middleware {
+	return func(next Provider) Provider {
+		return &retryMiddleware{
+			retries: retries,
+			backoff: backoff,
+			logger:  logger,
+			next:    next,
+		}
+	}
+}
+
+func (m *retryMiddleware) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
+	for attempt := 0; ; attempt++ {
+		streamed := false
+		err := m.next.Chat(ctx, model, messages, func(message Message) error {
+			streamed = true
+			return callback(message)
+		})
+		if err == nil || streamed || ctx.Err() != nil || attempt >= m.retries {
+			return err
+		}
+
+		if _, retryable := Classify(err); !retryable {
+			return err
+		}
+
+		delay := m.backoff << attempt
+		m.logger.Warn("Chat failed, retrying", zap.Int("attempt", attempt+1), zap.Duration("delay", delay), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+type timeoutMiddleware struct {
+	timeout     time.Duration
+	idleTimeout time.Duration
+	next        Provider
+}
+
+// NewTimeoutMiddleware fails the requests taking longer than timeout, or
+// waiting longer than idleTimeout for the next token (the first one included).
+// A zero duration disables the matching timeout.
+func NewTimeoutMiddleware(timeout time.Duration, idleTimeout time.Duration) middleware {
+	return func(next Provider) Provider {
+		return &timeoutMiddleware{
+			timeout:     timeout,
+			idleTimeout: idleTimeout,
+			next:        next,
+		}
+	}
+}
+
+func (m *timeoutMiddleware) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
+	requestCtx := ctx
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if m.timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, m.timeout, &Error{Code: ErrorCodeTimeout, Message: fmt.Sprintf("no response within %s", m.timeout)})
+		defer stop()
+	}
+
+	var idle *time.Timer
+	if m.idleTimeout > 0 {
+		idle = time.AfterFunc(m.idleTimeout, func() {
+			cancel(&Error{Code: ErrorCodeTimeout, Message: fmt.Sprintf("no token received for %s", m.idleTimeout)})
+		})
+		defer idle.Stop()
+	}
+
+	err := m.next.Chat(ctx, model, messages, func(message Message) error {
+		if idle != nil {
+			idle.Reset(m.idleTimeout)
+		}
+		return callback(message)
+	})
+
+	// The provider only sees a cancelled context, the timeout is the reason
+	var timeoutErr *Error
+	if err != nil && requestCtx.Err() == nil && errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+
+	return err
+}
@@ -0,0 +1,222 @@
+package providers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/platform/providers/providerstest"
+	"go.uber.org/zap"
+)
+
+// flakyProvider returns the errors in order, one per request, then streams a
+// single chunk
+type flakyProvider struct {
+	mutex sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (p *flakyProvider) Chat(ctx context.Context, model string, messages []providers.Message, callback providers.MessageCallback) error {
+	p.mutex.Lock()
+	p.calls++
+	var err error
+	if len(p.errs) > 0 {
+		err, p.errs = p.errs[0], p.errs[1:]
+	}
+	p.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return callback(providerstest.Text("ok"))
+}
+
+func chat(provider providers.Provider) error {
+	return provider.Chat(context.Background(), "model", nil, func(providers.Message) error { return nil })
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	unavailable := &providers.Error{StatusCode: http.StatusServiceUnavailable, Code: providers.ErrorCodeUnavailable}
+	invalid := &providers.Error{StatusCode: http.StatusBadRequest, Code: providers.ErrorCodeInvalidRequest}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{"succeeds", nil, nil, 1},
+		{"transient errors", []error{unavailable, unavailable}, nil, 3},
+		{"retries exhausted", []error{unavailable, unavailable, unavailable}, unavailable, 3},
+		{"refused request", []error{invalid}, invalid, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &flakyProvider{errs: tt.errs}
+			provider := providers.NewRetryMiddleware(2, time.Millisecond, zap.NewNop())(next)
+
+			if err := chat(provider); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Chat() error = %v, want %v", err, tt.wantErr)
+			}
+			if next.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", next.calls, tt.wantCalls)
+			}
+		})
+	}
+
+	t.Run("failed after streaming", func(t *testing.T) {
+		next := &providerstest.Provider{Chunks: []providers.Message{providerstest.Text("partial")}, Err: unavailable}
+		provider := providers.NewRetryMiddleware(2, time.Millisecond, zap.NewNop())(next)
+
+		if err := chat(provider); !errors.Is(err, unavailable) || len(next.Calls()) != 1 {
+			t.Errorf("Chat() error = %v after %d calls, want the streamed request not to be retried", err, len(next.Calls()))
+		}
+	})
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		idleTimeout time.Duration
+		wantTimeout bool
+	}{
+		{"in time", 200 * time.Millisecond, 50 * time.Millisecond, false},
+		{"idle", 0, 5 * time.Millisecond, true},
+		{"request", 25 * time.Millisecond, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every chunk arrives in time, the whole response does not
+			next := &providerstest.Provider{Delay: 10 * time.Millisecond}
+			for range 5 {
+				next.Chunks = append(next.Chunks, providerstest.Text("token"))
+			}
+			provider := providers.NewTimeoutMiddleware(tt.timeout, tt.idleTimeout)(next)
+
+			err := chat(provider)
+			if code, _ := providers.Classify(err); tt.wantTimeout != (code == providers.ErrorCodeTimeout) {
+				t.Errorf("Chat() error = %v, want timeout %v", err, tt.wantTimeout)
+			}
+		})
+	}
+
+	t.Run("cancelled by the caller", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		provider := providers.NewTimeoutMiddleware(time.Minute, time.Minute)(providerstest.New(providerstest.Text("token")))
+		if err := provider.Chat(ctx, "model", nil, func(providers.Message) error { return nil }); !errors.Is(err, context.Canceled) {
+			t.Errorf("Chat() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestCircuitBreakerMiddleware(t *testing.T) {
+	unavailable := &providers.Error{StatusCode: http.StatusServiceUnavailable, Code: providers.ErrorCodeUnavailable}
+	invalid := &providers.Error{StatusCode: http.StatusBadRequest, Code: providers.ErrorCodeInvalidRequest}
+
+	cooldown := 20 * time.Millisecond
+	next := &flakyProvider{errs: []error{unavailable, invalid, unavailable, unavailable, unavailable}}
+	provider := providers.NewCircuitBreakerMiddleware(providers.NewCircuitBreaker(2, cooldown))(next)
+
+	// A refused request shows the backend is up, it resets the failures
+	for _, want := range []error{unavailable, invalid, unavailable, unavailable} {
+		if err := chat(provider); !errors.Is(err, want) {
+			t.Fatalf("Chat() error = %v, want %v", err, want)
+		}
+	}
+
+	err := chat(provider)
+	if !errors.Is(err, providers.ErrCircuitOpen) || next.calls != 4 {
+		t.Fatalf("Chat() error = %v after %d calls, want the open circuit to fail fast", err, next.calls)
+	}
+	if code, retryable := providers.Classify(err); code != providers.ErrorCodeUnavailable || !retryable {
+		t.Errorf("Classify() = %q, %v, want a retryable unavailable provider", code, retryable)
+	}
+
+	// The trial request fails, the circuit opens again
+	time.Sleep(cooldown)
+	if err := chat(provider); !errors.Is(err, unavailable) {
+		t.Fatalf("Chat() error = %v, want the trial request to reach the backend", err)
+	}
+	if err := chat(provider); !errors.Is(err, providers.ErrCircuitOpen) {
+		t.Fatalf("Chat() error = %v, want %v", err, providers.ErrCircuitOpen)
+	}
+
+	// The trial request succeeds, the circuit closes
+	time.Sleep(cooldown)
+	for range 2 {
+		if err := chat(provider); err != nil {
+			t.Fatalf("Chat() error = %v, want the circuit to be closed", err)
+		}
+	}
+}
+
+// stallingProvider fails the first request, then streams a chunk and waits for
+// release before ending the second one
+type stallingProvider struct {
+	mutex   sync.Mutex
+	calls   int
+	release chan struct{}
+}
+
+func (p *stallingProvider) Chat(ctx context.Context, model string, messages []providers.Message, callback providers.MessageCallback) error {
+	p.mutex.Lock()
+	p.calls++
+	call := p.calls
+	p.mutex.Unlock()
+
+	if call == 1 {
+		return &providers.Error{StatusCode: http.StatusServiceUnavailable, Code: providers.ErrorCodeUnavailable}
+	}
+
+	if err := callback(providerstest.Text("ok")); err != nil {
+		return err
+	}
+	if call == 2 {
+		<-p.release
+	}
+
+	return nil
+}
+
+func TestCircuitBreakerMiddlewareTrialFirstToken(t *testing.T) {
+	cooldown := 20 * time.Millisecond
+	next := &stallingProvider{release: make(chan struct{})}
+	provider := providers.NewCircuitBreakerMiddleware(providers.NewCircuitBreaker(1, cooldown))(next)
+
+	if err := chat(provider); err == nil {
+		t.Fatalf("Chat() error = nil, want the failure opening the circuit")
+	}
+	time.Sleep(cooldown)
+
+	// The trial request streams its first token, then keeps generating
+	streaming := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		var once sync.Once
+		done <- provider.Chat(context.Background(), "model", nil, func(providers.Message) error {
+			once.Do(func() { close(streaming) })
+			return nil
+		})
+	}()
+	<-streaming
+
+	if err := chat(provider); err != nil {
+		t.Errorf("Chat() error = %v during the trial, want the circuit closed by its first token", err)
+	}
+
+	close(next.release)
+	if err := <-done; err != nil {
+		t.Errorf("Chat() error = %v, want the trial request to succeed", err)
+	}
+}
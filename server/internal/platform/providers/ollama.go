@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	neturl "net/url"
 
@@ -13,20 +14,38 @@ type ollamaProvider struct {
 	client *api.Client
 }
 
-func NewOllamaProvider(url string, logger *zap.Logger) (Provider, error) {
-	parsedURL, err := neturl.Parse(url)
+func NewOllamaProvider(url string, apiKey string, httpClient *http.Client, logger *zap.Logger) (Provider, error) {
+	client, err := newOllamaClient(url, apiKey, httpClient)
 	if err != nil {
 		return nil, err
 	}
 
-	client := api.NewClient(parsedURL, http.DefaultClient)
-
 	var provider Provider
 	provider = &ollamaProvider{client: client}
 	provider = NewLoggingMiddleware(logger)(provider)
 	return provider, nil
 }
 
+func newOllamaClient(url string, apiKey string, httpClient *http.Client) (*api.Client, error) {
+	parsedURL, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if apiKey != "" {
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient = &http.Client{Transport: &bearerTransport{token: apiKey, next: next}}
+	}
+
+	return api.NewClient(parsedURL, httpClient), nil
+}
+
 func (p *ollamaProvider) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
 	mappedMessages := make([]api.Message, len(messages))
 	for i, message := range messages {
@@ -49,7 +68,7 @@ func (p *ollamaProvider) Chat(ctx context.Context, model string, messages []Mess
 
 	thinking := false
 
-	return p.client.Chat(ctx, request, func(response api.ChatResponse) error {
+	err := p.client.Chat(ctx, request, func(response api.ChatResponse) error {
 		content := response.Message.Content
 		if content == "<think>" {
 			thinking = true
@@ -70,4 +89,27 @@ func (p *ollamaProvider) Chat(ctx context.Context, model string, messages []Mess
 
 		return callback(unmappedMessage)
 	})
+
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		message := statusErr.ErrorMessage
+		if message == "" {
+			message = statusErr.Status
+		}
+		return newStatusError(statusErr.StatusCode, message)
+	}
+
+	return err
+}
+
+// bearerTransport authenticates requests to ollama instances behind a proxy
+type bearerTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.Header.Set("Authorization", "Bearer "+t.token)
+	return t.next.RoundTrip(request)
 }
@@ -0,0 +1,111 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const DefaultOpenAIURL = "https://api.openai.com/v1"
+
+type openAIProvider struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewOpenAIProvider(url string, apiKey string, client *http.Client, logger *zap.Logger) (Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if url == "" {
+		url = DefaultOpenAIURL
+	}
+
+	var provider Provider
+	provider = &openAIProvider{
+		url:    strings.TrimSuffix(url, "/"),
+		apiKey: apiKey,
+		client: client,
+	}
+	provider = NewLoggingMiddleware(logger)(provider)
+	return provider, nil
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+			// Reasoning content is sent by compatible servers serving thinking models
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
+	if p.apiKey == "" {
+		return ErrMissingAPIKey
+	}
+
+	mappedMessages := make([]openAIMessage, len(messages))
+	for i, message := range messages {
+		mappedMessages[i] = openAIMessage{
+			Role:    message.Role.String(),
+			Content: message.Content,
+		}
+	}
+
+	request := map[string]any{
+		"model":    model,
+		"messages": mappedMessages,
+		"stream":   true,
+	}
+	headers := map[string]string{
+		"Authorization": "Bearer " + p.apiKey,
+	}
+
+	return postStream(ctx, p.client, p.url+"/chat/completions", headers, request, func(_ string, data []byte) error {
+		if string(data) == "[DONE]" {
+			return nil
+		}
+
+		var chunk openAIChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return err
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.ReasoningContent != "" {
+				if err := callback(assistantMessage(choice.Delta.ReasoningContent, true)); err != nil {
+					return err
+				}
+			}
+
+			if choice.Delta.Content != "" {
+				if err := callback(assistantMessage(choice.Delta.Content, false)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func assistantMessage(content string, thinking bool) Message {
+	return Message{
+		Role:    RoleAssistant,
+		Content: content,
+		Metadata: map[string]any{
+			ThinkMetadataKey: thinking,
+		},
+	}
+}
@@ -0,0 +1,111 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrInvalidPolicy = errors.New("invalid provider policy")
+
+// Policy configures how the requests to a provider are retried, timed out and
+// cut off when it is unhealthy. Zero values disable the matching middleware.
+type Policy struct {
+	// Retries is the number of attempts made after the first one fails
+	Retries int
+	// Backoff is the wait before the first retry, doubled for each next one
+	Backoff time.Duration
+	// Timeout bounds every attempt, a long generation is cut off by it. It is
+	// disabled by default, the idle timeout catches the stalled generations.
+	Timeout time.Duration
+	// IdleTimeout bounds the wait for each token, model loading included
+	IdleTimeout time.Duration
+	// BreakerFailures is the number of consecutive failures opening the
+	// circuit breaker
+	BreakerFailures int
+	// BreakerCooldown is how long the circuit stays open
+	BreakerCooldown time.Duration
+}
+
+var DefaultPolicy = Policy{
+	Retries:         2,
+	Backoff:         500 * time.Millisecond,
+	IdleTimeout:     2 * time.Minute,
+	BreakerFailures: 5,
+	BreakerCooldown: 30 * time.Second,
+}
+
+// apply wraps the provider with the middlewares of the policy. The circuit
+// breaker is outermost, so an open circuit is not retried, and every retry
+// gets its own timeout.
+func (p Policy) apply(provider Provider, breaker func() *CircuitBreaker, logger *zap.Logger) Provider {
+	if p.Timeout > 0 || p.IdleTimeout > 0 {
+		provider = NewTimeoutMiddleware(p.Timeout, p.IdleTimeout)(provider)
+	}
+	if p.Retries > 0 {
+		provider = NewRetryMiddleware(p.Retries, p.Backoff, logger)(provider)
+	}
+	if p.BreakerFailures > 0 {
+		provider = NewCircuitBreakerMiddleware(breaker())(provider)
+	}
+
+	return provider
+}
+
+// ParsePolicies parses the policies in the
+// name=retries:2,backoff:500ms,timeout:10m,idle-timeout:2m,breaker-failures:5,breaker-cooldown:30s
+// format, policies are separated by semicolons. The options left out keep
+// their DefaultPolicy value.
+func ParsePolicies(value string) (map[string]Policy, error) {
+	policies := map[string]Policy{}
+	for _, definition := range strings.Split(value, ";") {
+		definition = strings.TrimSpace(definition)
+		if definition == "" {
+			continue
+		}
+
+		name, options, ok := strings.Cut(definition, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPolicy, definition)
+		}
+
+		policy := DefaultPolicy
+		for _, option := range strings.Split(options, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(option), ":")
+			if err := policy.set(key, value); err != nil {
+				return nil, fmt.Errorf("%w: %s: %w", ErrInvalidPolicy, name, err)
+			}
+		}
+
+		policies[name] = policy
+	}
+
+	return policies, nil
+}
+
+func (p *Policy) set(key string, value string) error {
+	var err error
+	switch key {
+	case "retries":
+		p.Retries, err = strconv.Atoi(value)
+	case "backoff":
+		p.Backoff, err = time.ParseDuration(value)
+	case "timeout":
+		p.Timeout, err = time.ParseDuration(value)
+	case "idle-timeout":
+		p.IdleTimeout, err = time.ParseDuration(value)
+	case "breaker-failures":
+		p.BreakerFailures, err = strconv.Atoi(value)
+	case "breaker-cooldown":
+		p.BreakerCooldown, err = time.ParseDuration(value)
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+
+	return err
+}
@@ -0,0 +1,34 @@
+package providers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies("ollama=retries:0,idle-timeout:5m ; openai=breaker-failures:3,breaker-cooldown:1m,backoff:1s,timeout:1h")
+	if err != nil {
+		t.Fatalf("ParsePolicies() error = %v", err)
+	}
+
+	ollama := DefaultPolicy
+	ollama.Retries = 0
+	ollama.IdleTimeout = 5 * time.Minute
+	openai := DefaultPolicy
+	openai.BreakerFailures = 3
+	openai.BreakerCooldown = time.Minute
+	openai.Backoff = time.Second
+	openai.Timeout = time.Hour
+	want := map[string]Policy{"ollama": ollama, "openai": openai}
+	if !reflect.DeepEqual(policies, want) {
+		t.Errorf("ParsePolicies() = %+v, want %+v", policies, want)
+	}
+
+	for _, value := range []string{"ollama", "ollama=retries", "ollama=retries:x", "ollama=timeout:1", "ollama=unknown:1"} {
+		if _, err := ParsePolicies(value); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("ParsePolicies(%q) error = %v, want %v", value, err, ErrInvalidPolicy)
+		}
+	}
+}
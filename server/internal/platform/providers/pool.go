@@ -0,0 +1,336 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"go.uber.org/zap"
+)
+
+// Strategies picking the instance of a pool serving a request
+const (
+	BalancingRoundRobin    = "round-robin"
+	BalancingLeastInFlight = "least-in-flight"
+	// BalancingModelAware prefers the instances that already have the model
+	// loaded, the least busy one otherwise
+	BalancingModelAware = "model-aware"
+)
+
+const (
+	poolCheckInterval = 15 * time.Second
+	poolCheckTimeout  = 5 * time.Second
+	// poolEjectFailures is the number of consecutive failed requests taking an
+	// instance that can still be reached out of the pool
+	poolEjectFailures = 3
+)
+
+var ErrUnknownBalancing = errors.New("unknown balancing strategy")
+
+// BackendStats are the metrics of an instance of a pool
+type BackendStats struct {
+	URL          string    `json:"url"`
+	Healthy      bool      `json:"healthy"`
+	InFlight     int64     `json:"in_flight"`
+	Requests     int64     `json:"requests"`
+	Failures     int64     `json:"failures"`
+	LoadedModels []string  `json:"loaded_models"`
+	CheckedAt    time.Time `json:"checked_at"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+type poolBackend struct {
+	url      string
+	client   *api.Client
+	provider Provider
+
+	inFlight atomic.Int64
+	requests atomic.Int64
+	failures atomic.Int64
+	// consecutive counts the failed requests since the last successful one
+	consecutive atomic.Int64
+
+	mutex     sync.RWMutex
+	healthy   bool
+	models    []string
+	checkedAt time.Time
+	lastError string
+}
+
+func (b *poolBackend) isHealthy() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.healthy
+}
+
+func (b *poolBackend) hasModel(model string) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return slices.Contains(b.models, model)
+}
+
+func (b *poolBackend) setHealth(models []string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.healthy = err == nil
+	b.checkedAt = time.Now()
+	b.lastError = ""
+	if err != nil {
+		b.lastError = err.Error()
+		return
+	}
+	b.models = models
+	b.consecutive.Store(0)
+}
+
+func (b *poolBackend) stats() BackendStats {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return BackendStats{
+		URL:          b.url,
+		Healthy:      b.healthy,
+		InFlight:     b.inFlight.Load(),
+		Requests:     b.requests.Load(),
+		Failures:     b.failures.Load(),
+		LoadedModels: append([]string{}, b.models...),
+		CheckedAt:    b.checkedAt,
+		LastError:    b.lastError,
+	}
+}
+
+// Pool spreads the chat requests over several ollama instances. Instances
+// are checked periodically by Run, and taken out of the pool as soon as a
+// request cannot connect to them, or after poolEjectFailures consecutive
+// failed requests. A single bad request does not drain the pool.
+type Pool struct {
+	backends  []*poolBackend
+	balancing string
+	next      atomic.Uint64
+	logger    *zap.Logger
+}
+
+func NewOllamaPool(urls []string, apiKey string, balancing string, logger *zap.Logger) (*Pool, error) {
+	switch balancing {
+	case BalancingRoundRobin, BalancingLeastInFlight, BalancingModelAware:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBalancing, balancing)
+	}
+
+	pool := &Pool{balancing: balancing, logger: logger}
+	for _, url := range urls {
+		client, err := newOllamaClient(url, apiKey, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var provider Provider
+		provider = &ollamaProvider{client: client}
+		provider = NewLoggingMiddleware(logger.With(zap.String("url", url)))(provider)
+
+		// Instances are healthy until proven otherwise, requests are served
+		// before the first check completes
+		pool.backends = append(pool.backends, &poolBackend{
+			url:      url,
+			client:   client,
+			provider: provider,
+			healthy:  true,
+		})
+	}
+
+	return pool, nil
+}
+
+// Run checks the instances until ctx is done, refreshing the models they
+// have loaded
+func (p *Pool) Run(ctx context.Context) {
+	ticker := time.NewTicker(poolCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check checks every instance once
+func (p *Pool) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, backend := range p.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, poolCheckTimeout)
+			defer cancel()
+
+			running, err := backend.client.ListRunning(ctx)
+			if err != nil {
+				if backend.isHealthy() {
+					p.logger.Warn("Ollama instance is unhealthy", zap.String("url", backend.url), zap.Error(err))
+				}
+				backend.setHealth(nil, err)
+				return
+			}
+
+			models := make([]string, 0, len(running.Models))
+			for _, model := range running.Models {
+				models = append(models, model.Name)
+			}
+			backend.setHealth(models, nil)
+		}()
+	}
+	wg.Wait()
+}
+
+// Stats returns the metrics of every instance, in configuration order
+func (p *Pool) Stats() []BackendStats {
+	stats := make([]BackendStats, len(p.backends))
+	for idx, backend := range p.backends {
+		stats[idx] = backend.stats()
+	}
+
+	return stats
+}
+
+func (p *Pool) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
+	return p.chat(ctx, model, messages, callback, func(backend *poolBackend) Provider {
+		return backend.provider
+	})
+}
+
+// WithAPIKey returns a provider sending the requests with the API key, the
+// instances are picked by the pool and share its health and metrics
+func (p *Pool) WithAPIKey(apiKey string, httpClient *http.Client) (Provider, error) {
+	keyed := &keyedPool{pool: p, providers: make(map[*poolBackend]Provider, len(p.backends))}
+	for _, backend := range p.backends {
+		client, err := newOllamaClient(backend.url, apiKey, httpClient)
+		if err != nil {
+			return nil, err
+		}
+
+		var provider Provider
+		provider = &ollamaProvider{client: client}
+		provider = NewLoggingMiddleware(p.logger.With(zap.String("url", backend.url)))(provider)
+		keyed.providers[backend] = provider
+	}
+
+	return keyed, nil
+}
+
+type keyedPool struct {
+	pool      *Pool
+	providers map[*poolBackend]Provider
+}
+
+func (k *keyedPool) Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error {
+	return k.pool.chat(ctx, model, messages, callback, func(backend *poolBackend) Provider {
+		return k.providers[backend]
+	})
+}
+
+func (p *Pool) chat(ctx context.Context, model string, messages []Message, callback MessageCallback, provider func(*poolBackend) Provider) error {
+	backend := p.pick(model)
+	if backend == nil {
+		return &Error{Code: ErrorCodeUnavailable, Message: "no healthy ollama instance"}
+	}
+
+	backend.inFlight.Add(1)
+	backend.requests.Add(1)
+	defer backend.inFlight.Add(-1)
+
+	err := provider(backend).Chat(ctx, model, messages, callback)
+	switch {
+	case err == nil:
+		backend.consecutive.Store(0)
+
+		// The model stays loaded after serving the request
+		backend.mutex.Lock()
+		if !slices.Contains(backend.models, ollamaModelName(model)) {
+			backend.models = append(backend.models, ollamaModelName(model))
+		}
+		backend.mutex.Unlock()
+	case ctx.Err() == nil:
+		backend.failures.Add(1)
+		if !shouldFailover(err) {
+			break
+		}
+
+		var opErr *net.OpError
+		if consecutive := backend.consecutive.Add(1); errors.As(err, &opErr) || consecutive >= poolEjectFailures {
+			p.logger.Warn("Ollama instance is unhealthy", zap.String("url", backend.url), zap.Int64("consecutive_failures", consecutive), zap.Error(err))
+			backend.setHealth(nil, err)
+		}
+	}
+
+	return err
+}
+
+func (p *Pool) pick(model string) *poolBackend {
+	candidates := make([]*poolBackend, 0, len(p.backends))
+	for _, backend := range p.backends {
+		if backend.isHealthy() {
+			candidates = append(candidates, backend)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// The starting point rotates so ties are spread evenly
+	start := int(p.next.Add(1)-1) % len(candidates)
+	candidates = append(candidates[start:], candidates[:start]...)
+
+	switch p.balancing {
+	case BalancingLeastInFlight:
+		return leastInFlight(candidates)
+	case BalancingModelAware:
+		name := ollamaModelName(model)
+		loaded := slices.DeleteFunc(slices.Clone(candidates), func(backend *poolBackend) bool {
+			return !backend.hasModel(name)
+		})
+		if len(loaded) > 0 {
+			return leastInFlight(loaded)
+		}
+		return leastInFlight(candidates)
+	default:
+		return candidates[0]
+	}
+}
+
+func leastInFlight(backends []*poolBackend) *poolBackend {
+	best := backends[0]
+	for _, backend := range backends[1:] {
+		if backend.inFlight.Load() < best.inFlight.Load() {
+			best = backend
+		}
+	}
+
+	return best
+}
+
+// ollamaModelName adds the default tag, ollama reports the loaded models with
+// their tag
+func ollamaModelName(model string) string {
+	if strings.Contains(model, ":") {
+		return model
+	}
+
+	return model + ":latest"
+}
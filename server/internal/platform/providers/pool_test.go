@@ -0,0 +1,220 @@
+package providers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"go.uber.org/zap"
+)
+
+// ollamaServer fakes an ollama instance with the given models loaded
+type ollamaServer struct {
+	*httptest.Server
+	chats atomic.Int64
+	// authorization is the header of the last chat request
+	authorization atomic.Value
+	// status fails the chat requests with it, when set
+	status atomic.Int64
+}
+
+func newOllamaServer(t *testing.T, models ...string) *ollamaServer {
+	t.Helper()
+
+	server := &ollamaServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/ps", func(w http.ResponseWriter, r *http.Request) {
+		running := []map[string]string{}
+		for _, model := range models {
+			running = append(running, map[string]string{"name": model, "model": model})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"models": running})
+	})
+	mux.HandleFunc("POST /api/chat", func(w http.ResponseWriter, r *http.Request) {
+		server.chats.Add(1)
+		server.authorization.Store(r.Header.Get("Authorization"))
+		if status := server.status.Load(); status != 0 {
+			http.Error(w, `{}`, int(status))
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		json.NewEncoder(w).Encode(map[string]any{"message": map[string]string{"role": "assistant", "content": "hi"}, "done": true})
+	})
+
+	server.Server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaPoolBalancing(t *testing.T) {
+	t.Run("round robin", func(t *testing.T) {
+		first, second := newOllamaServer(t), newOllamaServer(t)
+		pool, err := providers.NewOllamaPool([]string{first.URL, second.URL}, "", providers.BalancingRoundRobin, zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewOllamaPool() error = %v", err)
+		}
+
+		for range 4 {
+			if err := chat(pool); err != nil {
+				t.Fatalf("Chat() error = %v", err)
+			}
+		}
+		if first.chats.Load() != 2 || second.chats.Load() != 2 {
+			t.Errorf("chats = %d, %d, want the requests spread evenly", first.chats.Load(), second.chats.Load())
+		}
+	})
+
+	t.Run("model aware", func(t *testing.T) {
+		cold, warm := newOllamaServer(t), newOllamaServer(t, "model:latest")
+		pool, err := providers.NewOllamaPool([]string{cold.URL, warm.URL}, "", providers.BalancingModelAware, zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewOllamaPool() error = %v", err)
+		}
+		pool.Check(context.Background())
+
+		for range 3 {
+			if err := chat(pool); err != nil {
+				t.Fatalf("Chat() error = %v", err)
+			}
+		}
+		if cold.chats.Load() != 0 || warm.chats.Load() != 3 {
+			t.Errorf("chats = %d, %d, want the instance with the model loaded to serve them", cold.chats.Load(), warm.chats.Load())
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := providers.NewOllamaPool(nil, "", "random", zap.NewNop()); !errors.Is(err, providers.ErrUnknownBalancing) {
+			t.Errorf("NewOllamaPool() error = %v, want %v", err, providers.ErrUnknownBalancing)
+		}
+	})
+}
+
+func TestOllamaPoolHealth(t *testing.T) {
+	healthy, down := newOllamaServer(t), newOllamaServer(t)
+	down.Close()
+
+	pool, err := providers.NewOllamaPool([]string{down.URL, healthy.URL}, "", providers.BalancingRoundRobin, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOllamaPool() error = %v", err)
+	}
+
+	// The unreachable instance is taken out of the pool by the first failure
+	var failures int
+	for range 4 {
+		if err := chat(pool); err != nil {
+			failures++
+		}
+	}
+	if failures != 1 || healthy.chats.Load() != 3 {
+		t.Errorf("failures = %d, chats = %d, want a single request sent to the unreachable instance", failures, healthy.chats.Load())
+	}
+
+	stats := pool.Stats()
+	if stats[0].Healthy || stats[0].Failures != 1 || stats[0].LastError == "" {
+		t.Errorf("Stats() = %+v, want the unreachable instance unhealthy", stats[0])
+	}
+	if !stats[1].Healthy || stats[1].Requests != 3 || stats[1].InFlight != 0 {
+		t.Errorf("Stats() = %+v, want the healthy instance to have served 3 requests", stats[1])
+	}
+
+	pool.Check(context.Background())
+	if stats := pool.Stats(); stats[0].Healthy || !stats[1].Healthy || stats[1].CheckedAt.IsZero() {
+		t.Errorf("Stats() after Check = %+v", stats)
+	}
+
+	// No instance left
+	healthy.Close()
+	pool.Check(context.Background())
+	if err := chat(pool); !errors.Is(err, providers.ErrProviderResponse) {
+		t.Errorf("Chat() error = %v, want no healthy instance", err)
+	}
+	if code, retryable := providers.Classify(chat(pool)); code != providers.ErrorCodeUnavailable || !retryable {
+		t.Errorf("Classify() = %q, %v, want a retryable unavailable provider", code, retryable)
+	}
+}
+
+func TestOllamaPoolConsecutiveFailures(t *testing.T) {
+	server := newOllamaServer(t)
+	pool, err := providers.NewOllamaPool([]string{server.URL}, "", providers.BalancingRoundRobin, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOllamaPool() error = %v", err)
+	}
+
+	fail := func(times int) {
+		t.Helper()
+
+		server.status.Store(http.StatusInternalServerError)
+		for range times {
+			if err := chat(pool); !errors.Is(err, providers.ErrProviderResponse) {
+				t.Fatalf("Chat() error = %v, want the instance to fail", err)
+			}
+		}
+		server.status.Store(0)
+	}
+
+	// A successful request resets the failures
+	fail(2)
+	if err := chat(pool); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	fail(2)
+	if stats := pool.Stats(); !stats[0].Healthy || stats[0].Failures != 4 {
+		t.Fatalf("Stats() = %+v, want the instance kept after 2 consecutive failures", stats[0])
+	}
+
+	fail(1)
+	if stats := pool.Stats(); stats[0].Healthy || stats[0].LastError == "" {
+		t.Errorf("Stats() = %+v, want the instance taken out after 3 consecutive failures", stats[0])
+	}
+}
+
+func TestOllamaPoolRegistry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, second := newOllamaServer(t), newOllamaServer(t)
+	pool, err := providers.NewOllamaPool([]string{first.URL, second.URL}, "", providers.BalancingRoundRobin, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOllamaPool() error = %v", err)
+	}
+
+	registry := providers.NewRegistry(zap.NewNop())
+	registry.RegisterPool(ctx, "ollama", providers.Config{Type: providers.TypeOllama, URL: first.URL}, pool)
+
+	if err := registry.Register("ollama", providers.Config{Type: providers.TypeOllama, URL: first.URL}); !errors.Is(err, providers.ErrProviderPooled) {
+		t.Errorf("Register() over a pool error = %v, want %v", err, providers.ErrProviderPooled)
+	}
+
+	// The providers built from the configuration, e.g. with a profile's key,
+	// are served by the pool
+	config, _ := registry.Config("ollama")
+	config.APIKey = "profile-key"
+	provider, err := providers.NewProvider(config, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	for range 2 {
+		if err := chat(provider); err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+	}
+	if first.chats.Load() != 1 || second.chats.Load() != 1 {
+		t.Errorf("chats = %d, %d, want the requests spread over the pool", first.chats.Load(), second.chats.Load())
+	}
+	if authorization := second.authorization.Load(); authorization != "Bearer profile-key" {
+		t.Errorf("Authorization = %v, want the profile's key", authorization)
+	}
+	if stats := pool.Stats(); stats[0].Requests+stats[1].Requests != 2 {
+		t.Errorf("Stats() = %+v, want the requests counted by the pool", stats)
+	}
+
+	registry.Remove("ollama")
+	if _, ok := registry.Pool("ollama"); ok {
+		t.Errorf("Pool() after Remove() kept the pool")
+	}
+}
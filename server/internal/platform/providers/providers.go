@@ -2,6 +2,10 @@ package providers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
 
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
@@ -30,21 +34,107 @@ type Provider interface {
 	Chat(ctx context.Context, model string, messages []Message, callback MessageCallback) error
 }
 
-func SetupProviders(ollamUrl string, logger *zap.Logger) (map[string]Provider, error) {
-	providers := make(map[string]Provider)
+const (
+	TypeOllama    = "ollama"
+	TypeOpenAI    = "openai"
+	TypeAnthropic = "anthropic"
+	// TypeChain is reported for the chains, they are not built from a
+	// configuration
+	TypeChain = "chain"
+)
 
-	ollamaProvider, err := NewOllamaProvider(ollamUrl, logger.With(zap.String("provider", "ollama")))
+var (
+	ErrProviderNotFound = errors.New("provider not found")
+	ErrUnsupportedType  = errors.New("unsupported provider type")
+	ErrModelNotAllowed  = errors.New("model is not allowed for this provider")
+	// ErrNameInUse is returned when registering a provider under the name of
+	// a chain, or the other way around
+	ErrNameInUse = errors.New("name is already used by a provider or chain")
+	// ErrProviderPooled is returned when replacing a load balanced provider,
+	// the pool is configured on startup
+	ErrProviderPooled = errors.New("provider is load balanced")
+)
+
+// Config describes how to reach a provider, it is used to build providers
+// outside of the global ones (e.g. per workspace)
+type Config struct {
+	Type          string
+	URL           string
+	APIKey        string
+	AllowedModels []string
+	Policy        Policy
+	// Breaker is shared with the other providers of the backend, the provider
+	// gets its own when nil
+	Breaker *CircuitBreaker
+	// Pool serves the requests of an ollama provider over its instances,
+	// with the API key of the configuration
+	Pool *Pool
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// AllowsModel reports whether the model can be used, an empty allow list
+// allows every model
+func (c Config) AllowsModel(model string) bool {
+	return len(c.AllowedModels) == 0 || slices.Contains(c.AllowedModels, model)
+}
+
+// NewProvider builds the provider wrapped with the middlewares of its policy
+func NewProvider(config Config, logger *zap.Logger) (Provider, error) {
+	var provider Provider
+	var err error
+	switch config.Type {
+	case TypeOllama:
+		if config.Pool != nil {
+			provider, err = config.Pool.WithAPIKey(config.APIKey, config.HTTPClient)
+			break
+		}
+		provider, err = NewOllamaProvider(config.URL, config.APIKey, config.HTTPClient, logger)
+	case TypeOpenAI:
+		provider, err = NewOpenAIProvider(config.URL, config.APIKey, config.HTTPClient, logger)
+	case TypeAnthropic:
+		provider, err = NewAnthropicProvider(config.URL, config.APIKey, config.HTTPClient, logger)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, config.Type)
+	}
 	if err != nil {
 		return nil, err
 	}
-	providers["ollama"] = ollamaProvider
 
-	return providers, nil
+	breaker := func() *CircuitBreaker {
+		if config.Breaker != nil {
+			return config.Breaker
+		}
+
+		return NewCircuitBreaker(config.Policy.BreakerFailures, config.Policy.BreakerCooldown)
+	}
+
+	return config.Policy.apply(provider, breaker, logger), nil
 }
 
-func ValidateRegisteredProvider(providers map[string]Provider) validator.Func {
+// SetupProviders builds the server default providers, keyed by name. The
+// providers without a policy use DefaultPolicy.
+func SetupProviders(configs map[string]Config, policies map[string]Policy, logger *zap.Logger) (*Registry, error) {
+	registry := NewRegistry(logger)
+	registry.SetPolicies(policies)
+
+	for name, config := range configs {
+		if err := registry.Register(name, config); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+func ValidateRegisteredProvider(registry *Registry) validator.Func {
 	return func(fieldLevel validator.FieldLevel) bool {
-		provider, ok := providers[fieldLevel.Field().String()]
+		name := fieldLevel.Field().String()
+		if _, ok := registry.Chain(name); ok {
+			return true
+		}
+
+		provider, ok := registry.Get(name)
 		return ok && provider != nil
 	}
 }
@@ -0,0 +1,80 @@
+// Package providerstest provides a scripted provider to the tests, no model
+// server is required.
+package providerstest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+)
+
+// Call is a chat request received by the provider
+type Call struct {
+	Model    string
+	Messages []providers.Message
+}
+
+// Provider streams the same chunks to every chat request, then returns Err.
+// It also resolves to itself, so it can stand in for a providers.Resolver.
+type Provider struct {
+	Chunks []providers.Message
+	// Delay is waited before every chunk
+	Delay time.Duration
+	Err   error
+
+	mutex sync.Mutex
+	calls []Call
+}
+
+func New(chunks ...providers.Message) *Provider {
+	return &Provider{Chunks: chunks}
+}
+
+// Text returns a message chunk
+func Text(content string) providers.Message {
+	return providers.Message{Role: providers.RoleAssistant, Content: content, Metadata: map[string]any{providers.ThinkMetadataKey: false}}
+}
+
+// Thinking returns a reasoning chunk
+func Thinking(content string) providers.Message {
+	return providers.Message{Role: providers.RoleAssistant, Content: content, Metadata: map[string]any{providers.ThinkMetadataKey: true}}
+}
+
+func (p *Provider) Chat(ctx context.Context, model string, messages []providers.Message, callback providers.MessageCallback) error {
+	p.mutex.Lock()
+	p.calls = append(p.calls, Call{Model: model, Messages: slices.Clone(messages)})
+	p.mutex.Unlock()
+
+	for _, chunk := range p.Chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.Delay):
+		}
+
+		if err := callback(chunk); err != nil {
+			return err
+		}
+	}
+
+	return p.Err
+}
+
+func (p *Provider) Resolve(ctx context.Context, scope providers.Scope, name string, model string) (providers.Provider, error) {
+	return p, nil
+}
+
+// Calls returns the chat requests received so far
+func (p *Provider) Calls() []Call {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return slices.Clone(p.calls)
+}
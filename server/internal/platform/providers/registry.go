@@ -0,0 +1,205 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Registry holds the server default providers, keyed by name. Providers can
+// be registered and removed at runtime (e.g. from the admin API). It also
+// holds the chains, logical names failing over a list of providers.
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+	configs   map[string]Config
+	chains    map[string][]Target
+	policies  map[string]Policy
+	pools     map[string]*runningPool
+	// breakers are shared with the providers built from the configurations
+	breakers *Breakers
+	logger   *zap.Logger
+}
+
+func NewRegistry(logger *zap.Logger) *Registry {
+	return &Registry{
+		providers: make(map[string]Provider),
+		configs:   make(map[string]Config),
+		chains:    make(map[string][]Target),
+		policies:  make(map[string]Policy),
+		pools:     make(map[string]*runningPool),
+		breakers:  NewBreakers(),
+		logger:    logger,
+	}
+}
+
+// SetPolicies sets the policies of the providers registered from now on,
+// keyed by name. The providers without one use DefaultPolicy.
+func (r *Registry) SetPolicies(policies map[string]Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.policies = maps.Clone(policies)
+}
+
+// runningPool is a registered pool, checked until it is replaced or removed
+type runningPool struct {
+	pool   *Pool
+	cancel context.CancelFunc
+}
+
+// Register builds the provider from its configuration, replacing the provider
+// previously registered under the same name. The name cannot be a chain's or
+// a pool's.
+func (r *Registry) Register(name string, config Config) error {
+	config.Policy = r.policy(name)
+	config.Breaker = r.breakers.Get(name, config.Policy)
+
+	provider, err := NewProvider(config, r.logger.With(zap.String("provider", name)))
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.chains[name]; ok {
+		return fmt.Errorf("%w: %s is already a chain", ErrNameInUse, name)
+	}
+	if _, ok := r.pools[name]; ok {
+		return fmt.Errorf("%w: %s", ErrProviderPooled, name)
+	}
+
+	r.providers[name] = provider
+	r.configs[name] = config
+	return nil
+}
+
+// RegisterPool registers the pool wrapped with the middlewares of its policy,
+// the configuration describes the pool (e.g. its allowed models). The pool is
+// run until ctx is done or it is removed.
+func (r *Registry) RegisterPool(ctx context.Context, name string, config Config, pool *Pool) {
+	config.Pool = pool
+	config.Policy = r.policy(name)
+	config.Breaker = r.breakers.Get(name, config.Policy)
+	breaker := func() *CircuitBreaker { return config.Breaker }
+	provider := config.Policy.apply(pool, breaker, r.logger.With(zap.String("provider", name)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopPool(name)
+	ctx, cancel := context.WithCancel(ctx)
+	go pool.Run(ctx)
+
+	r.providers[name] = provider
+	r.configs[name] = config
+	r.pools[name] = &runningPool{pool: pool, cancel: cancel}
+}
+
+// stopPool stops checking the pool registered under the name, the caller
+// holds the lock
+func (r *Registry) stopPool(name string) {
+	if running, ok := r.pools[name]; ok {
+		running.cancel()
+		delete(r.pools, name)
+	}
+}
+
+func (r *Registry) policy(name string) Policy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if policy, ok := r.policies[name]; ok {
+		return policy
+	}
+
+	return DefaultPolicy
+}
+
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.providers, name)
+	delete(r.configs, name)
+	r.stopPool(name)
+}
+
+// Pool returns the pool registered under the name, if the provider is one
+func (r *Registry) Pool(name string) (*Pool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	running, ok := r.pools[name]
+	if !ok {
+		return nil, false
+	}
+
+	return running.pool, true
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+func (r *Registry) Config(name string) (Config, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	config, ok := r.configs[name]
+	return config, ok
+}
+
+// RegisterChain registers the chain, its targets are looked up when resolving
+// a request so they can be registered later
+func (r *Registry) RegisterChain(name string, targets []Target) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: %s has no targets", ErrInvalidChain, name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.providers[name]; ok {
+		return fmt.Errorf("%w: %w: %s is already a provider", ErrInvalidChain, ErrNameInUse, name)
+	}
+
+	r.chains[name] = slices.Clone(targets)
+	return nil
+}
+
+func (r *Registry) Chain(name string) ([]Target, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	targets, ok := r.chains[name]
+	return slices.Clone(targets), ok
+}
+
+// Names returns the registered provider and chain names, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Sorted(func(yield func(string) bool) {
+		for name := range r.providers {
+			if !yield(name) {
+				return
+			}
+		}
+		for name := range r.chains {
+			if !yield(name) {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRegistryBreakers(t *testing.T) {
+	registry := NewRegistry(zap.NewNop())
+	config := Config{Type: TypeOllama, URL: "http://ollama:11434"}
+	for _, name := range []string{"ollama", "gpu"} {
+		if err := registry.Register(name, config); err != nil {
+			t.Fatalf("Register(%q) error = %v", name, err)
+		}
+	}
+
+	ollama, _ := registry.Config("ollama")
+	gpu, _ := registry.Config("gpu")
+	if ollama.Breaker == nil || ollama.Breaker == gpu.Breaker {
+		t.Fatalf("Register() breakers = %p, %p, want one per provider", ollama.Breaker, gpu.Breaker)
+	}
+
+	// The providers built from the configuration, e.g. with a profile's key,
+	// share the breaker of the provider
+	if err := registry.Register("ollama", config); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if replaced, _ := registry.Config("ollama"); replaced.Breaker != ollama.Breaker {
+		t.Errorf("Register() again replaced the breaker")
+	}
+
+	policy := DefaultPolicy
+	policy.BreakerCooldown = time.Hour
+	registry.SetPolicies(map[string]Policy{"ollama": policy})
+	if err := registry.Register("ollama", config); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if changed, _ := registry.Config("ollama"); changed.Breaker == ollama.Breaker {
+		t.Errorf("Register() with another policy kept the breaker")
+	}
+
+	if other := NewRegistry(zap.NewNop()); other.breakers.Get("ollama", DefaultPolicy) == ollama.Breaker {
+		t.Errorf("registries share their breakers")
+	}
+}
+
+func TestRegistryNames(t *testing.T) {
+	registry := NewRegistry(zap.NewNop())
+	if err := registry.Register("ollama", Config{Type: TypeOllama, URL: "http://ollama:11434"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := registry.RegisterChain("local", []Target{{Provider: "ollama"}}); err != nil {
+		t.Fatalf("RegisterChain() error = %v", err)
+	}
+
+	if err := registry.Register("local", Config{Type: TypeOllama, URL: "http://ollama:11434"}); !errors.Is(err, ErrNameInUse) {
+		t.Errorf("Register() over a chain error = %v, want %v", err, ErrNameInUse)
+	}
+	if _, ok := registry.Config("local"); ok {
+		t.Errorf("Register() over a chain registered the provider")
+	}
+	if err := registry.RegisterChain("ollama", []Target{{Provider: "ollama"}}); !errors.Is(err, ErrNameInUse) {
+		t.Errorf("RegisterChain() over a provider error = %v, want %v", err, ErrNameInUse)
+	}
+
+	if names, want := registry.Names(), []string{"local", "ollama"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Names() = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,42 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+)
+
+// Scope is who a provider is resolved for
+type Scope struct {
+	ProfileId   domain.ProfileId
+	WorkspaceId domain.WorkspaceId
+}
+
+// Resolver picks the provider serving a request. Resolvers are chained, each
+// one falling back to the next when it has no specific configuration.
+type Resolver interface {
+	Resolve(ctx context.Context, scope Scope, name string, model string) (Provider, error)
+}
+
+type registryResolver struct {
+	registry *Registry
+}
+
+// NewRegistryResolver resolves the server default providers
+func NewRegistryResolver(registry *Registry) Resolver {
+	return &registryResolver{registry: registry}
+}
+
+func (r *registryResolver) Resolve(ctx context.Context, scope Scope, name string, model string) (Provider, error) {
+	provider, ok := r.registry.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, name)
+	}
+
+	if config, ok := r.registry.Config(name); ok && !config.AllowsModel(model) {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotAllowed, model)
+	}
+
+	return provider, nil
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const accessTokenQueryParameter = "access_token"
+
+// Authenticator validates a bearer token and populates the auth context keys
+// (at least auth.UserIdContextKey)
+type Authenticator interface {
+	// Accepts reports whether the token is in a format the authenticator handles
+	Accepts(token string) bool
+	Authenticate(c *gin.Context, token string) error
+}
+
+// AuthenticationMiddleware authenticates the bearer token with the first
+// authenticator accepting it. Requests authenticated with a scoped token are
+// then checked against the scopes. The reason of a failure is logged, not
+// returned.
+func AuthenticationMiddleware(logger *zap.Logger, authenticators ...Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		// Browsers cannot set headers on WebSocket handshakes
+		if authHeader == "" && isWebSocketUpgrade(c) {
+			if token := c.Query(accessTokenQueryParameter); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
+
+		if authHeader == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			return
+		}
+
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		if token == authHeader {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
+			return
+		}
+
+		if err := authenticate(c, authenticators, token); err != nil {
+			switch {
+			case errors.Is(err, auth.ErrForbidden):
+				logger.Debug("Request forbidden", zap.String("path", c.FullPath()), zap.Error(err))
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": auth.ErrForbidden.Error()})
+			case errors.Is(err, auth.ErrUnauthorized):
+				logger.Debug("Request unauthorized", zap.String("path", c.FullPath()), zap.Error(err))
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrUnauthorized.Error()})
+			default:
+				logger.Error("Failed to authenticate the request", zap.String("path", c.FullPath()), zap.Error(err))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": http.StatusText(http.StatusInternalServerError)})
+			}
+			return
+		}
+
+		if scopes, ok := auth.GetScopesFromContext(c); ok && !auth.Allows(scopes, requiredScope(c)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("token scope %s required", requiredScope(c))})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func authenticate(c *gin.Context, authenticators []Authenticator, token string) error {
+	for _, authenticator := range authenticators {
+		if authenticator.Accepts(token) {
+			return authenticator.Authenticate(c, token)
+		}
+	}
+
+	return fmt.Errorf("%w: unsupported token", auth.ErrUnauthorized)
+}
+
+// requiredScope is read for safe methods, WebSockets can send messages so
+// they need write
+func requiredScope(c *gin.Context) auth.Scope {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if !isWebSocketUpgrade(c) {
+			return auth.ScopeRead
+		}
+	}
+
+	return auth.ScopeWrite
+}
+
+func isWebSocketUpgrade(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeAuthenticator accepts the tokens with its prefix, the token names the
+// outcome
+type fakeAuthenticator struct {
+	prefix string
+	scopes []auth.Scope
+	calls  int
+}
+
+func (a *fakeAuthenticator) Accepts(token string) bool {
+	return strings.HasPrefix(token, a.prefix)
+}
+
+func (a *fakeAuthenticator) Authenticate(c *gin.Context, token string) error {
+	a.calls++
+	switch strings.TrimPrefix(token, a.prefix) {
+	case "invalid":
+		return fmt.Errorf("%w: signature mismatch", auth.ErrUnauthorized)
+	case "broken":
+		return errors.New("database is down")
+	}
+
+	c.Set(auth.UserIdContextKey, a.prefix+"user")
+	if a.scopes != nil {
+		c.Set(auth.ScopesContextKey, a.scopes)
+	}
+	return nil
+}
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zap.DebugLevel)
+	scoped := &fakeAuthenticator{prefix: "yap_", scopes: []auth.Scope{auth.ScopeRead}}
+	fallback := &fakeAuthenticator{prefix: ""}
+
+	engine := gin.New()
+	engine.Use(AuthenticationMiddleware(zap.New(core), scoped, fallback))
+	handler := func(c *gin.Context) {
+		c.String(http.StatusOK, string(auth.GetUserIdFromContext(c)))
+	}
+	engine.GET("/", handler)
+	engine.POST("/", handler)
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		status int
+		body   string
+	}{
+		{name: "missing", method: http.MethodGet, status: http.StatusUnauthorized},
+		{name: "not bearer", method: http.MethodGet, header: "Basic dXNlcg==", status: http.StatusUnauthorized},
+		{name: "first accepting", method: http.MethodGet, header: "Bearer yap_token", status: http.StatusOK, body: "yap_user"},
+		{name: "last accepting", method: http.MethodGet, header: "Bearer jwt", status: http.StatusOK, body: "user"},
+		{name: "invalid", method: http.MethodGet, header: "Bearer yap_invalid", status: http.StatusUnauthorized, body: `{"error":"invalid credentials"}`},
+		{name: "failure", method: http.MethodGet, header: "Bearer broken", status: http.StatusInternalServerError, body: `{"error":"Internal Server Error"}`},
+		{name: "scope", method: http.MethodPost, header: "Bearer yap_token", status: http.StatusForbidden},
+		{name: "unscoped", method: http.MethodPost, header: "Bearer jwt", status: http.StatusOK, body: "user"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, "/", nil)
+			if test.header != "" {
+				request.Header.Set("Authorization", test.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+			if recorder.Code != test.status || (test.body != "" && recorder.Body.String() != test.body) {
+				t.Errorf("response = %d %s, want %d %s", recorder.Code, recorder.Body, test.status, test.body)
+			}
+		})
+	}
+
+	// The reason of a failure is logged, never returned
+	if failures := logs.FilterLevelExact(zap.ErrorLevel).All(); len(failures) != 1 || failures[0].ContextMap()["error"] != "database is down" {
+		t.Errorf("logged %v, want the reason of the failure", failures)
+	}
+	if invalid := logs.FilterMessage("Request unauthorized").All(); len(invalid) != 1 || !strings.Contains(invalid[0].ContextMap()["error"].(string), "signature mismatch") {
+		t.Errorf("logged %v, want the reason of the invalid credentials", invalid)
+	}
+
+	// The authenticators after the first accepting one are not tried
+	if fallback.calls != 3 {
+		t.Errorf("fallback authenticated %d tokens, want the ones the first authenticator does not accept", fallback.calls)
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method    string
+		websocket bool
+		want      auth.Scope
+	}{
+		{method: http.MethodGet, want: auth.ScopeRead},
+		{method: http.MethodHead, want: auth.ScopeRead},
+		{method: http.MethodOptions, want: auth.ScopeRead},
+		{method: http.MethodPost, want: auth.ScopeWrite},
+		{method: http.MethodPatch, want: auth.ScopeWrite},
+		{method: http.MethodDelete, want: auth.ScopeWrite},
+		{method: http.MethodGet, websocket: true, want: auth.ScopeWrite},
+	}
+	for _, test := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(test.method, "/", nil)
+		if test.websocket {
+			c.Request.Header.Set("Upgrade", "websocket")
+		}
+
+		if scope := requiredScope(c); scope != test.want {
+			t.Errorf("requiredScope(%s, websocket %v) = %s, want %s", test.method, test.websocket, scope, test.want)
+		}
+	}
+}
@@ -8,14 +8,23 @@ import (
 	"github.com/gin-gonic/gin"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
 )
 
-func ErrorMiddleware(translator ut.Translator) gin.HandlerFunc {
+func ErrorMiddleware(translator ut.Translator, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
 
 		err := c.Errors.Last()
 		if err != nil {
+			// The response was already sent (e.g. a stream), errors can only be logged
+			if c.Writer.Written() {
+				for _, err := range c.Errors {
+					logger.Error("Request failed after the response was sent", zap.String("path", c.FullPath()), zap.Error(err.Err))
+				}
+				return
+			}
+
 			status := c.Writer.Status()
 
 			var message any
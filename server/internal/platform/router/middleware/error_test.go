@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestErrorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zap.ErrorLevel)
+	engine := gin.New()
+	engine.Use(ErrorMiddleware(nil, zap.New(core)))
+	engine.GET("/failed", func(c *gin.Context) {
+		c.Status(http.StatusConflict)
+		c.Error(errors.New("conflict"))
+	})
+	engine.GET("/streamed", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		c.Error(errors.New("stream broken"))
+	})
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/failed", nil))
+	if recorder.Code != http.StatusConflict || recorder.Body.String() != `{"error":"conflict"}` {
+		t.Errorf("response = %d %s, want the error with its status", recorder.Code, recorder.Body)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logged %d entries, want the error to be returned only", logs.Len())
+	}
+
+	recorder = httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/streamed", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "partial" {
+		t.Errorf("response = %d %s, want the streamed response untouched", recorder.Code, recorder.Body)
+	}
+	if entries := logs.TakeAll(); len(entries) != 1 || entries[0].ContextMap()["error"] != "stream broken" {
+		t.Errorf("logged %v, want the error of the streamed response", entries)
+	}
+}
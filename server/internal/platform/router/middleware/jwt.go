@@ -1,90 +1,104 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
-	"net/http"
-	"strings"
+	"time"
 
 	"github.com/MicahParks/keyfunc/v3"
 	"github.com/dreadster3/yapper/server/internal/platform/auth"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
 )
 
+// DefaultAlgorithms are the asymmetric algorithms accepted when none are
+// configured, symmetric ones would let anyone holding the JWKS forge tokens
+var DefaultAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
 type JWTMiddleware struct {
-	jwksURL  string
-	keyFunc  jwt.Keyfunc
-	issuer   string
-	audience string
+	jwksURL string
+	keyFunc jwt.Keyfunc
+	parser  *jwt.Parser
 }
 
 type JWTConfig struct {
 	JWKSUrl  string
 	Issuer   string
 	Audience string
+	// Leeway is the clock skew tolerated when validating exp, nbf and iat
+	Leeway     time.Duration
+	Algorithms []string
+	// RefreshInterval is how often the JWKS is refreshed to pick up rotated
+	// keys, tokens signed with an unknown key also trigger a (rate limited)
+	// refresh
+	RefreshInterval time.Duration
 }
 
 func NewJWTMiddleware(config JWTConfig) (*JWTMiddleware, error) {
-	jwks, err := keyfunc.NewDefault([]string{config.JWKSUrl})
+	jwks, err := keyfunc.NewDefaultOverrideCtx(context.Background(), []string{config.JWKSUrl}, keyfunc.Override{
+		RefreshInterval: config.RefreshInterval,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get JWK Set from URL: %w", err)
 	}
 
+	algorithms := config.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = DefaultAlgorithms
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithLeeway(config.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	// Tokens with an array audience are accepted when it contains the audience
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
 	return &JWTMiddleware{
-		jwksURL:  config.JWKSUrl,
-		keyFunc:  jwks.Keyfunc,
-		issuer:   config.Issuer,
-		audience: config.Audience,
+		jwksURL: config.JWKSUrl,
+		keyFunc: jwks.Keyfunc,
+		parser:  jwt.NewParser(options...),
 	}, nil
 }
 
-func (m *JWTMiddleware) Middleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
-			return
-		}
-
-		token, err := jwt.Parse(tokenString, m.keyFunc)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			return
-		}
-
-		if !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			return
-		}
+func (m *JWTMiddleware) Middleware(logger *zap.Logger) gin.HandlerFunc {
+	return AuthenticationMiddleware(logger, m)
+}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			return
-		}
+// Accepts every token, the JWT authenticator is the last of the chain
+func (m *JWTMiddleware) Accepts(token string) bool {
+	return true
+}
 
-		if m.issuer != "" {
-			if iss, ok := claims["iss"].(string); !ok || iss != m.issuer {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid issuer"})
-				return
-			}
-		}
+func (m *JWTMiddleware) Authenticate(c *gin.Context, tokenString string) error {
+	token, err := m.parser.Parse(tokenString, m.keyFunc)
+	if err != nil {
+		return fmt.Errorf("%w: %w", auth.ErrUnauthorized, err)
+	}
+	if !token.Valid {
+		return fmt.Errorf("%w: invalid token", auth.ErrUnauthorized)
+	}
 
-		if m.audience != "" {
-			if aud, ok := claims["aud"].(string); !ok || aud != m.audience {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid audience"})
-				return
-			}
-		}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return fmt.Errorf("%w: invalid token claims", auth.ErrUnauthorized)
+	}
 
-		c.Set(auth.UserIdContextKey, claims["sub"])
-		c.Set(auth.UserClaimsContextKey, claims)
-		c.Next()
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return fmt.Errorf("%w: token subject required", auth.ErrUnauthorized)
 	}
+
+	c.Set(auth.UserIdContextKey, subject)
+	c.Set(auth.UserClaimsContextKey, claims)
+	c.Set(auth.AccessTokenContextKey, tokenString)
+	return nil
 }
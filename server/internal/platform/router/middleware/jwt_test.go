@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "yapper"
+	testKeyId    = "test"
+)
+
+// newJWKS serves the public key of the returned signing key
+func newJWKS(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating the key: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			"kid": testKeyId,
+			"alg": "ES256",
+			"use": "sig",
+		}}})
+	}))
+	t.Cleanup(server.Close)
+
+	return key, server.URL
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, jwksURL := newJWKS(t)
+	jwtMiddleware, err := NewJWTMiddleware(JWTConfig{JWKSUrl: jwksURL, Issuer: testIssuer, Audience: testAudience})
+	if err != nil {
+		t.Fatalf("NewJWTMiddleware() error = %v", err)
+	}
+	engine := gin.New()
+	engine.GET("/", jwtMiddleware.Middleware(zap.NewNop()), func(c *gin.Context) {
+		c.String(http.StatusOK, string(auth.GetUserIdFromContext(c)))
+	})
+
+	now := time.Now()
+	claims := func(change func(claims jwt.MapClaims)) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"iss": testIssuer,
+			"aud": testAudience,
+			"sub": "alice",
+			"iat": now.Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		if change != nil {
+			change(claims)
+		}
+		return claims
+	}
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = testKeyId
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("signing the token: %v", err)
+		}
+		return signed
+	}
+
+	symmetric := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil))
+	symmetric.Header["kid"] = testKeyId
+	hs256, _ := symmetric.SignedString([]byte("the public key"))
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "valid", token: sign(claims(nil)), status: http.StatusOK},
+		{name: "audience array", token: sign(claims(func(c jwt.MapClaims) { c["aud"] = []string{"other", testAudience} })), status: http.StatusOK},
+		{name: "issuer", token: sign(claims(func(c jwt.MapClaims) { c["iss"] = "https://attacker.example.com" })), status: http.StatusUnauthorized},
+		{name: "no issuer", token: sign(claims(func(c jwt.MapClaims) { delete(c, "iss") })), status: http.StatusUnauthorized},
+		{name: "audience", token: sign(claims(func(c jwt.MapClaims) { c["aud"] = "other" })), status: http.StatusUnauthorized},
+		{name: "audience array without", token: sign(claims(func(c jwt.MapClaims) { c["aud"] = []string{"other", "another"} })), status: http.StatusUnauthorized},
+		{name: "no expiry", token: sign(claims(func(c jwt.MapClaims) { delete(c, "exp") })), status: http.StatusUnauthorized},
+		{name: "expired", token: sign(claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() })), status: http.StatusUnauthorized},
+		{name: "issued in the future", token: sign(claims(func(c jwt.MapClaims) { c["iat"] = now.Add(time.Hour).Unix() })), status: http.StatusUnauthorized},
+		{name: "no subject", token: sign(claims(func(c jwt.MapClaims) { delete(c, "sub") })), status: http.StatusUnauthorized},
+		{name: "empty subject", token: sign(claims(func(c jwt.MapClaims) { c["sub"] = "" })), status: http.StatusUnauthorized},
+		{name: "symmetric algorithm", token: hs256, status: http.StatusUnauthorized},
+		{name: "no algorithm", token: none, status: http.StatusUnauthorized},
+		{name: "malformed", token: "not.a.token", status: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("Authorization", "Bearer "+test.token)
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+
+			if recorder.Code != test.status {
+				t.Errorf("response = %d %s, want %d", recorder.Code, recorder.Body, test.status)
+			}
+			if test.status == http.StatusOK && recorder.Body.String() != "alice" {
+				t.Errorf("user = %q, want the token subject", recorder.Body)
+			}
+		})
+	}
+}
+
+func TestJWTMiddlewareAlgorithms(t *testing.T) {
+	key, jwksURL := newJWKS(t)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"sub": "alice", "iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = testKeyId
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing the token: %v", err)
+	}
+
+	for algorithm, valid := range map[string]bool{"ES256": true, "RS256": false} {
+		jwtMiddleware, err := NewJWTMiddleware(JWTConfig{JWKSUrl: jwksURL, Algorithms: []string{algorithm}})
+		if err != nil {
+			t.Fatalf("NewJWTMiddleware() error = %v", err)
+		}
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if err := jwtMiddleware.Authenticate(c, signed); (err == nil) != valid {
+			t.Errorf("Authenticate() with %s allowed error = %v, want valid %v", algorithm, err, valid)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RolesMiddleware maps the claims stored by the JWT middleware to roles
+func RolesMiddleware(mapper auth.RoleMapper) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, _ := c.Get(auth.UserClaimsContextKey)
+		mapClaims, _ := claims.(jwt.MapClaims)
+
+		c.Set(auth.RolesContextKey, mapper.Map(mapClaims))
+		c.Next()
+	}
+}
+
+func RequireRole(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.HasRole(c, role) {
+			c.Error(fmt.Errorf("role %s required: %w", role, auth.ErrForbidden))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const claimsHeader = "X-Test-Claims"
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mapper := auth.RoleMapper{Claim: auth.DefaultRolesClaim, Roles: map[string][]auth.Role{"admins": {auth.RoleAdmin}}}
+
+	engine := gin.New()
+	engine.Use(ErrorMiddleware(nil, zap.NewNop()))
+	// Sets the claims of the test request like the JWT middleware does
+	engine.Use(func(c *gin.Context) {
+		if header := c.GetHeader(claimsHeader); header != "" {
+			var claims jwt.MapClaims
+			if err := json.Unmarshal([]byte(header), &claims); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			c.Set(auth.UserClaimsContextKey, claims)
+		}
+	})
+	engine.Use(RolesMiddleware(mapper))
+	engine.GET("/admin", RequireRole(auth.RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		claims string
+		want   int
+	}{
+		{name: "admin", claims: `{"groups":["users","admins"]}`, want: http.StatusNoContent},
+		{name: "not an admin", claims: `{"groups":["users"]}`, want: http.StatusForbidden},
+		{name: "no groups", claims: `{"sub":"user"}`, want: http.StatusForbidden},
+		{name: "no claims", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.claims != "" {
+				request.Header.Set(claimsHeader, tt.claims)
+			}
+
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", recorder.Code, tt.want, recorder.Body)
+			}
+		})
+	}
+}
@@ -1,42 +1,153 @@
 package router
 
 import (
+	"github.com/dreadster3/yapper/server/internal/admin"
 	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/events"
 	"github.com/dreadster3/yapper/server/internal/messages"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
 	"github.com/dreadster3/yapper/server/internal/platform/router/middleware"
 	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/secrets"
+	"github.com/dreadster3/yapper/server/internal/shares"
+	"github.com/dreadster3/yapper/server/internal/tokens"
+	"github.com/dreadster3/yapper/server/internal/users"
+	"github.com/dreadster3/yapper/server/internal/workspaces"
 	"github.com/gin-gonic/gin"
 	ut "github.com/go-playground/universal-translator"
+	"go.uber.org/zap"
 )
 
 func SetupRouter(
 	translator ut.Translator,
+	logger *zap.Logger,
 	jwtConfig *middleware.JWTConfig,
+	tokenAuthenticator middleware.Authenticator,
+	roleMapper auth.RoleMapper,
+	userRepository users.UserRepository,
 	profileRepository profiles.ProfileRepository,
+	provisionProfiles bool,
 	chatHandler chats.ChatHandler,
 	profileHandler profiles.ProfileHandler,
+	memberHandler chats.MemberHandler,
 	messageHandler messages.MessageHandler,
+	eventHandler events.EventHandler,
+	shareHandler shares.ShareHandler,
+	workspaceHandler workspaces.WorkspaceHandler,
+	secretHandler secrets.SecretHandler,
+	adminHandler admin.AdminHandler,
+	tokenHandler tokens.TokenHandler,
 ) (*gin.Engine, error) {
 	engine := gin.Default()
-	engine.Use(middleware.ErrorMiddleware(translator))
+	engine.Use(middleware.ErrorMiddleware(translator, logger))
 
 	jwtMiddleware, err := middleware.NewJWTMiddleware(*jwtConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	profileMiddleware := profiles.InjectProfileMiddleware(profileRepository)
-	v1 := engine.Group("/api/v1", jwtMiddleware.Middleware())
+	profileMiddleware := profiles.InjectProfileMiddleware(profileRepository, provisionProfiles)
+	v1 := engine.Group("/api/v1")
 	{
-		chatRoutes := v1.Group("/chats", profileMiddleware)
-		chatRoutes.POST("", chatHandler.Create)
+		// Public routes, the share token is the credential
+		sharedRoutes := v1.Group("/shared")
+		sharedRoutes.GET("/:token", shareHandler.GetShared)
+	}
 
-		messagesRoutes := chatRoutes.Group("/:chat_id/messages")
-		messagesRoutes.POST("", messageHandler.SendMessage)
+	authenticated := v1.Group("", middleware.AuthenticationMiddleware(logger, tokenAuthenticator, jwtMiddleware), middleware.RolesMiddleware(roleMapper), users.RejectDisabledMiddleware(userRepository))
+	{
+		adminRoutes := authenticated.Group("/admin", middleware.RequireRole(auth.RoleAdmin))
+		adminRoutes.GET("/profiles", adminHandler.ListProfiles)
+		adminRoutes.GET("/usage", adminHandler.Usage)
+		adminRoutes.GET("/users", adminHandler.ListUsers)
+		adminRoutes.POST("/users/:user_id/disable", adminHandler.DisableUser)
+		adminRoutes.POST("/users/:user_id/enable", adminHandler.EnableUser)
+		adminRoutes.GET("/providers", adminHandler.ListProviders)
+		adminRoutes.PUT("/providers/:provider", adminHandler.SaveProvider)
+		adminRoutes.DELETE("/providers/:provider", adminHandler.RemoveProvider)
+		adminRoutes.GET("/providers/:provider/backends", adminHandler.ListBackends)
 
-		profileRoutes := v1.Group("/profiles")
+		profileRoutes := authenticated.Group("/profiles")
 		profileRoutes.POST("", profileHandler.Create)
+		profileRoutes.GET("", profileHandler.List)
+
+		// Profile scoped routes use the profile from the route segment, or the
+		// one selected by the X-Profile-Id header (default profile otherwise)
+		selectedProfileRoutes := profileRoutes.Group("/:profile_id", profileMiddleware)
+		registerProfileRoutes(selectedProfileRoutes, chatHandler, memberHandler, messageHandler, eventHandler, shareHandler, workspaceHandler, secretHandler, tokenHandler)
+		selectedProfileRoutes.GET("", profileHandler.Get)
+		selectedProfileRoutes.PATCH("", profileHandler.Update)
+		selectedProfileRoutes.DELETE("", profileHandler.Delete)
+
+		currentProfileRoutes := authenticated.Group("", profileMiddleware)
+		registerProfileRoutes(currentProfileRoutes, chatHandler, memberHandler, messageHandler, eventHandler, shareHandler, workspaceHandler, secretHandler, tokenHandler)
+		currentProfileRoutes.GET("/profiles/me", profileHandler.Get)
+		currentProfileRoutes.PATCH("/profiles/me", profileHandler.Update)
+		currentProfileRoutes.DELETE("/profiles/me", profileHandler.Delete)
 	}
 
 	return engine, nil
 }
+
+func registerProfileRoutes(
+	routes *gin.RouterGroup,
+	chatHandler chats.ChatHandler,
+	memberHandler chats.MemberHandler,
+	messageHandler messages.MessageHandler,
+	eventHandler events.EventHandler,
+	shareHandler shares.ShareHandler,
+	workspaceHandler workspaces.WorkspaceHandler,
+	secretHandler secrets.SecretHandler,
+	tokenHandler tokens.TokenHandler,
+) {
+	chatRoutes := routes.Group("/chats")
+	chatRoutes.POST("", chatHandler.Create)
+	chatRoutes.GET("", chatHandler.List)
+	chatRoutes.PATCH("/:chat_id", chatHandler.Update)
+	chatRoutes.DELETE("/:chat_id", chatHandler.Delete)
+
+	messagesRoutes := chatRoutes.Group("/:chat_id/messages")
+	messagesRoutes.POST("", messageHandler.SendMessage)
+	messagesRoutes.POST("/:message_id/retry", messageHandler.Retry)
+
+	chatRoutes.GET("/:chat_id/ws", messageHandler.Connect)
+
+	memberRoutes := chatRoutes.Group("/:chat_id/members")
+	memberRoutes.POST("", memberHandler.Invite)
+	memberRoutes.GET("", memberHandler.List)
+	memberRoutes.PATCH("/:member_id", memberHandler.Update)
+	memberRoutes.DELETE("/:member_id", memberHandler.Remove)
+
+	invitationRoutes := routes.Group("/invitations")
+	invitationRoutes.GET("", memberHandler.ListInvitations)
+	invitationRoutes.POST("/:chat_id/accept", memberHandler.AcceptInvitation)
+	invitationRoutes.DELETE("/:chat_id", memberHandler.DeclineInvitation)
+
+	shareRoutes := chatRoutes.Group("/:chat_id/shares")
+	shareRoutes.POST("", shareHandler.Create)
+	shareRoutes.GET("", shareHandler.List)
+	shareRoutes.DELETE("/:share_id", shareHandler.Revoke)
+
+	workspaceRoutes := routes.Group("/workspaces")
+	workspaceRoutes.POST("", workspaceHandler.Create)
+	workspaceRoutes.GET("", workspaceHandler.List)
+	workspaceRoutes.GET("/:workspace_id", workspaceHandler.Get)
+	workspaceRoutes.PATCH("/:workspace_id", workspaceHandler.Update)
+	workspaceRoutes.DELETE("/:workspace_id", workspaceHandler.Delete)
+	workspaceRoutes.PUT("/:workspace_id/members/:member_id", workspaceHandler.SaveMember)
+	workspaceRoutes.DELETE("/:workspace_id/members/:member_id", workspaceHandler.RemoveMember)
+	workspaceRoutes.PUT("/:workspace_id/providers/:provider", workspaceHandler.SaveProvider)
+	workspaceRoutes.DELETE("/:workspace_id/providers/:provider", workspaceHandler.RemoveProvider)
+
+	secretRoutes := routes.Group("/secrets")
+	secretRoutes.GET("", secretHandler.List)
+	secretRoutes.PUT("/:provider", secretHandler.Save)
+	secretRoutes.DELETE("/:provider", secretHandler.Delete)
+
+	tokenRoutes := routes.Group("/tokens")
+	tokenRoutes.POST("", tokenHandler.Create)
+	tokenRoutes.GET("", tokenHandler.List)
+	tokenRoutes.DELETE("/:token_id", tokenHandler.Revoke)
+
+	routes.GET("/events", eventHandler.Stream)
+}
@@ -0,0 +1,220 @@
+// Package sqldb wraps database/sql for the SQL storage backends. Queries are
+// written once with ? placeholders and portable column types, the dialect
+// adapts them to the database.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Querier is implemented by both the database and its transactions, so
+// repositories run the same queries in and out of transactions
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type DB struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func Open(dialect Dialect, dsn string) (*DB, error) {
+	db, err := sql.Open(dialect.DriverName(), dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqldb.Open: %w", err)
+	}
+
+	return New(db, dialect), nil
+}
+
+func New(db *sql.DB, dialect Dialect) *DB {
+	dialect.Configure(db)
+	return &DB{db: db, dialect: dialect}
+}
+
+func (d *DB) Dialect() Dialect {
+	return d.dialect
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if tx := d.txFromContext(ctx); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+
+	return d.db.ExecContext(ctx, rebind(d.dialect, query), args...)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if tx := d.txFromContext(ctx); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+
+	return d.db.QueryContext(ctx, rebind(d.dialect, query), args...)
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if tx := d.txFromContext(ctx); tx != nil {
+		return tx.QueryRowContext(ctx, query, args...)
+	}
+
+	return d.db.QueryRowContext(ctx, rebind(d.dialect, query), args...)
+}
+
+// Transaction runs fn in a transaction, committed when fn succeeds. Within a
+// unit of work fn joins its transaction.
+func (d *DB) Transaction(ctx context.Context, fn func(tx Querier) error) error {
+	if tx := d.txFromContext(ctx); tx != nil {
+		return fn(tx)
+	}
+
+	sqlTx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&tx{tx: sqlTx, dialect: d.dialect, db: d}); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+
+	return sqlTx.Commit()
+}
+
+type txContextKey struct{}
+
+// Do runs fn as a unit of work, every query made through the database with the
+// context given to fn is part of the same transaction.
+func (d *DB) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return d.Transaction(ctx, func(tx Querier) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
+
+func (d *DB) txFromContext(ctx context.Context) *tx {
+	if tx, ok := ctx.Value(txContextKey{}).(*tx); ok && tx.db == d {
+		return tx
+	}
+
+	return nil
+}
+
+// IsUniqueViolation reports whether the error was caused by a unique
+// constraint
+func (d *DB) IsUniqueViolation(err error) bool {
+	return err != nil && d.dialect.IsUniqueViolation(err)
+}
+
+// Schema replaces the portable column types of a DDL statement, e.g.
+// {{timestamp}}, with the ones of the dialect
+func (d *DB) Schema(statement string) string {
+	for _, columnType := range []ColumnType{TypeTimestamp, TypeBlob} {
+		statement = strings.ReplaceAll(statement, "{{"+string(columnType)+"}}", d.dialect.ColumnType(columnType))
+	}
+
+	return statement
+}
+
+type tx struct {
+	tx      *sql.Tx
+	dialect Dialect
+	db      *DB
+}
+
+func (t *tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, rebind(t.dialect, query), args...)
+}
+
+func (t *tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, rebind(t.dialect, query), args...)
+}
+
+func (t *tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, rebind(t.dialect, query), args...)
+}
+
+// rebind replaces the ? placeholders with the ones of the dialect. Queries
+// never contain ? in literals.
+func rebind(dialect Dialect, query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(query) + 8)
+
+	position := 0
+	for _, char := range query {
+		if char == '?' {
+			position++
+			builder.WriteString(dialect.Placeholder(position))
+			continue
+		}
+
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+// In returns the placeholders and arguments of an IN clause
+func In[T any](values []T) (string, []any) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	args := make([]any, len(values))
+	for idx, value := range values {
+		args[idx] = value
+	}
+
+	return placeholders, args
+}
+
+// Scan reads every row with scan, closing the rows
+func Scan[T any](rows *sql.Rows, scan func(rows *sql.Rows) (T, error)) ([]T, error) {
+	defer rows.Close()
+
+	result := make([]T, 0)
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+// NullTime converts an optional time, times are stored in UTC
+func NullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// TimePtr converts a nullable column back to an optional time
+func TimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
@@ -0,0 +1,91 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{"postgres", Postgres{}, "SELECT * FROM chats WHERE id = ? AND name = ?", "SELECT * FROM chats WHERE id = $1 AND name = $2"},
+		{"sqlite", SQLite{}, "SELECT * FROM chats WHERE id = ? AND name = ?", "SELECT * FROM chats WHERE id = ? AND name = ?"},
+		{"no placeholders", Postgres{}, "DELETE FROM chats", "DELETE FROM chats"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebind(tt.dialect, tt.query); got != tt.want {
+				t.Errorf("rebind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	db := New(nil, Postgres{})
+	// Configure is a no-op for Postgres, the nil pool is never used
+	got := db.Schema("CREATE TABLE t (created_at {{timestamp}}, data {{blob}})")
+	want := "CREATE TABLE t (created_at TIMESTAMPTZ, data BYTEA)"
+	if got != want {
+		t.Errorf("Schema() = %q, want %q", got, want)
+	}
+}
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(SQLite{}, ":memory:")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatalf("creating the table: %v", err)
+	}
+
+	count := func() (count int) {
+		t.Helper()
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM items").Scan(&count); err != nil {
+			t.Fatalf("counting the items: %v", err)
+		}
+		return count
+	}
+
+	errRollback := errors.New("rollback")
+	err = db.Do(ctx, func(ctx context.Context) error {
+		if _, err := db.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", "first"); err != nil {
+			return err
+		}
+		// Nested transactions join the unit of work, SQLite only has a single
+		// connection so a new transaction would never start
+		return db.Transaction(ctx, func(tx Querier) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", "second"); err != nil {
+				return err
+			}
+			return errRollback
+		})
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Do() error = %v, want %v", err, errRollback)
+	}
+	if got := count(); got != 0 {
+		t.Errorf("Do() kept %d items after a failure, want 0", got)
+	}
+
+	err = db.Do(ctx, func(ctx context.Context) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", "first")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if got := count(); got != 1 {
+		t.Errorf("Do() kept %d items, want 1", got)
+	}
+}
@@ -0,0 +1,137 @@
+package sqldb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+var ErrUnknownDialect = errors.New("unknown SQL dialect")
+
+// ColumnType is a column type that differs between databases
+type ColumnType string
+
+const (
+	TypeTimestamp ColumnType = "timestamp"
+	TypeBlob      ColumnType = "blob"
+)
+
+type Dialect interface {
+	Name() string
+	DriverName() string
+	// Placeholder returns the bind parameter at the position, starting at 1
+	Placeholder(position int) string
+	ColumnType(columnType ColumnType) string
+	IsUniqueViolation(err error) bool
+	// Configure tunes the connection pool once the database is opened
+	Configure(db *sql.DB)
+}
+
+func DialectFor(name string) (Dialect, error) {
+	switch name {
+	case DialectPostgres:
+		return Postgres{}, nil
+	case DialectSQLite:
+		return SQLite{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDialect, name)
+	}
+}
+
+// Postgres uses the pgx driver, the DSN is a postgres:// URL
+type Postgres struct{}
+
+func (Postgres) Name() string {
+	return DialectPostgres
+}
+
+func (Postgres) DriverName() string {
+	return "pgx"
+}
+
+func (Postgres) Placeholder(position int) string {
+	return fmt.Sprintf("$%d", position)
+}
+
+func (Postgres) ColumnType(columnType ColumnType) string {
+	switch columnType {
+	case TypeTimestamp:
+		return "TIMESTAMPTZ"
+	case TypeBlob:
+		return "BYTEA"
+	default:
+		return string(columnType)
+	}
+}
+
+func (Postgres) IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func (Postgres) Configure(db *sql.DB) {}
+
+// SQLite uses the pure Go driver, the DSN is the path of the database file or
+// :memory:
+type SQLite struct{}
+
+func (SQLite) Name() string {
+	return DialectSQLite
+}
+
+func (SQLite) DriverName() string {
+	return "sqlite"
+}
+
+func (SQLite) Placeholder(position int) string {
+	return "?"
+}
+
+// DATETIME columns are parsed back to times by the driver. Times are written
+// in UTC with the same layout, so they compare correctly as text.
+func (SQLite) ColumnType(columnType ColumnType) string {
+	switch columnType {
+	case TypeTimestamp:
+		return "DATETIME"
+	case TypeBlob:
+		return "BLOB"
+	default:
+		return string(columnType)
+	}
+}
+
+func (SQLite) IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// Configure serializes the access to the database, SQLite has a single
+// writer and an in memory database only exists within its connection
+func (SQLite) Configure(db *sql.DB) {
+	db.SetMaxOpenConns(1)
+}
+
+// SQLiteDSN returns the DSN of the database file with the pragmas the
+// repositories expect
+func SQLiteDSN(path string) string {
+	if path == ":memory:" {
+		return path
+	}
+
+	return "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+}
@@ -0,0 +1,55 @@
+package sqldbtest
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+)
+
+const PostgresURLEnv = "YAPPER_TEST_POSTGRES_URL"
+
+// OpenPostgres returns a database in a new schema with every migration
+// applied, the schema is dropped when the test ends
+func OpenPostgres(t testing.TB) *sqldb.DB {
+	t.Helper()
+
+	dsn := os.Getenv(PostgresURLEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", PostgresURLEnv)
+	}
+
+	admin, err := sqldb.Open(sqldb.Postgres{}, dsn)
+	if err != nil {
+		t.Fatalf("opening Postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	ctx := context.Background()
+	schemaName := "yapper_test_" + domain.NewId()
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schemaName); err != nil {
+		t.Fatalf("creating the schema: %v", err)
+	}
+	t.Cleanup(func() { admin.ExecContext(ctx, "DROP SCHEMA "+schemaName+" CASCADE") })
+
+	// Every connection of the database uses the schema
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", PostgresURLEnv, err)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schemaName)
+	parsed.RawQuery = query.Encode()
+
+	db, err := sqldb.Open(sqldb.Postgres{}, parsed.String())
+	if err != nil {
+		t.Fatalf("opening Postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrate(t, db)
+	return db
+}
@@ -0,0 +1,26 @@
+// Package transaction groups repository writes into units of work. The
+// repositories join the unit of work through the context they are given, so
+// their interfaces are unchanged.
+package transaction
+
+import "context"
+
+// UnitOfWork runs fn atomically: nothing fn wrote through the repositories is
+// kept when it returns an error. fn may be run more than once when the
+// database asks for a retry, it must not have side effects outside of the
+// repositories (e.g. publishing events).
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type none struct{}
+
+// None runs fn as is, for the backends without transactions (e.g. in memory).
+// Writes are not rolled back.
+func None() UnitOfWork {
+	return none{}
+}
+
+func (none) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
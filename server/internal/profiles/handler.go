@@ -1,30 +1,64 @@
 package profiles
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 
+	"github.com/dreadster3/yapper/server/internal/domain"
 	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
+var ErrProfileNameRequired = errors.New("profile name is required")
+
 type ProfileHandler interface {
 	Create(c *gin.Context)
+	List(c *gin.Context)
+	Get(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+// PurgeScheduler schedules the removal of everything owned by a profile.
+type PurgeScheduler interface {
+	Schedule(ctx context.Context, profileId domain.ProfileId) error
+}
+
+// DisplayNameResolver resolves the name of the user from the identity
+// provider, used to name profiles created without one
+type DisplayNameResolver interface {
+	DisplayName(ctx context.Context, userId auth.UserId, accessToken string) (string, error)
 }
 
 type profileHandler struct {
-	repository ProfileRepository
+	repository   ProfileRepository
+	purger       PurgeScheduler
+	nameResolver DisplayNameResolver
 }
 
-func NewProfileHandler(repository ProfileRepository) ProfileHandler {
+// NewProfileHandler creates the handler, nameResolver is optional
+func NewProfileHandler(repository ProfileRepository, purger PurgeScheduler, nameResolver DisplayNameResolver) ProfileHandler {
 	return &profileHandler{
-		repository: repository,
+		repository:   repository,
+		purger:       purger,
+		nameResolver: nameResolver,
 	}
 }
 
 func (h *profileHandler) Create(c *gin.Context) {
-	var profile *Profile
-	if err := c.ShouldBindJSON(&profile); err != nil {
+	// Personal access tokens are bound to a single profile
+	if _, ok := auth.GetTokenProfileIdFromContext(c); ok {
+		c.Error(fmt.Errorf("profileHandler.Create: %w", auth.ErrForbidden))
+		return
+	}
+
+	// The body is optional, the name defaults to the user's name
+	profile := &Profile{}
+	if err := c.ShouldBindJSON(profile); err != nil && !errors.Is(err, io.EOF) {
 		c.Error(err)
 		return
 	}
@@ -33,14 +67,113 @@ func (h *profileHandler) Create(c *gin.Context) {
 
 	profile.UserId = auth.GetUserIdFromContext(c)
 
+	if profile.Name == "" {
+		profile.Name = h.defaultName(c)
+	}
+	if profile.Name == "" {
+		c.Status(http.StatusBadRequest)
+		c.Error(ErrProfileNameRequired)
+		return
+	}
+
 	if err := h.repository.Create(ctx, profile); err != nil {
-		if errors.Is(err, ErrProfileAlreadyCreated) {
-			c.Status(http.StatusConflict)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, profile)
+}
+
+// defaultName is the user's name in the identity provider, if it can be
+// resolved
+func (h *profileHandler) defaultName(c *gin.Context) string {
+	accessToken, ok := auth.GetAccessTokenFromContext(c)
+	if h.nameResolver == nil || !ok {
+		return ""
+	}
+
+	name, err := h.nameResolver.DisplayName(c.Request.Context(), auth.GetUserIdFromContext(c), accessToken)
+	if err != nil {
+		return ""
+	}
+
+	return name
+}
+
+func (h *profileHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	profiles, err := h.repository.GetByUserId(ctx, auth.GetUserIdFromContext(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if tokenProfileId, ok := auth.GetTokenProfileIdFromContext(c); ok {
+		profiles = utils.Filter(profiles, func(profile *Profile) bool {
+			return profile.Id == domain.ProfileId(tokenProfileId)
+		})
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+func (h *profileHandler) Get(c *gin.Context) {
+	c.JSON(http.StatusOK, GetProfileFromContext(c))
+}
+
+func (h *profileHandler) Update(c *gin.Context) {
+	var request struct {
+		Name *string `json:"name" binding:"omitempty,min=1"`
+		// Preferences are merged into the existing ones, null values remove the key
+		Preferences map[string]any `json:"preferences"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	profile := GetProfileFromContext(c)
+	if request.Name != nil {
+		profile.Name = *request.Name
+	}
+
+	if profile.Preferences == nil {
+		profile.Preferences = make(map[string]any)
+	}
+	for key, value := range request.Preferences {
+		if value == nil {
+			delete(profile.Preferences, key)
+			continue
 		}
 
+		profile.Preferences[key] = value
+	}
+
+	ctx := c.Request.Context()
+	if err := h.repository.Update(ctx, profile); err != nil {
 		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, profile)
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *profileHandler) Delete(c *gin.Context) {
+	profile := GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	// The purge is scheduled before removing the profile so that its data is
+	// always reachable by the purge job, even if the deletion below fails
+	if err := h.purger.Schedule(ctx, profile.Id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.repository.Delete(ctx, profile.Id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
 }
@@ -0,0 +1,169 @@
+package profiles
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"go.uber.org/zap"
+)
+
+type memoryProfileRepository struct {
+	mutex    sync.RWMutex
+	profiles map[domain.ProfileId]Profile
+}
+
+// NewMemoryProfileRepository returns a repository keeping the profiles in
+// this process, they are lost on restart.
+func NewMemoryProfileRepository(logger *zap.Logger) ProfileRepository {
+	var repo ProfileRepository
+	repo = &memoryProfileRepository{profiles: make(map[domain.ProfileId]Profile)}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *memoryProfileRepository) FindById(ctx context.Context, id domain.ProfileId) (*Profile, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	profile, ok := r.profiles[id]
+	if !ok {
+		return nil, ErrProfileNotFound
+	}
+
+	return copyProfile(profile), nil
+}
+
+func (r *memoryProfileRepository) FindDefaultByUserId(ctx context.Context, userId auth.UserId) (*Profile, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.findDefault(userId)
+}
+
+// findDefault must be called with the lock held
+func (r *memoryProfileRepository) findDefault(userId auth.UserId) (*Profile, error) {
+	profiles := r.find(func(profile Profile) bool {
+		return profile.UserId == userId
+	})
+	if len(profiles) == 0 {
+		return nil, ErrProfileNotFound
+	}
+
+	for _, profile := range profiles {
+		if profile.Default {
+			return profile, nil
+		}
+	}
+
+	return profiles[0], nil
+}
+
+func (r *memoryProfileRepository) GetByUserId(ctx context.Context, userId auth.UserId) ([]*Profile, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.find(func(profile Profile) bool {
+		return profile.UserId == userId
+	}), nil
+}
+
+func (r *memoryProfileRepository) GetAll(ctx context.Context) ([]*Profile, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	profiles := r.find(func(Profile) bool { return true })
+	slices.SortStableFunc(profiles, func(a, b *Profile) int {
+		return cmp.Compare(a.UserId, b.UserId)
+	})
+
+	return profiles, nil
+}
+
+// find returns the matching profiles oldest first, it must be called with the
+// lock held
+func (r *memoryProfileRepository) find(match func(Profile) bool) []*Profile {
+	result := []*Profile{}
+	for _, id := range slices.Sorted(maps.Keys(r.profiles)) {
+		if profile := r.profiles[id]; match(profile) {
+			result = append(result, copyProfile(profile))
+		}
+	}
+
+	return result
+}
+
+func (r *memoryProfileRepository) Create(ctx context.Context, profile *Profile) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	profile.Default = !r.hasDefault(profile.UserId)
+	r.insert(profile)
+	return nil
+}
+
+func (r *memoryProfileRepository) FindOrCreateDefault(ctx context.Context, profile *Profile) (*Profile, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, err := r.findDefault(profile.UserId)
+	if err == nil {
+		return existing, nil
+	}
+
+	profile.Default = true
+	r.insert(profile)
+	return profile, nil
+}
+
+func (r *memoryProfileRepository) hasDefault(userId auth.UserId) bool {
+	for _, profile := range r.profiles {
+		if profile.UserId == userId && profile.Default {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *memoryProfileRepository) insert(profile *Profile) {
+	if profile.Id == "" {
+		profile.Id = domain.ProfileId(domain.NewId())
+	}
+
+	r.profiles[profile.Id] = *copyProfile(*profile)
+}
+
+func (r *memoryProfileRepository) Update(ctx context.Context, profile *Profile) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.profiles[profile.Id]; !ok {
+		return ErrProfileNotFound
+	}
+
+	r.profiles[profile.Id] = *copyProfile(*profile)
+	return nil
+}
+
+func (r *memoryProfileRepository) Delete(ctx context.Context, id domain.ProfileId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.profiles, id)
+	return nil
+}
+
+// copyProfile keeps the stored preferences from being shared with the callers
+func copyProfile(profile Profile) *Profile {
+	profile.Preferences = maps.Clone(profile.Preferences)
+	if profile.Preferences == nil {
+		profile.Preferences = map[string]any{}
+	}
+
+	return &profile
+}
@@ -2,26 +2,68 @@ package profiles
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/dreadster3/yapper/server/internal/domain"
 	"github.com/dreadster3/yapper/server/internal/platform/auth"
 	"github.com/gin-gonic/gin"
 )
 
 const (
 	ProfileContextKey = "profile"
+	ProfileIdHeader   = "X-Profile-Id"
+	ProfileIdParam    = "profile_id"
 )
 
 func GetProfileFromContext(c *gin.Context) *Profile {
 	return c.MustGet(ProfileContextKey).(*Profile)
 }
 
-func InjectProfileMiddleware(repository ProfileRepository) gin.HandlerFunc {
+const defaultProfileName = "Default"
+
+// InjectProfileMiddleware loads the profile selected by the profile_id route
+// segment or the X-Profile-Id header, falling back to the user's default
+// profile when none is selected. With provision, users without any profile
+// get one created from their token claims.
+func InjectProfileMiddleware(repository ProfileRepository, provision bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := auth.GetUserIdFromContext(c)
 		ctx := c.Request.Context()
 
-		profile, err := repository.FindByUserId(ctx, userId)
+		profileId := domain.ProfileId(c.Param(ProfileIdParam))
+		if profileId == "" {
+			profileId = domain.ProfileId(c.GetHeader(ProfileIdHeader))
+		}
+
+		// Personal access tokens are bound to a single profile
+		if tokenProfileId, ok := auth.GetTokenProfileIdFromContext(c); ok {
+			if profileId != "" && profileId != domain.ProfileId(tokenProfileId) {
+				c.Error(fmt.Errorf("profiles.InjectProfileMiddleware: %w", auth.ErrForbidden))
+				c.Abort()
+				return
+			}
+			profileId = domain.ProfileId(tokenProfileId)
+		}
+
+		var profile *Profile
+		var err error
+		if profileId == "" {
+			profile, err = repository.FindDefaultByUserId(ctx, userId)
+			if provision && errors.Is(err, ErrProfileNotFound) {
+				profile, err = repository.FindOrCreateDefault(ctx, &Profile{
+					Name:        nameFromClaims(auth.GetClaimsFromContext(c)),
+					Preferences: map[string]any{},
+					UserId:      userId,
+				})
+			}
+		} else {
+			profile, err = repository.FindById(ctx, profileId)
+			if err == nil && profile.UserId != userId {
+				err = fmt.Errorf("profiles.InjectProfileMiddleware: %w", auth.ErrForbidden)
+			}
+		}
+
 		if err != nil {
 			if errors.Is(err, ErrProfileNotFound) {
 				c.Status(http.StatusNotFound)
@@ -36,3 +78,15 @@ func InjectProfileMiddleware(repository ProfileRepository) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// nameFromClaims picks the first of the name, preferred_username and email
+// claims set
+func nameFromClaims(claims map[string]any) string {
+	for _, claim := range []string{"name", "preferred_username", "email"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			return name
+		}
+	}
+
+	return defaultProfileName
+}
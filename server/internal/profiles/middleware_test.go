@@ -0,0 +1,101 @@
+package profiles_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/router/middleware"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	userHeader         = "X-Test-User"
+	tokenProfileHeader = "X-Test-Token-Profile"
+)
+
+// newProfileEngine routes /profiles/:profile_id and /profiles/me like the
+// API does, answering with the id of the selected profile
+func newProfileEngine(repository profiles.ProfileRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(middleware.ErrorMiddleware(nil, zap.NewNop()))
+	engine.Use(func(c *gin.Context) {
+		c.Set(auth.UserIdContextKey, c.GetHeader(userHeader))
+		if profileId := c.GetHeader(tokenProfileHeader); profileId != "" {
+			c.Set(auth.TokenProfileIdContextKey, profileId)
+		}
+	})
+
+	selected := func(c *gin.Context) {
+		c.String(http.StatusOK, string(profiles.GetProfileFromContext(c).Id))
+	}
+	profileMiddleware := profiles.InjectProfileMiddleware(repository, false)
+	engine.GET("/profiles/:profile_id", profileMiddleware, selected)
+	engine.GET("/profiles/me", profileMiddleware, selected)
+
+	return engine
+}
+
+func createProfile(t *testing.T, repository profiles.ProfileRepository, userId auth.UserId) domain.ProfileId {
+	t.Helper()
+
+	profile := &profiles.Profile{Name: "profile", Preferences: map[string]any{}, UserId: userId}
+	if err := repository.Create(context.Background(), profile); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return profile.Id
+}
+
+func TestInjectProfileMiddleware(t *testing.T) {
+	repository := profiles.NewMemoryProfileRepository(zap.NewNop())
+
+	alice := auth.UserId("alice")
+	defaultProfile := createProfile(t, repository, alice)
+	otherProfile := createProfile(t, repository, alice)
+	foreignProfile := createProfile(t, repository, "bob")
+
+	engine := newProfileEngine(repository)
+
+	tests := []struct {
+		name         string
+		path         string
+		tokenProfile domain.ProfileId
+		wantStatus   int
+		wantProfile  domain.ProfileId
+	}{
+		{name: "me resolves to the default profile", path: "/profiles/me", wantStatus: http.StatusOK, wantProfile: defaultProfile},
+		{name: "own profile", path: "/profiles/" + string(otherProfile), wantStatus: http.StatusOK, wantProfile: otherProfile},
+		{name: "foreign profile", path: "/profiles/" + string(foreignProfile), wantStatus: http.StatusForbidden},
+		{name: "unknown profile", path: "/profiles/" + domain.NewId(), wantStatus: http.StatusNotFound},
+		{name: "token selects its profile", path: "/profiles/" + string(otherProfile), tokenProfile: otherProfile, wantStatus: http.StatusOK, wantProfile: otherProfile},
+		{name: "token me resolves to its profile", path: "/profiles/me", tokenProfile: otherProfile, wantStatus: http.StatusOK, wantProfile: otherProfile},
+		{name: "token selects another profile", path: "/profiles/" + string(defaultProfile), tokenProfile: otherProfile, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			request.Header.Set(userHeader, string(alice))
+			if tt.tokenProfile != "" {
+				request.Header.Set(tokenProfileHeader, string(tt.tokenProfile))
+			}
+
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantProfile != "" && recorder.Body.String() != string(tt.wantProfile) {
+				t.Errorf("profile = %s, want %s", recorder.Body, tt.wantProfile)
+			}
+		})
+	}
+}
@@ -7,14 +7,17 @@ import (
 )
 
 type Profile struct {
-	Id     domain.ProfileId `json:"id" binding:"-"`
-	Name   string           `json:"name" binding:"required"`
-	UserId auth.UserId      `json:"-"`
+	Id          domain.ProfileId `json:"id" binding:"-"`
+	Name        string           `json:"name" binding:"-"`
+	Preferences map[string]any   `json:"preferences" binding:"-"`
+	UserId      auth.UserId      `json:"-"`
+	Default     bool             `json:"default" binding:"-"`
 }
 
 func (p Profile) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	encoder.AddString("id", string(p.Id))
 	encoder.AddString("name", p.Name)
 	encoder.AddString("user_id", string(p.UserId))
+	encoder.AddBool("default", p.Default)
 	return nil
 }
@@ -7,21 +7,32 @@ import (
 
 	"github.com/dreadster3/yapper/server/internal/domain"
 	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/database"
+	"github.com/dreadster3/yapper/server/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
-var (
-	ErrProfileNotFound       = errors.New("profile not found")
-	ErrProfileAlreadyCreated = errors.New("profile already created")
-)
+var ErrProfileNotFound = errors.New("profile not found")
 
 type ProfileRepository interface {
 	FindById(ctx context.Context, id domain.ProfileId) (*Profile, error)
-	FindByUserId(ctx context.Context, userId auth.UserId) (*Profile, error)
+	// FindDefaultByUserId returns the default profile of the user, or their
+	// oldest profile if the default one was deleted
+	FindDefaultByUserId(ctx context.Context, userId auth.UserId) (*Profile, error)
+	GetByUserId(ctx context.Context, userId auth.UserId) ([]*Profile, error)
+	GetAll(ctx context.Context) ([]*Profile, error)
+	// Create stores the profile, it becomes the user's default profile when
+	// they have none
 	Create(ctx context.Context, profile *Profile) error
+	// FindOrCreateDefault returns the default profile of the user, creating it
+	// from the given one when missing. Concurrent calls create a single profile.
+	FindOrCreateDefault(ctx context.Context, profile *Profile) (*Profile, error)
+	Update(ctx context.Context, profile *Profile) error
+	Delete(ctx context.Context, id domain.ProfileId) error
 }
 
 const (
@@ -29,30 +40,41 @@ const (
 )
 
 type profileEntity struct {
-	Id     primitive.ObjectID `bson:"_id"`
-	Name   string             `bson:"name"`
-	UserId string             `bson:"user_id"`
+	Id          primitive.ObjectID `bson:"_id"`
+	Name        string             `bson:"name"`
+	Preferences map[string]any     `bson:"preferences"`
+	UserId      string             `bson:"user_id"`
+	Default     bool               `bson:"default"`
 }
 
 func (p profileEntity) ToModel() *Profile {
+	preferences := p.Preferences
+	if preferences == nil {
+		preferences = map[string]any{}
+	}
+
 	return &Profile{
-		Id:     domain.ProfileId(p.Id.Hex()),
-		Name:   p.Name,
-		UserId: auth.UserId(p.UserId),
+		Id:          domain.ProfileId(p.Id.Hex()),
+		Name:        p.Name,
+		Preferences: preferences,
+		UserId:      auth.UserId(p.UserId),
+		Default:     p.Default,
 	}
 }
 
-func fromModel(p *Profile) *profileEntity {
-	id, err := primitive.ObjectIDFromHex(string(p.Id))
-	if err != nil && errors.Is(err, primitive.ErrInvalidHex) {
-		id = primitive.NewObjectID()
+func fromModel(p *Profile) (*profileEntity, error) {
+	id, err := database.ObjectId(p.Id)
+	if err != nil {
+		return nil, err
 	}
 
 	return &profileEntity{
-		Id:     id,
-		Name:   p.Name,
-		UserId: string(p.UserId),
-	}
+		Id:          id,
+		Name:        p.Name,
+		Preferences: p.Preferences,
+		UserId:      string(p.UserId),
+		Default:     p.Default,
+	}, nil
 }
 
 type profileRepository struct {
@@ -73,39 +95,153 @@ func (r *profileRepository) Collection() *mongo.Collection {
 }
 
 func (r *profileRepository) FindById(ctx context.Context, id domain.ProfileId) (*Profile, error) {
-	return nil, errors.New("not implemented") // TODO: Implement
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return nil, ErrProfileNotFound
+	}
+
+	var entity profileEntity
+	if err := r.Collection().FindOne(ctx, bson.M{"_id": objId}).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrProfileNotFound
+		}
+
+		return nil, fmt.Errorf("repository.FindById: %w", err)
+	}
+
+	return entity.ToModel(), nil
 }
 
-func (r *profileRepository) FindByUserId(ctx context.Context, userId auth.UserId) (*Profile, error) {
+func (r *profileRepository) FindDefaultByUserId(ctx context.Context, userId auth.UserId) (*Profile, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "default", Value: -1}, {Key: "_id", Value: 1}})
+
 	var entity profileEntity
-	if err := r.Collection().FindOne(ctx, bson.M{"user_id": userId}).Decode(&entity); err != nil {
+	if err := r.Collection().FindOne(ctx, bson.M{"user_id": userId}, opts).Decode(&entity); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, ErrProfileNotFound
 		}
 
-		return nil, fmt.Errorf("repository.FindByUserId: %w", err)
+		return nil, fmt.Errorf("repository.FindDefaultByUserId: %w", err)
 	}
 
 	return entity.ToModel(), nil
 }
 
+func (r *profileRepository) GetByUserId(ctx context.Context, userId auth.UserId) ([]*Profile, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	cursor, err := r.Collection().Find(ctx, bson.M{"user_id": userId}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetByUserId: %w", err)
+	}
+
+	var entities []profileEntity
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, fmt.Errorf("repository.GetByUserId: %w", err)
+	}
+
+	return utils.Map(entities, func(p profileEntity) *Profile {
+		return p.ToModel()
+	}), nil
+}
+
+func (r *profileRepository) GetAll(ctx context.Context) ([]*Profile, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := r.Collection().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetAll: %w", err)
+	}
+
+	var entities []profileEntity
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, fmt.Errorf("repository.GetAll: %w", err)
+	}
+
+	return utils.Map(entities, func(p profileEntity) *Profile {
+		return p.ToModel()
+	}), nil
+}
+
 func (r *profileRepository) Create(ctx context.Context, profile *Profile) error {
-	if _, err := r.FindByUserId(ctx, profile.UserId); err != nil {
-		if !errors.Is(err, ErrProfileNotFound) {
-			return err
-		}
+	// The unique index rejects the default profile if the user already has one
+	profile.Default = true
+	err := r.insert(ctx, profile)
+	if mongo.IsDuplicateKeyError(err) {
+		profile.Default = false
+		err = r.insert(ctx, profile)
+	}
 
-		entity := fromModel(profile)
-		result, err := r.Collection().InsertOne(ctx, entity)
-		if err != nil {
-			return fmt.Errorf("repository.Create: %w", err)
+	if err != nil {
+		return fmt.Errorf("repository.Create: %w", err)
+	}
+
+	return nil
+}
+
+func (r *profileRepository) FindOrCreateDefault(ctx context.Context, profile *Profile) (*Profile, error) {
+	existing, err := r.FindDefaultByUserId(ctx, profile.UserId)
+	if !errors.Is(err, ErrProfileNotFound) {
+		return existing, err
+	}
+
+	profile.Default = true
+	if err := r.insert(ctx, profile); err != nil {
+		// Created by a concurrent request
+		if mongo.IsDuplicateKeyError(err) {
+			return r.FindDefaultByUserId(ctx, profile.UserId)
 		}
 
-		profile.Id = domain.ProfileId(result.InsertedID.(primitive.ObjectID).Hex())
-		return nil
+		return nil, fmt.Errorf("repository.FindOrCreateDefault: %w", err)
+	}
+
+	return profile, nil
+}
+
+func (r *profileRepository) insert(ctx context.Context, profile *Profile) error {
+	entity, err := fromModel(profile)
+	if err != nil {
+		return err
 	}
 
-	return ErrProfileAlreadyCreated
+	result, err := r.Collection().InsertOne(ctx, entity)
+	if err != nil {
+		return err
+	}
+
+	profile.Id = domain.ProfileId(result.InsertedID.(primitive.ObjectID).Hex())
+	return nil
+}
+
+func (r *profileRepository) Update(ctx context.Context, profile *Profile) error {
+	entity, err := fromModel(profile)
+	if err != nil {
+		return fmt.Errorf("repository.Update: %w", err)
+	}
+
+	result, err := r.Collection().UpdateByID(ctx, entity.Id, bson.M{"$set": entity})
+	if err != nil {
+		return fmt.Errorf("repository.Update: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+func (r *profileRepository) Delete(ctx context.Context, id domain.ProfileId) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return ErrProfileNotFound
+	}
+
+	if _, err := r.Collection().DeleteOne(ctx, bson.M{"_id": objId}); err != nil {
+		return fmt.Errorf("repository.Delete: %w", err)
+	}
+
+	return nil
 }
 
 type repositoryMiddleware func(ProfileRepository) ProfileRepository
@@ -126,24 +262,64 @@ func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
 
 func (m *loggingMiddleware) FindById(ctx context.Context, id domain.ProfileId) (profile *Profile, err error) {
 	defer func() {
-		m.logger.Debug("FindById", zap.String("id", string(id)), zap.Object("profile", profile), zap.Error(err))
+		m.logger.Debug("FindById", zap.String("id", string(id)), utils.Object("profile", profile), zap.Error(err))
 	}()
 
 	return m.next.FindById(ctx, id)
 }
 
-func (m *loggingMiddleware) FindByUserId(ctx context.Context, userId auth.UserId) (profile *Profile, err error) {
+func (m *loggingMiddleware) FindDefaultByUserId(ctx context.Context, userId auth.UserId) (profile *Profile, err error) {
+	defer func() {
+		m.logger.Debug("FindDefaultByUserId", zap.String("user_id", string(userId)), utils.Object("profile", profile), zap.Error(err))
+	}()
+
+	return m.next.FindDefaultByUserId(ctx, userId)
+}
+
+func (m *loggingMiddleware) GetByUserId(ctx context.Context, userId auth.UserId) (profiles []*Profile, err error) {
 	defer func() {
-		m.logger.Debug("FindByUserId", zap.String("user_id", string(userId)), zap.Object("profile", profile), zap.Error(err))
+		m.logger.Debug("GetByUserId", zap.String("user_id", string(userId)), zap.Objects("profiles", profiles), zap.Error(err))
 	}()
 
-	return m.next.FindByUserId(ctx, userId)
+	return m.next.GetByUserId(ctx, userId)
+}
+
+func (m *loggingMiddleware) GetAll(ctx context.Context) (profiles []*Profile, err error) {
+	defer func() {
+		m.logger.Debug("GetAll", zap.Int("count", len(profiles)), zap.Error(err))
+	}()
+
+	return m.next.GetAll(ctx)
+}
+
+func (m *loggingMiddleware) FindOrCreateDefault(ctx context.Context, profile *Profile) (result *Profile, err error) {
+	defer func() {
+		m.logger.Debug("FindOrCreateDefault", utils.Object("profile", result), zap.Error(err))
+	}()
+
+	return m.next.FindOrCreateDefault(ctx, profile)
 }
 
 func (m *loggingMiddleware) Create(ctx context.Context, profile *Profile) error {
 	defer func() {
-		m.logger.Debug("Create", zap.Object("profile", profile))
+		m.logger.Debug("Create", utils.Object("profile", profile))
 	}()
 
 	return m.next.Create(ctx, profile)
 }
+
+func (m *loggingMiddleware) Update(ctx context.Context, profile *Profile) (err error) {
+	defer func() {
+		m.logger.Debug("Update", utils.Object("profile", profile), zap.Error(err))
+	}()
+
+	return m.next.Update(ctx, profile)
+}
+
+func (m *loggingMiddleware) Delete(ctx context.Context, id domain.ProfileId) (err error) {
+	defer func() {
+		m.logger.Debug("Delete", zap.String("id", string(id)), zap.Error(err))
+	}()
+
+	return m.next.Delete(ctx, id)
+}
@@ -0,0 +1,37 @@
+package profiles_test
+
+import (
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/database/mongotest"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/storage/storagetest"
+	"go.uber.org/zap"
+)
+
+func TestProfileRepository(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		storagetest.TestProfileRepository(t, func(t *testing.T) profiles.ProfileRepository {
+			return profiles.NewMemoryProfileRepository(zap.NewNop())
+		})
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		storagetest.TestProfileRepository(t, func(t *testing.T) profiles.ProfileRepository {
+			return profiles.NewSQLProfileRepository(sqldbtest.Open(t), zap.NewNop())
+		})
+	})
+
+	t.Run("Postgres", func(t *testing.T) {
+		storagetest.TestProfileRepository(t, func(t *testing.T) profiles.ProfileRepository {
+			return profiles.NewSQLProfileRepository(sqldbtest.OpenPostgres(t), zap.NewNop())
+		})
+	})
+
+	t.Run("Mongo", func(t *testing.T) {
+		storagetest.TestProfileRepository(t, func(t *testing.T) profiles.ProfileRepository {
+			return profiles.NewProfileRepository(mongotest.Open(t), zap.NewNop())
+		})
+	})
+}
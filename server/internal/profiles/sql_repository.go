@@ -0,0 +1,191 @@
+package profiles
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlProfileRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLProfileRepository(db *sqldb.DB, logger *zap.Logger) ProfileRepository {
+	var repo ProfileRepository
+	repo = &sqlProfileRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+const profileColumns = "id, name, preferences, user_id, is_default"
+
+func scanProfile(row interface{ Scan(...any) error }) (*Profile, error) {
+	var profile Profile
+	var preferences string
+	if err := row.Scan(&profile.Id, &profile.Name, &preferences, &profile.UserId, &profile.Default); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(preferences), &profile.Preferences); err != nil {
+		return nil, err
+	}
+	if profile.Preferences == nil {
+		profile.Preferences = map[string]any{}
+	}
+
+	return &profile, nil
+}
+
+func (r *sqlProfileRepository) findOne(ctx context.Context, query string, args ...any) (*Profile, error) {
+	profile, err := scanProfile(r.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProfileNotFound
+	}
+
+	return profile, err
+}
+
+func (r *sqlProfileRepository) find(ctx context.Context, query string, args ...any) ([]*Profile, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Profile, error) {
+		return scanProfile(rows)
+	})
+}
+
+func (r *sqlProfileRepository) FindById(ctx context.Context, id domain.ProfileId) (*Profile, error) {
+	profile, err := r.findOne(ctx, "SELECT "+profileColumns+" FROM profiles WHERE id = ?", id)
+	if err != nil && !errors.Is(err, ErrProfileNotFound) {
+		return nil, fmt.Errorf("repository.FindById: %w", err)
+	}
+
+	return profile, err
+}
+
+func (r *sqlProfileRepository) FindDefaultByUserId(ctx context.Context, userId auth.UserId) (*Profile, error) {
+	profile, err := r.findOne(ctx, "SELECT "+profileColumns+" FROM profiles WHERE user_id = ? ORDER BY is_default DESC, id LIMIT 1", userId)
+	if err != nil && !errors.Is(err, ErrProfileNotFound) {
+		return nil, fmt.Errorf("repository.FindDefaultByUserId: %w", err)
+	}
+
+	return profile, err
+}
+
+func (r *sqlProfileRepository) GetByUserId(ctx context.Context, userId auth.UserId) ([]*Profile, error) {
+	profiles, err := r.find(ctx, "SELECT "+profileColumns+" FROM profiles WHERE user_id = ? ORDER BY id", userId)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetByUserId: %w", err)
+	}
+
+	return profiles, nil
+}
+
+func (r *sqlProfileRepository) GetAll(ctx context.Context) ([]*Profile, error) {
+	profiles, err := r.find(ctx, "SELECT "+profileColumns+" FROM profiles ORDER BY user_id, id")
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetAll: %w", err)
+	}
+
+	return profiles, nil
+}
+
+func (r *sqlProfileRepository) Create(ctx context.Context, profile *Profile) error {
+	// The unique index rejects the default profile if the user already has one
+	profile.Default = true
+	err := r.insert(ctx, profile)
+	if r.db.IsUniqueViolation(err) {
+		profile.Default = false
+		err = r.insert(ctx, profile)
+	}
+
+	if err != nil {
+		return fmt.Errorf("repository.Create: %w", err)
+	}
+
+	return nil
+}
+
+func (r *sqlProfileRepository) FindOrCreateDefault(ctx context.Context, profile *Profile) (*Profile, error) {
+	existing, err := r.FindDefaultByUserId(ctx, profile.UserId)
+	if !errors.Is(err, ErrProfileNotFound) {
+		return existing, err
+	}
+
+	profile.Default = true
+	if err := r.insert(ctx, profile); err != nil {
+		// Created by a concurrent request
+		if r.db.IsUniqueViolation(err) {
+			return r.FindDefaultByUserId(ctx, profile.UserId)
+		}
+
+		return nil, fmt.Errorf("repository.FindOrCreateDefault: %w", err)
+	}
+
+	return profile, nil
+}
+
+func (r *sqlProfileRepository) insert(ctx context.Context, profile *Profile) error {
+	preferences, err := marshalPreferences(profile.Preferences)
+	if err != nil {
+		return err
+	}
+
+	id := profile.Id
+	if id == "" {
+		id = domain.ProfileId(domain.NewId())
+	}
+
+	if _, err := r.db.ExecContext(ctx, "INSERT INTO profiles ("+profileColumns+") VALUES (?, ?, ?, ?, ?)",
+		id, profile.Name, preferences, profile.UserId, profile.Default); err != nil {
+		return err
+	}
+
+	profile.Id = id
+	return nil
+}
+
+func (r *sqlProfileRepository) Update(ctx context.Context, profile *Profile) error {
+	preferences, err := marshalPreferences(profile.Preferences)
+	if err != nil {
+		return fmt.Errorf("repository.Update: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, "UPDATE profiles SET name = ?, preferences = ?, user_id = ?, is_default = ? WHERE id = ?",
+		profile.Name, preferences, profile.UserId, profile.Default, profile.Id)
+	if err != nil {
+		return fmt.Errorf("repository.Update: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+func (r *sqlProfileRepository) Delete(ctx context.Context, id domain.ProfileId) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM profiles WHERE id = ?", id); err != nil {
+		return fmt.Errorf("repository.Delete: %w", err)
+	}
+
+	return nil
+}
+
+func marshalPreferences(preferences map[string]any) (string, error) {
+	if preferences == nil {
+		preferences = map[string]any{}
+	}
+
+	encoded, err := json.Marshal(preferences)
+	return string(encoded), err
+}
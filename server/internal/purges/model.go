@@ -0,0 +1,30 @@
+package purges
+
+import (
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap/zapcore"
+)
+
+type JobId string
+
+// Job tracks the purge of everything owned by a deleted profile. It is kept
+// until the purge completes so it can be resumed after failures or restarts.
+type Job struct {
+	Id            JobId
+	ProfileId     domain.ProfileId
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+func (j Job) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("id", string(j.Id))
+	encoder.AddString("profile_id", string(j.ProfileId))
+	encoder.AddInt("attempts", j.Attempts)
+	encoder.AddString("last_error", j.LastError)
+	encoder.AddTime("next_attempt_at", j.NextAttemptAt)
+	return nil
+}
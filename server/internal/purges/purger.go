@@ -0,0 +1,167 @@
+package purges
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/secrets"
+	"github.com/dreadster3/yapper/server/internal/shares"
+	"github.com/dreadster3/yapper/server/internal/tokens"
+	"github.com/dreadster3/yapper/server/internal/workspaces"
+	"go.uber.org/zap"
+)
+
+const (
+	pollInterval = time.Minute
+	maxBackoff   = time.Hour
+)
+
+// Purger removes the chats, messages, steps, shares, secrets, tokens and
+// memberships (chat and workspace) of deleted profiles in the background.
+// Every deletion is idempotent, so a job that failed halfway is simply run
+// again from the start.
+type Purger struct {
+	jobRepository       JobRepository
+	profileRepository   profiles.ProfileRepository
+	chatRepository      chats.ChatRepository
+	memberRepository    chats.MemberRepository
+	contentDeleter      chats.ContentDeleter
+	workspaceRepository workspaces.WorkspaceRepository
+	shareRepository     shares.ShareRepository
+	secretRepository    secrets.SecretRepository
+	tokenRepository     tokens.TokenRepository
+	logger              *zap.Logger
+
+	trigger chan struct{}
+}
+
+func NewPurger(jobRepository JobRepository, profileRepository profiles.ProfileRepository, chatRepository chats.ChatRepository, memberRepository chats.MemberRepository, contentDeleter chats.ContentDeleter, workspaceRepository workspaces.WorkspaceRepository, shareRepository shares.ShareRepository, secretRepository secrets.SecretRepository, tokenRepository tokens.TokenRepository, logger *zap.Logger) *Purger {
+	return &Purger{
+		jobRepository:       jobRepository,
+		profileRepository:   profileRepository,
+		chatRepository:      chatRepository,
+		memberRepository:    memberRepository,
+		contentDeleter:      contentDeleter,
+		workspaceRepository: workspaceRepository,
+		shareRepository:     shareRepository,
+		secretRepository:    secretRepository,
+		tokenRepository:     tokenRepository,
+		logger:              logger,
+		trigger:             make(chan struct{}, 1),
+	}
+}
+
+func (p *Purger) Schedule(ctx context.Context, profileId domain.ProfileId) error {
+	job := &Job{
+		ProfileId:     profileId,
+		NextAttemptAt: time.Now(),
+	}
+	if err := p.jobRepository.Create(ctx, job); err != nil {
+		return fmt.Errorf("Purger.Schedule: %w", err)
+	}
+
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run processes due jobs until ctx is done. Jobs left over from a previous
+// run are picked up immediately.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		p.runDueJobs(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.trigger:
+		}
+	}
+}
+
+func (p *Purger) runDueJobs(ctx context.Context) {
+	jobs, err := p.jobRepository.GetDue(ctx, time.Now())
+	if err != nil {
+		p.logger.Error("Failed to fetch purge jobs", zap.Error(err))
+		return
+	}
+
+	for _, job := range jobs {
+		if err := p.purge(ctx, job.ProfileId); err != nil {
+			job.Attempts++
+			job.LastError = err.Error()
+			job.NextAttemptAt = time.Now().Add(backoff(job.Attempts))
+
+			p.logger.Warn("Purge failed", zap.Object("job", job), zap.Error(err))
+			if err := p.jobRepository.Update(ctx, job); err != nil {
+				p.logger.Error("Failed to update purge job", zap.Object("job", job), zap.Error(err))
+			}
+			continue
+		}
+
+		if err := p.jobRepository.Delete(ctx, job.Id); err != nil {
+			p.logger.Error("Failed to delete purge job", zap.Object("job", job), zap.Error(err))
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context, profileId domain.ProfileId) error {
+	profileChats, err := p.chatRepository.GetByProfileId(ctx, profileId)
+	if err != nil {
+		return err
+	}
+
+	for _, chat := range profileChats {
+		// Workspace chats belong to the workspace and outlive their creator
+		if chat.WorkspaceId != "" {
+			continue
+		}
+
+		if err := p.contentDeleter.DeleteByChatId(ctx, chat.Id); err != nil {
+			return err
+		}
+
+		if err := p.chatRepository.Delete(ctx, chat.Id); err != nil {
+			return err
+		}
+	}
+
+	// Shares of workspace chats survive the chat loop above
+	if err := p.shareRepository.DeleteByProfileId(ctx, profileId); err != nil {
+		return err
+	}
+
+	if err := p.memberRepository.DeleteByProfileId(ctx, profileId); err != nil {
+		return err
+	}
+
+	if err := p.workspaceRepository.RemoveMember(ctx, profileId); err != nil {
+		return err
+	}
+
+	if err := p.secretRepository.DeleteByProfileId(ctx, profileId); err != nil {
+		return err
+	}
+
+	if err := p.tokenRepository.DeleteByProfileId(ctx, profileId); err != nil {
+		return err
+	}
+
+	return p.profileRepository.Delete(ctx, profileId)
+}
+
+func backoff(attempts int) time.Duration {
+	delay := pollInterval << min(attempts, 10)
+	return min(delay, maxBackoff)
+}
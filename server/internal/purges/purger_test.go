@@ -0,0 +1,289 @@
+package purges
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/messages"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/secrets"
+	"github.com/dreadster3/yapper/server/internal/shares"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"github.com/dreadster3/yapper/server/internal/tokens"
+	"github.com/dreadster3/yapper/server/internal/workspaces"
+	"go.uber.org/zap"
+)
+
+var errDeleteFailed = errors.New("delete failed")
+
+// failingDeleter fails once after the given number of successful deletions
+type failingDeleter struct {
+	chats.ContentDeleter
+	succeed int
+	failed  bool
+}
+
+func (d *failingDeleter) DeleteByChatId(ctx context.Context, chatId chats.ChatId) error {
+	if d.succeed == 0 && !d.failed {
+		d.failed = true
+		return errDeleteFailed
+	}
+	d.succeed--
+	return d.ContentDeleter.DeleteByChatId(ctx, chatId)
+}
+
+type purgerFixture struct {
+	purger     *Purger
+	jobs       JobRepository
+	profiles   profiles.ProfileRepository
+	chats      chats.ChatRepository
+	shares     shares.ShareRepository
+	workspaces workspaces.WorkspaceRepository
+	deleter    *failingDeleter
+}
+
+func newPurgerFixture(t *testing.T) *purgerFixture {
+	t.Helper()
+
+	db := sqldbtest.Open(t)
+	logger := zap.NewNop()
+
+	f := &purgerFixture{
+		jobs:       NewSQLJobRepository(db, logger),
+		profiles:   profiles.NewSQLProfileRepository(db, logger),
+		chats:      chats.NewSQLChatRepository(db, logger),
+		shares:     shares.NewSQLShareRepository(db, logger),
+		workspaces: workspaces.NewSQLWorkspaceRepository(db, logger),
+	}
+	memberRepository := chats.NewSQLMemberRepository(db, logger)
+	f.deleter = &failingDeleter{
+		ContentDeleter: chats.NewContentDeleters(
+			f.shares,
+			memberRepository,
+			messages.NewChatContentDeleter(messages.NewSQLMessageRepository(db, logger), steps.NewSQLStepRepository(db, logger)),
+		),
+		failed: true,
+	}
+	f.purger = NewPurger(f.jobs, f.profiles, f.chats, memberRepository, f.deleter, f.workspaces, f.shares, secrets.NewSQLSecretRepository(db, logger), tokens.NewSQLTokenRepository(db, logger), logger)
+
+	return f
+}
+
+func (f *purgerFixture) createProfile(t *testing.T) domain.ProfileId {
+	t.Helper()
+
+	profile := &profiles.Profile{Name: "profile", UserId: auth.UserId(domain.NewId())}
+	if err := f.profiles.Create(context.Background(), profile); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return profile.Id
+}
+
+func (f *purgerFixture) createChat(t *testing.T, profileId domain.ProfileId, workspaceId domain.WorkspaceId) chats.ChatId {
+	t.Helper()
+
+	chat := &chats.Chat{ProfileId: profileId, WorkspaceId: workspaceId, Name: "chat"}
+	if err := f.chats.Create(context.Background(), chat); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return chat.Id
+}
+
+func (f *purgerFixture) createShare(t *testing.T, profileId domain.ProfileId, chatId chats.ChatId) shares.ShareId {
+	t.Helper()
+
+	share := &shares.Share{ChatId: chatId, ProfileId: profileId, TokenHash: domain.NewId()}
+	if err := f.shares.Create(context.Background(), share); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return share.Id
+}
+
+// run processes every scheduled job as if it were due
+func (f *purgerFixture) run(t *testing.T) {
+	t.Helper()
+
+	ctx := context.Background()
+	jobs, err := f.jobs.GetDue(ctx, time.Now().Add(maxBackoff))
+	if err != nil {
+		t.Fatalf("GetDue() error = %v", err)
+	}
+	for _, job := range jobs {
+		job.NextAttemptAt = time.Now()
+		if err := f.jobs.Update(ctx, job); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+
+	f.purger.runDueJobs(ctx)
+}
+
+func (f *purgerFixture) pendingJobs(t *testing.T) []*Job {
+	t.Helper()
+
+	jobs, err := f.jobs.GetDue(context.Background(), time.Now().Add(maxBackoff))
+	if err != nil {
+		t.Fatalf("GetDue() error = %v", err)
+	}
+	return jobs
+}
+
+func TestPurgerResumesAfterPartialRun(t *testing.T) {
+	ctx := context.Background()
+	f := newPurgerFixture(t)
+
+	profileId := f.createProfile(t)
+	first := f.createChat(t, profileId, "")
+	second := f.createChat(t, profileId, "")
+	f.deleter.failed = false
+	f.deleter.succeed = 1
+
+	if err := f.purger.Schedule(ctx, profileId); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	f.run(t)
+
+	jobs := f.pendingJobs(t)
+	if len(jobs) != 1 {
+		t.Fatalf("pending jobs = %d, want 1", len(jobs))
+	}
+	if jobs[0].Attempts != 1 || jobs[0].LastError != errDeleteFailed.Error() {
+		t.Errorf("job = %+v, want 1 attempt failed with %v", jobs[0], errDeleteFailed)
+	}
+	if _, err := f.profiles.FindById(ctx, profileId); err != nil {
+		t.Errorf("FindById() error = %v, want the profile kept until the purge completes", err)
+	}
+
+	remaining := 0
+	for _, chatId := range []chats.ChatId{first, second} {
+		if _, err := f.chats.FindById(ctx, chatId); err == nil {
+			remaining++
+		}
+	}
+	if remaining != 1 {
+		t.Fatalf("remaining chats = %d, want 1", remaining)
+	}
+
+	f.run(t)
+
+	if jobs := f.pendingJobs(t); len(jobs) != 0 {
+		t.Errorf("pending jobs = %d, want 0", len(jobs))
+	}
+	for _, chatId := range []chats.ChatId{first, second} {
+		if _, err := f.chats.FindById(ctx, chatId); !errors.Is(err, chats.ErrChatNotFound) {
+			t.Errorf("FindById(%s) error = %v, want %v", chatId, err, chats.ErrChatNotFound)
+		}
+	}
+	if _, err := f.profiles.FindById(ctx, profileId); !errors.Is(err, profiles.ErrProfileNotFound) {
+		t.Errorf("FindById() error = %v, want %v", err, profiles.ErrProfileNotFound)
+	}
+}
+
+func TestPurgerRunsTwice(t *testing.T) {
+	ctx := context.Background()
+	f := newPurgerFixture(t)
+
+	profileId := f.createProfile(t)
+	chatId := f.createChat(t, profileId, "")
+
+	for range 2 {
+		if err := f.purger.Schedule(ctx, profileId); err != nil {
+			t.Fatalf("Schedule() error = %v", err)
+		}
+		f.run(t)
+
+		if jobs := f.pendingJobs(t); len(jobs) != 0 {
+			t.Fatalf("pending jobs = %+v, want none", jobs)
+		}
+	}
+
+	if _, err := f.chats.FindById(ctx, chatId); !errors.Is(err, chats.ErrChatNotFound) {
+		t.Errorf("FindById() error = %v, want %v", err, chats.ErrChatNotFound)
+	}
+}
+
+func TestPurgerRemovesSharesOfWorkspaceChats(t *testing.T) {
+	ctx := context.Background()
+	f := newPurgerFixture(t)
+
+	profileId := f.createProfile(t)
+	other := f.createProfile(t)
+	workspace := &workspaces.Workspace{
+		Name: "workspace",
+		Members: []workspaces.Member{
+			{ProfileId: other, Role: workspaces.RoleAdmin},
+			{ProfileId: profileId, Role: workspaces.RoleMember},
+		},
+	}
+	if err := f.workspaces.Create(ctx, workspace); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	chatId := f.createChat(t, profileId, workspace.Id)
+	purged := f.createShare(t, profileId, chatId)
+	kept := f.createShare(t, other, chatId)
+
+	if err := f.purger.Schedule(ctx, profileId); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	f.run(t)
+
+	if _, err := f.chats.FindById(ctx, chatId); err != nil {
+		t.Errorf("FindById() error = %v, want the workspace chat kept", err)
+	}
+	if _, err := f.shares.FindById(ctx, purged); !errors.Is(err, shares.ErrShareNotFound) {
+		t.Errorf("FindById() error = %v, want %v", err, shares.ErrShareNotFound)
+	}
+	if _, err := f.shares.FindById(ctx, kept); err != nil {
+		t.Errorf("FindById() error = %v, want the share of the other member kept", err)
+	}
+}
+
+func TestPurgerReplacesLastWorkspaceAdmin(t *testing.T) {
+	ctx := context.Background()
+	f := newPurgerFixture(t)
+
+	profileId := f.createProfile(t)
+	member := f.createProfile(t)
+	viewer := f.createProfile(t)
+	workspace := &workspaces.Workspace{
+		Name: "workspace",
+		Members: []workspaces.Member{
+			{ProfileId: profileId, Role: workspaces.RoleAdmin},
+			{ProfileId: member, Role: workspaces.RoleMember},
+			{ProfileId: viewer, Role: workspaces.RoleViewer},
+		},
+	}
+	if err := f.workspaces.Create(ctx, workspace); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if err := f.purger.Schedule(ctx, profileId); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	f.run(t)
+
+	got, err := f.workspaces.FindById(ctx, workspace.Id)
+	if err != nil {
+		t.Fatalf("FindById() error = %v", err)
+	}
+
+	want := []workspaces.Member{
+		{ProfileId: member, Role: workspaces.RoleAdmin},
+		{ProfileId: viewer, Role: workspaces.RoleViewer},
+	}
+	if len(got.Members) != len(want) {
+		t.Fatalf("Members = %+v, want %+v", got.Members, want)
+	}
+	for i := range want {
+		if got.Members[i] != want[i] {
+			t.Errorf("Members[%d] = %+v, want %+v", i, got.Members[i], want[i])
+		}
+	}
+}
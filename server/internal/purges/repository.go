@@ -0,0 +1,189 @@
+package purges
+
+import (
+	"context"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/database"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+type JobRepository interface {
+	Create(ctx context.Context, job *Job) error
+	GetDue(ctx context.Context, now time.Time) ([]*Job, error)
+	Update(ctx context.Context, job *Job) error
+	Delete(ctx context.Context, id JobId) error
+}
+
+const collectionName = "purge_jobs"
+
+type job struct {
+	Id            primitive.ObjectID `bson:"_id"`
+	ProfileId     primitive.ObjectID `bson:"profile_id"`
+	Attempts      int                `bson:"attempts"`
+	LastError     string             `bson:"last_error"`
+	NextAttemptAt primitive.DateTime `bson:"next_attempt_at"`
+	CreatedAt     primitive.DateTime `bson:"created_at"`
+}
+
+func (j job) ToModel() *Job {
+	return &Job{
+		Id:            JobId(j.Id.Hex()),
+		ProfileId:     domain.ProfileId(j.ProfileId.Hex()),
+		Attempts:      j.Attempts,
+		LastError:     j.LastError,
+		NextAttemptAt: j.NextAttemptAt.Time(),
+		CreatedAt:     j.CreatedAt.Time(),
+	}
+}
+
+func fromModel(j *Job) (*job, error) {
+	id, err := database.ObjectId(j.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	profileId, err := primitive.ObjectIDFromHex(string(j.ProfileId))
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := j.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return &job{
+		Id:            id,
+		ProfileId:     profileId,
+		Attempts:      j.Attempts,
+		LastError:     j.LastError,
+		NextAttemptAt: primitive.NewDateTimeFromTime(j.NextAttemptAt),
+		CreatedAt:     primitive.NewDateTimeFromTime(createdAt),
+	}, nil
+}
+
+type jobRepository struct {
+	db *mongo.Database
+}
+
+func NewJobRepository(db *mongo.Database, logger *zap.Logger) JobRepository {
+	var repo JobRepository
+	repo = &jobRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *jobRepository) Collection() *mongo.Collection {
+	return r.db.Collection(collectionName)
+}
+
+func (r *jobRepository) Create(ctx context.Context, job *Job) error {
+	entity, err := fromModel(job)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.Collection().InsertOne(ctx, entity)
+	if err != nil {
+		return err
+	}
+
+	job.Id = JobId(result.InsertedID.(primitive.ObjectID).Hex())
+	return nil
+}
+
+func (r *jobRepository) GetDue(ctx context.Context, now time.Time) ([]*Job, error) {
+	cursor, err := r.Collection().Find(ctx, bson.M{"next_attempt_at": bson.M{"$lte": primitive.NewDateTimeFromTime(now)}})
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []job
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(j job) *Job {
+		return j.ToModel()
+	}), nil
+}
+
+func (r *jobRepository) Update(ctx context.Context, job *Job) error {
+	entity, err := fromModel(job)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().UpdateByID(ctx, entity.Id, bson.M{"$set": entity}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *jobRepository) Delete(ctx context.Context, id JobId) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteOne(ctx, bson.M{"_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type repositoryMiddleware func(JobRepository) JobRepository
+
+type loggingMiddleware struct {
+	logger *zap.Logger
+	next   JobRepository
+}
+
+func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
+	return func(next JobRepository) JobRepository {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *loggingMiddleware) Create(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		m.logger.Debug("Create", utils.Object("job", job), zap.Error(err))
+	}()
+
+	return m.next.Create(ctx, job)
+}
+
+func (m *loggingMiddleware) GetDue(ctx context.Context, now time.Time) (jobs []*Job, err error) {
+	defer func() {
+		m.logger.Debug("GetDue", zap.Time("now", now), zap.Objects("jobs", jobs), zap.Error(err))
+	}()
+
+	return m.next.GetDue(ctx, now)
+}
+
+func (m *loggingMiddleware) Update(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		m.logger.Debug("Update", utils.Object("job", job), zap.Error(err))
+	}()
+
+	return m.next.Update(ctx, job)
+}
+
+func (m *loggingMiddleware) Delete(ctx context.Context, id JobId) (err error) {
+	defer func() {
+		m.logger.Debug("Delete", zap.String("id", string(id)), zap.Error(err))
+	}()
+
+	return m.next.Delete(ctx, id)
+}
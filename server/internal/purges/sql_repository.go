@@ -0,0 +1,62 @@
+package purges
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlJobRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLJobRepository(db *sqldb.DB, logger *zap.Logger) JobRepository {
+	var repo JobRepository
+	repo = &sqlJobRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *sqlJobRepository) Create(ctx context.Context, job *Job) error {
+	if job.Id == "" {
+		job.Id = JobId(domain.NewId())
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.ExecContext(ctx, "INSERT INTO purge_jobs (id, profile_id, attempts, last_error, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		job.Id, job.ProfileId, job.Attempts, job.LastError, job.NextAttemptAt.UTC(), job.CreatedAt.UTC())
+	return err
+}
+
+func (r *sqlJobRepository) GetDue(ctx context.Context, now time.Time) ([]*Job, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, profile_id, attempts, last_error, next_attempt_at, created_at FROM purge_jobs WHERE next_attempt_at <= ? ORDER BY next_attempt_at", now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Job, error) {
+		var job Job
+		if err := rows.Scan(&job.Id, &job.ProfileId, &job.Attempts, &job.LastError, &job.NextAttemptAt, &job.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		return &job, nil
+	})
+}
+
+func (r *sqlJobRepository) Update(ctx context.Context, job *Job) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE purge_jobs SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		job.Attempts, job.LastError, job.NextAttemptAt.UTC(), job.Id)
+	return err
+}
+
+func (r *sqlJobRepository) Delete(ctx context.Context, id JobId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM purge_jobs WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/gin-gonic/gin"
+)
+
+type SecretHandler interface {
+	List(c *gin.Context)
+	Save(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type secretHandler struct {
+	repository SecretRepository
+	cipher     encryption.Cipher
+}
+
+func NewSecretHandler(repository SecretRepository, cipher encryption.Cipher) SecretHandler {
+	return &secretHandler{
+		repository: repository,
+		cipher:     cipher,
+	}
+}
+
+type secretUri struct {
+	Provider string `uri:"provider" binding:"required,registered_provider"`
+}
+
+func (h *secretHandler) List(c *gin.Context) {
+	profile := profiles.GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	secrets, err := h.repository.GetByProfileId(ctx, profile.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, secrets)
+}
+
+func (h *secretHandler) Save(c *gin.Context) {
+	var secret *Secret
+	if err := c.ShouldBindJSON(&secret); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var uri secretUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	envelope, err := h.cipher.Seal([]byte(secret.Value), associatedData(profile.Id, uri.Provider))
+	if err != nil {
+		if errors.Is(err, encryption.ErrNoMasterKey) {
+			c.Status(http.StatusServiceUnavailable)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	secret.ProfileId = profile.Id
+	secret.Provider = uri.Provider
+	secret.Hint = hint(secret.Value)
+	secret.Envelope = envelope
+
+	ctx := c.Request.Context()
+	if err := h.repository.Save(ctx, secret); err != nil {
+		c.Error(err)
+		return
+	}
+
+	secret.Value = ""
+	c.JSON(http.StatusOK, secret)
+}
+
+func (h *secretHandler) Delete(c *gin.Context) {
+	var uri secretUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	if err := h.repository.Delete(ctx, profile.Id, uri.Provider); err != nil {
+		if errors.Is(err, ErrSecretNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,42 @@
+package secrets
+
+import (
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"go.uber.org/zap/zapcore"
+)
+
+// Secret is an API key a profile brings for a provider. The value is write
+// only, only the envelope it is sealed in is stored.
+type Secret struct {
+	ProfileId domain.ProfileId     `json:"-" binding:"-"`
+	Provider  string               `json:"provider" binding:"-"`
+	Value     string               `json:"value,omitempty" binding:"required"`
+	Hint      string               `json:"hint" binding:"-"`
+	Envelope  *encryption.Envelope `json:"-" binding:"-"`
+	UpdatedAt time.Time            `json:"updated_at" binding:"-"`
+}
+
+// hint keeps the last characters of the key so users can tell keys apart
+func hint(value string) string {
+	const visible = 4
+	if len(value) <= visible*2 {
+		return "****"
+	}
+
+	return "****" + value[len(value)-visible:]
+}
+
+// associatedData binds the envelope to the profile and provider of the secret
+func associatedData(profileId domain.ProfileId, provider string) []byte {
+	return encryption.AssociatedData("profile_secret", string(profileId), provider)
+}
+
+func (s Secret) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("profile_id", string(s.ProfileId))
+	encoder.AddString("provider", s.Provider)
+	encoder.AddString("hint", s.Hint)
+	return nil
+}
@@ -0,0 +1,219 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+type SecretRepository interface {
+	Find(ctx context.Context, profileId domain.ProfileId, provider string) (*Secret, error)
+	GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Secret, error)
+	Save(ctx context.Context, secret *Secret) error
+	Delete(ctx context.Context, profileId domain.ProfileId, provider string) error
+	DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error
+}
+
+const collectionName = "profile_secrets"
+
+type secret struct {
+	ProfileId  primitive.ObjectID `bson:"profile_id"`
+	Provider   string             `bson:"provider"`
+	Hint       string             `bson:"hint"`
+	KeyId      string             `bson:"key_id"`
+	WrappedKey []byte             `bson:"wrapped_key"`
+	Ciphertext []byte             `bson:"ciphertext"`
+	UpdatedAt  primitive.DateTime `bson:"updated_at"`
+}
+
+func (s secret) ToModel() *Secret {
+	return &Secret{
+		ProfileId: domain.ProfileId(s.ProfileId.Hex()),
+		Provider:  s.Provider,
+		Hint:      s.Hint,
+		Envelope: &encryption.Envelope{
+			KeyId:      s.KeyId,
+			WrappedKey: s.WrappedKey,
+			Ciphertext: s.Ciphertext,
+		},
+		UpdatedAt: s.UpdatedAt.Time(),
+	}
+}
+
+type secretRepository struct {
+	db *mongo.Database
+}
+
+func NewSecretRepository(db *mongo.Database, logger *zap.Logger) SecretRepository {
+	var repo SecretRepository
+	repo = &secretRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *secretRepository) Collection() *mongo.Collection {
+	return r.db.Collection(collectionName)
+}
+
+func (r *secretRepository) Find(ctx context.Context, profileId domain.ProfileId, provider string) (*Secret, error) {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return nil, err
+	}
+
+	var entity secret
+	if err := r.Collection().FindOne(ctx, bson.M{"profile_id": objId, "provider": provider}).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrSecretNotFound
+		}
+
+		return nil, err
+	}
+
+	return entity.ToModel(), nil
+}
+
+func (r *secretRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Secret, error) {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.Collection().Find(ctx, bson.M{"profile_id": objId})
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []secret
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(s secret) *Secret {
+		return s.ToModel()
+	}), nil
+}
+
+// Save replaces the secret of the profile for the provider
+func (r *secretRepository) Save(ctx context.Context, model *Secret) error {
+	objId, err := primitive.ObjectIDFromHex(string(model.ProfileId))
+	if err != nil {
+		return err
+	}
+
+	model.UpdatedAt = time.Now()
+	entity := secret{
+		ProfileId:  objId,
+		Provider:   model.Provider,
+		Hint:       model.Hint,
+		KeyId:      model.Envelope.KeyId,
+		WrappedKey: model.Envelope.WrappedKey,
+		Ciphertext: model.Envelope.Ciphertext,
+		UpdatedAt:  primitive.NewDateTimeFromTime(model.UpdatedAt),
+	}
+
+	filter := bson.M{"profile_id": objId, "provider": model.Provider}
+	if _, err := r.Collection().ReplaceOne(ctx, filter, entity, options.Replace().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *secretRepository) Delete(ctx context.Context, profileId domain.ProfileId, provider string) error {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return err
+	}
+
+	result, err := r.Collection().DeleteOne(ctx, bson.M{"profile_id": objId, "provider": provider})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrSecretNotFound
+	}
+
+	return nil
+}
+
+func (r *secretRepository) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteMany(ctx, bson.M{"profile_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type repositoryMiddleware func(SecretRepository) SecretRepository
+
+type loggingMiddleware struct {
+	logger *zap.Logger
+	next   SecretRepository
+}
+
+func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
+	return func(next SecretRepository) SecretRepository {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *loggingMiddleware) Find(ctx context.Context, profileId domain.ProfileId, provider string) (secret *Secret, err error) {
+	defer func() {
+		m.logger.Debug("Find", zap.String("profile_id", string(profileId)), zap.String("provider", provider), utils.Object("secret", secret), zap.Error(err))
+	}()
+
+	return m.next.Find(ctx, profileId, provider)
+}
+
+func (m *loggingMiddleware) GetByProfileId(ctx context.Context, profileId domain.ProfileId) (secrets []*Secret, err error) {
+	defer func() {
+		m.logger.Debug("GetByProfileId", zap.String("profile_id", string(profileId)), zap.Objects("secrets", secrets), zap.Error(err))
+	}()
+
+	return m.next.GetByProfileId(ctx, profileId)
+}
+
+func (m *loggingMiddleware) Save(ctx context.Context, secret *Secret) (err error) {
+	defer func() {
+		m.logger.Debug("Save", utils.Object("secret", secret), zap.Error(err))
+	}()
+
+	return m.next.Save(ctx, secret)
+}
+
+func (m *loggingMiddleware) Delete(ctx context.Context, profileId domain.ProfileId, provider string) (err error) {
+	defer func() {
+		m.logger.Debug("Delete", zap.String("profile_id", string(profileId)), zap.String("provider", provider), zap.Error(err))
+	}()
+
+	return m.next.Delete(ctx, profileId, provider)
+}
+
+func (m *loggingMiddleware) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) (err error) {
+	defer func() {
+		m.logger.Debug("DeleteByProfileId", zap.String("profile_id", string(profileId)), zap.Error(err))
+	}()
+
+	return m.next.DeleteByProfileId(ctx, profileId)
+}
@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"go.uber.org/zap"
+)
+
+type providerResolver struct {
+	repository SecretRepository
+	cipher     encryption.Cipher
+	registry   *providers.Registry
+	next       providers.Resolver
+	logger     *zap.Logger
+}
+
+// NewProviderResolver builds the provider with the profile's own API key when
+// it has one, the server default provider is used otherwise
+func NewProviderResolver(repository SecretRepository, cipher encryption.Cipher, registry *providers.Registry, next providers.Resolver, logger *zap.Logger) providers.Resolver {
+	return &providerResolver{
+		repository: repository,
+		cipher:     cipher,
+		registry:   registry,
+		next:       next,
+		logger:     logger,
+	}
+}
+
+func (r *providerResolver) Resolve(ctx context.Context, scope providers.Scope, name string, model string) (providers.Provider, error) {
+	config, ok := r.registry.Config(name)
+	if !ok || scope.ProfileId == "" {
+		return r.next.Resolve(ctx, scope, name, model)
+	}
+
+	// The allow list of the administrator applies to the profile's own keys
+	if !config.AllowsModel(model) {
+		return nil, fmt.Errorf("%w: %s", providers.ErrModelNotAllowed, model)
+	}
+
+	secret, err := r.repository.Find(ctx, scope.ProfileId, name)
+	if err != nil {
+		if errors.Is(err, ErrSecretNotFound) {
+			return r.next.Resolve(ctx, scope, name, model)
+		}
+
+		return nil, err
+	}
+
+	apiKey, err := r.cipher.Open(secret.Envelope, associatedData(secret.ProfileId, secret.Provider))
+	if err != nil {
+		return nil, err
+	}
+
+	config.APIKey = string(apiKey)
+	return providers.NewProvider(config, r.logger.With(zap.String("provider", name), zap.String("profile_id", string(scope.ProfileId))))
+}
@@ -0,0 +1,57 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"go.uber.org/zap"
+)
+
+var errNextResolver = errors.New("next resolver")
+
+type nextResolver struct{}
+
+func (nextResolver) Resolve(ctx context.Context, scope providers.Scope, name string, model string) (providers.Provider, error) {
+	return nil, errNextResolver
+}
+
+func TestProviderResolverAllowedModels(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLSecretRepository(sqldbtest.Open(t), zap.NewNop())
+
+	registry := providers.NewRegistry(zap.NewNop())
+	if err := registry.Register("openai", providers.Config{Type: providers.TypeOpenAI, URL: "https://api.openai.com/v1", AllowedModels: []string{"gpt-4o"}}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	cipher, err := encryption.NewEnvelopeCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("NewEnvelopeCipher() error = %v", err)
+	}
+
+	secret := &Secret{ProfileId: "profile", Provider: "openai", Hint: "...cret"}
+	secret.Envelope, err = cipher.Seal([]byte("sk-secret"), associatedData(secret.ProfileId, secret.Provider))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if err := repo.Save(ctx, secret); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	resolver := NewProviderResolver(repo, cipher, registry, nextResolver{}, zap.NewNop())
+	scope := providers.Scope{ProfileId: secret.ProfileId}
+
+	if _, err := resolver.Resolve(ctx, scope, "openai", "gpt-4.1"); !errors.Is(err, providers.ErrModelNotAllowed) {
+		t.Errorf("Resolve() of a model outside the allow list error = %v, want %v", err, providers.ErrModelNotAllowed)
+	}
+	if provider, err := resolver.Resolve(ctx, scope, "openai", "gpt-4o"); err != nil || provider == nil {
+		t.Errorf("Resolve() of an allowed model = %v, %v, want the provider with the stored key", provider, err)
+	}
+	if _, err := resolver.Resolve(ctx, providers.Scope{ProfileId: "other"}, "openai", "gpt-4o"); !errors.Is(err, errNextResolver) {
+		t.Errorf("Resolve() without a stored key error = %v, want the next resolver", err)
+	}
+}
@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlSecretRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLSecretRepository(db *sqldb.DB, logger *zap.Logger) SecretRepository {
+	var repo SecretRepository
+	repo = &sqlSecretRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+const secretColumns = "profile_id, provider, hint, key_id, wrapped_key, ciphertext, updated_at"
+
+func scanSecret(row interface{ Scan(...any) error }) (*Secret, error) {
+	secret := Secret{Envelope: &encryption.Envelope{}}
+	if err := row.Scan(&secret.ProfileId, &secret.Provider, &secret.Hint, &secret.Envelope.KeyId, &secret.Envelope.WrappedKey, &secret.Envelope.Ciphertext, &secret.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+func (r *sqlSecretRepository) Find(ctx context.Context, profileId domain.ProfileId, provider string) (*Secret, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+secretColumns+" FROM profile_secrets WHERE profile_id = ? AND provider = ?", profileId, provider)
+	secret, err := scanSecret(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSecretNotFound
+	}
+
+	return secret, err
+}
+
+func (r *sqlSecretRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Secret, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+secretColumns+" FROM profile_secrets WHERE profile_id = ? ORDER BY provider", profileId)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Secret, error) {
+		return scanSecret(rows)
+	})
+}
+
+// Save replaces the secret of the profile for the provider
+func (r *sqlSecretRepository) Save(ctx context.Context, secret *Secret) error {
+	secret.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, `INSERT INTO profile_secrets (`+secretColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (profile_id, provider) DO UPDATE SET
+			hint = excluded.hint,
+			key_id = excluded.key_id,
+			wrapped_key = excluded.wrapped_key,
+			ciphertext = excluded.ciphertext,
+			updated_at = excluded.updated_at`,
+		secret.ProfileId, secret.Provider, secret.Hint, secret.Envelope.KeyId, secret.Envelope.WrappedKey, secret.Envelope.Ciphertext, secret.UpdatedAt.UTC())
+	return err
+}
+
+func (r *sqlSecretRepository) Delete(ctx context.Context, profileId domain.ProfileId, provider string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM profile_secrets WHERE profile_id = ? AND provider = ?", profileId, provider)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSecretNotFound
+	}
+
+	return nil
+}
+
+func (r *sqlSecretRepository) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM profile_secrets WHERE profile_id = ?", profileId)
+	return err
+}
@@ -0,0 +1,193 @@
+package shares
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/messages"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"github.com/gin-gonic/gin"
+)
+
+type ShareHandler interface {
+	Create(c *gin.Context)
+	List(c *gin.Context)
+	Revoke(c *gin.Context)
+	GetShared(c *gin.Context)
+}
+
+type shareHandler struct {
+	repository        ShareRepository
+	chatRepository    chats.ChatRepository
+	authorizer        chats.Authorizer
+	messageRepository messages.MessageRepository
+	stepRepository    steps.StepRepository
+}
+
+func NewShareHandler(repository ShareRepository, chatRepository chats.ChatRepository, authorizer chats.Authorizer, messageRepository messages.MessageRepository, stepRepository steps.StepRepository) ShareHandler {
+	return &shareHandler{
+		repository:        repository,
+		chatRepository:    chatRepository,
+		authorizer:        authorizer,
+		messageRepository: messageRepository,
+		stepRepository:    stepRepository,
+	}
+}
+
+func (h *shareHandler) Create(c *gin.Context) {
+	var share *Share
+	if err := c.ShouldBindJSON(&share); err != nil {
+		c.Error(err)
+		return
+	}
+
+	chat, ok := chats.AuthorizeChat(c, h.authorizer, chats.PermissionManage)
+	if !ok {
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	share.TokenHash = hashToken(token)
+	share.ChatId = chat.Id
+	share.ProfileId = profiles.GetProfileFromContext(c).Id
+
+	ctx := c.Request.Context()
+	if err := h.repository.Create(ctx, share); err != nil {
+		c.Error(err)
+		return
+	}
+
+	share.Token = token
+	c.JSON(http.StatusCreated, share)
+}
+
+func (h *shareHandler) List(c *gin.Context) {
+	chat, ok := chats.AuthorizeChat(c, h.authorizer, chats.PermissionManage)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	shares, err := h.repository.GetByChatId(ctx, chat.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (h *shareHandler) Revoke(c *gin.Context) {
+	var uri struct {
+		ShareId ShareId `uri:"share_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	chat, ok := chats.AuthorizeChat(c, h.authorizer, chats.PermissionManage)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	share, err := h.repository.FindById(ctx, uri.ShareId)
+	if err == nil && share.ChatId != chat.Id {
+		err = ErrShareNotFound
+	}
+	if err != nil {
+		if errors.Is(err, ErrShareNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	if share.RevokedAt == nil {
+		now := time.Now()
+		share.RevokedAt = &now
+		if err := h.repository.Update(ctx, share); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// GetShared is reachable without authentication, the token is the only
+// credential so every failure is reported as not found.
+func (h *shareHandler) GetShared(c *gin.Context) {
+	var uri struct {
+		Token string `uri:"token" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	share, err := h.repository.FindByTokenHash(ctx, hashToken(uri.Token))
+	if err == nil && !share.IsActive(time.Now()) {
+		err = ErrShareNotFound
+	}
+	if err != nil {
+		if errors.Is(err, ErrShareNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	chat, err := h.chatRepository.FindById(ctx, share.ChatId)
+	if err != nil {
+		if errors.Is(err, chats.ErrChatNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	chatMessages, err := h.messageRepository.GetByChatId(ctx, chat.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	snapshot := Snapshot{
+		Chat: SnapshotChat{
+			Id:   chat.Id,
+			Name: chat.Name,
+		},
+		Messages: make([]SnapshotMessage, len(chatMessages)),
+	}
+	for idx, message := range chatMessages {
+		messageSteps, err := h.stepRepository.GetByMessageId(ctx, message.Id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		snapshot.Messages[idx] = SnapshotMessage{
+			Id:        message.Id,
+			Role:      message.Role,
+			Content:   message.Content,
+			CreatedAt: message.CreatedAt,
+			Steps:     messageSteps,
+		}
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
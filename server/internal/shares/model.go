@@ -0,0 +1,90 @@
+package shares
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/messages"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"go.uber.org/zap/zapcore"
+)
+
+const tokenSize = 32
+
+type ShareId string
+
+// Share is a read-only link to a chat. Only the hash of the token is stored,
+// the token is returned once on creation.
+type Share struct {
+	Id        ShareId          `json:"id" binding:"-"`
+	Token     string           `json:"token,omitempty" binding:"-"`
+	TokenHash string           `json:"-" binding:"-"`
+	ChatId    chats.ChatId     `json:"chat_id" binding:"-"`
+	ProfileId domain.ProfileId `json:"-" binding:"-"`
+	ExpiresAt *time.Time       `json:"expires_at,omitempty" binding:"omitempty,gt"`
+	RevokedAt *time.Time       `json:"revoked_at,omitempty" binding:"-"`
+	CreatedAt time.Time        `json:"created_at" binding:"-"`
+}
+
+func (s Share) IsActive(now time.Time) bool {
+	if s.RevokedAt != nil {
+		return false
+	}
+
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
+
+func (s Share) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("id", string(s.Id))
+	encoder.AddString("chat_id", string(s.ChatId))
+	encoder.AddString("profile_id", string(s.ProfileId))
+	if s.ExpiresAt != nil {
+		encoder.AddTime("expires_at", *s.ExpiresAt)
+	}
+	if s.RevokedAt != nil {
+		encoder.AddTime("revoked_at", *s.RevokedAt)
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	token := make([]byte, tokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashToken is a plain SHA-256, tokens are random so they cannot be brute
+// forced
+func hashToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+type SnapshotChat struct {
+	Id   chats.ChatId `json:"id"`
+	Name string       `json:"name"`
+}
+
+// SnapshotMessage only exposes what the chat shows, status and backend details
+// stay private to the members
+type SnapshotMessage struct {
+	Id        domain.MessageId     `json:"id"`
+	Role      messages.MessageRole `json:"role"`
+	Content   string               `json:"content"`
+	CreatedAt time.Time            `json:"created_at"`
+	Steps     []*steps.Step        `json:"steps"`
+}
+
+// Snapshot is the read-only view of a chat exposed through a share link.
+type Snapshot struct {
+	Chat     SnapshotChat      `json:"chat"`
+	Messages []SnapshotMessage `json:"messages"`
+}
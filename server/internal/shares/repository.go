@@ -0,0 +1,292 @@
+package shares
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/database"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var ErrShareNotFound = errors.New("share not found")
+
+type ShareRepository interface {
+	FindById(ctx context.Context, id ShareId) (*Share, error)
+	FindByTokenHash(ctx context.Context, hash string) (*Share, error)
+	GetByChatId(ctx context.Context, chatId chats.ChatId) ([]*Share, error)
+	Create(ctx context.Context, share *Share) error
+	Update(ctx context.Context, share *Share) error
+	DeleteByChatId(ctx context.Context, chatId chats.ChatId) error
+	// DeleteByProfileId removes the shares the profile created
+	DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error
+}
+
+const collectionName = "shares"
+
+type share struct {
+	Id        primitive.ObjectID  `bson:"_id"`
+	TokenHash string              `bson:"token_hash"`
+	ChatId    primitive.ObjectID  `bson:"chat_id"`
+	ProfileId primitive.ObjectID  `bson:"profile_id"`
+	ExpiresAt *primitive.DateTime `bson:"expires_at"`
+	RevokedAt *primitive.DateTime `bson:"revoked_at"`
+	CreatedAt primitive.DateTime  `bson:"created_at"`
+}
+
+func toTime(dateTime *primitive.DateTime) *time.Time {
+	if dateTime == nil {
+		return nil
+	}
+
+	t := dateTime.Time()
+	return &t
+}
+
+func fromTime(t *time.Time) *primitive.DateTime {
+	if t == nil {
+		return nil
+	}
+
+	dateTime := primitive.NewDateTimeFromTime(*t)
+	return &dateTime
+}
+
+func (s share) ToModel() *Share {
+	return &Share{
+		Id:        ShareId(s.Id.Hex()),
+		TokenHash: s.TokenHash,
+		ChatId:    chats.ChatId(s.ChatId.Hex()),
+		ProfileId: domain.ProfileId(s.ProfileId.Hex()),
+		ExpiresAt: toTime(s.ExpiresAt),
+		RevokedAt: toTime(s.RevokedAt),
+		CreatedAt: s.CreatedAt.Time(),
+	}
+}
+
+func fromModel(s *Share) (*share, error) {
+	id, err := database.ObjectId(s.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	chatId, err := primitive.ObjectIDFromHex(string(s.ChatId))
+	if err != nil {
+		return nil, err
+	}
+
+	profileId, err := primitive.ObjectIDFromHex(string(s.ProfileId))
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := s.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return &share{
+		Id:        id,
+		TokenHash: s.TokenHash,
+		ChatId:    chatId,
+		ProfileId: profileId,
+		ExpiresAt: fromTime(s.ExpiresAt),
+		RevokedAt: fromTime(s.RevokedAt),
+		CreatedAt: primitive.NewDateTimeFromTime(createdAt),
+	}, nil
+}
+
+type shareRepository struct {
+	db *mongo.Database
+}
+
+func NewShareRepository(db *mongo.Database, logger *zap.Logger) ShareRepository {
+	var repo ShareRepository
+	repo = &shareRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *shareRepository) Collection() *mongo.Collection {
+	return r.db.Collection(collectionName)
+}
+
+func (r *shareRepository) findOne(ctx context.Context, filter bson.M) (*Share, error) {
+	var entity share
+	if err := r.Collection().FindOne(ctx, filter).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrShareNotFound
+		}
+
+		return nil, err
+	}
+
+	return entity.ToModel(), nil
+}
+
+func (r *shareRepository) FindById(ctx context.Context, id ShareId) (*Share, error) {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return nil, ErrShareNotFound
+	}
+
+	return r.findOne(ctx, bson.M{"_id": objId})
+}
+
+func (r *shareRepository) FindByTokenHash(ctx context.Context, hash string) (*Share, error) {
+	return r.findOne(ctx, bson.M{"token_hash": hash})
+}
+
+func (r *shareRepository) GetByChatId(ctx context.Context, chatId chats.ChatId) ([]*Share, error) {
+	objId, err := primitive.ObjectIDFromHex(string(chatId))
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.Collection().Find(ctx, bson.M{"chat_id": objId})
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []share
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(s share) *Share {
+		return s.ToModel()
+	}), nil
+}
+
+func (r *shareRepository) Create(ctx context.Context, share *Share) error {
+	entity, err := fromModel(share)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.Collection().InsertOne(ctx, entity)
+	if err != nil {
+		return err
+	}
+
+	share.Id = ShareId(result.InsertedID.(primitive.ObjectID).Hex())
+	share.CreatedAt = entity.CreatedAt.Time()
+	return nil
+}
+
+func (r *shareRepository) Update(ctx context.Context, share *Share) error {
+	entity, err := fromModel(share)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().UpdateByID(ctx, entity.Id, bson.M{"$set": entity}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *shareRepository) DeleteByChatId(ctx context.Context, chatId chats.ChatId) error {
+	objId, err := primitive.ObjectIDFromHex(string(chatId))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteMany(ctx, bson.M{"chat_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *shareRepository) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteMany(ctx, bson.M{"profile_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type repositoryMiddleware func(ShareRepository) ShareRepository
+
+type loggingMiddleware struct {
+	logger *zap.Logger
+	next   ShareRepository
+}
+
+func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
+	return func(next ShareRepository) ShareRepository {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *loggingMiddleware) FindById(ctx context.Context, id ShareId) (share *Share, err error) {
+	defer func() {
+		m.logger.Debug("FindById", zap.String("id", string(id)), utils.Object("share", share), zap.Error(err))
+	}()
+
+	return m.next.FindById(ctx, id)
+}
+
+func (m *loggingMiddleware) FindByTokenHash(ctx context.Context, hash string) (share *Share, err error) {
+	defer func() {
+		m.logger.Debug("FindByTokenHash", utils.Object("share", share), zap.Error(err))
+	}()
+
+	return m.next.FindByTokenHash(ctx, hash)
+}
+
+func (m *loggingMiddleware) GetByChatId(ctx context.Context, chatId chats.ChatId) (shares []*Share, err error) {
+	defer func() {
+		m.logger.Debug("GetByChatId", zap.String("chat_id", string(chatId)), zap.Objects("shares", shares), zap.Error(err))
+	}()
+
+	return m.next.GetByChatId(ctx, chatId)
+}
+
+func (m *loggingMiddleware) Create(ctx context.Context, share *Share) (err error) {
+	defer func() {
+		m.logger.Debug("Create", utils.Object("share", share), zap.Error(err))
+	}()
+
+	return m.next.Create(ctx, share)
+}
+
+func (m *loggingMiddleware) Update(ctx context.Context, share *Share) (err error) {
+	defer func() {
+		m.logger.Debug("Update", utils.Object("share", share), zap.Error(err))
+	}()
+
+	return m.next.Update(ctx, share)
+}
+
+func (m *loggingMiddleware) DeleteByChatId(ctx context.Context, chatId chats.ChatId) (err error) {
+	defer func() {
+		m.logger.Debug("DeleteByChatId", zap.String("chat_id", string(chatId)), zap.Error(err))
+	}()
+
+	return m.next.DeleteByChatId(ctx, chatId)
+}
+
+func (m *loggingMiddleware) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) (err error) {
+	defer func() {
+		m.logger.Debug("DeleteByProfileId", zap.String("profile_id", string(profileId)), zap.Error(err))
+	}()
+
+	return m.next.DeleteByProfileId(ctx, profileId)
+}
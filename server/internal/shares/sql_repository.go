@@ -0,0 +1,95 @@
+package shares
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlShareRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLShareRepository(db *sqldb.DB, logger *zap.Logger) ShareRepository {
+	var repo ShareRepository
+	repo = &sqlShareRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+const shareColumns = "id, token_hash, chat_id, profile_id, expires_at, revoked_at, created_at"
+
+func scanShare(row interface{ Scan(...any) error }) (*Share, error) {
+	var share Share
+	var expiresAt, revokedAt sql.NullTime
+	if err := row.Scan(&share.Id, &share.TokenHash, &share.ChatId, &share.ProfileId, &expiresAt, &revokedAt, &share.CreatedAt); err != nil {
+		return nil, err
+	}
+	share.ExpiresAt = sqldb.TimePtr(expiresAt)
+	share.RevokedAt = sqldb.TimePtr(revokedAt)
+
+	return &share, nil
+}
+
+func (r *sqlShareRepository) findOne(ctx context.Context, where string, args ...any) (*Share, error) {
+	share, err := scanShare(r.db.QueryRowContext(ctx, "SELECT "+shareColumns+" FROM shares WHERE "+where, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrShareNotFound
+	}
+
+	return share, err
+}
+
+func (r *sqlShareRepository) FindById(ctx context.Context, id ShareId) (*Share, error) {
+	return r.findOne(ctx, "id = ?", id)
+}
+
+func (r *sqlShareRepository) FindByTokenHash(ctx context.Context, hash string) (*Share, error) {
+	return r.findOne(ctx, "token_hash = ?", hash)
+}
+
+func (r *sqlShareRepository) GetByChatId(ctx context.Context, chatId chats.ChatId) ([]*Share, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+shareColumns+" FROM shares WHERE chat_id = ? ORDER BY created_at, id", chatId)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Share, error) {
+		return scanShare(rows)
+	})
+}
+
+func (r *sqlShareRepository) Create(ctx context.Context, share *Share) error {
+	if share.Id == "" {
+		share.Id = ShareId(domain.NewId())
+	}
+	if share.CreatedAt.IsZero() {
+		share.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.ExecContext(ctx, "INSERT INTO shares ("+shareColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		share.Id, share.TokenHash, share.ChatId, share.ProfileId, sqldb.NullTime(share.ExpiresAt), sqldb.NullTime(share.RevokedAt), share.CreatedAt.UTC())
+	return err
+}
+
+func (r *sqlShareRepository) Update(ctx context.Context, share *Share) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE shares SET token_hash = ?, expires_at = ?, revoked_at = ? WHERE id = ?",
+		share.TokenHash, sqldb.NullTime(share.ExpiresAt), sqldb.NullTime(share.RevokedAt), share.Id)
+	return err
+}
+
+func (r *sqlShareRepository) DeleteByChatId(ctx context.Context, chatId chats.ChatId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM shares WHERE chat_id = ?", chatId)
+	return err
+}
+
+func (r *sqlShareRepository) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM shares WHERE profile_id = ?", profileId)
+	return err
+}
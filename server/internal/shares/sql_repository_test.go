@@ -0,0 +1,32 @@
+package shares
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"go.uber.org/zap"
+)
+
+func TestSQLShareRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLShareRepository(sqldbtest.Open(t), zap.NewNop())
+
+	share := &Share{ChatId: "chat", ProfileId: "profile", TokenHash: hashToken("secret")}
+	if err := repo.Create(ctx, share); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	found, err := repo.FindByTokenHash(ctx, hashToken("secret"))
+	if err != nil {
+		t.Fatalf("FindByTokenHash() error = %v", err)
+	}
+	if found.Id != share.Id || found.Token != "" {
+		t.Errorf("FindByTokenHash() = %+v, want the share without its token", found)
+	}
+
+	if _, err := repo.FindByTokenHash(ctx, "secret"); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("FindByTokenHash() with the plaintext token error = %v, want %v", err, ErrShareNotFound)
+	}
+}
@@ -0,0 +1,107 @@
+package steps
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"go.uber.org/zap"
+)
+
+type memoryStepRepository struct {
+	mutex sync.RWMutex
+	steps map[domain.StepId]Step
+}
+
+// NewMemoryStepRepository returns a repository keeping the steps in this
+// process, they are lost on restart.
+func NewMemoryStepRepository(logger *zap.Logger) StepRepository {
+	var repo StepRepository
+	repo = &memoryStepRepository{steps: make(map[domain.StepId]Step)}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *memoryStepRepository) GetByMessageId(ctx context.Context, messageId domain.MessageId) ([]*Step, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := []*Step{}
+	for _, id := range slices.Sorted(maps.Keys(r.steps)) {
+		if step := r.steps[id]; step.MessageId == messageId {
+			result = append(result, &step)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *memoryStepRepository) Create(ctx context.Context, step *Step) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if step.Id == "" {
+		step.Id = domain.StepId(domain.NewId())
+	}
+
+	r.steps[step.Id] = *step
+	return nil
+}
+
+func (r *memoryStepRepository) Update(ctx context.Context, step *Step) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.steps[step.Id]
+	if !ok {
+		return nil
+	}
+
+	updated := *step
+	updated.MessageId = existing.MessageId
+	r.steps[step.Id] = updated
+	return nil
+}
+
+func (r *memoryStepRepository) AppendContent(ctx context.Context, id domain.StepId, delta string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	step, ok := r.steps[id]
+	if !ok {
+		return ErrStepNotFound
+	}
+
+	step.Content += delta
+	r.steps[id] = step
+	return nil
+}
+
+func (r *memoryStepRepository) FailPendingByMessageIds(ctx context.Context, messageIds []domain.MessageId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, step := range r.steps {
+		if step.Status == StepStatusPending && slices.Contains(messageIds, step.MessageId) {
+			step.Status = StepStatusFailed
+			r.steps[id] = step
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryStepRepository) DeleteByMessageId(ctx context.Context, messageId domain.MessageId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, step := range r.steps {
+		if step.MessageId == messageId {
+			delete(r.steps, id)
+		}
+	}
+
+	return nil
+}
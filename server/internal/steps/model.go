@@ -10,14 +10,15 @@ type StepStatus string
 const (
 	StepStatusPending StepStatus = "pending"
 	StepStatusDone    StepStatus = "done"
+	StepStatusFailed  StepStatus = "failed"
 )
 
 type Step struct {
-	Id        domain.StepId
-	MessageId domain.MessageId
-	Type      string
-	Content   string
-	Status    StepStatus
+	Id        domain.StepId    `json:"id"`
+	MessageId domain.MessageId `json:"message_id"`
+	Type      string           `json:"type"`
+	Content   string           `json:"content"`
+	Status    StepStatus       `json:"status"`
 }
 
 func (s Step) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
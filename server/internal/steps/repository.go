@@ -5,17 +5,26 @@ import (
 	"errors"
 
 	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/database"
 	"github.com/dreadster3/yapper/server/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
+var ErrStepNotFound = errors.New("step not found")
+
 type StepRepository interface {
 	GetByMessageId(ctx context.Context, messageId domain.MessageId) ([]*Step, error)
 	Create(ctx context.Context, step *Step) error
 	Update(ctx context.Context, step *Step) error
+	// AppendContent adds the delta at the end of the stored content
+	AppendContent(ctx context.Context, id domain.StepId, delta string) error
+	// FailPendingByMessageIds marks the pending steps of the messages as failed
+	FailPendingByMessageIds(ctx context.Context, messageIds []domain.MessageId) error
+	DeleteByMessageId(ctx context.Context, messageId domain.MessageId) error
 }
 
 const collectionName = "steps"
@@ -39,11 +48,9 @@ func (s step) ToModel() *Step {
 }
 
 func fromModel(m *Step) (*step, error) {
-	id, err := primitive.ObjectIDFromHex(string(m.Id))
+	id, err := database.ObjectId(m.Id)
 	if err != nil {
-		if errors.Is(err, primitive.ErrInvalidHex) {
-			id = primitive.NewObjectID()
-		}
+		return nil, err
 	}
 
 	messageId, err := primitive.ObjectIDFromHex(string(m.MessageId))
@@ -81,7 +88,7 @@ func (r *stepRepository) GetByMessageId(ctx context.Context, messageId domain.Me
 		return nil, err
 	}
 
-	cursor, err := r.Collection().Find(ctx, bson.M{"message_id": objId})
+	cursor, err := r.Collection().Find(ctx, bson.M{"message_id": objId}, options.Find().SetSort(bson.M{"_id": 1}))
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +131,51 @@ func (r *stepRepository) Update(ctx context.Context, step *Step) error {
 	return nil
 }
 
+func (r *stepRepository) AppendContent(ctx context.Context, id domain.StepId, delta string) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return ErrStepNotFound
+	}
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"content": bson.M{"$concat": bson.A{"$content", delta}}}}}}
+	result, err := r.Collection().UpdateByID(ctx, objId, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrStepNotFound
+	}
+
+	return nil
+}
+
+func (r *stepRepository) FailPendingByMessageIds(ctx context.Context, messageIds []domain.MessageId) error {
+	objIds, err := database.ObjectIds(messageIds)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Collection().UpdateMany(ctx,
+		bson.M{"message_id": bson.M{"$in": objIds}, "status": string(StepStatusPending)},
+		bson.M{"$set": bson.M{"status": string(StepStatusFailed)}},
+	)
+	return err
+}
+
+func (r *stepRepository) DeleteByMessageId(ctx context.Context, messageId domain.MessageId) error {
+	objId, err := primitive.ObjectIDFromHex(string(messageId))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteMany(ctx, bson.M{"message_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 type repositoryMiddleware func(StepRepository) StepRepository
 
 type loggingMiddleware struct {
@@ -150,7 +202,7 @@ func (m *loggingMiddleware) GetByMessageId(ctx context.Context, messageId domain
 
 func (m *loggingMiddleware) Create(ctx context.Context, step *Step) (err error) {
 	defer func() {
-		m.logger.Debug("Create", zap.Object("step", step), zap.Error(err))
+		m.logger.Debug("Create", utils.Object("step", step), zap.Error(err))
 	}()
 
 	return m.next.Create(ctx, step)
@@ -158,8 +210,32 @@ func (m *loggingMiddleware) Create(ctx context.Context, step *Step) (err error)
 
 func (m *loggingMiddleware) Update(ctx context.Context, step *Step) (err error) {
 	defer func() {
-		m.logger.Debug("Update", zap.Object("step", step), zap.Error(err))
+		m.logger.Debug("Update", utils.Object("step", step), zap.Error(err))
 	}()
 
 	return m.next.Update(ctx, step)
 }
+
+func (m *loggingMiddleware) AppendContent(ctx context.Context, id domain.StepId, delta string) (err error) {
+	defer func() {
+		m.logger.Debug("AppendContent", zap.String("id", string(id)), zap.Int("length", len(delta)), zap.Error(err))
+	}()
+
+	return m.next.AppendContent(ctx, id, delta)
+}
+
+func (m *loggingMiddleware) FailPendingByMessageIds(ctx context.Context, messageIds []domain.MessageId) (err error) {
+	defer func() {
+		m.logger.Debug("FailPendingByMessageIds", zap.Strings("message_ids", utils.Map(messageIds, func(id domain.MessageId) string { return string(id) })), zap.Error(err))
+	}()
+
+	return m.next.FailPendingByMessageIds(ctx, messageIds)
+}
+
+func (m *loggingMiddleware) DeleteByMessageId(ctx context.Context, messageId domain.MessageId) (err error) {
+	defer func() {
+		m.logger.Debug("DeleteByMessageId", zap.String("message_id", string(messageId)), zap.Error(err))
+	}()
+
+	return m.next.DeleteByMessageId(ctx, messageId)
+}
@@ -0,0 +1,37 @@
+package steps_test
+
+import (
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/database/mongotest"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"github.com/dreadster3/yapper/server/internal/storage/storagetest"
+	"go.uber.org/zap"
+)
+
+func TestStepRepository(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		storagetest.TestStepRepository(t, func(t *testing.T) steps.StepRepository {
+			return steps.NewMemoryStepRepository(zap.NewNop())
+		})
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		storagetest.TestStepRepository(t, func(t *testing.T) steps.StepRepository {
+			return steps.NewSQLStepRepository(sqldbtest.Open(t), zap.NewNop())
+		})
+	})
+
+	t.Run("Postgres", func(t *testing.T) {
+		storagetest.TestStepRepository(t, func(t *testing.T) steps.StepRepository {
+			return steps.NewSQLStepRepository(sqldbtest.OpenPostgres(t), zap.NewNop())
+		})
+	})
+
+	t.Run("Mongo", func(t *testing.T) {
+		storagetest.TestStepRepository(t, func(t *testing.T) steps.StepRepository {
+			return steps.NewStepRepository(mongotest.Open(t), zap.NewNop())
+		})
+	})
+}
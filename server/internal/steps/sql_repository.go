@@ -0,0 +1,82 @@
+package steps
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlStepRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLStepRepository(db *sqldb.DB, logger *zap.Logger) StepRepository {
+	var repo StepRepository
+	repo = &sqlStepRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *sqlStepRepository) GetByMessageId(ctx context.Context, messageId domain.MessageId) ([]*Step, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, message_id, type, content, status FROM steps WHERE message_id = ? ORDER BY id", messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Step, error) {
+		var step Step
+		if err := rows.Scan(&step.Id, &step.MessageId, &step.Type, &step.Content, &step.Status); err != nil {
+			return nil, err
+		}
+
+		return &step, nil
+	})
+}
+
+func (r *sqlStepRepository) Create(ctx context.Context, step *Step) error {
+	if step.Id == "" {
+		step.Id = domain.StepId(domain.NewId())
+	}
+
+	_, err := r.db.ExecContext(ctx, "INSERT INTO steps (id, message_id, type, content, status) VALUES (?, ?, ?, ?, ?)",
+		step.Id, step.MessageId, step.Type, step.Content, step.Status)
+	return err
+}
+
+func (r *sqlStepRepository) Update(ctx context.Context, step *Step) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE steps SET type = ?, content = ?, status = ? WHERE id = ?",
+		step.Type, step.Content, step.Status, step.Id)
+	return err
+}
+
+func (r *sqlStepRepository) AppendContent(ctx context.Context, id domain.StepId, delta string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE steps SET content = content || ? WHERE id = ?", delta, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrStepNotFound
+	}
+
+	return nil
+}
+
+func (r *sqlStepRepository) FailPendingByMessageIds(ctx context.Context, messageIds []domain.MessageId) error {
+	if len(messageIds) == 0 {
+		return nil
+	}
+
+	placeholders, args := sqldb.In(messageIds)
+	_, err := r.db.ExecContext(ctx, "UPDATE steps SET status = ? WHERE status = ? AND message_id IN ("+placeholders+")",
+		append([]any{StepStatusFailed, StepStatusPending}, args...)...)
+	return err
+}
+
+func (r *sqlStepRepository) DeleteByMessageId(ctx context.Context, messageId domain.MessageId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM steps WHERE message_id = ?", messageId)
+	return err
+}
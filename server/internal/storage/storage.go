@@ -0,0 +1,192 @@
+// Package storage opens the storage backend chosen on startup and builds
+// every repository on top of it.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/admin"
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/messages"
+	schema "github.com/dreadster3/yapper/server/internal/migrations"
+	"github.com/dreadster3/yapper/server/internal/platform/database"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/migrations"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"github.com/dreadster3/yapper/server/internal/platform/transaction"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/dreadster3/yapper/server/internal/purges"
+	"github.com/dreadster3/yapper/server/internal/secrets"
+	"github.com/dreadster3/yapper/server/internal/shares"
+	"github.com/dreadster3/yapper/server/internal/steps"
+	"github.com/dreadster3/yapper/server/internal/tokens"
+	"github.com/dreadster3/yapper/server/internal/users"
+	"github.com/dreadster3/yapper/server/internal/workspaces"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	BackendMongo    = "mongo"
+	BackendPostgres = sqldb.DialectPostgres
+	BackendSQLite   = sqldb.DialectSQLite
+)
+
+var (
+	ErrUnknownBackend = errors.New("unknown storage backend")
+	ErrMissingURL     = errors.New("the database URL is required by the storage backend")
+)
+
+type Config struct {
+	Backend string
+
+	// Mongo connection
+	Host     string
+	Port     int
+	User     string
+	Password string
+
+	// URL is the DSN of the SQL backends, the path of the database file for
+	// SQLite
+	URL string
+
+	// Cipher re-seals the stored keys when migrating
+	Cipher encryption.Cipher
+}
+
+type Repositories struct {
+	Profiles        profiles.ProfileRepository
+	Chats           chats.ChatRepository
+	Members         chats.MemberRepository
+	Messages        messages.MessageRepository
+	Steps           steps.StepRepository
+	Shares          shares.ShareRepository
+	Workspaces      workspaces.WorkspaceRepository
+	Secrets         secrets.SecretRepository
+	Tokens          tokens.TokenRepository
+	Users           users.UserRepository
+	ProviderConfigs admin.ProviderConfigRepository
+	PurgeJobs       purges.JobRepository
+}
+
+type Storage struct {
+	Repositories
+	Migrations *migrations.Runner
+	// UnitOfWork makes the repository writes atomic, the repositories join it
+	// through the context
+	UnitOfWork transaction.UnitOfWork
+	// Mongo is only set by the Mongo backend, the Mongo event bus requires it
+	Mongo *mongo.Database
+
+	close func(ctx context.Context) error
+}
+
+func Open(ctx context.Context, config Config, logger *zap.Logger) (*Storage, error) {
+	migrationLogger := logger.With(zap.String("component", "migrations"))
+
+	switch config.Backend {
+	case BackendMongo:
+		db, closeDatabase, err := database.ConnectDatabase(ctx, config.Host, config.Port, config.User, config.Password)
+		if err != nil {
+			return nil, err
+		}
+
+		runner, err := migrations.NewRunner(migrations.NewMongoStore(db), schema.Mongo(db, config.Cipher), migrationLogger)
+		if err != nil {
+			closeDatabase(ctx)
+			return nil, err
+		}
+
+		unitOfWork, err := database.NewUnitOfWork(ctx, db, logger)
+		if err != nil {
+			closeDatabase(ctx)
+			return nil, err
+		}
+
+		return &Storage{
+			Repositories: NewMongoRepositories(db, logger),
+			Migrations:   runner,
+			UnitOfWork:   unitOfWork,
+			Mongo:        db,
+			close:        closeDatabase,
+		}, nil
+	case BackendPostgres, BackendSQLite:
+		if config.URL == "" {
+			return nil, fmt.Errorf("%w: %s", ErrMissingURL, config.Backend)
+		}
+
+		dialect, err := sqldb.DialectFor(config.Backend)
+		if err != nil {
+			return nil, err
+		}
+
+		dsn := config.URL
+		if config.Backend == BackendSQLite {
+			dsn = sqldb.SQLiteDSN(config.URL)
+		}
+
+		db, err := sqldb.Open(dialect, dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		runner, err := migrations.NewRunner(migrations.NewSQLStore(db), schema.SQL(db, config.Cipher), migrationLogger)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		return &Storage{
+			Repositories: NewSQLRepositories(db, logger),
+			Migrations:   runner,
+			UnitOfWork:   db,
+			close:        func(context.Context) error { return db.Close() },
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, config.Backend)
+	}
+}
+
+func (s *Storage) Close(ctx context.Context) error {
+	return s.close(ctx)
+}
+
+func repositoryLogger(logger *zap.Logger, name string) *zap.Logger {
+	return logger.With(zap.String("repository", name))
+}
+
+func NewMongoRepositories(db *mongo.Database, logger *zap.Logger) Repositories {
+	return Repositories{
+		Profiles:        profiles.NewProfileRepository(db, repositoryLogger(logger, "profile")),
+		Chats:           chats.NewChatRepository(db, repositoryLogger(logger, "chat")),
+		Members:         chats.NewMemberRepository(db, repositoryLogger(logger, "member")),
+		Messages:        messages.NewMessageRepository(db, repositoryLogger(logger, "message")),
+		Steps:           steps.NewStepRepository(db, repositoryLogger(logger, "step")),
+		Shares:          shares.NewShareRepository(db, repositoryLogger(logger, "share")),
+		Workspaces:      workspaces.NewWorkspaceRepository(db, repositoryLogger(logger, "workspace")),
+		Secrets:         secrets.NewSecretRepository(db, repositoryLogger(logger, "secret")),
+		Tokens:          tokens.NewTokenRepository(db, repositoryLogger(logger, "token")),
+		Users:           users.NewUserRepository(db, repositoryLogger(logger, "user")),
+		ProviderConfigs: admin.NewProviderConfigRepository(db, repositoryLogger(logger, "provider_config")),
+		PurgeJobs:       purges.NewJobRepository(db, repositoryLogger(logger, "purge_job")),
+	}
+}
+
+func NewSQLRepositories(db *sqldb.DB, logger *zap.Logger) Repositories {
+	return Repositories{
+		Profiles:        profiles.NewSQLProfileRepository(db, repositoryLogger(logger, "profile")),
+		Chats:           chats.NewSQLChatRepository(db, repositoryLogger(logger, "chat")),
+		Members:         chats.NewSQLMemberRepository(db, repositoryLogger(logger, "member")),
+		Messages:        messages.NewSQLMessageRepository(db, repositoryLogger(logger, "message")),
+		Steps:           steps.NewSQLStepRepository(db, repositoryLogger(logger, "step")),
+		Shares:          shares.NewSQLShareRepository(db, repositoryLogger(logger, "share")),
+		Workspaces:      workspaces.NewSQLWorkspaceRepository(db, repositoryLogger(logger, "workspace")),
+		Secrets:         secrets.NewSQLSecretRepository(db, repositoryLogger(logger, "secret")),
+		Tokens:          tokens.NewSQLTokenRepository(db, repositoryLogger(logger, "token")),
+		Users:           users.NewSQLUserRepository(db, repositoryLogger(logger, "user")),
+		ProviderConfigs: admin.NewSQLProviderConfigRepository(db, repositoryLogger(logger, "provider_config")),
+		PurgeJobs:       purges.NewSQLJobRepository(db, repositoryLogger(logger, "purge_job")),
+	}
+}
@@ -0,0 +1,88 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+)
+
+func TestChatRepository(t *testing.T, newRepository NewRepository[chats.ChatRepository]) {
+	ctx := context.Background()
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		repo := newRepository(t)
+
+		profileId, workspaceId := id[domain.ProfileId](), id[domain.WorkspaceId]()
+		personal := &chats.Chat{Name: "Personal", ProfileId: profileId}
+		shared := &chats.Chat{Name: "Shared", ProfileId: profileId, WorkspaceId: workspaceId}
+		for _, chat := range []*chats.Chat{personal, shared} {
+			if err := repo.Create(ctx, chat); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if chat.Id == "" {
+				t.Fatalf("Create() did not set the id")
+			}
+		}
+
+		found, err := repo.FindById(ctx, shared.Id)
+		if err != nil || *found != *shared {
+			t.Errorf("FindById() = %v, %v, want %v", found, err, shared)
+		}
+
+		personal.Name = "Renamed"
+		if err := repo.Update(ctx, personal); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if found, err := repo.FindById(ctx, personal.Id); err != nil || found.Name != "Renamed" {
+			t.Errorf("FindById() after Update() = %v, %v, want the new name", found, err)
+		}
+
+		if err := repo.Delete(ctx, personal.Id); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := repo.FindById(ctx, personal.Id); !errors.Is(err, chats.ErrChatNotFound) {
+			t.Errorf("FindById() after Delete() error = %v, want %v", err, chats.ErrChatNotFound)
+		}
+		if err := repo.Update(ctx, personal); !errors.Is(err, chats.ErrChatNotFound) {
+			t.Errorf("Update() of a deleted chat error = %v, want %v", err, chats.ErrChatNotFound)
+		}
+	})
+
+	t.Run("Queries", func(t *testing.T) {
+		repo := newRepository(t)
+
+		profileId, workspaceId := id[domain.ProfileId](), id[domain.WorkspaceId]()
+		personal := &chats.Chat{Name: "Personal", ProfileId: profileId}
+		shared := &chats.Chat{Name: "Shared", ProfileId: profileId, WorkspaceId: workspaceId}
+		other := &chats.Chat{Name: "Other", ProfileId: id[domain.ProfileId]()}
+		for _, chat := range []*chats.Chat{personal, shared, other} {
+			if err := repo.Create(ctx, chat); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		if found, err := repo.GetByProfileId(ctx, profileId); err != nil || len(found) != 2 {
+			t.Errorf("GetByProfileId() = %v, %v, want 2 chats", found, err)
+		}
+
+		found, err := repo.GetByWorkspaceIds(ctx, []domain.WorkspaceId{workspaceId, id[domain.WorkspaceId]()})
+		if err != nil || len(found) != 1 || found[0].Id != shared.Id {
+			t.Errorf("GetByWorkspaceIds() = %v, %v, want the workspace chat", found, err)
+		}
+
+		found, err = repo.GetByIds(ctx, []chats.ChatId{personal.Id, other.Id})
+		if err != nil || len(found) != 2 {
+			t.Errorf("GetByIds() = %v, %v, want 2 chats", found, err)
+		}
+
+		if found, err := repo.GetByIds(ctx, nil); err != nil || len(found) != 0 {
+			t.Errorf("GetByIds(nil) = %v, %v, want no chats", found, err)
+		}
+		if found, err := repo.GetByWorkspaceIds(ctx, nil); err != nil || len(found) != 0 {
+			t.Errorf("GetByWorkspaceIds(nil) = %v, %v, want no chats", found, err)
+		}
+	})
+}
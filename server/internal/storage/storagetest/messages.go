@@ -0,0 +1,278 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/messages"
+)
+
+func TestMessageRepository(t *testing.T, newRepository NewRepository[messages.MessageRepository]) {
+	ctx := context.Background()
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		repo := newRepository(t)
+
+		message := &messages.Message{ChatId: id[chats.ChatId](), Provider: "ollama", Model: "llama", Role: messages.MessageRoleAssistant}
+		if err := repo.Create(ctx, message); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if message.Id == "" || message.CreatedAt.IsZero() || message.Status != messages.MessageStatusPending {
+			t.Fatalf("Create() = %+v, want an id, a creation time and the pending status", message)
+		}
+
+		message.Content = "Hello! How can I help?"
+		message.Status = messages.MessageStatusDone
+		if err := repo.Update(ctx, message); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		found, err := repo.FindById(ctx, message.Id)
+		if err != nil {
+			t.Fatalf("FindById() error = %v", err)
+		}
+		if found.Content != message.Content || found.Status != messages.MessageStatusDone || found.ChatId != message.ChatId {
+			t.Errorf("FindById() = %+v, want the updated message", found)
+		}
+		// Backends store the time with different precisions
+		if found.CreatedAt.Sub(message.CreatedAt).Abs() > time.Millisecond {
+			t.Errorf("FindById() created at = %v, want %v", found.CreatedAt, message.CreatedAt)
+		}
+
+		message.Status = messages.MessageStatusFailed
+		message.Error = &messages.MessageError{Provider: "ollama", Code: "unavailable", Message: "connection refused", Retryable: true}
+		if err := repo.Update(ctx, message); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if found, err := repo.FindById(ctx, message.Id); err != nil || found.Error == nil || *found.Error != *message.Error {
+			t.Errorf("FindById() = %v, %v, want the error details", found, err)
+		}
+
+		message.Status = messages.MessageStatusDone
+		message.Error = nil
+		message.BackendProvider = "openai"
+		message.BackendModel = "gpt"
+		if err := repo.Update(ctx, message); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if found, err := repo.FindById(ctx, message.Id); err != nil || found.Error != nil || found.BackendProvider != "openai" || found.BackendModel != "gpt" {
+			t.Errorf("FindById() = %v, %v, want the error details to be cleared and the backend", found, err)
+		}
+
+		if err := repo.AppendContent(ctx, message.Id, " Ask away."); err != nil {
+			t.Fatalf("AppendContent() error = %v", err)
+		}
+		if found, err := repo.FindById(ctx, message.Id); err != nil || found.Content != "Hello! How can I help? Ask away." {
+			t.Errorf("FindById() after AppendContent() = %v, %v, want the appended content", found, err)
+		}
+		if err := repo.AppendContent(ctx, id[domain.MessageId](), "lost"); !errors.Is(err, messages.ErrMessageNotFound) {
+			t.Errorf("AppendContent() of a missing message error = %v, want %v", err, messages.ErrMessageNotFound)
+		}
+
+		if err := repo.DeleteByChatId(ctx, message.ChatId); err != nil {
+			t.Fatalf("DeleteByChatId() error = %v", err)
+		}
+		if _, err := repo.FindById(ctx, message.Id); !errors.Is(err, messages.ErrMessageNotFound) {
+			t.Errorf("FindById() after DeleteByChatId() error = %v, want %v", err, messages.ErrMessageNotFound)
+		}
+	})
+
+	t.Run("GetByChatId", func(t *testing.T) {
+		repo := newRepository(t)
+
+		chatId := id[chats.ChatId]()
+		now := time.Now()
+		history := []*messages.Message{
+			{ChatId: chatId, Role: messages.MessageRoleUser, Content: "second", CreatedAt: now.Add(-time.Minute)},
+			{ChatId: chatId, Role: messages.MessageRoleAssistant, Content: "third", CreatedAt: now},
+			{ChatId: chatId, Role: messages.MessageRoleUser, Content: "first", CreatedAt: now.Add(-time.Hour)},
+			{ChatId: id[chats.ChatId](), Role: messages.MessageRoleUser, Content: "elsewhere"},
+		}
+		for _, message := range history {
+			if err := repo.Create(ctx, message); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		found, err := repo.GetByChatId(ctx, chatId)
+		if err != nil || len(found) != 3 {
+			t.Fatalf("GetByChatId() = %v, %v, want 3 messages", found, err)
+		}
+		for idx, content := range []string{"first", "second", "third"} {
+			if found[idx].Content != content {
+				t.Errorf("GetByChatId()[%d] = %q, want %q, messages are ordered by creation time", idx, found[idx].Content, content)
+			}
+		}
+
+		if found, err := repo.GetByChatId(ctx, id[chats.ChatId]()); err != nil || len(found) != 0 {
+			t.Errorf("GetByChatId() of an empty chat = %v, %v, want no messages", found, err)
+		}
+	})
+
+	t.Run("FailPending", func(t *testing.T) {
+		repo := newRepository(t)
+
+		now := time.Now()
+		stale := &messages.Message{ChatId: id[chats.ChatId](), Role: messages.MessageRoleAssistant, CreatedAt: now.Add(-time.Hour)}
+		done := &messages.Message{ChatId: stale.ChatId, Role: messages.MessageRoleAssistant, Status: messages.MessageStatusDone, CreatedAt: now.Add(-time.Hour)}
+		recent := &messages.Message{ChatId: stale.ChatId, Role: messages.MessageRoleAssistant, CreatedAt: now}
+		for _, message := range []*messages.Message{stale, done, recent} {
+			if err := repo.Create(ctx, message); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		pending, err := repo.GetPendingBefore(ctx, now.Add(-time.Minute))
+		if err != nil || len(pending) != 1 || pending[0].Id != stale.Id {
+			t.Fatalf("GetPendingBefore() = %v, %v, want the stale message", pending, err)
+		}
+
+		if err := repo.FailPending(ctx, []domain.MessageId{stale.Id, done.Id}); err != nil {
+			t.Fatalf("FailPending() error = %v", err)
+		}
+		for message, want := range map[*messages.Message]messages.MessageStatus{stale: messages.MessageStatusFailed, done: messages.MessageStatusDone, recent: messages.MessageStatusPending} {
+			if found, err := repo.FindById(ctx, message.Id); err != nil || found.Status != want {
+				t.Errorf("FindById() = %v, %v, want status %q", found, err, want)
+			}
+		}
+		if found, err := repo.FindById(ctx, stale.Id); err != nil || found.Error == nil || found.Error.Code != messages.ErrorCodeInterrupted || !found.Error.Retryable {
+			t.Errorf("FindById() = %v, %v, want a retryable interrupted error", found, err)
+		}
+
+		if err := repo.FailPending(ctx, nil); err != nil {
+			t.Errorf("FailPending(nil) error = %v", err)
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		repo := newRepository(t)
+
+		pending := &messages.Message{ChatId: id[chats.ChatId](), Role: messages.MessageRoleAssistant}
+		failed := &messages.Message{ChatId: pending.ChatId, Role: messages.MessageRoleAssistant, Content: "partial", Status: messages.MessageStatusFailed,
+			Error: &messages.MessageError{Code: messages.ErrorCodeInterrupted, Message: "interrupted", Retryable: true}}
+		for _, message := range []*messages.Message{pending, failed} {
+			if err := repo.Create(ctx, message); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		pending.Content = "Hello!"
+		pending.Status = messages.MessageStatusDone
+		if err := repo.Complete(ctx, pending); err != nil {
+			t.Fatalf("Complete() error = %v", err)
+		}
+		if found, err := repo.FindById(ctx, pending.Id); err != nil || found.Status != messages.MessageStatusDone || found.Content != "Hello!" {
+			t.Errorf("FindById() = %v, %v, want the completed message", found, err)
+		}
+
+		// The generation finishing after the reconciler failed the response
+		late := *failed
+		late.Content = "partial, then more"
+		late.Status = messages.MessageStatusDone
+		late.Error = nil
+		if err := repo.Complete(ctx, &late); !errors.Is(err, messages.ErrMessageNotFound) {
+			t.Errorf("Complete() of a failed message error = %v, want %v", err, messages.ErrMessageNotFound)
+		}
+		if found, err := repo.FindById(ctx, failed.Id); err != nil || found.Status != messages.MessageStatusFailed || found.Content != "partial" || found.Error == nil {
+			t.Errorf("FindById() = %v, %v, want the failed message untouched", found, err)
+		}
+
+		if err := repo.Complete(ctx, pending); !errors.Is(err, messages.ErrMessageNotFound) {
+			t.Errorf("Complete() of a done message error = %v, want %v", err, messages.ErrMessageNotFound)
+		}
+	})
+
+	t.Run("Touch", func(t *testing.T) {
+		repo := newRepository(t)
+
+		now := time.Now()
+		message := &messages.Message{ChatId: id[chats.ChatId](), Role: messages.MessageRoleAssistant, CreatedAt: now.Add(-time.Hour)}
+		if err := repo.Create(ctx, message); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if pending, err := repo.GetPendingBefore(ctx, now.Add(-time.Minute)); err != nil || len(pending) != 1 {
+			t.Fatalf("GetPendingBefore() = %v, %v, want the message not updated since its creation", pending, err)
+		}
+
+		if err := repo.Touch(ctx, message.Id); err != nil {
+			t.Fatalf("Touch() error = %v", err)
+		}
+		if pending, err := repo.GetPendingBefore(ctx, now.Add(-time.Minute)); err != nil || len(pending) != 0 {
+			t.Errorf("GetPendingBefore() = %v, %v, want no message once touched", pending, err)
+		}
+		if err := repo.Touch(ctx, id[domain.MessageId]()); !errors.Is(err, messages.ErrMessageNotFound) {
+			t.Errorf("Touch() of a missing message error = %v, want %v", err, messages.ErrMessageNotFound)
+		}
+	})
+
+	t.Run("ResetFailed", func(t *testing.T) {
+		repo := newRepository(t)
+
+		failed := &messages.Message{ChatId: id[chats.ChatId](), Role: messages.MessageRoleAssistant, Content: "partial", Status: messages.MessageStatusFailed,
+			Error: &messages.MessageError{Code: messages.ErrorCodeInterrupted, Message: "interrupted", Retryable: true}, BackendProvider: "ollama", BackendModel: "llama"}
+		done := &messages.Message{ChatId: failed.ChatId, Role: messages.MessageRoleAssistant, Status: messages.MessageStatusDone}
+		for _, message := range []*messages.Message{failed, done} {
+			if err := repo.Create(ctx, message); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		if err := repo.ResetFailed(ctx, failed.Id); err != nil {
+			t.Fatalf("ResetFailed() error = %v", err)
+		}
+		found, err := repo.FindById(ctx, failed.Id)
+		if err != nil || found.Status != messages.MessageStatusPending || found.Content != "" || found.Error != nil || found.BackendProvider != "" {
+			t.Errorf("FindById() = %v, %v, want a cleared pending message", found, err)
+		}
+
+		// The concurrent retry finds the message pending
+		if err := repo.ResetFailed(ctx, failed.Id); !errors.Is(err, messages.ErrMessageNotRetryable) {
+			t.Errorf("ResetFailed() of a pending message error = %v, want %v", err, messages.ErrMessageNotRetryable)
+		}
+		if err := repo.ResetFailed(ctx, done.Id); !errors.Is(err, messages.ErrMessageNotRetryable) {
+			t.Errorf("ResetFailed() of a done message error = %v, want %v", err, messages.ErrMessageNotRetryable)
+		}
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		repo := newRepository(t)
+
+		now := time.Now()
+		chatId := id[chats.ChatId]()
+		history := []*messages.Message{
+			{ChatId: chatId, Provider: "ollama", Model: "llama", Role: messages.MessageRoleUser, Content: "hello", CreatedAt: now},
+			{ChatId: chatId, Provider: "ollama", Model: "llama", Role: messages.MessageRoleAssistant, Content: "héllo", CreatedAt: now},
+			{ChatId: chatId, Provider: "ollama", Model: "llama", Role: messages.MessageRoleAssistant, Content: "old", CreatedAt: now.Add(-48 * time.Hour)},
+			{ChatId: chatId, Provider: "openai", Model: "gpt", Role: messages.MessageRoleAssistant, Content: "ok", CreatedAt: now},
+			{ChatId: chatId, Provider: "ollama", Model: "llama", Role: messages.MessageRoleAssistant, Content: "", CreatedAt: now},
+		}
+		for _, message := range history {
+			if err := repo.Create(ctx, message); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		usage, err := repo.Usage(ctx, now.Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("Usage() error = %v", err)
+		}
+
+		// The length is counted in characters, not bytes
+		want := []messages.Usage{
+			{Provider: "ollama", Model: "llama", Messages: 2, Characters: 5},
+			{Provider: "openai", Model: "gpt", Messages: 1, Characters: 2},
+		}
+		if len(usage) != len(want) {
+			t.Fatalf("Usage() = %v, want %v", usage, want)
+		}
+		for idx := range want {
+			if *usage[idx] != want[idx] {
+				t.Errorf("Usage()[%d] = %v, want %v", idx, *usage[idx], want[idx])
+			}
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+)
+
+func TestProfileRepository(t *testing.T, newRepository NewRepository[profiles.ProfileRepository]) {
+	ctx := context.Background()
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		repo := newRepository(t)
+
+		first := &profiles.Profile{Name: "Work", UserId: "user", Preferences: map[string]any{"theme": "dark"}}
+		if err := repo.Create(ctx, first); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if first.Id == "" || !first.Default {
+			t.Fatalf("Create() = %+v, want the first profile to get an id and be the default", first)
+		}
+
+		second := &profiles.Profile{Name: "Personal", UserId: "user"}
+		if err := repo.Create(ctx, second); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if second.Default {
+			t.Errorf("Create() made a second default profile")
+		}
+
+		found, err := repo.FindById(ctx, first.Id)
+		if err != nil {
+			t.Fatalf("FindById() error = %v", err)
+		}
+		if found.Name != "Work" || found.Preferences["theme"] != "dark" || found.UserId != "user" {
+			t.Errorf("FindById() = %+v, want the stored profile", found)
+		}
+		if found, _ := repo.FindById(ctx, second.Id); found.Preferences == nil {
+			t.Errorf("FindById() preferences = nil, want an empty map")
+		}
+
+		first.Name = "Renamed"
+		if err := repo.Update(ctx, first); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if found, _ := repo.FindById(ctx, first.Id); found.Name != "Renamed" {
+			t.Errorf("Update() did not persist the name, got %q", found.Name)
+		}
+
+		if err := repo.Delete(ctx, first.Id); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := repo.FindById(ctx, first.Id); !errors.Is(err, profiles.ErrProfileNotFound) {
+			t.Errorf("FindById() after Delete() error = %v, want %v", err, profiles.ErrProfileNotFound)
+		}
+		if err := repo.Update(ctx, first); !errors.Is(err, profiles.ErrProfileNotFound) {
+			t.Errorf("Update() of a deleted profile error = %v, want %v", err, profiles.ErrProfileNotFound)
+		}
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		repo := newRepository(t)
+
+		if _, err := repo.FindDefaultByUserId(ctx, "user"); !errors.Is(err, profiles.ErrProfileNotFound) {
+			t.Errorf("FindDefaultByUserId() without profiles error = %v, want %v", err, profiles.ErrProfileNotFound)
+		}
+
+		created, err := repo.FindOrCreateDefault(ctx, &profiles.Profile{Name: "Default", UserId: "user"})
+		if err != nil || created.Id == "" || !created.Default {
+			t.Fatalf("FindOrCreateDefault() = %v, %v, want a new default profile", created, err)
+		}
+
+		second := &profiles.Profile{Name: "Second", UserId: "user"}
+		if err := repo.Create(ctx, second); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		existing, err := repo.FindOrCreateDefault(ctx, &profiles.Profile{Name: "Other", UserId: "user"})
+		if err != nil || existing.Id != created.Id {
+			t.Errorf("FindOrCreateDefault() = %v, %v, want the existing default profile", existing, err)
+		}
+
+		found, err := repo.GetByUserId(ctx, "user")
+		if err != nil || len(found) != 2 || found[0].Id != created.Id {
+			t.Errorf("GetByUserId() = %v, %v, want both profiles oldest first", found, err)
+		}
+
+		// The oldest remaining profile stands in for the deleted default one
+		if err := repo.Delete(ctx, created.Id); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		defaultProfile, err := repo.FindDefaultByUserId(ctx, "user")
+		if err != nil || defaultProfile.Id != second.Id {
+			t.Errorf("FindDefaultByUserId() = %v, %v, want %s", defaultProfile, err, second.Id)
+		}
+	})
+
+	t.Run("ConcurrentFindOrCreateDefault", func(t *testing.T) {
+		repo := newRepository(t)
+
+		const workers = 8
+		ids := make([]domain.ProfileId, workers)
+		var wg sync.WaitGroup
+		for idx := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				profile, err := repo.FindOrCreateDefault(ctx, &profiles.Profile{Name: "Default", UserId: "user"})
+				if err != nil {
+					t.Errorf("FindOrCreateDefault() error = %v", err)
+					return
+				}
+				ids[idx] = profile.Id
+			}()
+		}
+		wg.Wait()
+
+		for _, profileId := range ids[1:] {
+			if profileId != ids[0] {
+				t.Fatalf("FindOrCreateDefault() returned %v, want a single profile", ids)
+			}
+		}
+		if found, err := repo.GetByUserId(ctx, "user"); err != nil || len(found) != 1 {
+			t.Errorf("GetByUserId() = %v, %v, want a single profile", found, err)
+		}
+	})
+
+	t.Run("GetAll", func(t *testing.T) {
+		repo := newRepository(t)
+
+		for _, userId := range []auth.UserId{"bob", "alice", "bob"} {
+			if err := repo.Create(ctx, &profiles.Profile{Name: string(userId), UserId: userId}); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		found, err := repo.GetAll(ctx)
+		if err != nil || len(found) != 3 {
+			t.Fatalf("GetAll() = %v, %v, want 3 profiles", found, err)
+		}
+		if found[0].UserId != "alice" || found[1].UserId != "bob" || !found[1].Default || found[2].Default {
+			t.Errorf("GetAll() = %v, %v, %v, want the profiles ordered by user and age", *found[0], *found[1], *found[2])
+		}
+	})
+}
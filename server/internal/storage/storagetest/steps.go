@@ -0,0 +1,69 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/steps"
+)
+
+func TestStepRepository(t *testing.T, newRepository NewRepository[steps.StepRepository]) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	messageId := id[domain.MessageId]()
+	first := &steps.Step{MessageId: messageId, Type: "thinking", Status: steps.StepStatusPending}
+	second := &steps.Step{MessageId: messageId, Type: "thinking", Status: steps.StepStatusPending}
+	other := &steps.Step{MessageId: id[domain.MessageId](), Type: "thinking", Status: steps.StepStatusPending}
+	for _, step := range []*steps.Step{first, second, other} {
+		if err := repo.Create(ctx, step); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if step.Id == "" {
+			t.Fatalf("Create() did not set the id")
+		}
+	}
+
+	if err := repo.FailPendingByMessageIds(ctx, []domain.MessageId{other.MessageId}); err != nil {
+		t.Fatalf("FailPendingByMessageIds() error = %v", err)
+	}
+	if found, err := repo.GetByMessageId(ctx, other.MessageId); err != nil || len(found) != 1 || found[0].Status != steps.StepStatusFailed {
+		t.Errorf("GetByMessageId() after FailPendingByMessageIds() = %v, %v, want a failed step", found, err)
+	}
+
+	if err := repo.AppendContent(ctx, first.Id, "Let me"); err != nil {
+		t.Fatalf("AppendContent() error = %v", err)
+	}
+	if err := repo.AppendContent(ctx, id[domain.StepId](), "lost"); !errors.Is(err, steps.ErrStepNotFound) {
+		t.Errorf("AppendContent() of a missing step error = %v, want %v", err, steps.ErrStepNotFound)
+	}
+	if found, err := repo.GetByMessageId(ctx, messageId); err != nil || found[0].Content != "Let me" {
+		t.Errorf("GetByMessageId() after AppendContent() = %v, %v, want the appended content", found, err)
+	}
+
+	first.Content = "Let me think"
+	first.Status = steps.StepStatusDone
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	found, err := repo.GetByMessageId(ctx, messageId)
+	if err != nil || len(found) != 2 {
+		t.Fatalf("GetByMessageId() = %v, %v, want 2 steps", found, err)
+	}
+	if *found[0] != *first || *found[1] != *second {
+		t.Errorf("GetByMessageId() = %v, %v, want the steps in creation order", *found[0], *found[1])
+	}
+
+	if err := repo.DeleteByMessageId(ctx, messageId); err != nil {
+		t.Fatalf("DeleteByMessageId() error = %v", err)
+	}
+	if found, err := repo.GetByMessageId(ctx, messageId); err != nil || len(found) != 0 {
+		t.Errorf("GetByMessageId() after delete = %v, %v, want no steps", found, err)
+	}
+	if found, err := repo.GetByMessageId(ctx, other.MessageId); err != nil || len(found) != 1 {
+		t.Errorf("GetByMessageId() of another message = %v, %v, want its step to be kept", found, err)
+	}
+}
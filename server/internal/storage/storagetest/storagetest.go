@@ -0,0 +1,18 @@
+// Package storagetest holds the contract every repository backend must pass.
+// Each suite takes a constructor returning an empty repository, the backends
+// run them from their own tests.
+package storagetest
+
+import (
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+)
+
+// NewRepository returns an empty repository for the test
+type NewRepository[T any] func(t *testing.T) T
+
+// id returns an identifier every backend accepts, Mongo requires ObjectIds
+func id[T ~string]() T {
+	return T(domain.NewId())
+}
@@ -0,0 +1,59 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// lastUsedPrecision limits the writes made to track token usage
+const lastUsedPrecision = time.Minute
+
+type Authenticator struct {
+	repository TokenRepository
+	logger     *zap.Logger
+}
+
+func NewAuthenticator(repository TokenRepository, logger *zap.Logger) *Authenticator {
+	return &Authenticator{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (a *Authenticator) Accepts(token string) bool {
+	return isToken(token)
+}
+
+func (a *Authenticator) Authenticate(c *gin.Context, value string) error {
+	ctx := c.Request.Context()
+
+	token, err := a.repository.FindByHash(ctx, hash(value))
+	if err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			return fmt.Errorf("%w: invalid token", auth.ErrUnauthorized)
+		}
+
+		return err
+	}
+
+	now := time.Now()
+	if token.IsExpired(now) {
+		return fmt.Errorf("%w: token expired", auth.ErrUnauthorized)
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedPrecision {
+		if err := a.repository.UpdateLastUsed(ctx, token.Id, now); err != nil {
+			a.logger.Warn("Failed to update token last use", zap.Object("token", token), zap.Error(err))
+		}
+	}
+
+	c.Set(auth.UserIdContextKey, string(token.UserId))
+	c.Set(auth.TokenProfileIdContextKey, string(token.ProfileId))
+	c.Set(auth.ScopesContextKey, token.Scopes)
+	return nil
+}
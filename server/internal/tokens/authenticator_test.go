@@ -0,0 +1,78 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestNewToken(t *testing.T) {
+	value, err := newToken()
+	if err != nil {
+		t.Fatalf("newToken() error = %v", err)
+	}
+	if !strings.HasPrefix(value, Prefix) || !isToken(value) {
+		t.Errorf("newToken() = %q, want the %s prefix", value, Prefix)
+	}
+	if other, _ := newToken(); other == value {
+		t.Errorf("newToken() returned the same token twice")
+	}
+
+	if isToken("eyJhbGciOiJSUzI1NiJ9.e30.signature") {
+		t.Errorf("isToken() accepted a JWT")
+	}
+
+	if digest := hash(value); len(digest) != 64 || digest != hash(value) || digest == hash(value+"x") {
+		t.Errorf("hash() = %q, want the SHA-256 of the token", digest)
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLTokenRepository(sqldbtest.Open(t), zap.NewNop())
+	authenticator := NewAuthenticator(repo, zap.NewNop())
+
+	create := func(value string, expiresAt *time.Time) *Token {
+		token := &Token{ProfileId: "profile", UserId: "user", Name: value, Scopes: []auth.Scope{auth.ScopeRead}, Hash: hash(value), ExpiresAt: expiresAt}
+		if err := repo.Create(ctx, token); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return token
+	}
+	expiredAt := time.Now().Add(-time.Minute)
+	create("yap_valid", nil)
+	create("yap_expired", &expiredAt)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	if err := authenticator.Authenticate(c, "yap_valid"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	profileId, _ := auth.GetTokenProfileIdFromContext(c)
+	scopes, scoped := auth.GetScopesFromContext(c)
+	if auth.GetUserIdFromContext(c) != "user" || profileId != "profile" || !scoped || len(scopes) != 1 || scopes[0] != auth.ScopeRead {
+		t.Errorf("Authenticate() context = %q, %q, %v, want the token owner and scopes", auth.GetUserIdFromContext(c), profileId, scopes)
+	}
+	if found, _ := repo.FindByHash(ctx, hash("yap_valid")); found.LastUsedAt == nil {
+		t.Errorf("Authenticate() did not track the token use")
+	}
+
+	for _, value := range []string{"yap_unknown", "yap_expired"} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		if err := authenticator.Authenticate(c, value); !errors.Is(err, auth.ErrUnauthorized) {
+			t.Errorf("Authenticate(%q) error = %v, want %v", value, err, auth.ErrUnauthorized)
+		}
+		if auth.GetUserIdFromContext(c) != "" {
+			t.Errorf("Authenticate(%q) authenticated the request", value)
+		}
+	}
+}
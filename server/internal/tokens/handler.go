@@ -0,0 +1,96 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/gin-gonic/gin"
+)
+
+type TokenHandler interface {
+	Create(c *gin.Context)
+	List(c *gin.Context)
+	Revoke(c *gin.Context)
+}
+
+type tokenHandler struct {
+	repository TokenRepository
+}
+
+func NewTokenHandler(repository TokenRepository) TokenHandler {
+	return &tokenHandler{repository: repository}
+}
+
+func (h *tokenHandler) Create(c *gin.Context) {
+	// Tokens cannot be used to mint other tokens
+	if _, ok := auth.GetScopesFromContext(c); ok {
+		c.Error(fmt.Errorf("tokenHandler.Create: %w", auth.ErrForbidden))
+		return
+	}
+
+	var token *Token
+	if err := c.ShouldBindJSON(&token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	value, err := newToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	token.ProfileId = profile.Id
+	token.UserId = profile.UserId
+	token.Hash = hash(value)
+
+	ctx := c.Request.Context()
+	if err := h.repository.Create(ctx, token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	token.Value = value
+	c.JSON(http.StatusCreated, token)
+}
+
+func (h *tokenHandler) List(c *gin.Context) {
+	profile := profiles.GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	tokens, err := h.repository.GetByProfileId(ctx, profile.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *tokenHandler) Revoke(c *gin.Context) {
+	var uri struct {
+		TokenId TokenId `uri:"token_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	if err := h.repository.Delete(ctx, profile.Id, uri.TokenId); err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
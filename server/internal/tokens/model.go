@@ -0,0 +1,70 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// Prefix tells personal access tokens apart from JWTs
+	Prefix    = "yap_"
+	tokenSize = 32
+)
+
+type TokenId string
+
+// Token is a personal access token bound to a profile. Only the hash of the
+// token is stored, the value is returned once on creation.
+type Token struct {
+	Id         TokenId          `json:"id" binding:"-"`
+	ProfileId  domain.ProfileId `json:"-" binding:"-"`
+	UserId     auth.UserId      `json:"-" binding:"-"`
+	Name       string           `json:"name" binding:"required"`
+	Scopes     []auth.Scope     `json:"scopes" binding:"required,min=1,dive,oneof=read write"`
+	Value      string           `json:"value,omitempty" binding:"-"`
+	Hash       string           `json:"-" binding:"-"`
+	ExpiresAt  *time.Time       `json:"expires_at,omitempty" binding:"omitempty,gt"`
+	LastUsedAt *time.Time       `json:"last_used_at,omitempty" binding:"-"`
+	CreatedAt  time.Time        `json:"created_at" binding:"-"`
+}
+
+func (t Token) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+func (t Token) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("id", string(t.Id))
+	encoder.AddString("profile_id", string(t.ProfileId))
+	encoder.AddString("name", t.Name)
+	if t.ExpiresAt != nil {
+		encoder.AddTime("expires_at", *t.ExpiresAt)
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	token := make([]byte, tokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return Prefix + base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hash is a plain SHA-256, tokens are random so they cannot be brute forced
+func hash(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+func isToken(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
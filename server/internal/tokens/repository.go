@@ -0,0 +1,265 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+type TokenRepository interface {
+	FindByHash(ctx context.Context, hash string) (*Token, error)
+	GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Token, error)
+	Create(ctx context.Context, token *Token) error
+	UpdateLastUsed(ctx context.Context, id TokenId, lastUsedAt time.Time) error
+	Delete(ctx context.Context, profileId domain.ProfileId, id TokenId) error
+	DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error
+}
+
+const collectionName = "personal_access_tokens"
+
+type token struct {
+	Id         primitive.ObjectID  `bson:"_id"`
+	ProfileId  primitive.ObjectID  `bson:"profile_id"`
+	UserId     string              `bson:"user_id"`
+	Name       string              `bson:"name"`
+	Scopes     []string            `bson:"scopes"`
+	Hash       string              `bson:"hash"`
+	ExpiresAt  *primitive.DateTime `bson:"expires_at,omitempty"`
+	LastUsedAt *primitive.DateTime `bson:"last_used_at,omitempty"`
+	CreatedAt  primitive.DateTime  `bson:"created_at"`
+}
+
+func (t token) ToModel() *Token {
+	return &Token{
+		Id:        TokenId(t.Id.Hex()),
+		ProfileId: domain.ProfileId(t.ProfileId.Hex()),
+		UserId:    auth.UserId(t.UserId),
+		Name:      t.Name,
+		Scopes: utils.Map(t.Scopes, func(scope string) auth.Scope {
+			return auth.Scope(scope)
+		}),
+		Hash:       t.Hash,
+		ExpiresAt:  toTime(t.ExpiresAt),
+		LastUsedAt: toTime(t.LastUsedAt),
+		CreatedAt:  t.CreatedAt.Time(),
+	}
+}
+
+func toTime(dateTime *primitive.DateTime) *time.Time {
+	if dateTime == nil {
+		return nil
+	}
+
+	converted := dateTime.Time()
+	return &converted
+}
+
+func fromTime(t *time.Time) *primitive.DateTime {
+	if t == nil {
+		return nil
+	}
+
+	converted := primitive.NewDateTimeFromTime(*t)
+	return &converted
+}
+
+type tokenRepository struct {
+	db *mongo.Database
+}
+
+func NewTokenRepository(db *mongo.Database, logger *zap.Logger) TokenRepository {
+	var repo TokenRepository
+	repo = &tokenRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *tokenRepository) Collection() *mongo.Collection {
+	return r.db.Collection(collectionName)
+}
+
+func (r *tokenRepository) FindByHash(ctx context.Context, hash string) (*Token, error) {
+	var entity token
+	if err := r.Collection().FindOne(ctx, bson.M{"hash": hash}).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrTokenNotFound
+		}
+
+		return nil, err
+	}
+
+	return entity.ToModel(), nil
+}
+
+func (r *tokenRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Token, error) {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.Collection().Find(ctx, bson.M{"profile_id": objId})
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []token
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(t token) *Token {
+		return t.ToModel()
+	}), nil
+}
+
+func (r *tokenRepository) Create(ctx context.Context, model *Token) error {
+	profileId, err := primitive.ObjectIDFromHex(string(model.ProfileId))
+	if err != nil {
+		return err
+	}
+
+	model.CreatedAt = time.Now()
+	entity := token{
+		Id:        primitive.NewObjectID(),
+		ProfileId: profileId,
+		UserId:    string(model.UserId),
+		Name:      model.Name,
+		Scopes: utils.Map(model.Scopes, func(scope auth.Scope) string {
+			return string(scope)
+		}),
+		Hash:      model.Hash,
+		ExpiresAt: fromTime(model.ExpiresAt),
+		CreatedAt: primitive.NewDateTimeFromTime(model.CreatedAt),
+	}
+
+	if _, err := r.Collection().InsertOne(ctx, entity); err != nil {
+		return err
+	}
+
+	model.Id = TokenId(entity.Id.Hex())
+	return nil
+}
+
+func (r *tokenRepository) UpdateLastUsed(ctx context.Context, id TokenId, lastUsedAt time.Time) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"last_used_at": primitive.NewDateTimeFromTime(lastUsedAt)}}
+	if _, err := r.Collection().UpdateByID(ctx, objId, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *tokenRepository) Delete(ctx context.Context, profileId domain.ProfileId, id TokenId) error {
+	profileObjId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return err
+	}
+
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return ErrTokenNotFound
+	}
+
+	result, err := r.Collection().DeleteOne(ctx, bson.M{"_id": objId, "profile_id": profileObjId})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+func (r *tokenRepository) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Collection().DeleteMany(ctx, bson.M{"profile_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type repositoryMiddleware func(TokenRepository) TokenRepository
+
+type loggingMiddleware struct {
+	logger *zap.Logger
+	next   TokenRepository
+}
+
+func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
+	return func(next TokenRepository) TokenRepository {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *loggingMiddleware) FindByHash(ctx context.Context, hash string) (token *Token, err error) {
+	defer func() {
+		m.logger.Debug("FindByHash", utils.Object("token", token), zap.Error(err))
+	}()
+
+	return m.next.FindByHash(ctx, hash)
+}
+
+func (m *loggingMiddleware) GetByProfileId(ctx context.Context, profileId domain.ProfileId) (tokens []*Token, err error) {
+	defer func() {
+		m.logger.Debug("GetByProfileId", zap.String("profile_id", string(profileId)), zap.Objects("tokens", tokens), zap.Error(err))
+	}()
+
+	return m.next.GetByProfileId(ctx, profileId)
+}
+
+func (m *loggingMiddleware) Create(ctx context.Context, token *Token) (err error) {
+	defer func() {
+		m.logger.Debug("Create", utils.Object("token", token), zap.Error(err))
+	}()
+
+	return m.next.Create(ctx, token)
+}
+
+func (m *loggingMiddleware) UpdateLastUsed(ctx context.Context, id TokenId, lastUsedAt time.Time) (err error) {
+	defer func() {
+		m.logger.Debug("UpdateLastUsed", zap.String("id", string(id)), zap.Time("last_used_at", lastUsedAt), zap.Error(err))
+	}()
+
+	return m.next.UpdateLastUsed(ctx, id, lastUsedAt)
+}
+
+func (m *loggingMiddleware) Delete(ctx context.Context, profileId domain.ProfileId, id TokenId) (err error) {
+	defer func() {
+		m.logger.Debug("Delete", zap.String("profile_id", string(profileId)), zap.String("id", string(id)), zap.Error(err))
+	}()
+
+	return m.next.Delete(ctx, profileId, id)
+}
+
+func (m *loggingMiddleware) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) (err error) {
+	defer func() {
+		m.logger.Debug("DeleteByProfileId", zap.String("profile_id", string(profileId)), zap.Error(err))
+	}()
+
+	return m.next.DeleteByProfileId(ctx, profileId)
+}
@@ -0,0 +1,102 @@
+package tokens
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.uber.org/zap"
+)
+
+type sqlTokenRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLTokenRepository(db *sqldb.DB, logger *zap.Logger) TokenRepository {
+	var repo TokenRepository
+	repo = &sqlTokenRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+const tokenColumns = "id, profile_id, user_id, name, scopes, hash, expires_at, last_used_at, created_at"
+
+// Scopes are stored space separated, as in OAuth
+func scanToken(row interface{ Scan(...any) error }) (*Token, error) {
+	var token Token
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	if err := row.Scan(&token.Id, &token.ProfileId, &token.UserId, &token.Name, &scopes, &token.Hash, &expiresAt, &lastUsedAt, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	token.Scopes = utils.Map(strings.Fields(scopes), func(scope string) auth.Scope {
+		return auth.Scope(scope)
+	})
+	token.ExpiresAt = sqldb.TimePtr(expiresAt)
+	token.LastUsedAt = sqldb.TimePtr(lastUsedAt)
+
+	return &token, nil
+}
+
+func (r *sqlTokenRepository) FindByHash(ctx context.Context, hash string) (*Token, error) {
+	token, err := scanToken(r.db.QueryRowContext(ctx, "SELECT "+tokenColumns+" FROM personal_access_tokens WHERE hash = ?", hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+
+	return token, err
+}
+
+func (r *sqlTokenRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Token, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+tokenColumns+" FROM personal_access_tokens WHERE profile_id = ? ORDER BY id", profileId)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*Token, error) {
+		return scanToken(rows)
+	})
+}
+
+func (r *sqlTokenRepository) Create(ctx context.Context, token *Token) error {
+	token.Id = TokenId(domain.NewId())
+	token.CreatedAt = time.Now()
+
+	scopes := strings.Join(utils.Map(token.Scopes, func(scope auth.Scope) string {
+		return string(scope)
+	}), " ")
+
+	_, err := r.db.ExecContext(ctx, "INSERT INTO personal_access_tokens ("+tokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		token.Id, token.ProfileId, token.UserId, token.Name, scopes, token.Hash, sqldb.NullTime(token.ExpiresAt), sqldb.NullTime(token.LastUsedAt), token.CreatedAt.UTC())
+	return err
+}
+
+func (r *sqlTokenRepository) UpdateLastUsed(ctx context.Context, id TokenId, lastUsedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?", lastUsedAt.UTC(), id)
+	return err
+}
+
+func (r *sqlTokenRepository) Delete(ctx context.Context, profileId domain.ProfileId, id TokenId) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM personal_access_tokens WHERE id = ? AND profile_id = ?", id, profileId)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+func (r *sqlTokenRepository) DeleteByProfileId(ctx context.Context, profileId domain.ProfileId) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM personal_access_tokens WHERE profile_id = ?", profileId)
+	return err
+}
@@ -0,0 +1,63 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"go.uber.org/zap"
+)
+
+func TestSQLTokenRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLTokenRepository(sqldbtest.Open(t), zap.NewNop())
+
+	expiresAt := time.Now().Add(time.Hour)
+	token := &Token{
+		ProfileId: "profile",
+		UserId:    "user",
+		Name:      "CLI",
+		Scopes:    []auth.Scope{auth.ScopeRead, auth.ScopeWrite},
+		Hash:      hash("yap_secret"),
+		ExpiresAt: &expiresAt,
+	}
+	if err := repo.Create(ctx, token); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	duplicate := &Token{ProfileId: "profile", Name: "Copy", Scopes: token.Scopes, Hash: token.Hash}
+	if err := repo.Create(ctx, duplicate); err == nil {
+		t.Errorf("Create() accepted a duplicate hash")
+	}
+
+	found, err := repo.FindByHash(ctx, token.Hash)
+	if err != nil {
+		t.Fatalf("FindByHash() error = %v", err)
+	}
+	if found.Id != token.Id || len(found.Scopes) != 2 || found.Scopes[1] != auth.ScopeWrite {
+		t.Errorf("FindByHash() = %v, want the stored token", found)
+	}
+	if found.ExpiresAt == nil || !found.ExpiresAt.Equal(expiresAt) || found.LastUsedAt != nil {
+		t.Errorf("FindByHash() times = %v, %v, want the expiry only", found.ExpiresAt, found.LastUsedAt)
+	}
+
+	if err := repo.UpdateLastUsed(ctx, token.Id, time.Now()); err != nil {
+		t.Fatalf("UpdateLastUsed() error = %v", err)
+	}
+	if found, _ := repo.FindByHash(ctx, token.Hash); found.LastUsedAt == nil {
+		t.Errorf("UpdateLastUsed() did not persist the time")
+	}
+
+	if err := repo.Delete(ctx, "someone else", token.Id); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Delete() by another profile error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if err := repo.Delete(ctx, "profile", token.Id); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.FindByHash(ctx, token.Hash); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("FindByHash() after Delete() error = %v, want %v", err, ErrTokenNotFound)
+	}
+}
@@ -0,0 +1,31 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrUserDisabled = fmt.Errorf("user is disabled: %w", auth.ErrForbidden)
+
+// RejectDisabledMiddleware stops requests of users disabled by an admin
+func RejectDisabledMiddleware(repository UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := repository.FindById(c.Request.Context(), auth.GetUserIdFromContext(c))
+		if err != nil && !errors.Is(err, ErrUserNotFound) {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		if user != nil && user.Disabled {
+			c.Error(ErrUserDisabled)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package users_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/router/middleware"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"github.com/dreadster3/yapper/server/internal/users"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const userHeader = "X-Test-User"
+
+func TestRejectDisabledMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repository := users.NewSQLUserRepository(sqldbtest.Open(t), zap.NewNop())
+	for _, user := range []*users.User{
+		{Id: "disabled", Disabled: true, Reason: "abuse"},
+		{Id: "enabled"},
+	} {
+		if err := repository.Save(context.Background(), user); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	engine := gin.New()
+	engine.Use(middleware.ErrorMiddleware(nil, zap.NewNop()))
+	engine.Use(func(c *gin.Context) {
+		c.Set(auth.UserIdContextKey, c.GetHeader(userHeader))
+	})
+	engine.GET("/", users.RejectDisabledMiddleware(repository), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		userId string
+		want   int
+	}{
+		{userId: "disabled", want: http.StatusForbidden},
+		{userId: "enabled", want: http.StatusNoContent},
+		// Users are only stored once an admin changed them
+		{userId: "unknown", want: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userId, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set(userHeader, tt.userId)
+
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d (%s), want %d", recorder.Code, recorder.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package users
+
+import (
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"go.uber.org/zap/zapcore"
+)
+
+// User holds the server side state of an identity provider user. Users are
+// only stored once an admin acts on them.
+type User struct {
+	Id        auth.UserId `json:"id" binding:"-"`
+	Disabled  bool        `json:"disabled" binding:"-"`
+	Reason    string      `json:"reason,omitempty" binding:"-"`
+	UpdatedAt time.Time   `json:"updated_at" binding:"-"`
+}
+
+func (u User) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("id", string(u.Id))
+	encoder.AddBool("disabled", u.Disabled)
+	encoder.AddString("reason", u.Reason)
+	return nil
+}
@@ -0,0 +1,141 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+type UserRepository interface {
+	FindById(ctx context.Context, id auth.UserId) (*User, error)
+	GetAll(ctx context.Context) ([]*User, error)
+	Save(ctx context.Context, user *User) error
+}
+
+const collectionName = "users"
+
+type user struct {
+	Id        string             `bson:"_id"`
+	Disabled  bool               `bson:"disabled"`
+	Reason    string             `bson:"reason"`
+	UpdatedAt primitive.DateTime `bson:"updated_at"`
+}
+
+func (u user) ToModel() *User {
+	return &User{
+		Id:        auth.UserId(u.Id),
+		Disabled:  u.Disabled,
+		Reason:    u.Reason,
+		UpdatedAt: u.UpdatedAt.Time(),
+	}
+}
+
+type userRepository struct {
+	db *mongo.Database
+}
+
+func NewUserRepository(db *mongo.Database, logger *zap.Logger) UserRepository {
+	var repo UserRepository
+	repo = &userRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *userRepository) Collection() *mongo.Collection {
+	return r.db.Collection(collectionName)
+}
+
+func (r *userRepository) FindById(ctx context.Context, id auth.UserId) (*User, error) {
+	var entity user
+	if err := r.Collection().FindOne(ctx, bson.M{"_id": string(id)}).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return entity.ToModel(), nil
+}
+
+func (r *userRepository) GetAll(ctx context.Context) ([]*User, error) {
+	cursor, err := r.Collection().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []user
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(u user) *User {
+		return u.ToModel()
+	}), nil
+}
+
+func (r *userRepository) Save(ctx context.Context, model *User) error {
+	model.UpdatedAt = time.Now()
+	entity := user{
+		Id:        string(model.Id),
+		Disabled:  model.Disabled,
+		Reason:    model.Reason,
+		UpdatedAt: primitive.NewDateTimeFromTime(model.UpdatedAt),
+	}
+
+	if _, err := r.Collection().ReplaceOne(ctx, bson.M{"_id": entity.Id}, entity, options.Replace().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type repositoryMiddleware func(UserRepository) UserRepository
+
+type loggingMiddleware struct {
+	logger *zap.Logger
+	next   UserRepository
+}
+
+func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
+	return func(next UserRepository) UserRepository {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *loggingMiddleware) FindById(ctx context.Context, id auth.UserId) (user *User, err error) {
+	defer func() {
+		m.logger.Debug("FindById", zap.String("id", string(id)), utils.Object("user", user), zap.Error(err))
+	}()
+
+	return m.next.FindById(ctx, id)
+}
+
+func (m *loggingMiddleware) GetAll(ctx context.Context) (users []*User, err error) {
+	defer func() {
+		m.logger.Debug("GetAll", zap.Objects("users", users), zap.Error(err))
+	}()
+
+	return m.next.GetAll(ctx)
+}
+
+func (m *loggingMiddleware) Save(ctx context.Context, user *User) (err error) {
+	defer func() {
+		m.logger.Debug("Save", utils.Object("user", user), zap.Error(err))
+	}()
+
+	return m.next.Save(ctx, user)
+}
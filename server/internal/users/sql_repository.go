@@ -0,0 +1,63 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"go.uber.org/zap"
+)
+
+type sqlUserRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLUserRepository(db *sqldb.DB, logger *zap.Logger) UserRepository {
+	var repo UserRepository
+	repo = &sqlUserRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func scanUser(row interface{ Scan(...any) error }) (*User, error) {
+	var user User
+	if err := row.Scan(&user.Id, &user.Disabled, &user.Reason, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *sqlUserRepository) FindById(ctx context.Context, id auth.UserId) (*User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, "SELECT id, disabled, reason, updated_at FROM users WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+
+	return user, err
+}
+
+func (r *sqlUserRepository) GetAll(ctx context.Context) ([]*User, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, disabled, reason, updated_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.Scan(rows, func(rows *sql.Rows) (*User, error) {
+		return scanUser(rows)
+	})
+}
+
+func (r *sqlUserRepository) Save(ctx context.Context, user *User) error {
+	user.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, `INSERT INTO users (id, disabled, reason, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			disabled = excluded.disabled,
+			reason = excluded.reason,
+			updated_at = excluded.updated_at`,
+		user.Id, user.Disabled, user.Reason, user.UpdatedAt.UTC())
+	return err
+}
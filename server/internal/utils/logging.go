@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Object logs the value the pointer refers to, nothing when it is nil. The
+// models marshal themselves with value receivers, zap.Object panics on a nil
+// pointer to them (e.g. the result of a failed lookup).
+func Object[T zapcore.ObjectMarshaler](key string, value *T) zap.Field {
+	if value == nil {
+		return zap.Skip()
+	}
+
+	return zap.Object(key, *value)
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type item struct{ name string }
+
+func (i item) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("name", i.name)
+	return nil
+}
+
+func TestObject(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	var missing *item
+	logger.Debug("FindById", Object("item", missing))
+	logger.Debug("FindById", Object("item", &item{name: "found"}))
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	if _, ok := entries[0].ContextMap()["item"]; ok {
+		t.Errorf("Object() of a nil pointer logged %v, want no field", entries[0].ContextMap())
+	}
+	if got, ok := entries[1].ContextMap()["item"].(map[string]any); !ok || got["name"] != "found" {
+		t.Errorf("Object() logged %v, want the item", entries[1].ContextMap())
+	}
+}
@@ -0,0 +1,72 @@
+package workspaces
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/utils"
+)
+
+// ChatRole maps the workspace role to the role it grants on workspace chats
+func (r Role) ChatRole() chats.Role {
+	switch r {
+	case RoleAdmin:
+		return chats.RoleOwner
+	case RoleMember:
+		return chats.RoleEditor
+	default:
+		return chats.RoleViewer
+	}
+}
+
+type chatAccess struct {
+	repository WorkspaceRepository
+}
+
+func NewChatAccess(repository WorkspaceRepository) chats.WorkspaceAccess {
+	return &chatAccess{repository: repository}
+}
+
+func (a *chatAccess) ChatRole(ctx context.Context, workspaceId domain.WorkspaceId, profileId domain.ProfileId) (chats.Role, error) {
+	workspace, err := a.repository.FindById(ctx, workspaceId)
+	if err != nil {
+		if errors.Is(err, ErrWorkspaceNotFound) {
+			return "", fmt.Errorf("workspaces.ChatRole: %w", auth.ErrForbidden)
+		}
+
+		return "", err
+	}
+
+	role, ok := workspace.RoleOf(profileId)
+	if !ok {
+		return "", fmt.Errorf("workspaces.ChatRole: %w", auth.ErrForbidden)
+	}
+
+	return role.ChatRole(), nil
+}
+
+func (a *chatAccess) WorkspaceIds(ctx context.Context, profileId domain.ProfileId) ([]domain.WorkspaceId, error) {
+	workspaces, err := a.repository.GetByProfileId(ctx, profileId)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.Map(workspaces, func(w *Workspace) domain.WorkspaceId {
+		return w.Id
+	}), nil
+}
+
+func (a *chatAccess) Members(ctx context.Context, workspaceId domain.WorkspaceId) ([]domain.ProfileId, error) {
+	workspace, err := a.repository.FindById(ctx, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.Map(workspace.Members, func(m Member) domain.ProfileId {
+		return m.ProfileId
+	}), nil
+}
@@ -0,0 +1,353 @@
+package workspaces
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/dreadster3/yapper/server/internal/chats"
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/auth"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/profiles"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrLastAdmin = errors.New("a workspace needs at least one admin")
+
+type WorkspaceHandler interface {
+	Create(c *gin.Context)
+	List(c *gin.Context)
+	Get(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	SaveMember(c *gin.Context)
+	RemoveMember(c *gin.Context)
+	SaveProvider(c *gin.Context)
+	RemoveProvider(c *gin.Context)
+}
+
+type workspaceHandler struct {
+	repository        WorkspaceRepository
+	profileRepository profiles.ProfileRepository
+	chatRepository    chats.ChatRepository
+	contentDeleter    chats.ContentDeleter
+	egress            providers.Egress
+	cipher            encryption.Cipher
+}
+
+func NewWorkspaceHandler(repository WorkspaceRepository, profileRepository profiles.ProfileRepository, chatRepository chats.ChatRepository, contentDeleter chats.ContentDeleter, egress providers.Egress, cipher encryption.Cipher) WorkspaceHandler {
+	return &workspaceHandler{
+		repository:        repository,
+		profileRepository: profileRepository,
+		chatRepository:    chatRepository,
+		contentDeleter:    contentDeleter,
+		egress:            egress,
+		cipher:            cipher,
+	}
+}
+
+func (h *workspaceHandler) Create(c *gin.Context) {
+	var workspace *Workspace
+	if err := c.ShouldBindJSON(&workspace); err != nil {
+		c.Error(err)
+		return
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	workspace.Members = []Member{{ProfileId: profile.Id, Role: RoleAdmin}}
+	workspace.Providers = []ProviderConfig{}
+
+	ctx := c.Request.Context()
+	if err := h.repository.Create(ctx, workspace); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, workspace.Redacted())
+}
+
+func (h *workspaceHandler) List(c *gin.Context) {
+	profile := profiles.GetProfileFromContext(c)
+	ctx := c.Request.Context()
+
+	workspaces, err := h.repository.GetByProfileId(ctx, profile.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	redacted := make([]*Workspace, len(workspaces))
+	for idx, workspace := range workspaces {
+		redacted[idx] = workspace.Redacted()
+	}
+
+	c.JSON(http.StatusOK, redacted)
+}
+
+func (h *workspaceHandler) Get(c *gin.Context) {
+	workspace, ok := h.authorize(c, RoleViewer)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace.Redacted())
+}
+
+func (h *workspaceHandler) Update(c *gin.Context) {
+	var request struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(err)
+		return
+	}
+
+	workspace, ok := h.authorize(c, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	workspace.Name = request.Name
+	h.save(c, workspace)
+}
+
+// Delete removes the workspace along with every chat it owns
+func (h *workspaceHandler) Delete(c *gin.Context) {
+	workspace, ok := h.authorize(c, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	workspaceChats, err := h.chatRepository.GetByWorkspaceIds(ctx, []domain.WorkspaceId{workspace.Id})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	for _, chat := range workspaceChats {
+		if err := h.contentDeleter.DeleteByChatId(ctx, chat.Id); err != nil {
+			c.Error(err)
+			return
+		}
+
+		if err := h.chatRepository.Delete(ctx, chat.Id); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	if err := h.repository.Delete(ctx, workspace.Id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *workspaceHandler) SaveMember(c *gin.Context) {
+	var member *Member
+	if err := c.ShouldBindJSON(&member); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var uri struct {
+		ProfileId domain.ProfileId `uri:"member_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	workspace, ok := h.authorize(c, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.profileRepository.FindById(ctx, uri.ProfileId); err != nil {
+		if errors.Is(err, profiles.ErrProfileNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	member.ProfileId = uri.ProfileId
+	idx := slices.IndexFunc(workspace.Members, func(m Member) bool { return m.ProfileId == member.ProfileId })
+	if idx < 0 {
+		workspace.Members = append(workspace.Members, *member)
+	} else {
+		workspace.Members[idx] = *member
+	}
+
+	if !hasAdmin(workspace) {
+		c.Status(http.StatusBadRequest)
+		c.Error(ErrLastAdmin)
+		return
+	}
+
+	h.save(c, workspace)
+}
+
+// RemoveMember removes a member from the workspace. Members can always leave.
+func (h *workspaceHandler) RemoveMember(c *gin.Context) {
+	var uri struct {
+		ProfileId domain.ProfileId `uri:"member_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	role := RoleAdmin
+	if uri.ProfileId == profiles.GetProfileFromContext(c).Id {
+		role = RoleViewer
+	}
+
+	workspace, ok := h.authorize(c, role)
+	if !ok {
+		return
+	}
+
+	workspace.Members = slices.DeleteFunc(workspace.Members, func(m Member) bool { return m.ProfileId == uri.ProfileId })
+	if !hasAdmin(workspace) {
+		c.Status(http.StatusBadRequest)
+		c.Error(ErrLastAdmin)
+		return
+	}
+
+	h.save(c, workspace)
+}
+
+func (h *workspaceHandler) SaveProvider(c *gin.Context) {
+	var provider *ProviderConfig
+	if err := c.ShouldBindJSON(&provider); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var uri struct {
+		Name string `uri:"provider" binding:"required,registered_provider"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.egress.CheckURL(provider.URL); err != nil {
+		c.Status(http.StatusBadRequest)
+		c.Error(err)
+		return
+	}
+
+	workspace, ok := h.authorize(c, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	provider.Name = uri.Name
+	if provider.APIKey != "" {
+		envelope, err := h.cipher.Seal([]byte(provider.APIKey), associatedData(workspace.Id, provider.Name))
+		if err != nil {
+			if errors.Is(err, encryption.ErrNoMasterKey) {
+				c.Status(http.StatusServiceUnavailable)
+			}
+
+			c.Error(err)
+			return
+		}
+		provider.Envelope = envelope
+		provider.APIKey = ""
+	}
+
+	idx := slices.IndexFunc(workspace.Providers, func(p ProviderConfig) bool { return p.Name == provider.Name })
+	if idx < 0 {
+		workspace.Providers = append(workspace.Providers, *provider)
+	} else {
+		// Keep the stored key unless a new one is provided
+		if provider.Envelope == nil {
+			provider.Envelope = workspace.Providers[idx].Envelope
+		}
+		workspace.Providers[idx] = *provider
+	}
+
+	h.save(c, workspace)
+}
+
+func (h *workspaceHandler) RemoveProvider(c *gin.Context) {
+	var uri struct {
+		Name string `uri:"provider" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return
+	}
+
+	workspace, ok := h.authorize(c, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	workspace.Providers = slices.DeleteFunc(workspace.Providers, func(p ProviderConfig) bool { return p.Name == uri.Name })
+	h.save(c, workspace)
+}
+
+// save writes the workspace back, a concurrent change of the same workspace
+// fails the request with a conflict instead of being overwritten
+func (h *workspaceHandler) save(c *gin.Context, workspace *Workspace) {
+	ctx := c.Request.Context()
+	if err := h.repository.Update(ctx, workspace); err != nil {
+		switch {
+		case errors.Is(err, ErrWorkspaceNotFound):
+			c.Status(http.StatusNotFound)
+		case errors.Is(err, ErrWorkspaceConflict):
+			c.Status(http.StatusConflict)
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace.Redacted())
+}
+
+// authorize loads the workspace from the route and checks that the current
+// profile has at least the given role in it
+func (h *workspaceHandler) authorize(c *gin.Context, minimum Role) (*Workspace, bool) {
+	var uri struct {
+		WorkspaceId domain.WorkspaceId `uri:"workspace_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.Error(err)
+		return nil, false
+	}
+
+	profile := profiles.GetProfileFromContext(c)
+	workspace, err := h.repository.FindById(c.Request.Context(), uri.WorkspaceId)
+	if err != nil {
+		if errors.Is(err, ErrWorkspaceNotFound) {
+			c.Status(http.StatusNotFound)
+		}
+
+		c.Error(err)
+		return nil, false
+	}
+
+	role, ok := workspace.RoleOf(profile.Id)
+	if !ok || !role.AtLeast(minimum) {
+		c.Error(fmt.Errorf("workspaceHandler.authorize: %w", auth.ErrForbidden))
+		return nil, false
+	}
+
+	return workspace, true
+}
+
+func hasAdmin(workspace *Workspace) bool {
+	return slices.ContainsFunc(workspace.Members, func(m Member) bool { return m.Role == RoleAdmin })
+}
@@ -0,0 +1,113 @@
+package workspaces
+
+import (
+	"slices"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"go.uber.org/zap/zapcore"
+)
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleViewer Role = "viewer"
+)
+
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 2
+	case RoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r Role) AtLeast(minimum Role) bool {
+	return r.rank() >= minimum.rank()
+}
+
+type Member struct {
+	ProfileId domain.ProfileId `json:"profile_id" binding:"-"`
+	Role      Role             `json:"role" binding:"required,oneof=admin member viewer"`
+}
+
+// ProviderConfig overrides the global provider of the same name for the chats
+// of the workspace. The API key is write only and stored encrypted.
+type ProviderConfig struct {
+	Name          string               `json:"name" binding:"-"`
+	Type          string               `json:"type" binding:"required,oneof=ollama openai anthropic"`
+	URL           string               `json:"url" binding:"required,url"`
+	APIKey        string               `json:"api_key,omitempty" binding:"-"`
+	HasAPIKey     bool                 `json:"has_api_key" binding:"-"`
+	AllowedModels []string             `json:"allowed_models" binding:"-"`
+	Envelope      *encryption.Envelope `json:"-" binding:"-"`
+}
+
+func (p ProviderConfig) Config() providers.Config {
+	return providers.Config{
+		Type:          p.Type,
+		URL:           p.URL,
+		APIKey:        p.APIKey,
+		AllowedModels: p.AllowedModels,
+	}
+}
+
+// associatedData binds the envelope to the workspace and name of the provider
+func associatedData(workspaceId domain.WorkspaceId, name string) []byte {
+	return encryption.AssociatedData("workspace_provider", string(workspaceId), name)
+}
+
+type Workspace struct {
+	Id        domain.WorkspaceId `json:"id" binding:"-"`
+	Name      string             `json:"name" binding:"required"`
+	Members   []Member           `json:"members" binding:"-"`
+	Providers []ProviderConfig   `json:"providers" binding:"-"`
+	CreatedAt time.Time          `json:"created_at" binding:"-"`
+	// Version changes with every write, an update only applies to the
+	// version the workspace was loaded at
+	Version int `json:"-" binding:"-"`
+}
+
+func (w Workspace) RoleOf(profileId domain.ProfileId) (Role, bool) {
+	idx := slices.IndexFunc(w.Members, func(m Member) bool { return m.ProfileId == profileId })
+	if idx < 0 {
+		return "", false
+	}
+
+	return w.Members[idx].Role, true
+}
+
+func (w Workspace) Provider(name string) (ProviderConfig, bool) {
+	idx := slices.IndexFunc(w.Providers, func(p ProviderConfig) bool { return p.Name == name })
+	if idx < 0 {
+		return ProviderConfig{}, false
+	}
+
+	return w.Providers[idx], true
+}
+
+// Redacted returns a copy of the workspace that is safe to send to clients
+func (w Workspace) Redacted() *Workspace {
+	w.Providers = slices.Clone(w.Providers)
+	for idx := range w.Providers {
+		w.Providers[idx].HasAPIKey = w.Providers[idx].Envelope != nil
+		w.Providers[idx].APIKey = ""
+	}
+
+	return &w
+}
+
+func (w Workspace) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("id", string(w.Id))
+	encoder.AddString("name", w.Name)
+	encoder.AddInt("members", len(w.Members))
+	encoder.AddInt("providers", len(w.Providers))
+	return nil
+}
@@ -0,0 +1,73 @@
+package workspaces
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"go.uber.org/zap"
+)
+
+type providerResolver struct {
+	repository WorkspaceRepository
+	cipher     encryption.Cipher
+	egress     providers.Egress
+	client     *http.Client
+	breakers   *providers.Breakers
+	next       providers.Resolver
+	logger     *zap.Logger
+}
+
+// NewProviderResolver resolves the provider configured in the workspace of the
+// chat, workspace configurations take precedence over every other one. The
+// workspace providers can only reach the backends the egress allows.
+func NewProviderResolver(repository WorkspaceRepository, cipher encryption.Cipher, egress providers.Egress, next providers.Resolver, logger *zap.Logger) providers.Resolver {
+	return &providerResolver{
+		repository: repository,
+		cipher:     cipher,
+		egress:     egress,
+		client:     egress.Client(),
+		breakers:   providers.NewBreakers(),
+		next:       next,
+		logger:     logger,
+	}
+}
+
+func (r *providerResolver) Resolve(ctx context.Context, scope providers.Scope, name string, model string) (providers.Provider, error) {
+	if scope.WorkspaceId != "" {
+		workspace, err := r.repository.FindById(ctx, scope.WorkspaceId)
+		if err != nil {
+			return nil, err
+		}
+
+		if config, ok := workspace.Provider(name); ok {
+			providerConfig := config.Config()
+			if !providerConfig.AllowsModel(model) {
+				return nil, fmt.Errorf("%w: %s", providers.ErrModelNotAllowed, model)
+			}
+
+			// The allowed hosts may have changed since the provider was saved
+			if err := r.egress.CheckURL(providerConfig.URL); err != nil {
+				return nil, err
+			}
+
+			if config.Envelope != nil {
+				apiKey, err := r.cipher.Open(config.Envelope, associatedData(workspace.Id, name))
+				if err != nil {
+					return nil, err
+				}
+				providerConfig.APIKey = string(apiKey)
+			}
+
+			providerConfig.Policy = providers.DefaultPolicy
+			// Workspaces reach their own backends, they do not share breakers
+			providerConfig.Breaker = r.breakers.Get(string(scope.WorkspaceId)+"/"+name, providerConfig.Policy)
+			providerConfig.HTTPClient = r.client
+			return providers.NewProvider(providerConfig, r.logger.With(zap.String("provider", name), zap.String("workspace_id", string(scope.WorkspaceId))))
+		}
+	}
+
+	return r.next.Resolve(ctx, scope, name, model)
+}
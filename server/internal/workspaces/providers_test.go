@@ -0,0 +1,57 @@
+package workspaces
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/providers"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"go.uber.org/zap"
+)
+
+type nextResolver struct{}
+
+func (nextResolver) Resolve(ctx context.Context, scope providers.Scope, name string, model string) (providers.Provider, error) {
+	return nil, providers.ErrProviderNotFound
+}
+
+func TestProviderResolverEgress(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLWorkspaceRepository(sqldbtest.Open(t), zap.NewNop())
+
+	workspace := &Workspace{
+		Name: "Team",
+		Providers: []ProviderConfig{
+			{Name: "internal", Type: providers.TypeOllama, URL: "http://127.0.0.1:11434"},
+			{Name: "openai", Type: providers.TypeOpenAI, URL: "https://api.openai.com/v1"},
+		},
+	}
+	if err := repo.Create(ctx, workspace); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	cipher, err := encryption.NewEnvelopeCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("NewEnvelopeCipher() error = %v", err)
+	}
+	workspace.Providers[1].Envelope, err = cipher.Seal([]byte("sk-secret"), associatedData(workspace.Id, "openai"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if err := repo.Update(ctx, workspace); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	egress := providers.Egress{AllowedHosts: []string{"api.openai.com"}}
+	resolver := NewProviderResolver(repo, cipher, egress, nextResolver{}, zap.NewNop())
+	scope := providers.Scope{WorkspaceId: workspace.Id}
+
+	if _, err := resolver.Resolve(ctx, scope, "internal", "llama"); !errors.Is(err, providers.ErrAddressNotAllowed) {
+		t.Errorf("Resolve() of a loopback provider error = %v, want %v", err, providers.ErrAddressNotAllowed)
+	}
+	if provider, err := resolver.Resolve(ctx, scope, "openai", "gpt-4o"); err != nil || provider == nil {
+		t.Errorf("Resolve() of an allowed provider = %v, %v", provider, err)
+	}
+}
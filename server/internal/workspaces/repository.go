@@ -0,0 +1,341 @@
+package workspaces
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/database"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceConflict = errors.New("workspace was changed concurrently")
+)
+
+type WorkspaceRepository interface {
+	FindById(ctx context.Context, id domain.WorkspaceId) (*Workspace, error)
+	// GetByProfileId returns the workspaces the profile is a member of
+	GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Workspace, error)
+	Create(ctx context.Context, workspace *Workspace) error
+	// Update fails with ErrWorkspaceConflict when the workspace changed since
+	// it was loaded
+	Update(ctx context.Context, workspace *Workspace) error
+	Delete(ctx context.Context, id domain.WorkspaceId) error
+	// RemoveMember removes the profile from every workspace, the first
+	// remaining member becomes admin of the workspaces left without one
+	RemoveMember(ctx context.Context, profileId domain.ProfileId) error
+}
+
+const collectionName = "workspaces"
+
+type member struct {
+	ProfileId primitive.ObjectID `bson:"profile_id"`
+	Role      string             `bson:"role"`
+}
+
+type providerConfig struct {
+	Name          string   `bson:"name"`
+	Type          string   `bson:"type"`
+	URL           string   `bson:"url"`
+	AllowedModels []string `bson:"allowed_models"`
+	KeyId         string   `bson:"key_id,omitempty"`
+	WrappedKey    []byte   `bson:"wrapped_key,omitempty"`
+	Ciphertext    []byte   `bson:"ciphertext,omitempty"`
+}
+
+func (p providerConfig) ToModel() ProviderConfig {
+	config := ProviderConfig{
+		Name:          p.Name,
+		Type:          p.Type,
+		URL:           p.URL,
+		AllowedModels: p.AllowedModels,
+	}
+	if p.KeyId != "" {
+		config.Envelope = &encryption.Envelope{KeyId: p.KeyId, WrappedKey: p.WrappedKey, Ciphertext: p.Ciphertext}
+		config.HasAPIKey = true
+	}
+
+	return config
+}
+
+func fromProviderConfig(p ProviderConfig) providerConfig {
+	config := providerConfig{
+		Name:          p.Name,
+		Type:          p.Type,
+		URL:           p.URL,
+		AllowedModels: p.AllowedModels,
+	}
+	if p.Envelope != nil {
+		config.KeyId = p.Envelope.KeyId
+		config.WrappedKey = p.Envelope.WrappedKey
+		config.Ciphertext = p.Envelope.Ciphertext
+	}
+
+	return config
+}
+
+type workspace struct {
+	Id        primitive.ObjectID `bson:"_id"`
+	Name      string             `bson:"name"`
+	Members   []member           `bson:"members"`
+	Providers []providerConfig   `bson:"providers"`
+	CreatedAt primitive.DateTime `bson:"created_at"`
+	Version   int                `bson:"version"`
+}
+
+func (w workspace) ToModel() *Workspace {
+	return &Workspace{
+		Id:   domain.WorkspaceId(w.Id.Hex()),
+		Name: w.Name,
+		Members: utils.Map(w.Members, func(m member) Member {
+			return Member{ProfileId: domain.ProfileId(m.ProfileId.Hex()), Role: Role(m.Role)}
+		}),
+		Providers: utils.Map(w.Providers, providerConfig.ToModel),
+		CreatedAt: w.CreatedAt.Time(),
+		Version:   w.Version,
+	}
+}
+
+func fromModel(w *Workspace) (*workspace, error) {
+	id, err := database.ObjectId(w.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]member, len(w.Members))
+	for idx, m := range w.Members {
+		profileId, err := primitive.ObjectIDFromHex(string(m.ProfileId))
+		if err != nil {
+			return nil, err
+		}
+		members[idx] = member{ProfileId: profileId, Role: string(m.Role)}
+	}
+
+	createdAt := w.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return &workspace{
+		Id:        id,
+		Name:      w.Name,
+		Members:   members,
+		Providers: utils.Map(w.Providers, fromProviderConfig),
+		CreatedAt: primitive.NewDateTimeFromTime(createdAt),
+		Version:   w.Version,
+	}, nil
+}
+
+type workspaceRepository struct {
+	db *mongo.Database
+}
+
+func NewWorkspaceRepository(db *mongo.Database, logger *zap.Logger) WorkspaceRepository {
+	var repo WorkspaceRepository
+	repo = &workspaceRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *workspaceRepository) Collection() *mongo.Collection {
+	return r.db.Collection(collectionName)
+}
+
+func (r *workspaceRepository) FindById(ctx context.Context, id domain.WorkspaceId) (*Workspace, error) {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	var entity workspace
+	if err := r.Collection().FindOne(ctx, bson.M{"_id": objId}).Decode(&entity); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrWorkspaceNotFound
+		}
+
+		return nil, err
+	}
+
+	return entity.ToModel(), nil
+}
+
+func (r *workspaceRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Workspace, error) {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.Collection().Find(ctx, bson.M{"members.profile_id": objId})
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []workspace
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(entities, func(w workspace) *Workspace {
+		return w.ToModel()
+	}), nil
+}
+
+func (r *workspaceRepository) Create(ctx context.Context, workspace *Workspace) error {
+	entity, err := fromModel(workspace)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.Collection().InsertOne(ctx, entity)
+	if err != nil {
+		return err
+	}
+
+	workspace.Id = domain.WorkspaceId(result.InsertedID.(primitive.ObjectID).Hex())
+	workspace.CreatedAt = entity.CreatedAt.Time()
+	return nil
+}
+
+func (r *workspaceRepository) Update(ctx context.Context, workspace *Workspace) error {
+	entity, err := fromModel(workspace)
+	if err != nil {
+		return err
+	}
+
+	entity.Version++
+	result, err := r.Collection().UpdateOne(ctx, bson.M{"_id": entity.Id, "version": workspace.Version}, bson.M{"$set": entity})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		count, err := r.Collection().CountDocuments(ctx, bson.M{"_id": entity.Id})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrWorkspaceNotFound
+		}
+
+		return ErrWorkspaceConflict
+	}
+
+	workspace.Version = entity.Version
+	return nil
+}
+
+func (r *workspaceRepository) Delete(ctx context.Context, id domain.WorkspaceId) error {
+	objId, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return ErrWorkspaceNotFound
+	}
+
+	if _, err := r.Collection().DeleteOne(ctx, bson.M{"_id": objId}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *workspaceRepository) RemoveMember(ctx context.Context, profileId domain.ProfileId) error {
+	objId, err := primitive.ObjectIDFromHex(string(profileId))
+	if err != nil {
+		return err
+	}
+
+	ids, err := r.Collection().Distinct(ctx, "_id", bson.M{"members.profile_id": objId})
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	update := bson.M{"$pull": bson.M{"members": bson.M{"profile_id": objId}}, "$inc": bson.M{"version": 1}}
+	if _, err := r.Collection().UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, update); err != nil {
+		return err
+	}
+
+	promote := bson.M{
+		"_id":          bson.M{"$in": ids},
+		"members.role": bson.M{"$ne": string(RoleAdmin)},
+		"members.0":    bson.M{"$exists": true},
+	}
+	if _, err := r.Collection().UpdateMany(ctx, promote, bson.M{"$set": bson.M{"members.0.role": string(RoleAdmin)}}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type repositoryMiddleware func(WorkspaceRepository) WorkspaceRepository
+
+type loggingMiddleware struct {
+	logger *zap.Logger
+	next   WorkspaceRepository
+}
+
+func NewLoggingMiddleware(logger *zap.Logger) repositoryMiddleware {
+	return func(next WorkspaceRepository) WorkspaceRepository {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (m *loggingMiddleware) FindById(ctx context.Context, id domain.WorkspaceId) (workspace *Workspace, err error) {
+	defer func() {
+		m.logger.Debug("FindById", zap.String("id", string(id)), utils.Object("workspace", workspace), zap.Error(err))
+	}()
+
+	return m.next.FindById(ctx, id)
+}
+
+func (m *loggingMiddleware) GetByProfileId(ctx context.Context, profileId domain.ProfileId) (workspaces []*Workspace, err error) {
+	defer func() {
+		m.logger.Debug("GetByProfileId", zap.String("profile_id", string(profileId)), zap.Objects("workspaces", workspaces), zap.Error(err))
+	}()
+
+	return m.next.GetByProfileId(ctx, profileId)
+}
+
+func (m *loggingMiddleware) Create(ctx context.Context, workspace *Workspace) (err error) {
+	defer func() {
+		m.logger.Debug("Create", utils.Object("workspace", workspace), zap.Error(err))
+	}()
+
+	return m.next.Create(ctx, workspace)
+}
+
+func (m *loggingMiddleware) Update(ctx context.Context, workspace *Workspace) (err error) {
+	defer func() {
+		m.logger.Debug("Update", utils.Object("workspace", workspace), zap.Error(err))
+	}()
+
+	return m.next.Update(ctx, workspace)
+}
+
+func (m *loggingMiddleware) Delete(ctx context.Context, id domain.WorkspaceId) (err error) {
+	defer func() {
+		m.logger.Debug("Delete", zap.String("id", string(id)), zap.Error(err))
+	}()
+
+	return m.next.Delete(ctx, id)
+}
+
+func (m *loggingMiddleware) RemoveMember(ctx context.Context, profileId domain.ProfileId) (err error) {
+	defer func() {
+		m.logger.Debug("RemoveMember", zap.String("profile_id", string(profileId)), zap.Error(err))
+	}()
+
+	return m.next.RemoveMember(ctx, profileId)
+}
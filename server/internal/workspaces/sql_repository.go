@@ -0,0 +1,270 @@
+package workspaces
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/dreadster3/yapper/server/internal/domain"
+	"github.com/dreadster3/yapper/server/internal/platform/encryption"
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb"
+	"github.com/dreadster3/yapper/server/internal/utils"
+	"go.uber.org/zap"
+)
+
+// sqlProviderConfig is the JSON stored in the providers column
+type sqlProviderConfig struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	URL           string   `json:"url"`
+	AllowedModels []string `json:"allowed_models"`
+	KeyId         string   `json:"key_id,omitempty"`
+	WrappedKey    []byte   `json:"wrapped_key,omitempty"`
+	Ciphertext    []byte   `json:"ciphertext,omitempty"`
+}
+
+type sqlWorkspaceRepository struct {
+	db *sqldb.DB
+}
+
+func NewSQLWorkspaceRepository(db *sqldb.DB, logger *zap.Logger) WorkspaceRepository {
+	var repo WorkspaceRepository
+	repo = &sqlWorkspaceRepository{db: db}
+	repo = NewLoggingMiddleware(logger)(repo)
+	return repo
+}
+
+func (r *sqlWorkspaceRepository) FindById(ctx context.Context, id domain.WorkspaceId) (*Workspace, error) {
+	workspaces, err := r.find(ctx, "id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(workspaces) == 0 {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	return workspaces[0], nil
+}
+
+func (r *sqlWorkspaceRepository) GetByProfileId(ctx context.Context, profileId domain.ProfileId) ([]*Workspace, error) {
+	return r.find(ctx, "id IN (SELECT workspace_id FROM workspace_members WHERE profile_id = ?)", profileId)
+}
+
+// find loads the workspaces matching the condition with their members
+func (r *sqlWorkspaceRepository) find(ctx context.Context, where string, args ...any) ([]*Workspace, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, providers, created_at, version FROM workspaces WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces, err := sqldb.Scan(rows, func(rows *sql.Rows) (*Workspace, error) {
+		var workspace Workspace
+		var providers string
+		if err := rows.Scan(&workspace.Id, &workspace.Name, &providers, &workspace.CreatedAt, &workspace.Version); err != nil {
+			return nil, err
+		}
+
+		var configs []sqlProviderConfig
+		if err := json.Unmarshal([]byte(providers), &configs); err != nil {
+			return nil, err
+		}
+
+		workspace.Members = []Member{}
+		workspace.Providers = utils.Map(configs, func(p sqlProviderConfig) ProviderConfig {
+			config := ProviderConfig{
+				Name:          p.Name,
+				Type:          p.Type,
+				URL:           p.URL,
+				AllowedModels: p.AllowedModels,
+			}
+			if p.KeyId != "" {
+				config.Envelope = &encryption.Envelope{KeyId: p.KeyId, WrappedKey: p.WrappedKey, Ciphertext: p.Ciphertext}
+				config.HasAPIKey = true
+			}
+
+			return config
+		})
+
+		return &workspace, nil
+	})
+	if err != nil || len(workspaces) == 0 {
+		return workspaces, err
+	}
+
+	byId := make(map[domain.WorkspaceId]*Workspace, len(workspaces))
+	for _, workspace := range workspaces {
+		byId[workspace.Id] = workspace
+	}
+
+	placeholders, ids := sqldb.In(utils.Map(workspaces, func(w *Workspace) domain.WorkspaceId { return w.Id }))
+	rows, err = r.db.QueryContext(ctx, "SELECT workspace_id, profile_id, role FROM workspace_members WHERE workspace_id IN ("+placeholders+") ORDER BY workspace_id, position", ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var workspaceId domain.WorkspaceId
+		var member Member
+		if err := rows.Scan(&workspaceId, &member.ProfileId, &member.Role); err != nil {
+			return nil, err
+		}
+
+		byId[workspaceId].Members = append(byId[workspaceId].Members, member)
+	}
+
+	return workspaces, rows.Err()
+}
+
+func (r *sqlWorkspaceRepository) Create(ctx context.Context, workspace *Workspace) error {
+	providers, err := marshalProviders(workspace.Providers)
+	if err != nil {
+		return err
+	}
+
+	id := workspace.Id
+	if id == "" {
+		id = domain.WorkspaceId(domain.NewId())
+	}
+	createdAt := workspace.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	err = r.db.Transaction(ctx, func(tx sqldb.Querier) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO workspaces (id, name, providers, created_at) VALUES (?, ?, ?, ?)",
+			id, workspace.Name, providers, createdAt.UTC()); err != nil {
+			return err
+		}
+
+		return insertMembers(ctx, tx, id, workspace.Members)
+	})
+	if err != nil {
+		return err
+	}
+
+	workspace.Id = id
+	workspace.CreatedAt = createdAt
+	return nil
+}
+
+func (r *sqlWorkspaceRepository) Update(ctx context.Context, workspace *Workspace) error {
+	providers, err := marshalProviders(workspace.Providers)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Transaction(ctx, func(tx sqldb.Querier) error {
+		result, err := tx.ExecContext(ctx, "UPDATE workspaces SET name = ?, providers = ?, version = version + 1 WHERE id = ? AND version = ?",
+			workspace.Name, providers, workspace.Id, workspace.Version)
+		if err != nil {
+			return err
+		}
+
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			var count int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM workspaces WHERE id = ?", workspace.Id).Scan(&count); err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrWorkspaceNotFound
+			}
+
+			return ErrWorkspaceConflict
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM workspace_members WHERE workspace_id = ?", workspace.Id); err != nil {
+			return err
+		}
+
+		return insertMembers(ctx, tx, workspace.Id, workspace.Members)
+	})
+	if err != nil {
+		return err
+	}
+
+	workspace.Version++
+	return nil
+}
+
+func insertMembers(ctx context.Context, tx sqldb.Querier, workspaceId domain.WorkspaceId, members []Member) error {
+	for position, member := range members {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO workspace_members (workspace_id, profile_id, role, position) VALUES (?, ?, ?, ?)",
+			workspaceId, member.ProfileId, member.Role, position); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *sqlWorkspaceRepository) Delete(ctx context.Context, id domain.WorkspaceId) error {
+	return r.db.Transaction(ctx, func(tx sqldb.Querier) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM workspace_members WHERE workspace_id = ?", id); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, "DELETE FROM workspaces WHERE id = ?", id)
+		return err
+	})
+}
+
+func (r *sqlWorkspaceRepository) RemoveMember(ctx context.Context, profileId domain.ProfileId) error {
+	return r.db.Transaction(ctx, func(tx sqldb.Querier) error {
+		rows, err := tx.QueryContext(ctx, "SELECT workspace_id FROM workspace_members WHERE profile_id = ?", profileId)
+		if err != nil {
+			return err
+		}
+
+		workspaceIds, err := sqldb.Scan(rows, func(rows *sql.Rows) (domain.WorkspaceId, error) {
+			var workspaceId domain.WorkspaceId
+			err := rows.Scan(&workspaceId)
+			return workspaceId, err
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM workspace_members WHERE profile_id = ?", profileId); err != nil {
+			return err
+		}
+
+		for _, workspaceId := range workspaceIds {
+			if _, err := tx.ExecContext(ctx, "UPDATE workspaces SET version = version + 1 WHERE id = ?", workspaceId); err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, `UPDATE workspace_members SET role = ?
+				WHERE workspace_id = ?
+				AND position = (SELECT MIN(position) FROM workspace_members WHERE workspace_id = ?)
+				AND NOT EXISTS (SELECT 1 FROM workspace_members WHERE workspace_id = ? AND role = ?)`,
+				RoleAdmin, workspaceId, workspaceId, workspaceId, RoleAdmin); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func marshalProviders(providers []ProviderConfig) (string, error) {
+	configs := utils.Map(providers, func(p ProviderConfig) sqlProviderConfig {
+		config := sqlProviderConfig{
+			Name:          p.Name,
+			Type:          p.Type,
+			URL:           p.URL,
+			AllowedModels: p.AllowedModels,
+		}
+		if p.Envelope != nil {
+			config.KeyId = p.Envelope.KeyId
+			config.WrappedKey = p.Envelope.WrappedKey
+			config.Ciphertext = p.Envelope.Ciphertext
+		}
+
+		return config
+	})
+	encoded, err := json.Marshal(configs)
+	return string(encoded), err
+}
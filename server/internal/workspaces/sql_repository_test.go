@@ -0,0 +1,85 @@
+package workspaces
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dreadster3/yapper/server/internal/platform/sqldb/sqldbtest"
+	"go.uber.org/zap"
+)
+
+func TestSQLWorkspaceRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLWorkspaceRepository(sqldbtest.Open(t), zap.NewNop())
+
+	workspace := &Workspace{
+		Name:    "Team",
+		Members: []Member{{ProfileId: "admin", Role: RoleAdmin}, {ProfileId: "viewer", Role: RoleViewer}},
+		Providers: []ProviderConfig{
+			{Name: "ollama", Type: "ollama", URL: "http://gpu:11434", AllowedModels: []string{"llama"}},
+		},
+	}
+	if err := repo.Create(ctx, workspace); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	found, err := repo.FindById(ctx, workspace.Id)
+	if err != nil {
+		t.Fatalf("FindById() error = %v", err)
+	}
+	if len(found.Members) != 2 || found.Members[1].ProfileId != "viewer" {
+		t.Errorf("FindById() members = %v, want the members in order", found.Members)
+	}
+	if provider, ok := found.Provider("ollama"); !ok || provider.URL != "http://gpu:11434" || provider.AllowedModels[0] != "llama" {
+		t.Errorf("FindById() providers = %v, want the stored provider", found.Providers)
+	}
+
+	stale := mustFind(t, repo, workspace)
+
+	found.Members = append(found.Members, Member{ProfileId: "member", Role: RoleMember})
+	if err := repo.Update(ctx, found); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	stale.Providers = nil
+	if err := repo.Update(ctx, stale); !errors.Is(err, ErrWorkspaceConflict) {
+		t.Errorf("Update() of a stale workspace error = %v, want %v", err, ErrWorkspaceConflict)
+	}
+
+	workspaces, err := repo.GetByProfileId(ctx, "member")
+	if err != nil || len(workspaces) != 1 || len(workspaces[0].Members) != 3 {
+		t.Errorf("GetByProfileId() = %v, %v, want the updated workspace", workspaces, err)
+	}
+
+	if err := repo.RemoveMember(ctx, "viewer"); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+	if _, ok := mustFind(t, repo, workspace).RoleOf("viewer"); ok {
+		t.Errorf("RemoveMember() kept the member")
+	}
+	if err := repo.Update(ctx, found); !errors.Is(err, ErrWorkspaceConflict) {
+		t.Errorf("Update() after RemoveMember() error = %v, want %v", err, ErrWorkspaceConflict)
+	}
+
+	if err := repo.Delete(ctx, workspace.Id); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.FindById(ctx, workspace.Id); !errors.Is(err, ErrWorkspaceNotFound) {
+		t.Errorf("FindById() after Delete() error = %v, want %v", err, ErrWorkspaceNotFound)
+	}
+	if workspaces, err := repo.GetByProfileId(ctx, "admin"); err != nil || len(workspaces) != 0 {
+		t.Errorf("GetByProfileId() after Delete() = %v, %v, want no workspaces", workspaces, err)
+	}
+}
+
+func mustFind(t *testing.T, repo WorkspaceRepository, workspace *Workspace) *Workspace {
+	t.Helper()
+
+	found, err := repo.FindById(context.Background(), workspace.Id)
+	if err != nil {
+		t.Fatalf("FindById() error = %v", err)
+	}
+
+	return found
+}